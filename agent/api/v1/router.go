@@ -4,20 +4,31 @@ import (
 	"encoding/json"
 	"fmt"
 	"log"
-	"os"
 	"sync"
+	"time"
 
 	"go_cmdb/agent/config"
 	"go_cmdb/agent/executor"
+	"go_cmdb/agent/store"
 
 	"github.com/gin-gonic/gin"
 )
 
 // TaskExecutor handles task execution
 type TaskExecutor struct {
-	// In-memory map for idempotency (requestId -> result)
-	resultCache       sync.Map
+	// On-disk store for idempotency (requestId -> task state)
+	resultStore       *store.ResultStore
+	mu                sync.Mutex
+	active            map[string]bool // requestIds accepted by this process and not finished
+	queue             chan *queuedTask
+	nginxMu           sync.Mutex
 	applyConfigExec   *executor.ApplyConfigExecutor
+	purgeCacheExec    *executor.PurgeCacheExecutor
+	reloadExec        *executor.ReloadExecutor
+	rollbackExec      *executor.RollbackConfigExecutor
+	validateExec      *executor.ValidateConfigExecutor
+	dirConfig         *config.DirConfig
+	stateReader       *executor.StateReader
 }
 
 // NewTaskExecutor creates a new task executor
@@ -29,15 +40,56 @@ func NewTaskExecutor() *TaskExecutor {
 		log.Fatalf("Failed to ensure directories: %v", err)
 	}
 	
+	// Remove old versions and staging leftovers from crashed runs
+	if _, err := executor.NewVersionGC(dirConfig).RunStartup(); err != nil {
+		log.Printf("[VersionGC] Startup run failed: %v", err)
+	}
+
+	// Create idempotency store
+	resultStore, err := store.NewResultStore(
+		dirConfig.GetResultsDir(),
+		time.Duration(dirConfig.ResultTTLHours)*time.Hour,
+		dirConfig.ResultMaxEntries,
+	)
+	if err != nil {
+		log.Fatalf("Failed to create result store: %v", err)
+	}
+
 	// Create apply_config executor
 	applyConfigExec, err := executor.NewApplyConfigExecutor(dirConfig)
 	if err != nil {
 		log.Fatalf("Failed to create apply_config executor: %v", err)
 	}
+
+	// Create purge_cache executor
+	purgeCacheExec, err := executor.NewPurgeCacheExecutor(dirConfig)
+	if err != nil {
+		log.Fatalf("Failed to create purge_cache executor: %v", err)
+	}
 	
-	return &TaskExecutor{
+	e := &TaskExecutor{
+		resultStore:     resultStore,
+		active:          make(map[string]bool),
+		queue:           make(chan *queuedTask, dirConfig.TaskQueueSize),
 		applyConfigExec: applyConfigExec,
+		purgeCacheExec:  purgeCacheExec,
+		reloadExec:      executor.NewReloadExecutor(dirConfig),
+		rollbackExec:    executor.NewRollbackConfigExecutor(applyConfigExec),
+		validateExec:    executor.NewValidateConfigExecutor(applyConfigExec),
+		dirConfig:       dirConfig,
+		stateReader:     executor.NewStateReader(dirConfig),
 	}
+
+	// Start async task workers
+	workers := dirConfig.TaskWorkers
+	if workers <= 0 {
+		workers = 1
+	}
+	for i := 0; i < workers; i++ {
+		go e.worker()
+	}
+
+	return e
 }
 
 // ExecuteTaskRequest represents a task execution request
@@ -73,25 +125,7 @@ func (e *TaskExecutor) Execute(c *gin.Context) {
 		return
 	}
 
-	// Check idempotency: if requestId already processed, return cached result
-	if cachedResult, ok := e.resultCache.Load(req.RequestID); ok {
-		log.Printf("[IDEMPOTENT] Request %s already processed, returning cached result", req.RequestID)
-		c.JSON(200, cachedResult)
-		return
-	}
-
-	// Execute task based on type
-	var message string
-	var err error
-
-	switch req.Type {
-	case "apply_config":
-		message, err = e.executeApplyConfig(req.RequestID, req.Payload)
-	case "reload":
-		message, err = e.executeReload(req.RequestID, req.Payload)
-	case "purge_cache":
-		message, err = e.executePurgeCache(req.RequestID, req.Payload)
-	default:
+	if !knownTaskTypes[req.Type] {
 		c.JSON(400, gin.H{
 			"code":    2002,
 			"message": fmt.Sprintf("unknown task type: %s", req.Type),
@@ -100,28 +134,42 @@ func (e *TaskExecutor) Execute(c *gin.Context) {
 		return
 	}
 
-	// Build response
-	resp := ExecuteTaskResponse{
-		Code:    0,
-		Message: "success",
-	}
-	resp.Data.RequestID = req.RequestID
-
+	// Check idempotency: if requestId already processed, return stored result
+	state, isNew, err := e.acceptTask(&req)
 	if err != nil {
-		resp.Data.Status = "failed"
-		resp.Data.Message = err.Error()
-		log.Printf("[ERROR] Task %s (%s) failed: %v", req.RequestID, req.Type, err)
-	} else {
-		resp.Data.Status = "success"
-		resp.Data.Message = message
-		log.Printf("[SUCCESS] Task %s (%s) completed: %s", req.RequestID, req.Type, message)
+		c.JSON(500, gin.H{
+			"code":    2003,
+			"message": fmt.Sprintf("failed to accept task: %v", err),
+			"data":    nil,
+		})
+		return
+	}
+	if !isNew {
+		log.Printf("[IDEMPOTENT] Request %s already processed, returning stored result", req.RequestID)
+		c.JSON(200, newExecuteTaskResponse(state))
+		return
 	}
 
-	// Cache result for idempotency
-	e.resultCache.Store(req.RequestID, resp)
+	// Execute task synchronously
+	state = e.runTask(state, req.Payload)
 
 	// Return response
-	c.JSON(200, resp)
+	c.JSON(200, newExecuteTaskResponse(state))
+}
+
+// newExecuteTaskResponse builds the execute response from a task state
+func newExecuteTaskResponse(state *TaskState) ExecuteTaskResponse {
+	resp := ExecuteTaskResponse{
+		Code:    0,
+		Message: "success",
+	}
+	resp.Data.RequestID = state.RequestID
+	resp.Data.Status = state.Status
+	resp.Data.Message = state.Message
+	if state.Status == TaskStatusFailed {
+		resp.Data.Message = state.LastError
+	}
+	return resp
 }
 
 // executeApplyConfig executes apply_config task
@@ -141,44 +189,67 @@ func (e *TaskExecutor) executeApplyConfig(requestID string, payload interface{})
 	return message, nil
 }
 
-// executeReload simulates reload task
-func (e *TaskExecutor) executeReload(requestID string, payload interface{}) (string, error) {
-	// Write to file
-	filename := fmt.Sprintf("/tmp/cmdb_reload_%s.txt", requestID)
-	content := fmt.Sprintf("Reload task executed at %s\n", requestID)
+// executeRollbackConfig executes rollback_config task
+func (e *TaskExecutor) executeRollbackConfig(requestID string, payload interface{}) (string, error) {
+	// Serialize payload to JSON
+	payloadJSON, err := json.Marshal(payload)
+	if err != nil {
+		return "", fmt.Errorf("failed to marshal payload: %w", err)
+	}
 
-	if err := os.WriteFile(filename, []byte(content), 0644); err != nil {
-		return "", fmt.Errorf("failed to write file: %w", err)
+	// Execute rollback_config
+	return e.rollbackExec.Execute(string(payloadJSON))
+}
+
+// executeValidateConfig executes validate_config task
+func (e *TaskExecutor) executeValidateConfig(requestID string, payload interface{}) (string, error) {
+	// Serialize payload to JSON
+	payloadJSON, err := json.Marshal(payload)
+	if err != nil {
+		return "", fmt.Errorf("failed to marshal payload: %w", err)
 	}
 
-	return fmt.Sprintf("Reload completed and logged to %s", filename), nil
+	// Execute validate_config (render to staging, nginx -t, live untouched)
+	return e.validateExec.Execute(string(payloadJSON))
 }
 
-// executePurgeCache simulates purge_cache task
-func (e *TaskExecutor) executePurgeCache(requestID string, payload interface{}) (string, error) {
-	// Write payload to file
-	filename := fmt.Sprintf("/tmp/cmdb_purge_cache_%s.json", requestID)
-	data, err := json.MarshalIndent(payload, "", "  ")
+// executeReload executes reload task
+func (e *TaskExecutor) executeReload(requestID string, payload interface{}) (string, error) {
+	// Serialize payload to JSON
+	payloadJSON, err := json.Marshal(payload)
 	if err != nil {
 		return "", fmt.Errorf("failed to marshal payload: %w", err)
 	}
 
-	if err := os.WriteFile(filename, data, 0644); err != nil {
-		return "", fmt.Errorf("failed to write file: %w", err)
+	// Execute reload (nginx -t, reload, verify new worker generation)
+	return e.reloadExec.Execute(string(payloadJSON))
+}
+
+// executePurgeCache executes purge_cache task
+func (e *TaskExecutor) executePurgeCache(requestID string, payload interface{}) (string, error) {
+	// Serialize payload to JSON
+	payloadJSON, err := json.Marshal(payload)
+	if err != nil {
+		return "", fmt.Errorf("failed to marshal payload: %w", err)
 	}
 
-	return fmt.Sprintf("Cache purged and saved to %s", filename), nil
+	// Execute purge_cache
+	return e.purgeCacheExec.Execute(string(payloadJSON))
 }
 
 // SetupRouter sets up the agent API v1 routes
-func SetupRouter(r *gin.Engine) {
-	executor := NewTaskExecutor()
+func SetupRouter(r *gin.Engine, executor *TaskExecutor) {
 
 	v1 := r.Group("/agent/v1")
 	{
+		v1.GET("/ping", executor.Ping)
+		v1.GET("/state", executor.State)
+
 		tasks := v1.Group("/tasks")
 		{
 			tasks.POST("/execute", executor.Execute)
+			tasks.POST("", executor.Submit)
+			tasks.GET("/:requestId", executor.GetTask)
 		}
 	}
 }
@@ -0,0 +1,79 @@
+package v1
+
+import (
+	"fmt"
+
+	"go_cmdb/agent/executor"
+
+	"github.com/gin-gonic/gin"
+)
+
+// StateDetail represents the response data of GET /agent/v1/state
+type StateDetail struct {
+	executor.AgentState
+	Versions    []int64 `json:"versions"`
+	QueuedTasks int     `json:"queuedTasks"`
+	ActiveTasks int     `json:"activeTasks"`
+}
+
+// Ping handles GET /agent/v1/ping
+// Used by the control plane health worker; returns the agent state summary
+func (e *TaskExecutor) Ping(c *gin.Context) {
+	state, err := e.stateReader.Read()
+	if err != nil {
+		c.JSON(500, gin.H{
+			"code":    2003,
+			"message": fmt.Sprintf("failed to read agent state: %v", err),
+			"data":    nil,
+		})
+		return
+	}
+
+	c.JSON(200, gin.H{
+		"code":    0,
+		"message": "pong",
+		"data":    state,
+	})
+}
+
+// State handles GET /agent/v1/state
+// Returns the agent state with the versions on disk and task queue usage
+func (e *TaskExecutor) State(c *gin.Context) {
+	state, err := e.stateReader.Read()
+	if err != nil {
+		c.JSON(500, gin.H{
+			"code":    2003,
+			"message": fmt.Sprintf("failed to read agent state: %v", err),
+			"data":    nil,
+		})
+		return
+	}
+
+	versions, err := e.dirConfig.ListVersions()
+	if err != nil {
+		c.JSON(500, gin.H{
+			"code":    2003,
+			"message": fmt.Sprintf("failed to list versions: %v", err),
+			"data":    nil,
+		})
+		return
+	}
+	if versions == nil {
+		versions = []int64{}
+	}
+
+	e.mu.Lock()
+	activeTasks := len(e.active)
+	e.mu.Unlock()
+
+	c.JSON(200, gin.H{
+		"code":    0,
+		"message": "success",
+		"data": StateDetail{
+			AgentState:  *state,
+			Versions:    versions,
+			QueuedTasks: len(e.queue),
+			ActiveTasks: activeTasks,
+		},
+	})
+}
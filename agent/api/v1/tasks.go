@@ -0,0 +1,298 @@
+package v1
+
+import (
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Task status constants
+const (
+	TaskStatusPending = "pending"
+	TaskStatusRunning = "running"
+	TaskStatusSuccess = "success"
+	TaskStatusFailed  = "failed"
+)
+
+// knownTaskTypes lists the task types the agent can execute
+var knownTaskTypes = map[string]bool{
+	"apply_config":    true,
+	"rollback_config": true,
+	"reload":          true,
+	"purge_cache":     true,
+	"validate_config": true,
+}
+
+// TaskState represents the state of a task, persisted in the result store
+type TaskState struct {
+	RequestID  string     `json:"requestId"`
+	Type       string     `json:"type"`
+	Status     string     `json:"status"`
+	Message    string     `json:"message,omitempty"`
+	LastError  string     `json:"lastError,omitempty"`
+	CreatedAt  time.Time  `json:"createdAt"`
+	StartedAt  *time.Time `json:"startedAt,omitempty"`
+	FinishedAt *time.Time `json:"finishedAt,omitempty"`
+}
+
+// IsFinished reports whether the task reached a terminal status
+func (s *TaskState) IsFinished() bool {
+	return s.Status == TaskStatusSuccess || s.Status == TaskStatusFailed
+}
+
+// queuedTask is a task waiting for a worker
+type queuedTask struct {
+	state   *TaskState
+	payload interface{}
+}
+
+// TaskStateResponse represents a task state response
+type TaskStateResponse struct {
+	Code    int        `json:"code"`
+	Message string     `json:"message"`
+	Data    *TaskState `json:"data"`
+}
+
+// Submit handles POST /agent/v1/tasks
+// Accepts a task and runs it asynchronously in the worker pool
+func (e *TaskExecutor) Submit(c *gin.Context) {
+	// Parse request body
+	var req ExecuteTaskRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(400, gin.H{
+			"code":    2001,
+			"message": "invalid request body",
+			"data":    nil,
+		})
+		return
+	}
+
+	if !knownTaskTypes[req.Type] {
+		c.JSON(400, gin.H{
+			"code":    2002,
+			"message": fmt.Sprintf("unknown task type: %s", req.Type),
+			"data":    nil,
+		})
+		return
+	}
+
+	// Check idempotency: duplicate requestId returns the existing state
+	state, isNew, err := e.acceptTask(&req)
+	if err != nil {
+		c.JSON(500, gin.H{
+			"code":    2003,
+			"message": fmt.Sprintf("failed to accept task: %v", err),
+			"data":    nil,
+		})
+		return
+	}
+	if !isNew {
+		log.Printf("[IDEMPOTENT] Request %s already accepted (status=%s)", req.RequestID, state.Status)
+		c.JSON(200, TaskStateResponse{Code: 0, Message: "success", Data: state})
+		return
+	}
+
+	// Enqueue (bounded), reject when the queue is full
+	select {
+	case e.queue <- &queuedTask{state: state, payload: req.Payload}:
+	default:
+		e.releaseTask(state.RequestID)
+		c.JSON(503, gin.H{
+			"code":    2004,
+			"message": "task queue is full",
+			"data":    nil,
+		})
+		return
+	}
+
+	log.Printf("[ACCEPTED] Task %s (%s) queued", req.RequestID, req.Type)
+	c.JSON(202, TaskStateResponse{Code: 0, Message: "accepted", Data: state})
+}
+
+// GetTask handles GET /agent/v1/tasks/:requestId
+func (e *TaskExecutor) GetTask(c *gin.Context) {
+	requestID := c.Param("requestId")
+
+	state, err := e.loadTask(requestID)
+	if err != nil {
+		c.JSON(500, gin.H{
+			"code":    2003,
+			"message": fmt.Sprintf("failed to load task: %v", err),
+			"data":    nil,
+		})
+		return
+	}
+	if state == nil {
+		c.JSON(404, gin.H{
+			"code":    2005,
+			"message": "task not found",
+			"data":    nil,
+		})
+		return
+	}
+
+	c.JSON(200, TaskStateResponse{Code: 0, Message: "success", Data: state})
+}
+
+// worker runs queued tasks until the queue is closed
+func (e *TaskExecutor) worker() {
+	for task := range e.queue {
+		e.runTask(task.state, task.payload)
+	}
+}
+
+// acceptTask registers a new task, or returns the existing state of a duplicate requestId.
+// Tasks left unfinished by a previous agent process are accepted again.
+func (e *TaskExecutor) acceptTask(req *ExecuteTaskRequest) (*TaskState, bool, error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	var existing TaskState
+	ok, err := e.resultStore.Get(req.RequestID, &existing)
+	if err != nil {
+		return nil, false, err
+	}
+	if ok && (existing.IsFinished() || e.active[req.RequestID]) {
+		return &existing, false, nil
+	}
+
+	state := &TaskState{
+		RequestID: req.RequestID,
+		Type:      req.Type,
+		Status:    TaskStatusPending,
+		CreatedAt: time.Now(),
+	}
+	if err := e.resultStore.Put(state.RequestID, state); err != nil {
+		return nil, false, err
+	}
+	e.active[state.RequestID] = true
+
+	return state, true, nil
+}
+
+// releaseTask forgets a task that was accepted but never queued
+func (e *TaskExecutor) releaseTask(requestID string) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	delete(e.active, requestID)
+	if err := e.resultStore.Delete(requestID); err != nil {
+		log.Printf("[ERROR] Failed to delete task %s: %v", requestID, err)
+	}
+}
+
+// loadTask loads the state of a task (nil if unknown).
+// Unfinished tasks not owned by this process were interrupted by an agent restart.
+func (e *TaskExecutor) loadTask(requestID string) (*TaskState, error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	var state TaskState
+	ok, err := e.resultStore.Get(requestID, &state)
+	if err != nil || !ok {
+		return nil, err
+	}
+
+	if !state.IsFinished() && !e.active[requestID] {
+		now := time.Now()
+		state.Status = TaskStatusFailed
+		state.LastError = "task interrupted by agent restart"
+		state.FinishedAt = &now
+		if err := e.resultStore.Put(requestID, &state); err != nil {
+			log.Printf("[ERROR] Failed to store task %s: %v", requestID, err)
+		}
+	}
+
+	return &state, nil
+}
+
+// saveTask persists the state of a task
+func (e *TaskExecutor) saveTask(state *TaskState, finished bool) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	if err := e.resultStore.Put(state.RequestID, state); err != nil {
+		log.Printf("[ERROR] Failed to store task %s: %v", state.RequestID, err)
+	}
+	if finished {
+		delete(e.active, state.RequestID)
+	}
+}
+
+// runTask executes an accepted task and records its result
+func (e *TaskExecutor) runTask(state *TaskState, payload interface{}) *TaskState {
+	startedAt := time.Now()
+	state.Status = TaskStatusRunning
+	state.StartedAt = &startedAt
+	e.saveTask(state, false)
+
+	// Tasks that touch nginx config or process run one at a time
+	if state.Type != "purge_cache" {
+		e.nginxMu.Lock()
+	}
+	message, err := e.dispatch(state.RequestID, state.Type, payload)
+	if state.Type != "purge_cache" {
+		e.nginxMu.Unlock()
+	}
+
+	finishedAt := time.Now()
+	state.FinishedAt = &finishedAt
+	if err != nil {
+		state.Status = TaskStatusFailed
+		state.LastError = err.Error()
+		log.Printf("[ERROR] Task %s (%s) failed: %v", state.RequestID, state.Type, err)
+	} else {
+		state.Status = TaskStatusSuccess
+		state.Message = message
+		log.Printf("[SUCCESS] Task %s (%s) completed: %s", state.RequestID, state.Type, message)
+	}
+	e.saveTask(state, true)
+
+	return state
+}
+
+// dispatch executes a task based on its type
+func (e *TaskExecutor) dispatch(requestID, taskType string, payload interface{}) (string, error) {
+	switch taskType {
+	case "apply_config":
+		return e.executeApplyConfig(requestID, payload)
+	case "rollback_config":
+		return e.executeRollbackConfig(requestID, payload)
+	case "reload":
+		return e.executeReload(requestID, payload)
+	case "purge_cache":
+		return e.executePurgeCache(requestID, payload)
+	case "validate_config":
+		return e.executeValidateConfig(requestID, payload)
+	default:
+		return "", fmt.Errorf("unknown task type: %s", taskType)
+	}
+}
+
+// RunTaskSync runs a task outside the HTTP API (e.g. pulled from the control plane).
+// Duplicate requestIds return the stored result; skipped is true while the same requestId is running.
+func (e *TaskExecutor) RunTaskSync(requestID, taskType string, payload interface{}) (string, string, bool) {
+	if !knownTaskTypes[taskType] {
+		return TaskStatusFailed, fmt.Sprintf("unknown task type: %s", taskType), false
+	}
+
+	state, isNew, err := e.acceptTask(&ExecuteTaskRequest{RequestID: requestID, Type: taskType})
+	if err != nil {
+		return TaskStatusFailed, fmt.Sprintf("failed to accept task: %v", err), false
+	}
+	if !isNew {
+		if !state.IsFinished() {
+			return state.Status, "", true
+		}
+		log.Printf("[IDEMPOTENT] Request %s already processed, returning stored result", requestID)
+	} else {
+		state = e.runTask(state, payload)
+	}
+
+	if state.Status == TaskStatusFailed {
+		return state.Status, state.LastError, false
+	}
+	return state.Status, state.Message, false
+}
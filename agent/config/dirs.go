@@ -4,6 +4,8 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"sort"
+	"strconv"
 )
 
 // DirConfig holds directory configuration for Agent
@@ -13,7 +15,26 @@ type DirConfig struct {
 	NginxConf      string // Default: /etc/nginx/nginx.conf
 	NginxTestCmd   string // Default: nginx -t -c /etc/nginx/nginx.conf
 	NginxReloadCmd string // Default: nginx -s reload
+	NginxPidFile   string // Default: /run/nginx.pid
 	CMDBRenderDir  string // Default: /etc/nginx/cmdb
+	CacheDir       string // Default: /var/cache/nginx/cmdb (proxy_cache_path)
+	CacheLevels    string // Default: 1:2 (proxy_cache_path levels)
+	CacheKey       string // Default: $scheme$host$request_uri (proxy_cache_key)
+	CacheZone      string // Default: cmdb_cache (proxy_cache keys_zone)
+	CacheZoneSize  string // Default: 64m (keys_zone shared memory size)
+	CacheMaxSize   string // Default: 10g (proxy_cache_path max_size)
+	CacheInactive  string // Default: 7d (proxy_cache_path inactive)
+	CacheZoneOwned bool   // Default: true (render proxy_cache_path; false when nginx.conf declares the zone)
+	OriginCABundle string // Default: /etc/ssl/certs/ca-certificates.crt (verifies https origins without a custom CA)
+
+	VersionRetainCount int // Default: 10 (keep the newest N versions, 0 = no count limit)
+	VersionRetainDays  int // Default: 0 (keep versions newer than D days, 0 = no age limit)
+
+	ResultTTLHours   int // Default: 24 (task results kept for idempotency)
+	ResultMaxEntries int // Default: 10000 (max stored task results, 0 = no limit)
+
+	TaskWorkers   int // Default: 4 (async task worker pool size)
+	TaskQueueSize int // Default: 100 (async task queue capacity)
 }
 
 // NewDirConfig creates a new directory configuration from environment variables
@@ -28,7 +49,26 @@ func NewDirConfig() *DirConfig {
 		NginxConf:      nginxConf,
 		NginxTestCmd:   nginxTestCmd,
 		NginxReloadCmd: getEnv("NGINX_RELOAD_CMD", "nginx -s reload"),
+		NginxPidFile:   getEnv("NGINX_PID_FILE", "/run/nginx.pid"),
 		CMDBRenderDir:  getEnv("CMDB_RENDER_DIR", "/etc/nginx/cmdb"),
+		CacheDir:       getEnv("NGINX_CACHE_DIR", "/var/cache/nginx/cmdb"),
+		CacheLevels:    getEnv("NGINX_CACHE_LEVELS", "1:2"),
+		CacheKey:       getEnv("NGINX_CACHE_KEY", "$scheme$host$request_uri"),
+		CacheZone:      getEnv("NGINX_CACHE_ZONE", "cmdb_cache"),
+		CacheZoneSize:  getEnv("NGINX_CACHE_ZONE_SIZE", "64m"),
+		CacheMaxSize:   getEnv("NGINX_CACHE_MAX_SIZE", "10g"),
+		CacheInactive:  getEnv("NGINX_CACHE_INACTIVE", "7d"),
+		CacheZoneOwned: getEnvBool("NGINX_CACHE_ZONE_OWNED", true),
+		OriginCABundle: getEnv("ORIGIN_CA_BUNDLE", "/etc/ssl/certs/ca-certificates.crt"),
+
+		VersionRetainCount: getEnvInt("VERSION_RETAIN_COUNT", 10),
+		VersionRetainDays:  getEnvInt("VERSION_RETAIN_DAYS", 0),
+
+		ResultTTLHours:   getEnvInt("RESULT_STORE_TTL_HOURS", 24),
+		ResultMaxEntries: getEnvInt("RESULT_STORE_MAX_ENTRIES", 10000),
+
+		TaskWorkers:   getEnvInt("AGENT_TASK_WORKERS", 4),
+		TaskQueueSize: getEnvInt("AGENT_TASK_QUEUE_SIZE", 100),
 	}
 }
 
@@ -52,9 +92,14 @@ func (c *DirConfig) GetMetaDir() string {
 	return filepath.Join(c.CMDBRenderDir, "meta")
 }
 
+// GetResultsDir returns the task results directory path (idempotency store)
+func (c *DirConfig) GetResultsDir() string {
+	return filepath.Join(c.GetMetaDir(), "results")
+}
+
 // GetStagingDir returns the staging directory path for a specific version
 func (c *DirConfig) GetStagingDir(version int64) string {
-	return filepath.Join(c.CMDBRenderDir, ".staging", fmt.Sprintf("%d", version))
+	return filepath.Join(c.GetStagingRootDir(), fmt.Sprintf("%d", version))
 }
 
 // GetVersionsDir returns the versions directory path
@@ -72,6 +117,49 @@ func (c *DirConfig) GetLiveDir() string {
 	return filepath.Join(c.CMDBRenderDir, "live")
 }
 
+// ListVersions returns all version numbers under the versions directory (ascending)
+func (c *DirConfig) ListVersions() ([]int64, error) {
+	entries, err := os.ReadDir(c.GetVersionsDir())
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to read versions directory: %w", err)
+	}
+
+	versions := make([]int64, 0, len(entries))
+	for _, entry := range entries {
+		if !entry.IsDir() {
+			continue
+		}
+		version, err := strconv.ParseInt(entry.Name(), 10, 64)
+		if err != nil {
+			continue
+		}
+		versions = append(versions, version)
+	}
+
+	sort.Slice(versions, func(i, j int) bool { return versions[i] < versions[j] })
+	return versions, nil
+}
+
+// GetLiveVersion returns the version the live symlink points to (0 if not set)
+func (c *DirConfig) GetLiveVersion() (int64, error) {
+	target, err := os.Readlink(c.GetLiveDir())
+	if err != nil {
+		if os.IsNotExist(err) {
+			return 0, nil
+		}
+		return 0, fmt.Errorf("failed to read live symlink: %w", err)
+	}
+
+	version, err := strconv.ParseInt(filepath.Base(target), 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("live symlink points to unexpected target: %s", target)
+	}
+	return version, nil
+}
+
 // EnsureDirectories creates all required directories
 func (c *DirConfig) EnsureDirectories() error {
 	dirs := []string{
@@ -109,6 +197,11 @@ func (c *DirConfig) EnsureStagingDir(version int64) error {
 	return nil
 }
 
+// GetStagingRootDir returns the staging root directory path
+func (c *DirConfig) GetStagingRootDir() string {
+	return filepath.Join(c.CMDBRenderDir, ".staging")
+}
+
 // CleanStagingDir removes staging directory for a specific version
 func (c *DirConfig) CleanStagingDir(version int64) error {
 	stagingDir := c.GetStagingDir(version)
@@ -151,3 +244,21 @@ func getEnv(key, defaultValue string) string {
 	}
 	return defaultValue
 }
+
+func getEnvInt(key string, defaultValue int) int {
+	if value := os.Getenv(key); value != "" {
+		if n, err := strconv.Atoi(value); err == nil && n >= 0 {
+			return n
+		}
+	}
+	return defaultValue
+}
+
+func getEnvBool(key string, defaultValue bool) bool {
+	if value := os.Getenv(key); value != "" {
+		if b, err := strconv.ParseBool(value); err == nil {
+			return b
+		}
+	}
+	return defaultValue
+}
@@ -3,8 +3,8 @@ package executor
 import (
 	"encoding/json"
 	"fmt"
+	"log"
 	"os"
-	"os/exec"
 	"path/filepath"
 	"time"
 
@@ -20,11 +20,13 @@ type ApplyConfigPayload struct {
 
 // WebsiteConfig represents a website configuration
 type WebsiteConfig struct {
-	WebsiteID int            `json:"websiteId"`
-	Status    string         `json:"status"`
-	Domains   []DomainConfig `json:"domains"`
-	Origin    OriginConfig   `json:"origin"`
-	HTTPS     HTTPSConfig    `json:"https"`
+	WebsiteID   int               `json:"websiteId"`
+	Status      string            `json:"status"`
+	Domains     []DomainConfig    `json:"domains"`
+	Origin      OriginConfig      `json:"origin"`
+	HTTPS       HTTPSConfig       `json:"https"`
+	CacheRuleID int               `json:"cacheRuleId,omitempty"`
+	CacheItems  []CacheItemConfig `json:"cacheItems,omitempty"`
 }
 
 // DomainConfig represents a domain configuration
@@ -40,6 +42,12 @@ type OriginConfig struct {
 	RedirectURL        string          `json:"redirectUrl,omitempty"`
 	RedirectStatusCode int             `json:"redirectStatusCode,omitempty"`
 	UpstreamName       string          `json:"upstreamName,omitempty"`
+	Protocol           string          `json:"protocol,omitempty"`   // http|https, derived from addresses if empty
+	HostHeader         string          `json:"hostHeader,omitempty"` // Host sent to the origin, $host if empty
+	SNI                bool            `json:"sni,omitempty"`        // proxy_ssl_server_name
+	SNIName            string          `json:"sniName,omitempty"`    // proxy_ssl_name, Host header if empty
+	Verify             bool            `json:"verify,omitempty"`     // proxy_ssl_verify
+	CACertPem          string          `json:"caCertPem,omitempty"`  // CA to verify the origin, system bundle if empty
 	Addresses          []AddressConfig `json:"addresses,omitempty"`
 }
 
@@ -67,6 +75,14 @@ type CertificateConfig struct {
 	KeyPem        string `json:"keyPem"`
 }
 
+// CacheItemConfig represents a cache rule item
+type CacheItemConfig struct {
+	MatchType  string `json:"matchType"`
+	MatchValue string `json:"matchValue"`
+	Mode       string `json:"mode"`
+	TTLSeconds int    `json:"ttlSeconds"`
+}
+
 // VersionMeta represents version metadata
 type VersionMeta struct {
 	Version   int64  `json:"version"`
@@ -87,6 +103,7 @@ type ErrorMeta struct {
 type ApplyConfigExecutor struct {
 	dirConfig *config.DirConfig
 	renderer  *render.Renderer
+	gc        *VersionGC
 }
 
 // NewApplyConfigExecutor creates a new apply_config executor
@@ -99,6 +116,7 @@ func NewApplyConfigExecutor(dirConfig *config.DirConfig) (*ApplyConfigExecutor,
 	return &ApplyConfigExecutor{
 		dirConfig: dirConfig,
 		renderer:  renderer,
+		gc:        NewVersionGC(dirConfig),
 	}, nil
 }
 
@@ -110,15 +128,17 @@ func (e *ApplyConfigExecutor) Execute(payloadJSON string) (string, error) {
 		return "", fmt.Errorf("failed to parse payload: %w", err)
 	}
 
-	// Step 1: Check idempotency (version must be greater than applied version)
-	appliedVersion, err := e.readAppliedVersion()
+	// Step 1: Check idempotency (version must be greater than any version applied before).
+	// Rollbacks lower the applied version but not the high-water mark, a redelivered
+	// apply_config of a rolled back version must not bring it back.
+	highWaterVersion, err := e.readHighWaterVersion()
 	if err != nil {
-		return "", fmt.Errorf("failed to read applied version: %w", err)
+		return "", fmt.Errorf("failed to read high-water version: %w", err)
 	}
 
-	if payload.Version <= appliedVersion {
+	if payload.Version <= highWaterVersion {
 		// Idempotent: already applied
-		return fmt.Sprintf("Version %d already applied (current: %d)", payload.Version, appliedVersion), nil
+		return fmt.Sprintf("Version %d already applied (current: %d)", payload.Version, highWaterVersion), nil
 	}
 
 	// Step 2: Create staging directory
@@ -129,34 +149,70 @@ func (e *ApplyConfigExecutor) Execute(payloadJSON string) (string, error) {
 	stagingDir := e.dirConfig.GetStagingDir(payload.Version)
 
 	// Step 3: Render configurations
-	if err := e.renderConfigurations(stagingDir, &payload); err != nil {
+	if err := e.renderConfigurations(stagingDir, e.dirConfig.GetVersionDir(payload.Version), &payload); err != nil {
 		e.writeLastError(payload.Version, err)
 		e.dirConfig.CleanStagingDir(payload.Version)
 		return "", fmt.Errorf("failed to render configurations: %w", err)
 	}
 
-	// Step 4: Execute nginx -t
-	if err := e.nginxTest(stagingDir); err != nil {
+	// Step 4: Move staging to versions directory
+	versionDir := e.dirConfig.GetVersionDir(payload.Version)
+	// Only a leftover of an interrupted apply can exist here: versions up to the
+	// high-water mark were skipped above, so it is never the live version
+	liveVersion, err := e.dirConfig.GetLiveVersion()
+	if err == nil && liveVersion == payload.Version {
+		err = fmt.Errorf("version %d is live, refusing to replace it", payload.Version)
+	}
+	if err != nil {
 		e.writeLastError(payload.Version, err)
 		e.dirConfig.CleanStagingDir(payload.Version)
-		return "", fmt.Errorf("nginx test failed: %w", err)
+		return "", err
+	}
+	if err := os.RemoveAll(versionDir); err != nil {
+		e.writeLastError(payload.Version, err)
+		e.dirConfig.CleanStagingDir(payload.Version)
+		return "", fmt.Errorf("failed to remove existing version directory: %w", err)
 	}
-
-	// Step 5: Move staging to versions directory
-	versionDir := e.dirConfig.GetVersionDir(payload.Version)
 	if err := os.Rename(stagingDir, versionDir); err != nil {
 		e.writeLastError(payload.Version, err)
 		e.dirConfig.CleanStagingDir(payload.Version)
 		return "", fmt.Errorf("failed to move staging to versions: %w", err)
 	}
 
+	// Step 5: Execute nginx -t against the new version before it goes live.
+	// The rendered certificate paths point into the version directory, so it is tested there
+	// and removed on failure: versions/<n> only exist for configs that passed nginx -t.
+	if err := nginxTestDir(e.dirConfig, versionDir); err != nil {
+		e.writeLastError(payload.Version, err)
+		os.RemoveAll(versionDir)
+		return "", fmt.Errorf("nginx test failed: %w", err)
+	}
+
 	// Step 6: Atomically switch live symlink to new version
 	if err := e.dirConfig.AtomicSwitchToVersion(payload.Version); err != nil {
 		e.writeLastError(payload.Version, err)
 		return "", fmt.Errorf("failed to switch live symlink: %w", err)
 	}
 
-	// Step 7: Update metadata
+	// Step 7: Reload nginx, switch back on failure (nginx still runs the previous version).
+	// Metadata is only written once the reload succeeded, so the previous version stays
+	// applied and a retry of this version is not skipped as already applied.
+	if err := nginxReload(e.dirConfig.NginxReloadCmd); err != nil {
+		e.writeLastError(payload.Version, err)
+		// The first apply on a node had nothing live before, its live symlink is removed instead
+		var restoreErr error
+		if liveVersion > 0 {
+			restoreErr = e.dirConfig.AtomicSwitchToVersion(liveVersion)
+		} else {
+			restoreErr = os.Remove(e.dirConfig.GetLiveDir())
+		}
+		if restoreErr != nil {
+			return "", fmt.Errorf("reload failed: %w (restore to version %d failed: %v)", err, liveVersion, restoreErr)
+		}
+		return "", fmt.Errorf("apply of version %d aborted, reload failed: %w", payload.Version, err)
+	}
+
+	// Step 8: Update metadata
 	if err := e.writeAppliedVersion(payload.Version); err != nil {
 		return "", fmt.Errorf("failed to write applied version: %w", err)
 	}
@@ -165,18 +221,36 @@ func (e *ApplyConfigExecutor) Execute(payloadJSON string) (string, error) {
 		return "", fmt.Errorf("failed to write last success version: %w", err)
 	}
 
-	// Step 8: Reload nginx
-	if err := e.nginxReload(); err != nil {
-		// Reload failed, but configuration is already applied
-		// Log error but don't fail the task
-		return fmt.Sprintf("Configuration applied (version %d), but reload failed: %v", payload.Version, err), nil
+	if err := e.writeHighWaterVersion(payload.Version); err != nil {
+		return "", fmt.Errorf("failed to write high-water version: %w", err)
+	}
+
+	// Step 9: Remove old versions and staging leftovers (best effort)
+	if _, err := e.gc.RunAfterApply(payload.Version); err != nil {
+		log.Printf("[VersionGC] Failed after applying version %d: %v", payload.Version, err)
 	}
 
 	return fmt.Sprintf("Configuration applied successfully (version %d)", payload.Version), nil
 }
 
-// renderConfigurations renders all configurations to staging directory
-func (e *ApplyConfigExecutor) renderConfigurations(stagingDir string, payload *ApplyConfigPayload) error {
+// renderConfigurations renders all configurations to staging directory.
+// File paths referenced from the config point to versionDir, where staging is moved before nginx -t.
+func (e *ApplyConfigExecutor) renderConfigurations(stagingDir, versionDir string, payload *ApplyConfigPayload) error {
+	// Declare the cache zone the cache locations refer to
+	if e.dirConfig.CacheZoneOwned {
+		zoneData := &render.CacheZoneData{
+			Path:     e.dirConfig.CacheDir,
+			Levels:   e.dirConfig.CacheLevels,
+			Zone:     e.dirConfig.CacheZone,
+			Size:     e.dirConfig.CacheZoneSize,
+			MaxSize:  e.dirConfig.CacheMaxSize,
+			Inactive: e.dirConfig.CacheInactive,
+		}
+		if err := e.renderer.RenderCacheZone(stagingDir, zoneData); err != nil {
+			return fmt.Errorf("failed to render cache zone: %w", err)
+		}
+	}
+
 	for _, website := range payload.Websites {
 		// Render upstream (only if not redirect mode)
 		if website.Origin.Mode != "redirect" {
@@ -210,6 +284,11 @@ func (e *ApplyConfigExecutor) renderConfigurations(stagingDir string, payload *A
 				RedirectURL:        website.Origin.RedirectURL,
 				RedirectStatusCode: website.Origin.RedirectStatusCode,
 				UpstreamName:       website.Origin.UpstreamName,
+				Protocol:           originProtocol(&website.Origin),
+				HostHeader:         website.Origin.HostHeader,
+				SNI:                website.Origin.SNI,
+				SNIName:            website.Origin.SNIName,
+				Verify:             website.Origin.Verify,
 			},
 			HTTPS: render.HTTPSData{
 				Enabled:       website.HTTPS.Enabled,
@@ -218,6 +297,25 @@ func (e *ApplyConfigExecutor) renderConfigurations(stagingDir string, payload *A
 			},
 		}
 
+		// Build cache locations (redirect mode never caches)
+		if website.Origin.Mode != "redirect" && len(website.CacheItems) > 0 {
+			rules := make([]render.CacheRuleData, 0, len(website.CacheItems))
+			for _, item := range website.CacheItems {
+				rules = append(rules, render.CacheRuleData{
+					MatchType:  item.MatchType,
+					MatchValue: item.MatchValue,
+					Mode:       item.Mode,
+					TTLSeconds: item.TTLSeconds,
+				})
+			}
+
+			cache, err := render.BuildCacheData(e.dirConfig.CacheZone, e.dirConfig.CacheKey, rules)
+			if err != nil {
+				return fmt.Errorf("failed to build cache rules for website %d: %w", website.WebsiteID, err)
+			}
+			serverData.Cache = cache
+		}
+
 		for _, domain := range website.Domains {
 			serverData.Domains = append(serverData.Domains, render.DomainData{
 				Domain:    domain.Domain,
@@ -228,8 +326,8 @@ func (e *ApplyConfigExecutor) renderConfigurations(stagingDir string, payload *A
 		// Set certificate paths if HTTPS is enabled
 		if website.HTTPS.Enabled && website.HTTPS.Certificate != nil {
 			certID := website.HTTPS.Certificate.CertificateID
-			serverData.CertPath = filepath.Join(stagingDir, "certs", fmt.Sprintf("cert_%d.pem", certID))
-			serverData.KeyPath = filepath.Join(stagingDir, "certs", fmt.Sprintf("key_%d.pem", certID))
+			serverData.CertPath = filepath.Join(versionDir, "certs", fmt.Sprintf("cert_%d.pem", certID))
+			serverData.KeyPath = filepath.Join(versionDir, "certs", fmt.Sprintf("key_%d.pem", certID))
 
 			// Write certificate files
 			if err := e.renderer.WriteCertificate(stagingDir, certID, website.HTTPS.Certificate.CertPem, website.HTTPS.Certificate.KeyPem); err != nil {
@@ -237,6 +335,17 @@ func (e *ApplyConfigExecutor) renderConfigurations(stagingDir string, payload *A
 			}
 		}
 
+		// Origin certificate verification (https origins only)
+		if serverData.Origin.Protocol == "https" && website.Origin.Verify {
+			serverData.Origin.TrustedCertPath = e.dirConfig.OriginCABundle
+			if website.Origin.CACertPem != "" {
+				serverData.Origin.TrustedCertPath = filepath.Join(versionDir, "certs", fmt.Sprintf("origin_ca_%d.pem", website.WebsiteID))
+				if err := e.renderer.WriteOriginCA(stagingDir, website.WebsiteID, website.Origin.CACertPem); err != nil {
+					return fmt.Errorf("failed to write origin CA for website %d: %w", website.WebsiteID, err)
+				}
+			}
+		}
+
 		if err := e.renderer.RenderServer(stagingDir, website.WebsiteID, serverData); err != nil {
 			return fmt.Errorf("failed to render server for website %d: %w", website.WebsiteID, err)
 		}
@@ -245,58 +354,18 @@ func (e *ApplyConfigExecutor) renderConfigurations(stagingDir string, payload *A
 	return nil
 }
 
-// nginxTest executes nginx -t to validate configuration
-func (e *ApplyConfigExecutor) nginxTest(stagingDir string) error {
-	// Execute nginx test command
-	cmd := exec.Command("sh", "-c", e.dirConfig.NginxTestCmd)
-	output, err := cmd.CombinedOutput()
-
-	if err != nil {
-		// Extract exit code
-		exitCode := -1
-		if exitErr, ok := err.(*exec.ExitError); ok {
-			exitCode = exitErr.ExitCode()
-		}
-
-		// Truncate stderr to 2KB
-		stderr := string(output)
-		if len(stderr) > 2048 {
-			stderr = stderr[:2048] + "... (truncated)"
-		}
-
-		return &NginxTestError{
-			Cmd:      e.dirConfig.NginxTestCmd,
-			ExitCode: exitCode,
-			Stderr:   stderr,
-		}
+// originProtocol returns the protocol used to reach the origin.
+// The control plane rejects mixed address sets, so the first enabled address decides when it is not set.
+func originProtocol(origin *OriginConfig) string {
+	if origin.Protocol != "" {
+		return origin.Protocol
 	}
-
-	return nil
-}
-
-// NginxTestError represents nginx test error with detailed information
-type NginxTestError struct {
-	Cmd      string
-	ExitCode int
-	Stderr   string
-}
-
-func (e *NginxTestError) Error() string {
-	return fmt.Sprintf("nginx test failed (exit code %d): %s", e.ExitCode, e.Stderr)
-}
-
-
-
-// nginxReload reloads nginx
-func (e *ApplyConfigExecutor) nginxReload() error {
-	cmd := exec.Command("sh", "-c", e.dirConfig.NginxReloadCmd)
-	output, err := cmd.CombinedOutput()
-
-	if err != nil {
-		return fmt.Errorf("nginx reload failed: %w, output: %s", err, string(output))
+	for _, addr := range origin.Addresses {
+		if addr.Enabled && addr.Protocol != "" {
+			return addr.Protocol
+		}
 	}
-
-	return nil
+	return "http"
 }
 
 // readAppliedVersion reads the currently applied version
@@ -342,6 +411,47 @@ func (e *ApplyConfigExecutor) writeAppliedVersion(version int64) error {
 	return nil
 }
 
+// readHighWaterVersion reads the highest version ever applied.
+// Nodes without the file (applied before it existed) fall back to the applied version.
+func (e *ApplyConfigExecutor) readHighWaterVersion() (int64, error) {
+	highWater, err := readVersionMeta(filepath.Join(e.dirConfig.GetMetaDir(), "high_water_version.json"))
+	if err != nil {
+		return 0, err
+	}
+
+	applied, err := e.readAppliedVersion()
+	if err != nil {
+		return 0, fmt.Errorf("failed to read applied version: %w", err)
+	}
+
+	if applied > highWater {
+		return applied, nil
+	}
+	return highWater, nil
+}
+
+// writeHighWaterVersion writes the highest version ever applied
+func (e *ApplyConfigExecutor) writeHighWaterVersion(version int64) error {
+	metaDir := e.dirConfig.GetMetaDir()
+	filePath := filepath.Join(metaDir, "high_water_version.json")
+
+	meta := VersionMeta{
+		Version:   version,
+		AppliedAt: time.Now().Format(time.RFC3339),
+	}
+
+	data, err := json.MarshalIndent(meta, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal high-water version: %w", err)
+	}
+
+	if err := os.WriteFile(filePath, data, 0644); err != nil {
+		return fmt.Errorf("failed to write high-water version file: %w", err)
+	}
+
+	return nil
+}
+
 // writeLastSuccessVersion writes the last success version
 func (e *ApplyConfigExecutor) writeLastSuccessVersion(version int64) error {
 	metaDir := e.dirConfig.GetMetaDir()
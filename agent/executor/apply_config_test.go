@@ -0,0 +1,152 @@
+package executor
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"go_cmdb/agent/config"
+	"go_cmdb/agent/render"
+)
+
+func TestRenderConfigurationsHTTPSOrigin(t *testing.T) {
+	dirConfig := &config.DirConfig{
+		CMDBRenderDir:  t.TempDir(),
+		OriginCABundle: "/etc/ssl/certs/ca-certificates.crt",
+	}
+	if err := dirConfig.EnsureStagingDir(2); err != nil {
+		t.Fatal(err)
+	}
+	e, err := NewApplyConfigExecutor(dirConfig)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	payload := &ApplyConfigPayload{
+		Version: 2,
+		Websites: []WebsiteConfig{
+			{
+				WebsiteID: 1,
+				Domains:   []DomainConfig{{Domain: "a.example.com", IsPrimary: true}},
+				Origin: OriginConfig{
+					Mode:         "group",
+					UpstreamName: "upstream_originset_1",
+					HostHeader:   "origin.example.com",
+					SNI:          true,
+					Verify:       true,
+					CACertPem:    "-----BEGIN CERTIFICATE-----\n",
+					Addresses:    []AddressConfig{{Role: "primary", Protocol: "https", Address: "10.0.0.1:443", Weight: 10, Enabled: true}},
+				},
+			},
+			{
+				WebsiteID: 2,
+				Domains:   []DomainConfig{{Domain: "b.example.com", IsPrimary: true}},
+				Origin: OriginConfig{
+					Mode:         "group",
+					UpstreamName: "upstream_originset_2",
+					Protocol:     "http",
+					Addresses:    []AddressConfig{{Role: "primary", Protocol: "http", Address: "10.0.0.2:80", Weight: 10, Enabled: true}},
+				},
+			},
+		},
+	}
+
+	stagingDir := dirConfig.GetStagingDir(2)
+	versionDir := dirConfig.GetVersionDir(2)
+	if err := e.renderConfigurations(stagingDir, versionDir, payload); err != nil {
+		t.Fatal(err)
+	}
+
+	server := readFile(t, filepath.Join(stagingDir, "servers", "server_site_1.conf"))
+	for _, want := range []string{
+		"proxy_pass https://upstream_originset_1;",
+		"proxy_set_header Host origin.example.com;",
+		"proxy_ssl_server_name on;",
+		"proxy_ssl_name origin.example.com;",
+		"proxy_ssl_verify on;",
+		"proxy_ssl_trusted_certificate " + filepath.Join(versionDir, "certs", "origin_ca_1.pem") + ";",
+	} {
+		if !strings.Contains(server, want) {
+			t.Errorf("server_site_1.conf missing %q:\n%s", want, server)
+		}
+	}
+	if _, err := os.Stat(filepath.Join(stagingDir, "certs", "origin_ca_1.pem")); err != nil {
+		t.Errorf("origin CA not written: %v", err)
+	}
+
+	server = readFile(t, filepath.Join(stagingDir, "servers", "server_site_2.conf"))
+	if !strings.Contains(server, "proxy_pass http://upstream_originset_2;") || !strings.Contains(server, "proxy_set_header Host $host;") {
+		t.Errorf("unexpected http origin config:\n%s", server)
+	}
+	if strings.Contains(server, "proxy_ssl") {
+		t.Errorf("http origin must not have proxy_ssl directives:\n%s", server)
+	}
+}
+
+func readFile(t *testing.T, path string) string {
+	t.Helper()
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(data)
+}
+
+func TestApplyConfigTestsNewVersion(t *testing.T) {
+	tests := []struct {
+		name    string
+		bin     string
+		reload  string
+		wantErr bool
+	}{
+		// nginx -t only passes for the config that includes the new version
+		{name: "new version passes", bin: `sh -c 'grep -q "versions/8/" "$3"' nginx`, reload: "true"},
+		{name: "new version fails", bin: "false", reload: "true", wantErr: true},
+		{name: "reload fails", bin: "true", reload: "false", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rollback, dirConfig := newRollbackTestExecutor(t)
+			e := rollback.apply
+			dirConfig.NginxBin = tt.bin
+			dirConfig.NginxReloadCmd = tt.reload
+			renderer, err := render.NewRenderer(dirConfig)
+			if err != nil {
+				t.Fatal(err)
+			}
+			e.renderer = renderer
+			e.gc = NewVersionGC(dirConfig)
+
+			_, err = e.Execute(`{"version":8,"websites":[]}`)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatal("expected apply to fail")
+				}
+				if live, _ := dirConfig.GetLiveVersion(); live != 7 {
+					t.Errorf("expected live version to stay 7, got %d", live)
+				}
+				if highWater, _ := e.readHighWaterVersion(); highWater != 7 {
+					t.Errorf("expected high-water version to stay 7, got %d", highWater)
+				}
+				var reloadErr *NginxReloadError
+				if tt.reload == "false" && !errors.As(err, &reloadErr) {
+					t.Errorf("expected a *NginxReloadError, got %v", err)
+				}
+				// A version that failed nginx -t is not kept
+				if _, statErr := os.Stat(dirConfig.GetVersionDir(8)); tt.bin == "false" && !os.IsNotExist(statErr) {
+					t.Errorf("version 8 directory must be removed, stat: %v", statErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("apply failed: %v", err)
+			}
+			if live, _ := dirConfig.GetLiveVersion(); live != 8 {
+				t.Errorf("expected live version 8, got %d", live)
+			}
+		})
+	}
+}
@@ -0,0 +1,92 @@
+package executor
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"time"
+
+	"go_cmdb/agent/config"
+)
+
+// NginxTestError represents nginx test error with detailed information
+type NginxTestError struct {
+	Cmd      string
+	ExitCode int
+	Stderr   string
+}
+
+func (e *NginxTestError) Error() string {
+	return fmt.Sprintf("nginx test failed (exit code %d): %s", e.ExitCode, e.Stderr)
+}
+
+// nginxTest runs an nginx -t command line, failures are returned as *NginxTestError
+func nginxTest(command string) error {
+	output, exitCode, err := runShell(command)
+	if err != nil {
+		return &NginxTestError{
+			Cmd:      command,
+			ExitCode: exitCode,
+			Stderr:   truncateOutput(output),
+		}
+	}
+	return nil
+}
+
+// nginxTestDir runs nginx -t with the live includes of nginx.conf pointed at dir,
+// so a rendered version can be tested before live is switched to it
+func nginxTestDir(dirConfig *config.DirConfig, dir string) error {
+	mainConf, err := os.ReadFile(dirConfig.NginxConf)
+	if err != nil {
+		return fmt.Errorf("failed to read %s: %w", dirConfig.NginxConf, err)
+	}
+	stagedConf, err := stagedNginxConf(string(mainConf), dirConfig.GetLiveDir(), dir)
+	if err != nil {
+		return err
+	}
+
+	// Written next to nginx.conf so relative includes (mime.types, ...) still resolve
+	confPath := filepath.Join(filepath.Dir(dirConfig.NginxConf), fmt.Sprintf(".cmdb_test_%d.conf", time.Now().UnixNano()))
+	if err := os.WriteFile(confPath, []byte(stagedConf), 0644); err != nil {
+		return fmt.Errorf("failed to write test config: %w", err)
+	}
+	defer os.Remove(confPath)
+
+	return nginxTest(fmt.Sprintf("%s -t -c %s", dirConfig.NginxBin, confPath))
+}
+
+// nginxReload runs the nginx reload command line, failures are returned as *NginxReloadError
+func nginxReload(command string) error {
+	output, exitCode, err := runShell(command)
+	if err != nil {
+		return &NginxReloadError{
+			Stage:    ReloadStageReload,
+			Cmd:      command,
+			ExitCode: exitCode,
+			Stderr:   truncateOutput(output),
+		}
+	}
+	return nil
+}
+
+// runShell runs a command through sh -c and returns its combined output and exit code
+func runShell(command string) (string, int, error) {
+	output, err := exec.Command("sh", "-c", command).CombinedOutput()
+	if err != nil {
+		exitCode := -1
+		if exitErr, ok := err.(*exec.ExitError); ok {
+			exitCode = exitErr.ExitCode()
+		}
+		return string(output), exitCode, err
+	}
+	return string(output), 0, nil
+}
+
+// truncateOutput truncates command output to 2KB
+func truncateOutput(output string) string {
+	if len(output) > 2048 {
+		return output[:2048] + "... (truncated)"
+	}
+	return output
+}
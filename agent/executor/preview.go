@@ -0,0 +1,60 @@
+package executor
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// RenderedFile is one nginx config file rendered from an apply_config payload
+type RenderedFile struct {
+	Path    string `json:"path"`
+	Content string `json:"content"`
+}
+
+// Preview renders the payload to a temporary directory and returns the upstream and server files.
+// Paths are relative to the version directory. Certificate files are omitted since they hold private keys.
+func (e *ApplyConfigExecutor) Preview(payload *ApplyConfigPayload) ([]RenderedFile, error) {
+	tmpDir, err := os.MkdirTemp("", "cmdb_preview_")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create preview directory: %w", err)
+	}
+	defer os.RemoveAll(tmpDir)
+
+	for _, subdir := range []string{"upstreams", "servers", "certs"} {
+		if err := os.MkdirAll(filepath.Join(tmpDir, subdir), 0755); err != nil {
+			return nil, fmt.Errorf("failed to create preview directory: %w", err)
+		}
+	}
+
+	// Rendered paths reference the version directory the agent would apply
+	if err := e.renderConfigurations(tmpDir, e.dirConfig.GetVersionDir(payload.Version), payload); err != nil {
+		return nil, err
+	}
+
+	files := make([]RenderedFile, 0)
+	for _, subdir := range []string{"upstreams", "servers"} {
+		entries, err := os.ReadDir(filepath.Join(tmpDir, subdir))
+		if err != nil {
+			return nil, fmt.Errorf("failed to read %s: %w", subdir, err)
+		}
+		for _, entry := range entries {
+			if entry.IsDir() || !strings.HasSuffix(entry.Name(), ".conf") {
+				continue
+			}
+			content, err := os.ReadFile(filepath.Join(tmpDir, subdir, entry.Name()))
+			if err != nil {
+				return nil, fmt.Errorf("failed to read %s: %w", entry.Name(), err)
+			}
+			files = append(files, RenderedFile{
+				Path:    subdir + "/" + entry.Name(),
+				Content: string(content),
+			})
+		}
+	}
+
+	sort.Slice(files, func(i, j int) bool { return files[i].Path < files[j].Path })
+	return files, nil
+}
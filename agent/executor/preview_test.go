@@ -0,0 +1,46 @@
+package executor
+
+import (
+	"strings"
+	"testing"
+
+	"go_cmdb/agent/config"
+)
+
+func TestPreview(t *testing.T) {
+	dirConfig := &config.DirConfig{CMDBRenderDir: t.TempDir()}
+	e, err := NewApplyConfigExecutor(dirConfig)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	files, err := e.Preview(&ApplyConfigPayload{
+		Version: 3,
+		Websites: []WebsiteConfig{{
+			WebsiteID: 1,
+			Domains:   []DomainConfig{{Domain: "a.example.com", IsPrimary: true}},
+			Origin: OriginConfig{
+				Mode:         "group",
+				UpstreamName: "upstream_originset_1",
+				Addresses:    []AddressConfig{{Role: "primary", Protocol: "http", Address: "10.0.0.1:80", Weight: 10, Enabled: true}},
+			},
+		}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	paths := make([]string, 0, len(files))
+	for _, f := range files {
+		paths = append(paths, f.Path)
+		if strings.HasPrefix(f.Path, "certs/") {
+			t.Errorf("certificate files must not be previewed: %s", f.Path)
+		}
+	}
+	if len(files) != 2 || files[0].Path != "servers/server_site_1.conf" || !strings.HasPrefix(files[1].Path, "upstreams/") {
+		t.Fatalf("unexpected preview files: %v", paths)
+	}
+	if !strings.Contains(files[0].Content, "proxy_pass http://upstream_originset_1;") {
+		t.Errorf("unexpected server config:\n%s", files[0].Content)
+	}
+}
@@ -0,0 +1,389 @@
+package executor
+
+import (
+	"bytes"
+	"crypto/md5"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"go_cmdb/agent/config"
+)
+
+// Purge types
+const (
+	PurgeTypeURL     = "url"
+	PurgeTypePrefix  = "prefix"
+	PurgeTypeWebsite = "website"
+)
+
+// cacheHeaderReadSize is how much of a cache file is read to find its KEY line
+const cacheHeaderReadSize = 4096
+
+// PurgeCachePayload represents the payload for purge_cache task
+type PurgeCachePayload struct {
+	Type      string   `json:"type"`                // url|prefix|website
+	URLs      []string `json:"urls,omitempty"`      // type=url: exact URLs
+	Prefixes  []string `json:"prefixes,omitempty"`  // type=prefix: URL prefixes
+	WebsiteID int      `json:"websiteId,omitempty"` // type=website
+	Domains   []string `json:"domains,omitempty"`   // type=website: optional, read from live config if empty
+}
+
+// PurgeCacheResult represents the result of a purge_cache task
+type PurgeCacheResult struct {
+	Type    string `json:"type"`
+	Removed int    `json:"removed"`
+	Scanned int    `json:"scanned"`
+}
+
+// PurgeCacheExecutor handles purge_cache task execution
+type PurgeCacheExecutor struct {
+	dirConfig *config.DirConfig
+	levels    []int
+}
+
+// NewPurgeCacheExecutor creates a new purge_cache executor
+func NewPurgeCacheExecutor(dirConfig *config.DirConfig) (*PurgeCacheExecutor, error) {
+	levels, err := parseCacheLevels(dirConfig.CacheLevels)
+	if err != nil {
+		return nil, err
+	}
+
+	return &PurgeCacheExecutor{
+		dirConfig: dirConfig,
+		levels:    levels,
+	}, nil
+}
+
+// Execute executes the purge_cache task
+func (e *PurgeCacheExecutor) Execute(payloadJSON string) (string, error) {
+	var payload PurgeCachePayload
+	if err := json.Unmarshal([]byte(payloadJSON), &payload); err != nil {
+		return "", fmt.Errorf("failed to parse payload: %w", err)
+	}
+
+	result, err := e.Purge(&payload)
+	if err != nil {
+		return "", err
+	}
+
+	return fmt.Sprintf("Purged %d cache entries (type=%s, scanned=%d)", result.Removed, result.Type, result.Scanned), nil
+}
+
+// Purge removes the cache files matched by the payload
+func (e *PurgeCacheExecutor) Purge(payload *PurgeCachePayload) (*PurgeCacheResult, error) {
+	result := &PurgeCacheResult{Type: payload.Type}
+
+	switch payload.Type {
+	case PurgeTypeURL:
+		if len(payload.URLs) == 0 {
+			return nil, fmt.Errorf("urls is required for purge type url")
+		}
+		for _, rawURL := range payload.URLs {
+			keys, err := e.keysForURL(rawURL)
+			if err != nil {
+				return nil, err
+			}
+			for _, key := range keys {
+				removed, err := e.removeKey(key)
+				if err != nil {
+					return nil, err
+				}
+				if removed {
+					result.Removed++
+				}
+			}
+		}
+
+	case PurgeTypePrefix:
+		if len(payload.Prefixes) == 0 {
+			return nil, fmt.Errorf("prefixes is required for purge type prefix")
+		}
+		var keyPrefixes []string
+		for _, rawPrefix := range payload.Prefixes {
+			prefixes, err := e.keyPrefixesForURL(rawPrefix)
+			if err != nil {
+				return nil, err
+			}
+			keyPrefixes = append(keyPrefixes, prefixes...)
+		}
+		if err := e.scanAndRemove(keyPrefixes, result); err != nil {
+			return nil, err
+		}
+
+	case PurgeTypeWebsite:
+		domains := payload.Domains
+		if len(domains) == 0 {
+			if payload.WebsiteID <= 0 {
+				return nil, fmt.Errorf("websiteId or domains is required for purge type website")
+			}
+			liveDomains, err := e.readLiveDomains(payload.WebsiteID)
+			if err != nil {
+				return nil, err
+			}
+			domains = liveDomains
+		}
+		var keyPrefixes []string
+		for _, domain := range domains {
+			for _, scheme := range []string{"http", "https"} {
+				prefix, err := e.keyPrefix(scheme, domain, "/")
+				if err != nil {
+					return nil, err
+				}
+				keyPrefixes = append(keyPrefixes, prefix)
+			}
+		}
+		if err := e.scanAndRemove(keyPrefixes, result); err != nil {
+			return nil, err
+		}
+
+	default:
+		return nil, fmt.Errorf("unknown purge type: %s", payload.Type)
+	}
+
+	return result, nil
+}
+
+// keysForURL returns the cache keys of an exact URL (both schemes if none is given)
+func (e *PurgeCacheExecutor) keysForURL(rawURL string) ([]string, error) {
+	schemes, host, requestURI, err := splitPurgeURL(rawURL)
+	if err != nil {
+		return nil, err
+	}
+
+	keys := make([]string, 0, len(schemes))
+	for _, scheme := range schemes {
+		keys = append(keys, expandCacheKey(e.dirConfig.CacheKey, scheme, host, requestURI))
+	}
+	return keys, nil
+}
+
+// keyPrefixesForURL returns the cache key prefixes of a URL prefix
+func (e *PurgeCacheExecutor) keyPrefixesForURL(rawURL string) ([]string, error) {
+	schemes, host, requestURI, err := splitPurgeURL(rawURL)
+	if err != nil {
+		return nil, err
+	}
+
+	prefixes := make([]string, 0, len(schemes))
+	for _, scheme := range schemes {
+		prefix, err := e.keyPrefix(scheme, host, requestURI)
+		if err != nil {
+			return nil, err
+		}
+		prefixes = append(prefixes, prefix)
+	}
+	return prefixes, nil
+}
+
+// keyPrefix expands the cache key with a partial request URI.
+// Only meaningful when the key template ends with the request URI.
+func (e *PurgeCacheExecutor) keyPrefix(scheme, host, requestURI string) (string, error) {
+	tmpl := e.dirConfig.CacheKey
+	if !strings.HasSuffix(tmpl, "$request_uri") && !strings.HasSuffix(tmpl, "$uri") {
+		return "", fmt.Errorf("prefix purge requires cache key ending with $request_uri or $uri (current: %s)", tmpl)
+	}
+	return expandCacheKey(tmpl, scheme, host, requestURI), nil
+}
+
+// removeKey removes the cache file of a single key
+func (e *PurgeCacheExecutor) removeKey(key string) (bool, error) {
+	path := CacheFilePath(e.dirConfig.CacheDir, e.levels, key)
+	if err := os.Remove(path); err != nil {
+		if os.IsNotExist(err) {
+			return false, nil
+		}
+		return false, fmt.Errorf("failed to remove cache file %s: %w", path, err)
+	}
+	return true, nil
+}
+
+// scanAndRemove walks the cache directory and removes files whose key matches a prefix
+func (e *PurgeCacheExecutor) scanAndRemove(keyPrefixes []string, result *PurgeCacheResult) error {
+	if len(keyPrefixes) == 0 {
+		return nil
+	}
+
+	err := filepath.WalkDir(e.dirConfig.CacheDir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			if os.IsNotExist(err) {
+				return nil
+			}
+			return err
+		}
+		if !d.Type().IsRegular() {
+			return nil
+		}
+
+		result.Scanned++
+
+		key, ok := readCacheKey(path)
+		if !ok {
+			return nil
+		}
+
+		for _, prefix := range keyPrefixes {
+			if strings.HasPrefix(key, prefix) {
+				if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+					return fmt.Errorf("failed to remove cache file %s: %w", path, err)
+				}
+				result.Removed++
+				break
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("failed to scan cache directory: %w", err)
+	}
+
+	return nil
+}
+
+// serverNamePattern matches the server_name directive in a rendered server file
+var serverNamePattern = regexp.MustCompile(`(?m)^\s*server_name\s+([^;]+);`)
+
+// readLiveDomains reads the domains of a website from the live server config
+func (e *PurgeCacheExecutor) readLiveDomains(websiteID int) ([]string, error) {
+	path := filepath.Join(e.dirConfig.GetLiveDir(), "servers", fmt.Sprintf("server_site_%d.conf", websiteID))
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read live server config for website %d: %w", websiteID, err)
+	}
+
+	seen := make(map[string]bool)
+	var domains []string
+	for _, match := range serverNamePattern.FindAllStringSubmatch(string(data), -1) {
+		for _, domain := range strings.Fields(match[1]) {
+			if !seen[domain] {
+				seen[domain] = true
+				domains = append(domains, domain)
+			}
+		}
+	}
+
+	if len(domains) == 0 {
+		return nil, fmt.Errorf("no server_name found in live config for website %d", websiteID)
+	}
+	return domains, nil
+}
+
+// CacheFilePath returns the proxy_cache file path of a cache key
+// (md5 of the key, split into directories by the levels from the end of the hash)
+func CacheFilePath(cacheDir string, levels []int, key string) string {
+	sum := md5.Sum([]byte(key))
+	hash := hex.EncodeToString(sum[:])
+
+	parts := []string{cacheDir}
+	end := len(hash)
+	for _, level := range levels {
+		parts = append(parts, hash[end-level:end])
+		end -= level
+	}
+	parts = append(parts, hash)
+
+	return filepath.Join(parts...)
+}
+
+// parseCacheLevels parses proxy_cache_path levels (e.g. "1:2")
+func parseCacheLevels(levels string) ([]int, error) {
+	if levels == "" {
+		return nil, nil
+	}
+
+	parts := strings.Split(levels, ":")
+	if len(parts) > 3 {
+		return nil, fmt.Errorf("invalid cache levels %q: at most 3 levels", levels)
+	}
+
+	result := make([]int, 0, len(parts))
+	for _, part := range parts {
+		n, err := strconv.Atoi(part)
+		if err != nil || n < 1 || n > 2 {
+			return nil, fmt.Errorf("invalid cache levels %q: each level must be 1 or 2", levels)
+		}
+		result = append(result, n)
+	}
+	return result, nil
+}
+
+// expandCacheKey expands the nginx variables used in proxy_cache_key
+func expandCacheKey(tmpl, scheme, host, requestURI string) string {
+	uri, args, hasArgs := strings.Cut(requestURI, "?")
+	isArgs := ""
+	if hasArgs {
+		isArgs = "?"
+	}
+
+	return strings.NewReplacer(
+		"$scheme", scheme,
+		"$host", host,
+		"$request_uri", requestURI,
+		"$uri", uri,
+		"$is_args", isArgs,
+		"$args", args,
+	).Replace(tmpl)
+}
+
+// splitPurgeURL splits a purge URL into schemes, host and request URI.
+// URLs without a scheme match both http and https.
+func splitPurgeURL(rawURL string) ([]string, string, string, error) {
+	schemes := []string{"http", "https"}
+	if !strings.Contains(rawURL, "://") {
+		rawURL = "http://" + rawURL
+	} else {
+		schemes = nil
+	}
+
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, "", "", fmt.Errorf("invalid url %q: %w", rawURL, err)
+	}
+	if u.Host == "" {
+		return nil, "", "", fmt.Errorf("invalid url %q: missing host", rawURL)
+	}
+	if schemes == nil {
+		if u.Scheme != "http" && u.Scheme != "https" {
+			return nil, "", "", fmt.Errorf("invalid url %q: scheme must be http or https", rawURL)
+		}
+		schemes = []string{u.Scheme}
+	}
+
+	requestURI := u.RequestURI()
+	return schemes, strings.ToLower(u.Hostname()), requestURI, nil
+}
+
+// readCacheKey reads the KEY line from the header of a cache file
+func readCacheKey(path string) (string, bool) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", false
+	}
+	defer f.Close()
+
+	buf := make([]byte, cacheHeaderReadSize)
+	n, err := io.ReadFull(f, buf)
+	if err != nil && err != io.ErrUnexpectedEOF {
+		return "", false
+	}
+	buf = buf[:n]
+
+	idx := bytes.Index(buf, []byte("\nKEY: "))
+	if idx < 0 {
+		return "", false
+	}
+	rest := buf[idx+len("\nKEY: "):]
+	end := bytes.IndexByte(rest, '\n')
+	if end < 0 {
+		return "", false
+	}
+	return string(rest[:end]), true
+}
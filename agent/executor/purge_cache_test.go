@@ -0,0 +1,146 @@
+package executor
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"go_cmdb/agent/config"
+)
+
+func TestCacheFilePath(t *testing.T) {
+	// levels=1:2 takes the last char, then the two chars before it
+	path := CacheFilePath("/cache", []int{1, 2}, "httpexample.com/index.html")
+
+	hash := filepath.Base(path)
+	if len(hash) != 32 {
+		t.Fatalf("expected md5 file name, got %s", hash)
+	}
+
+	expected := filepath.Join("/cache", hash[31:], hash[29:31], hash)
+	if path != expected {
+		t.Errorf("expected %s, got %s", expected, path)
+	}
+}
+
+func TestExpandCacheKey(t *testing.T) {
+	tests := []struct {
+		name       string
+		tmpl       string
+		requestURI string
+		expected   string
+	}{
+		{
+			name:       "default key",
+			tmpl:       "$scheme$host$request_uri",
+			requestURI: "/a/b.js?v=1",
+			expected:   "httpsexample.com/a/b.js?v=1",
+		},
+		{
+			name:       "uri and args",
+			tmpl:       "$host$uri$is_args$args",
+			requestURI: "/a/b.js?v=1",
+			expected:   "example.com/a/b.js?v=1",
+		},
+		{
+			name:       "uri without args",
+			tmpl:       "$host$uri$is_args$args",
+			requestURI: "/a/b.js",
+			expected:   "example.com/a/b.js",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := expandCacheKey(tt.tmpl, "https", "example.com", tt.requestURI)
+			if got != tt.expected {
+				t.Errorf("expected %s, got %s", tt.expected, got)
+			}
+		})
+	}
+}
+
+func TestPurge(t *testing.T) {
+	cacheDir := t.TempDir()
+	dirConfig := &config.DirConfig{
+		CMDBRenderDir: t.TempDir(),
+		CacheDir:      cacheDir,
+		CacheLevels:   "1:2",
+		CacheKey:      "$scheme$host$request_uri",
+	}
+
+	exec, err := NewPurgeCacheExecutor(dirConfig)
+	if err != nil {
+		t.Fatalf("NewPurgeCacheExecutor() failed: %v", err)
+	}
+
+	keys := []string{
+		"httpsexample.com/index.html",
+		"httpsexample.com/static/a.js",
+		"httpexample.com/static/b.css",
+		"httpsother.com/static/a.js",
+	}
+	for _, key := range keys {
+		writeCacheFile(t, cacheDir, exec.levels, key)
+	}
+
+	// Exact URL with scheme only removes that scheme
+	result, err := exec.Purge(&PurgeCachePayload{Type: PurgeTypeURL, URLs: []string{"https://example.com/index.html"}})
+	if err != nil {
+		t.Fatalf("url purge failed: %v", err)
+	}
+	if result.Removed != 1 {
+		t.Errorf("url purge: expected 1 removed, got %d", result.Removed)
+	}
+
+	// Prefix without scheme matches both schemes
+	result, err = exec.Purge(&PurgeCachePayload{Type: PurgeTypePrefix, Prefixes: []string{"example.com/static/"}})
+	if err != nil {
+		t.Fatalf("prefix purge failed: %v", err)
+	}
+	if result.Removed != 2 {
+		t.Errorf("prefix purge: expected 2 removed, got %d", result.Removed)
+	}
+
+	// Website purge removes every entry of its domains
+	result, err = exec.Purge(&PurgeCachePayload{Type: PurgeTypeWebsite, Domains: []string{"other.com"}})
+	if err != nil {
+		t.Fatalf("website purge failed: %v", err)
+	}
+	if result.Removed != 1 {
+		t.Errorf("website purge: expected 1 removed, got %d", result.Removed)
+	}
+
+	for _, key := range keys {
+		if _, err := os.Stat(CacheFilePath(cacheDir, exec.levels, key)); !os.IsNotExist(err) {
+			t.Errorf("cache file for %s still exists", key)
+		}
+	}
+}
+
+func TestParseCacheLevels(t *testing.T) {
+	if _, err := parseCacheLevels("1:2"); err != nil {
+		t.Errorf("1:2 should be valid: %v", err)
+	}
+	if _, err := parseCacheLevels("3"); err == nil {
+		t.Error("3 should be invalid")
+	}
+	if _, err := parseCacheLevels("1:2:2:1"); err == nil {
+		t.Error("four levels should be invalid")
+	}
+}
+
+// writeCacheFile writes a minimal nginx cache file with a KEY header line
+func writeCacheFile(t *testing.T, cacheDir string, levels []int, key string) {
+	t.Helper()
+
+	path := CacheFilePath(cacheDir, levels, key)
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		t.Fatalf("failed to create cache dir: %v", err)
+	}
+
+	content := "\x05\x00\x00\x00binary-header\nKEY: " + key + "\nHTTP/1.1 200 OK\r\n\r\nbody"
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatalf("failed to write cache file: %v", err)
+	}
+}
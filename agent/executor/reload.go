@@ -0,0 +1,229 @@
+package executor
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"go_cmdb/agent/config"
+)
+
+// Reload stages
+const (
+	ReloadStageTest   = "test"
+	ReloadStageReload = "reload"
+	ReloadStageVerify = "verify"
+)
+
+// defaultReloadTimeout is how long to wait for the new worker generation
+const defaultReloadTimeout = 10 * time.Second
+
+// ReloadPayload represents the payload for reload task
+type ReloadPayload struct {
+	TimeoutSec int `json:"timeoutSec,omitempty"` // Wait for new workers, default 10s
+}
+
+// ReloadResult represents a successful reload
+type ReloadResult struct {
+	MasterPID     int   `json:"masterPid"`
+	OldWorkerPIDs []int `json:"oldWorkerPids"`
+	NewWorkerPIDs []int `json:"newWorkerPids"`
+}
+
+// NginxReloadError represents reload error with detailed information
+type NginxReloadError struct {
+	Stage           string // test|reload|verify
+	Cmd             string
+	ExitCode        int
+	Stderr          string
+	MasterPIDBefore int
+	MasterPIDAfter  int
+}
+
+func (e *NginxReloadError) Error() string {
+	switch e.Stage {
+	case ReloadStageVerify:
+		return fmt.Sprintf("nginx reload not confirmed (master pid %d -> %d): %s", e.MasterPIDBefore, e.MasterPIDAfter, e.Stderr)
+	default:
+		return fmt.Sprintf("nginx %s failed (exit code %d): %s", e.Stage, e.ExitCode, e.Stderr)
+	}
+}
+
+// ReloadExecutor handles reload task execution
+type ReloadExecutor struct {
+	dirConfig *config.DirConfig
+	procDir   string
+}
+
+// NewReloadExecutor creates a new reload executor
+func NewReloadExecutor(dirConfig *config.DirConfig) *ReloadExecutor {
+	return &ReloadExecutor{
+		dirConfig: dirConfig,
+		procDir:   "/proc",
+	}
+}
+
+// Execute executes the reload task
+func (e *ReloadExecutor) Execute(payloadJSON string) (string, error) {
+	var payload ReloadPayload
+	if payloadJSON != "" && payloadJSON != "null" {
+		if err := json.Unmarshal([]byte(payloadJSON), &payload); err != nil {
+			return "", fmt.Errorf("failed to parse payload: %w", err)
+		}
+	}
+
+	timeout := defaultReloadTimeout
+	if payload.TimeoutSec > 0 {
+		timeout = time.Duration(payload.TimeoutSec) * time.Second
+	}
+
+	result, err := e.Reload(timeout)
+	if err != nil {
+		return "", err
+	}
+
+	return fmt.Sprintf("Nginx reloaded (master pid %d, workers %v -> %v)", result.MasterPID, result.OldWorkerPIDs, result.NewWorkerPIDs), nil
+}
+
+// Reload runs nginx -t, reloads nginx and waits until a new worker generation is running
+func (e *ReloadExecutor) Reload(timeout time.Duration) (*ReloadResult, error) {
+	// Step 1: nginx -t
+	if err := nginxTest(e.dirConfig.NginxTestCmd); err != nil {
+		testErr := err.(*NginxTestError)
+		return nil, &NginxReloadError{
+			Stage:    ReloadStageTest,
+			Cmd:      testErr.Cmd,
+			ExitCode: testErr.ExitCode,
+			Stderr:   testErr.Stderr,
+		}
+	}
+
+	// Step 2: snapshot master and workers
+	masterBefore, err := e.readMasterPID()
+	if err != nil {
+		return nil, &NginxReloadError{
+			Stage:  ReloadStageVerify,
+			Stderr: err.Error(),
+		}
+	}
+	workersBefore := e.listChildren(masterBefore)
+
+	// Step 3: reload
+	if err := nginxReload(e.dirConfig.NginxReloadCmd); err != nil {
+		reloadErr := err.(*NginxReloadError)
+		reloadErr.MasterPIDBefore = masterBefore
+		return nil, reloadErr
+	}
+
+	// Step 4: same master, new worker generation
+	deadline := time.Now().Add(timeout)
+	for {
+		masterAfter, err := e.readMasterPID()
+		if err == nil && masterAfter != masterBefore {
+			return nil, &NginxReloadError{
+				Stage:           ReloadStageVerify,
+				Stderr:          "nginx master pid changed, nginx was restarted instead of reloaded",
+				MasterPIDBefore: masterBefore,
+				MasterPIDAfter:  masterAfter,
+			}
+		}
+
+		if err == nil {
+			workersAfter := e.listChildren(masterAfter)
+			if hasNewPID(workersBefore, workersAfter) {
+				return &ReloadResult{
+					MasterPID:     masterAfter,
+					OldWorkerPIDs: workersBefore,
+					NewWorkerPIDs: workersAfter,
+				}, nil
+			}
+		}
+
+		if time.Now().After(deadline) {
+			stderr := "no new worker generation after reload"
+			if err != nil {
+				stderr = err.Error()
+			}
+			return nil, &NginxReloadError{
+				Stage:           ReloadStageVerify,
+				Stderr:          stderr,
+				MasterPIDBefore: masterBefore,
+				MasterPIDAfter:  masterAfter,
+			}
+		}
+
+		time.Sleep(200 * time.Millisecond)
+	}
+}
+
+// readMasterPID reads the nginx master pid from the pid file
+func (e *ReloadExecutor) readMasterPID() (int, error) {
+	data, err := os.ReadFile(e.dirConfig.NginxPidFile)
+	if err != nil {
+		return 0, fmt.Errorf("failed to read nginx pid file: %w", err)
+	}
+
+	pid, err := strconv.Atoi(strings.TrimSpace(string(data)))
+	if err != nil || pid <= 0 {
+		return 0, fmt.Errorf("invalid nginx pid file content: %q", strings.TrimSpace(string(data)))
+	}
+
+	return pid, nil
+}
+
+// listChildren lists the pids whose parent is ppid (sorted)
+func (e *ReloadExecutor) listChildren(ppid int) []int {
+	entries, err := os.ReadDir(e.procDir)
+	if err != nil {
+		return nil
+	}
+
+	children := make([]int, 0)
+	for _, entry := range entries {
+		pid, err := strconv.Atoi(entry.Name())
+		if err != nil {
+			continue
+		}
+
+		data, err := os.ReadFile(filepath.Join(e.procDir, entry.Name(), "stat"))
+		if err != nil {
+			continue
+		}
+
+		// Format: pid (comm) state ppid ...; comm may contain spaces
+		stat := string(data)
+		idx := strings.LastIndexByte(stat, ')')
+		if idx < 0 {
+			continue
+		}
+		fields := strings.Fields(stat[idx+1:])
+		if len(fields) < 2 {
+			continue
+		}
+		if parent, err := strconv.Atoi(fields[1]); err == nil && parent == ppid {
+			children = append(children, pid)
+		}
+	}
+
+	sort.Ints(children)
+	return children
+}
+
+// hasNewPID reports whether after contains a pid that is not in before
+func hasNewPID(before, after []int) bool {
+	seen := make(map[int]bool, len(before))
+	for _, pid := range before {
+		seen[pid] = true
+	}
+	for _, pid := range after {
+		if !seen[pid] {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,41 @@
+package executor
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestListChildren(t *testing.T) {
+	procDir := t.TempDir()
+	stats := map[string]string{
+		"100": "100 (nginx) S 1 100 100 0 -1",
+		"101": "101 (nginx: worker) S 100 100 100 0 -1",
+		"102": "102 (nginx: worker) S 100 100 100 0 -1",
+		"200": "200 (bash) S 1 200 200 0 -1",
+	}
+	for pid, stat := range stats {
+		if err := os.MkdirAll(filepath.Join(procDir, pid), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(filepath.Join(procDir, pid, "stat"), []byte(stat), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	e := &ReloadExecutor{procDir: procDir}
+	got := e.listChildren(100)
+	if !reflect.DeepEqual(got, []int{101, 102}) {
+		t.Errorf("expected [101 102], got %v", got)
+	}
+}
+
+func TestHasNewPID(t *testing.T) {
+	if hasNewPID([]int{101, 102}, []int{101, 102}) {
+		t.Error("same workers should not count as a new generation")
+	}
+	if !hasNewPID([]int{101, 102}, []int{103, 104}) {
+		t.Error("new workers should count as a new generation")
+	}
+}
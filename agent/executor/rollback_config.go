@@ -0,0 +1,125 @@
+package executor
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"go_cmdb/agent/config"
+)
+
+// RollbackConfigPayload represents the payload for rollback_config task
+type RollbackConfigPayload struct {
+	Version     int64 `json:"version,omitempty"`     // Target version, 0 = previous successful version
+	FromVersion int64 `json:"fromVersion,omitempty"` // Roll back from this version (default: live version)
+	ReleaseID   int64 `json:"releaseId,omitempty"`   // Release that requested the rollback (informational)
+}
+
+// RollbackConfigExecutor handles rollback_config task execution
+type RollbackConfigExecutor struct {
+	dirConfig *config.DirConfig
+	apply     *ApplyConfigExecutor
+}
+
+// NewRollbackConfigExecutor creates a new rollback_config executor.
+// It shares metadata and nginx helpers with the apply_config executor.
+func NewRollbackConfigExecutor(applyExec *ApplyConfigExecutor) *RollbackConfigExecutor {
+	return &RollbackConfigExecutor{
+		dirConfig: applyExec.dirConfig,
+		apply:     applyExec,
+	}
+}
+
+// Execute executes the rollback_config task
+func (e *RollbackConfigExecutor) Execute(payloadJSON string) (string, error) {
+	var payload RollbackConfigPayload
+	if payloadJSON != "" && payloadJSON != "null" {
+		if err := json.Unmarshal([]byte(payloadJSON), &payload); err != nil {
+			return "", fmt.Errorf("failed to parse payload: %w", err)
+		}
+	}
+
+	// Step 1: Resolve current live version
+	liveVersion, err := e.dirConfig.GetLiveVersion()
+	if err != nil {
+		return "", err
+	}
+
+	// Step 2: Resolve target version
+	target, err := e.resolveTargetVersion(&payload, liveVersion)
+	if err != nil {
+		return "", err
+	}
+
+	if target == liveVersion {
+		// Idempotent: already on target version
+		return fmt.Sprintf("Version %d already live", target), nil
+	}
+
+	// Step 3: Execute nginx -t against the target version before it goes live
+	if err := nginxTestDir(e.dirConfig, e.dirConfig.GetVersionDir(target)); err != nil {
+		e.apply.writeLastError(target, err)
+		return "", fmt.Errorf("nginx test failed: %w", err)
+	}
+
+	// Step 4: Switch live symlink to target version
+	if err := e.dirConfig.AtomicSwitchToVersion(target); err != nil {
+		e.apply.writeLastError(target, err)
+		return "", fmt.Errorf("failed to switch live symlink: %w", err)
+	}
+
+	// Step 5: Reload nginx, switch back on failure (nginx still runs the previous version)
+	if err := nginxReload(e.dirConfig.NginxReloadCmd); err != nil {
+		e.apply.writeLastError(target, err)
+		if liveVersion > 0 {
+			if restoreErr := e.dirConfig.AtomicSwitchToVersion(liveVersion); restoreErr != nil {
+				return "", fmt.Errorf("reload failed: %w (restore to version %d failed: %v)", err, liveVersion, restoreErr)
+			}
+		}
+		return "", fmt.Errorf("rollback to version %d aborted, reload failed: %w", target, err)
+	}
+
+	// Step 6: Update metadata.
+	// The high-water mark is left alone, so apply_config keeps skipping the rolled back versions.
+	if err := e.apply.writeAppliedVersion(target); err != nil {
+		return "", fmt.Errorf("failed to write applied version: %w", err)
+	}
+
+	if err := e.apply.writeLastSuccessVersion(target); err != nil {
+		return "", fmt.Errorf("failed to write last success version: %w", err)
+	}
+
+	return fmt.Sprintf("Configuration rolled back from version %d to version %d", liveVersion, target), nil
+}
+
+// resolveTargetVersion returns the explicit target version, or the newest
+// version below the rollback source (versions/<n> only exist for configs that passed nginx -t)
+func (e *RollbackConfigExecutor) resolveTargetVersion(payload *RollbackConfigPayload, liveVersion int64) (int64, error) {
+	versions, err := e.dirConfig.ListVersions()
+	if err != nil {
+		return 0, err
+	}
+
+	if payload.Version > 0 {
+		for _, version := range versions {
+			if version == payload.Version {
+				return version, nil
+			}
+		}
+		return 0, fmt.Errorf("version %d not found on this node", payload.Version)
+	}
+
+	from := payload.FromVersion
+	if from <= 0 {
+		from = liveVersion
+	}
+	if from <= 0 {
+		return 0, fmt.Errorf("no live version to roll back from")
+	}
+
+	for i := len(versions) - 1; i >= 0; i-- {
+		if versions[i] < from {
+			return versions[i], nil
+		}
+	}
+	return 0, fmt.Errorf("no previous version before %d on this node", from)
+}
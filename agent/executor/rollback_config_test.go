@@ -0,0 +1,139 @@
+package executor
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"go_cmdb/agent/config"
+)
+
+func TestResolveTargetVersion(t *testing.T) {
+	dirConfig := &config.DirConfig{CMDBRenderDir: t.TempDir()}
+	for _, version := range []int64{3, 5, 7} {
+		if err := os.MkdirAll(dirConfig.GetVersionDir(version), 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	e := NewRollbackConfigExecutor(&ApplyConfigExecutor{dirConfig: dirConfig})
+
+	tests := []struct {
+		name     string
+		payload  RollbackConfigPayload
+		live     int64
+		expected int64
+		wantErr  bool
+	}{
+		{name: "previous of live", live: 7, expected: 5},
+		{name: "previous of release version", payload: RollbackConfigPayload{FromVersion: 5}, live: 7, expected: 3},
+		{name: "explicit version", payload: RollbackConfigPayload{Version: 3}, live: 7, expected: 3},
+		{name: "missing explicit version", payload: RollbackConfigPayload{Version: 4}, live: 7, wantErr: true},
+		{name: "no previous version", live: 3, wantErr: true},
+		{name: "nothing live", live: 0, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := e.resolveTargetVersion(&tt.payload, tt.live)
+			if tt.wantErr {
+				if err == nil {
+					t.Errorf("expected error, got version %d", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if got != tt.expected {
+				t.Errorf("expected version %d, got %d", tt.expected, got)
+			}
+		})
+	}
+}
+
+func newRollbackTestExecutor(t *testing.T) (*RollbackConfigExecutor, *config.DirConfig) {
+	root := t.TempDir()
+	dirConfig := &config.DirConfig{
+		CMDBRenderDir:  filepath.Join(root, "cmdb"),
+		NginxConf:      filepath.Join(root, "nginx.conf"),
+		NginxBin:       "true",
+		NginxReloadCmd: "true",
+	}
+	if err := os.WriteFile(dirConfig.NginxConf, []byte("include "+dirConfig.GetLiveDir()+"/servers/*.conf;\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := dirConfig.EnsureDirectories(); err != nil {
+		t.Fatal(err)
+	}
+	for _, version := range []int64{5, 7} {
+		if err := os.MkdirAll(dirConfig.GetVersionDir(version), 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	applyExec := &ApplyConfigExecutor{dirConfig: dirConfig}
+	if err := dirConfig.AtomicSwitchToVersion(7); err != nil {
+		t.Fatal(err)
+	}
+	for _, write := range []func(int64) error{applyExec.writeAppliedVersion, applyExec.writeLastSuccessVersion, applyExec.writeHighWaterVersion} {
+		if err := write(7); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	return NewRollbackConfigExecutor(applyExec), dirConfig
+}
+
+func TestRollbackKeepsHighWaterMark(t *testing.T) {
+	e, dirConfig := newRollbackTestExecutor(t)
+
+	if _, err := e.Execute(`{"version":5}`); err != nil {
+		t.Fatalf("rollback failed: %v", err)
+	}
+	if live, _ := dirConfig.GetLiveVersion(); live != 5 {
+		t.Fatalf("expected live version 5, got %d", live)
+	}
+
+	// A redelivered apply_config of the rolled back version must be skipped
+	result, err := e.apply.Execute(`{"version":7,"websites":[]}`)
+	if err != nil || !strings.Contains(result, "already applied") {
+		t.Fatalf("expected version 7 to be skipped, got %q, %v", result, err)
+	}
+	if live, _ := dirConfig.GetLiveVersion(); live != 5 {
+		t.Errorf("expected live version to stay 5, got %d", live)
+	}
+	if _, err := os.Stat(dirConfig.GetVersionDir(7)); err != nil {
+		t.Errorf("version 7 directory must be kept: %v", err)
+	}
+}
+
+func TestRollbackLeavesLiveOnFailure(t *testing.T) {
+	tests := []struct {
+		name   string
+		bin    string
+		reload string
+	}{
+		{name: "nginx test fails", bin: "false", reload: "true"},
+		{name: "reload fails", bin: "true", reload: "false"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e, dirConfig := newRollbackTestExecutor(t)
+			dirConfig.NginxBin = tt.bin
+			dirConfig.NginxReloadCmd = tt.reload
+
+			if _, err := e.Execute(`{"version":5}`); err == nil {
+				t.Fatal("expected rollback to fail")
+			}
+			if live, _ := dirConfig.GetLiveVersion(); live != 7 {
+				t.Errorf("expected live version to stay 7, got %d", live)
+			}
+			if applied, _ := e.apply.readAppliedVersion(); applied != 7 {
+				t.Errorf("expected applied version to stay 7, got %d", applied)
+			}
+		})
+	}
+}
@@ -0,0 +1,159 @@
+package executor
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"go_cmdb/agent/config"
+)
+
+// defaultConfigTestTTL is how long a nginx -t result is reused
+const defaultConfigTestTTL = 30 * time.Second
+
+// AgentState represents the config and nginx state reported by the agent
+type AgentState struct {
+	AppliedVersion     int64      `json:"appliedVersion"`
+	LastSuccessVersion int64      `json:"lastSuccessVersion"`
+	LiveVersion        int64      `json:"liveVersion"`
+	LastError          *ErrorMeta `json:"lastError"`
+	NginxVersion       string     `json:"nginxVersion"`
+	ConfigTestOK       bool       `json:"configTestOk"`
+	ConfigTestError    string     `json:"configTestError,omitempty"`
+	ConfigTestedAt     *time.Time `json:"configTestedAt,omitempty"`
+	UptimeSec          int64      `json:"uptimeSec"`
+}
+
+// StateReader collects the agent state from the meta directory and nginx
+type StateReader struct {
+	dirConfig *config.DirConfig
+	startedAt time.Time
+	testTTL   time.Duration
+	now       func() time.Time
+
+	mu           sync.Mutex
+	nginxVersion string
+	testOK       bool
+	testError    string
+	testedAt     *time.Time
+}
+
+// NewStateReader creates a new state reader. Uptime is counted from its creation.
+func NewStateReader(dirConfig *config.DirConfig) *StateReader {
+	return &StateReader{
+		dirConfig: dirConfig,
+		startedAt: time.Now(),
+		testTTL:   defaultConfigTestTTL,
+		now:       time.Now,
+	}
+}
+
+// Read returns the current agent state
+func (r *StateReader) Read() (*AgentState, error) {
+	metaDir := r.dirConfig.GetMetaDir()
+
+	appliedVersion, err := readVersionMeta(filepath.Join(metaDir, "applied_version.json"))
+	if err != nil {
+		return nil, err
+	}
+	lastSuccessVersion, err := readVersionMeta(filepath.Join(metaDir, "last_success_version.json"))
+	if err != nil {
+		return nil, err
+	}
+	liveVersion, err := r.dirConfig.GetLiveVersion()
+	if err != nil {
+		return nil, err
+	}
+	lastError, err := readErrorMeta(filepath.Join(metaDir, "last_error.json"))
+	if err != nil {
+		return nil, err
+	}
+
+	state := &AgentState{
+		AppliedVersion:     appliedVersion,
+		LastSuccessVersion: lastSuccessVersion,
+		LiveVersion:        liveVersion,
+		LastError:          lastError,
+		NginxVersion:       r.getNginxVersion(),
+		UptimeSec:          int64(r.now().Sub(r.startedAt).Seconds()),
+	}
+	state.ConfigTestOK, state.ConfigTestError, state.ConfigTestedAt = r.configTest()
+
+	return state, nil
+}
+
+// configTest runs nginx -t, reusing the previous result within testTTL
+func (r *StateReader) configTest() (bool, string, *time.Time) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	now := r.now()
+	if r.testedAt != nil && now.Sub(*r.testedAt) < r.testTTL {
+		return r.testOK, r.testError, r.testedAt
+	}
+
+	err := nginxTest(r.dirConfig.NginxTestCmd)
+	r.testOK = err == nil
+	r.testError = ""
+	if err != nil {
+		r.testError = err.Error()
+	}
+	r.testedAt = &now
+
+	return r.testOK, r.testError, r.testedAt
+}
+
+// getNginxVersion returns the nginx version from nginx -v (cached once known)
+func (r *StateReader) getNginxVersion() string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.nginxVersion != "" {
+		return r.nginxVersion
+	}
+
+	// nginx -v prints "nginx version: nginx/1.24.0" to stderr
+	output, err := exec.Command(r.dirConfig.NginxBin, "-v").CombinedOutput()
+	if err != nil {
+		return ""
+	}
+	r.nginxVersion = parseNginxVersion(string(output))
+	return r.nginxVersion
+}
+
+// parseNginxVersion extracts the version from nginx -v output
+func parseNginxVersion(output string) string {
+	output = strings.TrimSpace(output)
+	idx := strings.Index(output, "nginx/")
+	if idx < 0 {
+		return output
+	}
+	fields := strings.Fields(output[idx+len("nginx/"):])
+	if len(fields) == 0 {
+		return ""
+	}
+	return fields[0]
+}
+
+// readErrorMeta reads last_error.json (nil if missing)
+func readErrorMeta(filePath string) (*ErrorMeta, error) {
+	data, err := os.ReadFile(filePath)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to read %s: %w", filepath.Base(filePath), err)
+	}
+
+	var meta ErrorMeta
+	if err := json.Unmarshal(data, &meta); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", filepath.Base(filePath), err)
+	}
+
+	return &meta, nil
+}
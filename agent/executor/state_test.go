@@ -0,0 +1,80 @@
+package executor
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"go_cmdb/agent/config"
+)
+
+func TestParseNginxVersion(t *testing.T) {
+	tests := map[string]string{
+		"nginx version: nginx/1.24.0\n":                "1.24.0",
+		"nginx version: openresty/1.21.4.1 nginx/1.21": "1.21",
+		"nginx version: nginx/":                        "",
+		"custom build":                                 "custom build",
+	}
+	for output, want := range tests {
+		if got := parseNginxVersion(output); got != want {
+			t.Errorf("parseNginxVersion(%q) = %q, want %q", output, got, want)
+		}
+	}
+}
+
+func TestStateReaderRead(t *testing.T) {
+	root := t.TempDir()
+	dirConfig := &config.DirConfig{
+		CMDBRenderDir: root,
+		NginxBin:      filepath.Join(root, "missing-nginx"),
+		NginxTestCmd:  "exit 1",
+	}
+	metaDir := dirConfig.GetMetaDir()
+	if err := os.MkdirAll(metaDir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	files := map[string]string{
+		"applied_version.json":      `{"version":5}`,
+		"last_success_version.json": `{"version":4}`,
+		"last_error.json":           `{"version":5,"error":"nginx test failed"}`,
+	}
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(metaDir, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	r := NewStateReader(dirConfig)
+	r.startedAt = time.Now().Add(-time.Minute)
+
+	state, err := r.Read()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if state.AppliedVersion != 5 || state.LastSuccessVersion != 4 {
+		t.Errorf("unexpected versions: applied=%d lastSuccess=%d", state.AppliedVersion, state.LastSuccessVersion)
+	}
+	if state.LastError == nil || state.LastError.Error != "nginx test failed" {
+		t.Errorf("unexpected last error: %+v", state.LastError)
+	}
+	if state.ConfigTestOK || state.ConfigTestError == "" {
+		t.Errorf("expected failed config test, got ok=%v error=%q", state.ConfigTestOK, state.ConfigTestError)
+	}
+	if state.UptimeSec < 60 {
+		t.Errorf("expected uptime >= 60s, got %d", state.UptimeSec)
+	}
+	if state.NginxVersion != "" {
+		t.Errorf("expected empty nginx version, got %q", state.NginxVersion)
+	}
+
+	// The config test result is reused within the TTL
+	r.dirConfig.NginxTestCmd = "true"
+	state, err = r.Read()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if state.ConfigTestOK {
+		t.Error("expected cached config test result")
+	}
+}
@@ -0,0 +1,66 @@
+package executor
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"go_cmdb/agent/config"
+)
+
+// ValidateConfigExecutor handles validate_config task execution.
+// It renders the payload to a throwaway staging directory and runs nginx -t against it;
+// versions, metadata and the live symlink are never touched.
+type ValidateConfigExecutor struct {
+	dirConfig *config.DirConfig
+	apply     *ApplyConfigExecutor
+}
+
+// NewValidateConfigExecutor creates a new validate_config executor.
+// It shares the renderer with the apply_config executor so both render identical files.
+func NewValidateConfigExecutor(applyExec *ApplyConfigExecutor) *ValidateConfigExecutor {
+	return &ValidateConfigExecutor{
+		dirConfig: applyExec.dirConfig,
+		apply:     applyExec,
+	}
+}
+
+// Execute executes the validate_config task (payload has the apply_config format)
+func (e *ValidateConfigExecutor) Execute(payloadJSON string) (string, error) {
+	var payload ApplyConfigPayload
+	if err := json.Unmarshal([]byte(payloadJSON), &payload); err != nil {
+		return "", fmt.Errorf("failed to parse payload: %w", err)
+	}
+
+	// Step 1: Render to a private staging directory (removed when done)
+	stagingDir := filepath.Join(e.dirConfig.GetStagingRootDir(), fmt.Sprintf("validate_%d_%d", payload.Version, time.Now().UnixNano()))
+	for _, subdir := range []string{"upstreams", "servers", "certs"} {
+		if err := os.MkdirAll(filepath.Join(stagingDir, subdir), 0755); err != nil {
+			return "", fmt.Errorf("failed to create staging directory: %w", err)
+		}
+	}
+	defer os.RemoveAll(stagingDir)
+
+	// Certificate paths point into the staging directory, the files are never moved
+	if err := e.apply.renderConfigurations(stagingDir, stagingDir, &payload); err != nil {
+		return "", fmt.Errorf("failed to render configurations: %w", err)
+	}
+
+	// Step 2: nginx -t against the staged files
+	if err := nginxTestDir(e.dirConfig, stagingDir); err != nil {
+		return "", err
+	}
+
+	return fmt.Sprintf("Configuration valid (version %d, %d websites)", payload.Version, len(payload.Websites)), nil
+}
+
+// stagedNginxConf rewrites the references to the live directory in nginx.conf to the staging directory
+func stagedNginxConf(mainConf, liveDir, stagingDir string) (string, error) {
+	if !strings.Contains(mainConf, liveDir) {
+		return "", fmt.Errorf("nginx.conf does not include %s, staged files cannot be validated", liveDir)
+	}
+	return strings.ReplaceAll(mainConf, liveDir, stagingDir), nil
+}
@@ -0,0 +1,73 @@
+package executor
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"go_cmdb/agent/config"
+)
+
+func TestStagedNginxConf(t *testing.T) {
+	conf := "http {\n    include /data/cmdb/live/upstreams/*.conf;\n    include /data/cmdb/live/servers/*.conf;\n}\n"
+	got, err := stagedNginxConf(conf, "/data/cmdb/live", "/data/cmdb/staging/validate_3")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(got, "/data/cmdb/live") || !strings.Contains(got, "include /data/cmdb/staging/validate_3/servers/*.conf;") {
+		t.Errorf("unexpected staged conf:\n%s", got)
+	}
+
+	if _, err := stagedNginxConf("http {}\n", "/data/cmdb/live", "/tmp/x"); err == nil {
+		t.Error("expected error when live directory is not included")
+	}
+}
+
+func TestValidateConfigExecute(t *testing.T) {
+	root := t.TempDir()
+	nginxConf := filepath.Join(root, "nginx.conf")
+	dirConfig := &config.DirConfig{
+		CMDBRenderDir: filepath.Join(root, "cmdb"),
+		NginxConf:     nginxConf,
+		NginxBin:      "true",
+	}
+	if err := os.WriteFile(nginxConf, []byte("include "+dirConfig.GetLiveDir()+"/servers/*.conf;\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.MkdirAll(dirConfig.GetStagingRootDir(), 0755); err != nil {
+		t.Fatal(err)
+	}
+	applyExec, err := NewApplyConfigExecutor(dirConfig)
+	if err != nil {
+		t.Fatal(err)
+	}
+	e := NewValidateConfigExecutor(applyExec)
+
+	payload := `{"version":7,"websites":[{"websiteId":1,"domains":[{"domain":"a.example.com","isPrimary":true}],"origin":{"mode":"group","upstreamName":"upstream_originset_1","addresses":[{"role":"primary","protocol":"http","address":"10.0.0.1:80","weight":10,"enabled":true}]}}]}`
+	if _, err := e.Execute(payload); err != nil {
+		t.Fatalf("expected valid config, got %v", err)
+	}
+
+	dirConfig.NginxBin = "false"
+	if _, err := e.Execute(payload); err == nil {
+		t.Error("expected nginx test error")
+	} else if _, ok := err.(*NginxTestError); !ok {
+		t.Errorf("expected *NginxTestError, got %T", err)
+	}
+
+	// Neither run may leave staged files or touch the live directory
+	entries, err := os.ReadDir(dirConfig.GetStagingRootDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 0 {
+		t.Errorf("staging directory not cleaned up: %d entries", len(entries))
+	}
+	if _, err := os.Lstat(dirConfig.GetLiveDir()); !os.IsNotExist(err) {
+		t.Errorf("live directory must not be created, got %v", err)
+	}
+	if matches, _ := filepath.Glob(filepath.Join(root, ".cmdb_validate_*")); len(matches) != 0 {
+		t.Errorf("validate config not removed: %v", matches)
+	}
+}
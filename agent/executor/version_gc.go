@@ -0,0 +1,193 @@
+package executor
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strconv"
+	"time"
+
+	"go_cmdb/agent/config"
+)
+
+// VersionGCResult represents the result of a version garbage collection run
+type VersionGCResult struct {
+	RemovedVersions []int64 `json:"removedVersions"`
+	RemovedStaging  []int64 `json:"removedStaging"`
+	KeptVersions    []int64 `json:"keptVersions"`
+}
+
+// VersionGC removes old versions/<n> and leftover .staging/<n> directories
+type VersionGC struct {
+	dirConfig *config.DirConfig
+	now       func() time.Time
+}
+
+// NewVersionGC creates a new version garbage collector
+func NewVersionGC(dirConfig *config.DirConfig) *VersionGC {
+	return &VersionGC{
+		dirConfig: dirConfig,
+		now:       time.Now,
+	}
+}
+
+// RunStartup runs garbage collection at agent startup.
+// No task is running yet, so every staging directory is a leftover from a crashed run.
+func (g *VersionGC) RunStartup() (*VersionGCResult, error) {
+	return g.run(0, true)
+}
+
+// RunAfterApply runs garbage collection after a successful apply of appliedVersion.
+// Only staging directories of versions up to appliedVersion are removed.
+func (g *VersionGC) RunAfterApply(appliedVersion int64) (*VersionGCResult, error) {
+	return g.run(appliedVersion, false)
+}
+
+func (g *VersionGC) run(appliedVersion int64, allStaging bool) (*VersionGCResult, error) {
+	result := &VersionGCResult{
+		RemovedVersions: []int64{},
+		RemovedStaging:  []int64{},
+		KeptVersions:    []int64{},
+	}
+
+	// Step 1: Staging leftovers
+	stagingVersions, err := g.listStaging()
+	if err != nil {
+		return nil, err
+	}
+	for _, version := range stagingVersions {
+		if !allStaging && version > appliedVersion {
+			continue
+		}
+		if err := g.dirConfig.CleanStagingDir(version); err != nil {
+			return nil, err
+		}
+		result.RemovedStaging = append(result.RemovedStaging, version)
+	}
+
+	// Step 2: Versions outside the retention policy
+	versions, err := g.dirConfig.ListVersions()
+	if err != nil {
+		return nil, err
+	}
+
+	protected, err := g.protectedVersions()
+	if err != nil {
+		return nil, err
+	}
+
+	for i, version := range versions {
+		keep, err := g.shouldKeep(version, len(versions)-i, protected)
+		if err != nil {
+			return nil, err
+		}
+		if keep {
+			result.KeptVersions = append(result.KeptVersions, version)
+			continue
+		}
+
+		if err := os.RemoveAll(g.dirConfig.GetVersionDir(version)); err != nil {
+			return nil, fmt.Errorf("failed to remove version directory %d: %w", version, err)
+		}
+		result.RemovedVersions = append(result.RemovedVersions, version)
+	}
+
+	if len(result.RemovedVersions) > 0 || len(result.RemovedStaging) > 0 {
+		log.Printf("[VersionGC] Removed versions %v, staging %v (kept %v)", result.RemovedVersions, result.RemovedStaging, result.KeptVersions)
+	}
+
+	return result, nil
+}
+
+// shouldKeep reports whether a version is retained.
+// rank is 1 for the newest version. A version is kept if it is protected,
+// within the newest N, or newer than D days; with no policy configured everything is kept.
+func (g *VersionGC) shouldKeep(version int64, rank int, protected map[int64]bool) (bool, error) {
+	if protected[version] {
+		return true, nil
+	}
+
+	count := g.dirConfig.VersionRetainCount
+	days := g.dirConfig.VersionRetainDays
+	if count <= 0 && days <= 0 {
+		return true, nil
+	}
+
+	if count > 0 && rank <= count {
+		return true, nil
+	}
+
+	if days > 0 {
+		info, err := os.Stat(g.dirConfig.GetVersionDir(version))
+		if err != nil {
+			return false, fmt.Errorf("failed to stat version directory %d: %w", version, err)
+		}
+		if g.now().Sub(info.ModTime()) < time.Duration(days)*24*time.Hour {
+			return true, nil
+		}
+	}
+
+	return false, nil
+}
+
+// protectedVersions returns the versions that must never be removed (live, applied, last success)
+func (g *VersionGC) protectedVersions() (map[int64]bool, error) {
+	protected := make(map[int64]bool)
+
+	liveVersion, err := g.dirConfig.GetLiveVersion()
+	if err != nil {
+		return nil, err
+	}
+	protected[liveVersion] = true
+
+	for _, name := range []string{"applied_version.json", "last_success_version.json"} {
+		version, err := readVersionMeta(filepath.Join(g.dirConfig.GetMetaDir(), name))
+		if err != nil {
+			return nil, err
+		}
+		protected[version] = true
+	}
+
+	return protected, nil
+}
+
+// listStaging returns the versions that have a staging directory
+func (g *VersionGC) listStaging() ([]int64, error) {
+	entries, err := os.ReadDir(g.dirConfig.GetStagingRootDir())
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to read staging directory: %w", err)
+	}
+
+	versions := make([]int64, 0, len(entries))
+	for _, entry := range entries {
+		version, err := strconv.ParseInt(entry.Name(), 10, 64)
+		if err != nil {
+			continue
+		}
+		versions = append(versions, version)
+	}
+	return versions, nil
+}
+
+// readVersionMeta reads the version from a version metadata file (0 if missing)
+func readVersionMeta(filePath string) (int64, error) {
+	data, err := os.ReadFile(filePath)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return 0, nil
+		}
+		return 0, fmt.Errorf("failed to read %s: %w", filepath.Base(filePath), err)
+	}
+
+	var meta VersionMeta
+	if err := json.Unmarshal(data, &meta); err != nil {
+		return 0, fmt.Errorf("failed to parse %s: %w", filepath.Base(filePath), err)
+	}
+
+	return meta.Version, nil
+}
@@ -0,0 +1,77 @@
+package executor
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"go_cmdb/agent/config"
+)
+
+func TestVersionGC(t *testing.T) {
+	dirConfig := &config.DirConfig{
+		CMDBRenderDir:      t.TempDir(),
+		VersionRetainCount: 2,
+		VersionRetainDays:  1,
+	}
+	if err := dirConfig.EnsureDirectories(); err != nil {
+		t.Fatal(err)
+	}
+
+	old := time.Now().Add(-72 * time.Hour)
+	for version := int64(1); version <= 6; version++ {
+		dir := dirConfig.GetVersionDir(version)
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			t.Fatal(err)
+		}
+		// Version 4 is recent, all others are old
+		if version != 4 {
+			if err := os.Chtimes(dir, old, old); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+
+	// Version 2 is live (rolled back), version 1 is last success
+	if err := dirConfig.AtomicSwitchToVersion(2); err != nil {
+		t.Fatal(err)
+	}
+	data, _ := json.Marshal(VersionMeta{Version: 1})
+	if err := os.WriteFile(filepath.Join(dirConfig.GetMetaDir(), "last_success_version.json"), data, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	// Staging leftovers: 5 is done, 7 may still be in progress
+	for _, version := range []int64{5, 7} {
+		if err := dirConfig.EnsureStagingDir(version); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	result, err := NewVersionGC(dirConfig).RunAfterApply(6)
+	if err != nil {
+		t.Fatalf("RunAfterApply() failed: %v", err)
+	}
+
+	if !reflect.DeepEqual(result.RemovedVersions, []int64{3}) {
+		t.Errorf("expected removed versions [3], got %v", result.RemovedVersions)
+	}
+	if !reflect.DeepEqual(result.KeptVersions, []int64{1, 2, 4, 5, 6}) {
+		t.Errorf("expected kept versions [1 2 4 5 6], got %v", result.KeptVersions)
+	}
+	if !reflect.DeepEqual(result.RemovedStaging, []int64{5}) {
+		t.Errorf("expected removed staging [5], got %v", result.RemovedStaging)
+	}
+
+	// Startup removes every staging leftover
+	result, err = NewVersionGC(dirConfig).RunStartup()
+	if err != nil {
+		t.Fatalf("RunStartup() failed: %v", err)
+	}
+	if !reflect.DeepEqual(result.RemovedStaging, []int64{7}) {
+		t.Errorf("expected removed staging [7], got %v", result.RemovedStaging)
+	}
+}
@@ -0,0 +1,288 @@
+package pull
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// maxLastErrorLen is the maximum lastError length accepted by update-status
+const maxLastErrorLen = 2048
+
+// TaskRunner runs a pulled task with the agent executors
+type TaskRunner interface {
+	// RunTaskSync runs a task and returns its final status (success|failed) and output.
+	// skipped is true if the same requestId is still running.
+	RunTaskSync(requestID, taskType string, payload interface{}) (status string, output string, skipped bool)
+}
+
+// Config holds pull mode configuration
+type Config struct {
+	ServerURL string        // Control plane base URL, e.g. https://cmdb.example.com
+	NodeID    int64         // Node ID of this agent (sent as X-Node-Id), 0 = identified by client certificate only
+	Interval  time.Duration // Poll interval when no task is pending
+	Limit     int           // Max tasks per pull
+	TLSConfig *tls.Config   // Client TLS config (client certificate, control plane CA)
+}
+
+// PulledTask represents a task returned by /api/v1/agent/tasks/pull
+type PulledTask struct {
+	ID        int64                  `json:"id"`
+	NodeID    int                    `json:"nodeId"`
+	Type      string                 `json:"type"`
+	Payload   map[string]interface{} `json:"payload"`
+	Status    string                 `json:"status"`
+	Attempts  int                    `json:"attempts"`  // Executions including this one
+	UpdatedAt time.Time              `json:"updatedAt"` // Time the task was claimed
+}
+
+// pullResponse represents the response of /api/v1/agent/tasks/pull
+type pullResponse struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+	Data    struct {
+		Items []PulledTask `json:"items"`
+	} `json:"data"`
+}
+
+// statusReport represents a request to /api/v1/agent/tasks/update-status
+type statusReport struct {
+	ID        int64  `json:"id"`
+	Attempts  int    `json:"attempts"` // Attempt the result belongs to, stale attempts are rejected
+	Status    string `json:"status"`   // succeeded|failed
+	LastError string `json:"lastError,omitempty"`
+}
+
+// Puller polls the control plane for tasks and reports their status
+type Puller struct {
+	cfg    Config
+	client *http.Client
+	runner TaskRunner
+
+	mu      sync.Mutex
+	reports []statusReport // reports not yet accepted by the control plane
+}
+
+// NewPuller creates a new puller
+func NewPuller(cfg Config, runner TaskRunner) *Puller {
+	if cfg.Interval <= 0 {
+		cfg.Interval = 5 * time.Second
+	}
+	if cfg.Limit <= 0 {
+		cfg.Limit = 10
+	}
+
+	return &Puller{
+		cfg: cfg,
+		client: &http.Client{
+			Timeout: 30 * time.Second,
+			Transport: &http.Transport{
+				TLSClientConfig:     cfg.TLSConfig,
+				TLSHandshakeTimeout: 15 * time.Second,
+			},
+		},
+		runner: runner,
+	}
+}
+
+// Run polls until ctx is done. When a poll returns tasks, the next poll starts immediately.
+func (p *Puller) Run(ctx context.Context) {
+	log.Printf("[Pull] Starting pull loop (server=%s, nodeId=%d, interval=%v)", p.cfg.ServerURL, p.cfg.NodeID, p.cfg.Interval)
+
+	for {
+		count, err := p.PollOnce()
+		if err != nil {
+			log.Printf("[Pull] Poll failed: %v", err)
+		}
+
+		wait := p.cfg.Interval
+		if err == nil && count > 0 {
+			wait = 0
+		}
+
+		select {
+		case <-ctx.Done():
+			log.Println("[Pull] Pull loop stopped")
+			return
+		case <-time.After(wait):
+		}
+	}
+}
+
+// PollOnce flushes pending status reports, pulls tasks, runs them and reports their status.
+// Returns the number of pulled tasks.
+func (p *Puller) PollOnce() (int, error) {
+	// Reports that failed earlier must reach the control plane, otherwise the task stays running
+	p.flushReports()
+
+	tasks, err := p.pull()
+	if err != nil {
+		return 0, err
+	}
+
+	for _, task := range tasks {
+		requestID := taskRequestID(task)
+		status, output, skipped := p.runner.RunTaskSync(requestID, task.Type, task.Payload)
+		if skipped {
+			log.Printf("[Pull] Task %d (%s) is still running, skipping", task.ID, task.Type)
+			continue
+		}
+
+		report := statusReport{ID: task.ID, Attempts: task.Attempts, Status: "succeeded"}
+		if status != "success" {
+			report.Status = "failed"
+			report.LastError = truncate(output, maxLastErrorLen)
+		}
+		p.queueReport(report)
+	}
+
+	p.flushReports()
+	return len(tasks), nil
+}
+
+// taskRequestID returns the requestId of one execution of a task.
+// A retry must not get the stored result of an earlier attempt: the attempt number changes per claim,
+// the claim time tells attempts apart after a manual retry reset the counter.
+func taskRequestID(task PulledTask) string {
+	return fmt.Sprintf("pull-task-%d-%d-%d", task.ID, task.Attempts, task.UpdatedAt.UnixMilli())
+}
+
+// pull fetches tasks assigned to this node
+func (p *Puller) pull() ([]PulledTask, error) {
+	url := fmt.Sprintf("%s/api/v1/agent/tasks/pull?limit=%d", p.cfg.ServerURL, p.cfg.Limit)
+	httpReq, err := http.NewRequest("GET", url, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+
+	var resp pullResponse
+	if err := p.do(httpReq, &resp); err != nil {
+		return nil, err
+	}
+	if resp.Code != 0 {
+		return nil, fmt.Errorf("control plane returned error code %d: %s", resp.Code, resp.Message)
+	}
+
+	return resp.Data.Items, nil
+}
+
+// queueReport adds a status report to the pending list
+func (p *Puller) queueReport(report statusReport) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.reports = append(p.reports, report)
+}
+
+// flushReports sends pending status reports, keeping the ones that failed to send.
+// Reports the control plane rejected (4xx or a business error) are dropped, sending them again gives the same answer.
+func (p *Puller) flushReports() {
+	p.mu.Lock()
+	reports := p.reports
+	p.reports = nil
+	p.mu.Unlock()
+
+	var remaining []statusReport
+	for _, report := range reports {
+		if err := p.updateStatus(report); err != nil {
+			var rejected *rejectedError
+			if errors.As(err, &rejected) {
+				log.Printf("[Pull] Dropping status report of task %d (attempt %d, %s): %v", report.ID, report.Attempts, report.Status, err)
+				continue
+			}
+			log.Printf("[Pull] Failed to report status of task %d: %v", report.ID, err)
+			remaining = append(remaining, report)
+			continue
+		}
+		log.Printf("[Pull] Reported task %d as %s", report.ID, report.Status)
+	}
+
+	if len(remaining) > 0 {
+		p.mu.Lock()
+		p.reports = append(remaining, p.reports...)
+		p.mu.Unlock()
+	}
+}
+
+// updateStatus reports the final status of a task
+func (p *Puller) updateStatus(report statusReport) error {
+	body, err := json.Marshal(report)
+	if err != nil {
+		return fmt.Errorf("failed to marshal request: %w", err)
+	}
+
+	httpReq, err := http.NewRequest("POST", p.cfg.ServerURL+"/api/v1/agent/tasks/update-status", bytes.NewBuffer(body))
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+	httpReq.Header.Set("Content-Type", "application/json")
+
+	var resp struct {
+		Code    int    `json:"code"`
+		Message string `json:"message"`
+	}
+	if err := p.do(httpReq, &resp); err != nil {
+		return err
+	}
+	if resp.Code != 0 {
+		return &rejectedError{msg: fmt.Sprintf("control plane returned error code %d: %s", resp.Code, resp.Message)}
+	}
+
+	return nil
+}
+
+// rejectedError is an answer the control plane gives again for the same request:
+// a 4xx status or a business error code
+type rejectedError struct {
+	msg string
+}
+
+func (e *rejectedError) Error() string {
+	return e.msg
+}
+
+// do sends a request with the node identity and decodes the JSON response
+func (p *Puller) do(httpReq *http.Request, v interface{}) error {
+	if p.cfg.NodeID > 0 {
+		httpReq.Header.Set("X-Node-Id", strconv.FormatInt(p.cfg.NodeID, 10))
+	}
+
+	httpResp, err := p.client.Do(httpReq)
+	if err != nil {
+		return fmt.Errorf("failed to send request: %w", err)
+	}
+	defer httpResp.Body.Close()
+
+	respBody, err := io.ReadAll(httpResp.Body)
+	if err != nil {
+		return fmt.Errorf("failed to read response: %w", err)
+	}
+
+	if httpResp.StatusCode >= 400 && httpResp.StatusCode < 500 {
+		return &rejectedError{msg: fmt.Sprintf("control plane returned status %d: %s", httpResp.StatusCode, truncate(string(respBody), 512))}
+	}
+	if httpResp.StatusCode != http.StatusOK {
+		return fmt.Errorf("control plane returned status %d: %s", httpResp.StatusCode, truncate(string(respBody), 512))
+	}
+
+	if err := json.Unmarshal(respBody, v); err != nil {
+		return fmt.Errorf("failed to parse response: %w", err)
+	}
+
+	return nil
+}
+
+func truncate(s string, max int) string {
+	if len(s) > max {
+		return s[:max]
+	}
+	return s
+}
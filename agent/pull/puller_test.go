@@ -0,0 +1,136 @@
+package pull
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+)
+
+type fakeRunner struct {
+	mu   sync.Mutex
+	runs []string
+}
+
+func (r *fakeRunner) RunTaskSync(requestID, taskType string, payload interface{}) (string, string, bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.runs = append(r.runs, requestID)
+	if taskType == "reload" {
+		return "failed", "nginx reload failed", false
+	}
+	return "success", "done", false
+}
+
+func TestPollOnce(t *testing.T) {
+	var mu sync.Mutex
+	var reports []statusReport
+	failReports := true
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("X-Node-Id") != "7" {
+			t.Errorf("expected X-Node-Id 7, got %q", r.Header.Get("X-Node-Id"))
+		}
+
+		switch r.URL.Path {
+		case "/api/v1/agent/tasks/pull":
+			mu.Lock()
+			pulled := len(reports) > 0 || !failReports
+			mu.Unlock()
+			items := []map[string]interface{}{}
+			if !pulled {
+				items = append(items,
+					map[string]interface{}{"id": 1, "type": "purge_cache", "payload": map[string]interface{}{}, "attempts": 1, "updatedAt": "2026-10-17T08:00:00Z"},
+					map[string]interface{}{"id": 2, "type": "reload", "payload": map[string]interface{}{}, "attempts": 3, "updatedAt": "2026-10-17T08:00:00Z"},
+				)
+			}
+			json.NewEncoder(w).Encode(map[string]interface{}{"code": 0, "message": "success", "data": map[string]interface{}{"items": items}})
+
+		case "/api/v1/agent/tasks/update-status":
+			mu.Lock()
+			defer mu.Unlock()
+			if failReports {
+				w.WriteHeader(http.StatusBadGateway)
+				return
+			}
+			var report statusReport
+			json.NewDecoder(r.Body).Decode(&report)
+			reports = append(reports, report)
+			json.NewEncoder(w).Encode(map[string]interface{}{"code": 0, "message": "success"})
+		}
+	}))
+	defer server.Close()
+
+	runner := &fakeRunner{}
+	p := NewPuller(Config{ServerURL: server.URL, NodeID: 7}, runner)
+
+	count, err := p.PollOnce()
+	if err != nil {
+		t.Fatalf("PollOnce() failed: %v", err)
+	}
+	if count != 2 || len(runner.runs) != 2 || runner.runs[0] != "pull-task-1-1-1792224000000" || runner.runs[1] != "pull-task-2-3-1792224000000" {
+		t.Fatalf("expected 2 tasks run, got count=%d runs=%v", count, runner.runs)
+	}
+
+	// Reports failed to send and are retried on the next poll
+	if len(p.reports) != 2 {
+		t.Fatalf("expected 2 pending reports, got %d", len(p.reports))
+	}
+
+	mu.Lock()
+	failReports = false
+	mu.Unlock()
+
+	if _, err := p.PollOnce(); err != nil {
+		t.Fatalf("PollOnce() failed: %v", err)
+	}
+	if len(reports) != 2 {
+		t.Fatalf("expected 2 reports, got %d", len(reports))
+	}
+	if reports[0].Status != "succeeded" || reports[1].Status != "failed" || reports[1].LastError != "nginx reload failed" || reports[1].Attempts != 3 {
+		t.Errorf("unexpected reports: %+v", reports)
+	}
+}
+
+func TestPollOnceWithoutNodeID(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if _, ok := r.Header["X-Node-Id"]; ok {
+			t.Errorf("X-Node-Id must not be sent without a node ID, got %q", r.Header.Get("X-Node-Id"))
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{"code": 0, "message": "success", "data": map[string]interface{}{"items": []interface{}{}}})
+	}))
+	defer server.Close()
+
+	p := NewPuller(Config{ServerURL: server.URL}, &fakeRunner{})
+	if _, err := p.PollOnce(); err != nil {
+		t.Fatalf("PollOnce() failed: %v", err)
+	}
+}
+
+func TestFlushReportsDropsRejected(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var report statusReport
+		json.NewDecoder(r.Body).Decode(&report)
+		switch report.ID {
+		case 1: // stale attempt
+			w.WriteHeader(http.StatusConflict)
+			json.NewEncoder(w).Encode(map[string]interface{}{"code": 4009, "message": "task is not in a running state"})
+		case 2: // business error
+			json.NewEncoder(w).Encode(map[string]interface{}{"code": 5001, "message": "invalid task payload"})
+		default: // control plane unavailable
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+	}))
+	defer server.Close()
+
+	p := NewPuller(Config{ServerURL: server.URL, NodeID: 7}, &fakeRunner{})
+	for id := int64(1); id <= 3; id++ {
+		p.queueReport(statusReport{ID: id, Attempts: 1, Status: "succeeded"})
+	}
+	p.flushReports()
+
+	if len(p.reports) != 1 || p.reports[0].ID != 3 {
+		t.Fatalf("expected only the report of task 3 to be kept, got %+v", p.reports)
+	}
+}
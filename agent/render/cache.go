@@ -0,0 +1,133 @@
+package render
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// Cache modes
+const (
+	CacheModeDefault = "default"
+	CacheModeFollow  = "follow"
+	CacheModeForce   = "force"
+	CacheModeBypass  = "bypass"
+)
+
+// Cache match types
+const (
+	MatchTypePath   = "path"
+	MatchTypeSuffix = "suffix"
+	MatchTypeExact  = "exact"
+)
+
+// CacheRuleData holds a cache rule item from the payload
+type CacheRuleData struct {
+	MatchType  string
+	MatchValue string
+	Mode       string
+	TTLSeconds int
+}
+
+// CacheData holds cache configuration for a server block
+type CacheData struct {
+	Zone      string
+	Key       string
+	Root      *CacheLocationData  // path rule "/", merged into "location /"
+	Locations []CacheLocationData // all other rules, in render order
+}
+
+// CacheLocationData holds a rendered cache location block
+type CacheLocationData struct {
+	Match         string   // location modifier and pattern, e.g. "= /index.html"
+	Mode          string   // default|follow|force|bypass
+	Valid         string   // proxy_cache_valid arguments, empty for bypass
+	IgnoreHeaders []string // proxy_ignore_headers
+	Revalidate    bool     // proxy_cache_revalidate
+}
+
+// suffixPattern restricts suffix values to what the control plane validator allows
+var suffixPattern = regexp.MustCompile(`^\.[a-zA-Z0-9._-]+$`)
+
+// BuildCacheData converts cache rules into ordered location blocks.
+// Order: exact matches, then path prefixes (longest first), then suffix regexes
+// in rule order. nginx checks regex locations after the longest prefix, so suffix
+// rules take precedence over path rules.
+func BuildCacheData(zone, key string, rules []CacheRuleData) (CacheData, error) {
+	data := CacheData{Zone: zone, Key: key}
+	var exact, path, suffix []CacheLocationData
+
+	for _, rule := range rules {
+		location := CacheLocationData{Mode: rule.Mode}
+
+		switch rule.Mode {
+		case CacheModeDefault:
+			location.Valid = fmt.Sprintf("200 301 302 %ds", rule.TTLSeconds)
+		case CacheModeFollow:
+			// TTL only applies when the origin sends no caching headers
+			location.Valid = fmt.Sprintf("200 301 302 %ds", rule.TTLSeconds)
+			location.Revalidate = true
+		case CacheModeForce:
+			location.Valid = fmt.Sprintf("200 301 302 %ds", rule.TTLSeconds)
+			location.IgnoreHeaders = []string{"Cache-Control", "Expires", "Set-Cookie", "Vary"}
+		case CacheModeBypass:
+		default:
+			return CacheData{}, fmt.Errorf("unknown cache mode: %s", rule.Mode)
+		}
+
+		if rule.Mode != CacheModeBypass && rule.TTLSeconds <= 0 {
+			return CacheData{}, fmt.Errorf("invalid ttlSeconds %d for %s %s", rule.TTLSeconds, rule.MatchType, rule.MatchValue)
+		}
+
+		if strings.ContainsAny(rule.MatchValue, " \t\r\n;{}\"'") {
+			return CacheData{}, fmt.Errorf("invalid matchValue: %q", rule.MatchValue)
+		}
+
+		switch rule.MatchType {
+		case MatchTypeExact:
+			if !strings.HasPrefix(rule.MatchValue, "/") {
+				return CacheData{}, fmt.Errorf("exact matchValue must start with /: %s", rule.MatchValue)
+			}
+			location.Match = "= " + rule.MatchValue
+			exact = append(exact, location)
+		case MatchTypePath:
+			if !strings.HasPrefix(rule.MatchValue, "/") {
+				return CacheData{}, fmt.Errorf("path matchValue must start with /: %s", rule.MatchValue)
+			}
+			location.Match = rule.MatchValue
+			if rule.MatchValue == "/" {
+				root := location
+				data.Root = &root
+				continue
+			}
+			path = append(path, location)
+		case MatchTypeSuffix:
+			if !suffixPattern.MatchString(rule.MatchValue) {
+				return CacheData{}, fmt.Errorf("invalid suffix matchValue: %s", rule.MatchValue)
+			}
+			location.Match = "~* " + regexp.QuoteMeta(rule.MatchValue) + "$"
+			suffix = append(suffix, location)
+		default:
+			return CacheData{}, fmt.Errorf("unknown match type: %s", rule.MatchType)
+		}
+	}
+
+	// Longest path first (nginx picks the longest prefix regardless of order,
+	// this only keeps the rendered file stable and readable)
+	sort.SliceStable(path, func(i, j int) bool {
+		return len(path[i].Match) > len(path[j].Match)
+	})
+
+	data.Locations = make([]CacheLocationData, 0, len(rules))
+	data.Locations = append(data.Locations, exact...)
+	data.Locations = append(data.Locations, path...)
+	data.Locations = append(data.Locations, suffix...)
+
+	return data, nil
+}
+
+// Enabled reports whether the server block has any cache rule
+func (c CacheData) Enabled() bool {
+	return c.Root != nil || len(c.Locations) > 0
+}
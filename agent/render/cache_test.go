@@ -0,0 +1,158 @@
+package render
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"go_cmdb/agent/config"
+)
+
+func TestBuildCacheDataModes(t *testing.T) {
+	tests := []struct {
+		mode       string
+		valid      string
+		ignore     bool
+		revalidate bool
+	}{
+		{CacheModeDefault, "200 301 302 60s", false, false},
+		{CacheModeFollow, "200 301 302 60s", false, true},
+		{CacheModeForce, "200 301 302 60s", true, false},
+		{CacheModeBypass, "", false, false},
+	}
+
+	for _, tt := range tests {
+		data, err := BuildCacheData("zone", "$host", []CacheRuleData{
+			{MatchType: MatchTypePath, MatchValue: "/static", Mode: tt.mode, TTLSeconds: 60},
+		})
+		if err != nil {
+			t.Fatalf("%s: %v", tt.mode, err)
+		}
+		loc := data.Locations[0]
+		if loc.Valid != tt.valid || (len(loc.IgnoreHeaders) > 0) != tt.ignore || loc.Revalidate != tt.revalidate {
+			t.Errorf("%s: unexpected location %+v", tt.mode, loc)
+		}
+	}
+}
+
+func TestBuildCacheDataOrder(t *testing.T) {
+	data, err := BuildCacheData("zone", "$host", []CacheRuleData{
+		{MatchType: MatchTypeSuffix, MatchValue: ".css", Mode: CacheModeDefault, TTLSeconds: 60},
+		{MatchType: MatchTypePath, MatchValue: "/a", Mode: CacheModeDefault, TTLSeconds: 60},
+		{MatchType: MatchTypePath, MatchValue: "/", Mode: CacheModeBypass},
+		{MatchType: MatchTypeExact, MatchValue: "/index.html", Mode: CacheModeBypass},
+		{MatchType: MatchTypePath, MatchValue: "/a/b", Mode: CacheModeDefault, TTLSeconds: 60},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var got []string
+	for _, loc := range data.Locations {
+		got = append(got, loc.Match)
+	}
+	want := []string{"= /index.html", "/a/b", "/a", `~* \.css$`}
+	if strings.Join(got, "|") != strings.Join(want, "|") {
+		t.Fatalf("order = %q, want %q", got, want)
+	}
+	if data.Root == nil || data.Root.Mode != CacheModeBypass {
+		t.Fatalf("root rule not merged: %+v", data.Root)
+	}
+}
+
+func TestBuildCacheDataValidation(t *testing.T) {
+	tests := map[string]CacheRuleData{
+		"unknown mode":       {MatchType: MatchTypePath, MatchValue: "/a", Mode: "forever", TTLSeconds: 60},
+		"unknown match type": {MatchType: "regex", MatchValue: "/a", Mode: CacheModeDefault, TTLSeconds: 60},
+		"zero ttl":           {MatchType: MatchTypePath, MatchValue: "/a", Mode: CacheModeDefault},
+		"relative path":      {MatchType: MatchTypePath, MatchValue: "a", Mode: CacheModeDefault, TTLSeconds: 60},
+		"relative exact":     {MatchType: MatchTypeExact, MatchValue: "index.html", Mode: CacheModeBypass},
+		"injection":          {MatchType: MatchTypePath, MatchValue: "/a; }", Mode: CacheModeBypass},
+		"bad suffix":         {MatchType: MatchTypeSuffix, MatchValue: "css", Mode: CacheModeDefault, TTLSeconds: 60},
+	}
+
+	for name, rule := range tests {
+		if _, err := BuildCacheData("zone", "$host", []CacheRuleData{rule}); err == nil {
+			t.Errorf("%s: expected error", name)
+		}
+	}
+}
+
+func TestRenderServerCacheLocations(t *testing.T) {
+	renderer, err := NewRenderer(&config.DirConfig{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cache, err := BuildCacheData("cmdb_cache", "$scheme$host$request_uri", []CacheRuleData{
+		{MatchType: MatchTypeSuffix, MatchValue: ".js", Mode: CacheModeForce, TTLSeconds: 3600},
+		{MatchType: MatchTypePath, MatchValue: "/", Mode: CacheModeBypass},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	stagingDir := t.TempDir()
+	os.MkdirAll(filepath.Join(stagingDir, "servers"), 0755)
+	data := &ServerData{
+		WebsiteID: 1,
+		Domains:   []DomainData{{Domain: "example.com", IsPrimary: true}},
+		Origin:    OriginData{Mode: "group", UpstreamName: "upstream_site_1", Protocol: "http"},
+		HTTPS:     HTTPSData{Enabled: true, HSTS: true},
+		Cache:     cache,
+		CertPath:  "/certs/cert_1.pem",
+		KeyPath:   "/certs/key_1.pem",
+	}
+	if err := renderer.RenderServer(stagingDir, 1, data); err != nil {
+		t.Fatal(err)
+	}
+
+	content, err := os.ReadFile(filepath.Join(stagingDir, "servers", "server_site_1.conf"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	conf := string(content)
+
+	jsLocation := conf[strings.Index(conf, `location ~* \.js$ {`):]
+	jsLocation = jsLocation[:strings.Index(jsLocation, "}")]
+	for _, want := range []string{
+		"proxy_cache_valid 200 301 302 3600s;",
+		"proxy_ignore_headers Cache-Control Expires Set-Cookie Vary;",
+		"add_header X-Cache-Status $upstream_cache_status;",
+		// add_header in the location stops the server-level HSTS header from being inherited
+		`add_header Strict-Transport-Security "max-age=31536000; includeSubDomains" always;`,
+	} {
+		if !strings.Contains(jsLocation, want) {
+			t.Errorf("cache location missing %q:\n%s", want, jsLocation)
+		}
+	}
+
+	rootLocation := conf[strings.Index(conf, "location / {"):]
+	if !strings.Contains(rootLocation, "proxy_cache off;") || strings.Contains(rootLocation, "add_header") {
+		t.Errorf("unexpected root location:\n%s", rootLocation)
+	}
+}
+
+func TestRenderCacheZone(t *testing.T) {
+	renderer, err := NewRenderer(&config.DirConfig{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	stagingDir := t.TempDir()
+	os.MkdirAll(filepath.Join(stagingDir, "upstreams"), 0755)
+	data := &CacheZoneData{Path: "/var/cache/nginx/cmdb", Levels: "1:2", Zone: "cmdb_cache", Size: "64m", MaxSize: "10g", Inactive: "7d"}
+	if err := renderer.RenderCacheZone(stagingDir, data); err != nil {
+		t.Fatal(err)
+	}
+
+	content, err := os.ReadFile(filepath.Join(stagingDir, "upstreams", "cache_zone.conf"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "proxy_cache_path /var/cache/nginx/cmdb levels=1:2 keys_zone=cmdb_cache:64m max_size=10g inactive=7d use_temp_path=off;"
+	if !strings.Contains(string(content), want) {
+		t.Fatalf("cache zone file = %s, want %s", content, want)
+	}
+}
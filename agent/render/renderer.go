@@ -58,6 +58,7 @@ type ServerData struct {
 	Domains     []DomainData
 	Origin      OriginData
 	HTTPS       HTTPSData
+	Cache       CacheData
 	CertPath    string
 	KeyPath     string
 	GeneratedAt string
@@ -75,6 +76,12 @@ type OriginData struct {
 	RedirectURL        string
 	RedirectStatusCode int
 	UpstreamName       string
+	Protocol           string // http|https
+	HostHeader         string // empty means $host
+	SNI                bool
+	SNIName            string // empty means the Host header
+	Verify             bool
+	TrustedCertPath    string
 }
 
 // HTTPSData holds data for HTTPS configuration
@@ -84,6 +91,39 @@ type HTTPSData struct {
 	HSTS          bool
 }
 
+// CacheZoneData holds data for the proxy_cache_path declaration
+type CacheZoneData struct {
+	Path        string
+	Levels      string
+	Zone        string
+	Size        string
+	MaxSize     string
+	Inactive    string
+	GeneratedAt string
+}
+
+// RenderCacheZone renders the proxy_cache_path declaration.
+// It goes next to the upstreams, which nginx.conf includes at http level.
+func (r *Renderer) RenderCacheZone(stagingDir string, data *CacheZoneData) error {
+	// Set generated time
+	data.GeneratedAt = time.Now().Format(time.RFC3339)
+
+	// Render template
+	var buf bytes.Buffer
+	if err := r.templates.ExecuteTemplate(&buf, "cache_zone.tmpl", data); err != nil {
+		return fmt.Errorf("failed to execute cache zone template: %w", err)
+	}
+
+	// Write to file
+	filepath := filepath.Join(stagingDir, "upstreams", "cache_zone.conf")
+
+	if err := os.WriteFile(filepath, buf.Bytes(), 0644); err != nil {
+		return fmt.Errorf("failed to write cache zone file: %w", err)
+	}
+
+	return nil
+}
+
 // RenderUpstream renders upstream configuration to a file
 func (r *Renderer) RenderUpstream(stagingDir string, websiteID int, data *UpstreamData) error {
 	// Set generated time
@@ -146,3 +186,13 @@ func (r *Renderer) WriteCertificate(stagingDir string, certificateID int, certPe
 
 	return nil
 }
+
+// WriteOriginCA writes the CA certificate used to verify a website's origin
+func (r *Renderer) WriteOriginCA(stagingDir string, websiteID int, caPem string) error {
+	caPath := filepath.Join(stagingDir, "certs", fmt.Sprintf("origin_ca_%d.pem", websiteID))
+	if err := os.WriteFile(caPath, []byte(caPem), 0644); err != nil {
+		return fmt.Errorf("failed to write origin CA: %w", err)
+	}
+
+	return nil
+}
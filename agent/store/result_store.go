@@ -0,0 +1,248 @@
+package store
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// resultRecord is the on-disk format of a stored result
+type resultRecord struct {
+	RequestID string          `json:"requestId"`
+	StoredAt  time.Time       `json:"storedAt"`
+	Value     json.RawMessage `json:"value"`
+}
+
+// ResultStore is an on-disk idempotency store keyed by requestId.
+// Each entry is a JSON file; entries expire after ttl and the oldest
+// entries are removed once maxEntries is exceeded. The entries are indexed
+// in memory, so writes never have to list the directory.
+type ResultStore struct {
+	dir        string
+	ttl        time.Duration
+	maxEntries int
+	mu         sync.Mutex
+	now        func() time.Time
+
+	index map[string]time.Time // path -> stored at
+	order []storeEntry         // write order, may hold stale entries of overwritten or deleted paths
+}
+
+// NewResultStore creates a result store in dir and prunes expired entries
+func NewResultStore(dir string, ttl time.Duration, maxEntries int) (*ResultStore, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create result store directory: %w", err)
+	}
+
+	s := &ResultStore{
+		dir:        dir,
+		ttl:        ttl,
+		maxEntries: maxEntries,
+		now:        time.Now,
+		index:      make(map[string]time.Time),
+	}
+
+	if err := s.Prune(); err != nil {
+		return nil, err
+	}
+
+	return s, nil
+}
+
+// Get loads the stored value of requestID into v.
+// Returns false if there is no entry or the entry has expired.
+func (s *ResultStore) Get(requestID string, v interface{}) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	path := s.path(requestID)
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return false, nil
+		}
+		return false, fmt.Errorf("failed to read result: %w", err)
+	}
+
+	var record resultRecord
+	if err := json.Unmarshal(data, &record); err != nil || record.RequestID != requestID {
+		// Corrupted or foreign entry, drop it
+		s.remove(path)
+		return false, nil
+	}
+
+	if s.expired(record.StoredAt) {
+		s.remove(path)
+		return false, nil
+	}
+
+	if err := json.Unmarshal(record.Value, v); err != nil {
+		return false, fmt.Errorf("failed to parse stored result: %w", err)
+	}
+
+	return true, nil
+}
+
+// Put stores the value of requestID, replacing any existing entry
+func (s *ResultStore) Put(requestID string, v interface{}) error {
+	value, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Errorf("failed to marshal result: %w", err)
+	}
+
+	storedAt := s.now()
+	data, err := json.Marshal(resultRecord{
+		RequestID: requestID,
+		StoredAt:  storedAt,
+		Value:     value,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to marshal result record: %w", err)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	// Write to temp file then rename, so readers never see a partial file
+	path := s.path(requestID)
+	tempPath := path + ".tmp"
+	if err := os.WriteFile(tempPath, data, 0644); err != nil {
+		return fmt.Errorf("failed to write result: %w", err)
+	}
+	if err := os.Rename(tempPath, path); err != nil {
+		os.Remove(tempPath)
+		return fmt.Errorf("failed to rename result: %w", err)
+	}
+
+	s.index[path] = storedAt
+	s.order = append(s.order, storeEntry{path: path, modTime: storedAt})
+
+	s.enforceCap()
+	return nil
+}
+
+// Delete removes the entry of requestID
+func (s *ResultStore) Delete(requestID string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	path := s.path(requestID)
+	if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to delete result: %w", err)
+	}
+	delete(s.index, path)
+	return nil
+}
+
+// Prune removes expired entries, rebuilds the index from disk and enforces the size cap
+func (s *ResultStore) Prune() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	entries, err := s.list()
+	if err != nil {
+		return err
+	}
+
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].modTime.Before(entries[j].modTime)
+	})
+
+	s.index = make(map[string]time.Time, len(entries))
+	s.order = make([]storeEntry, 0, len(entries))
+
+	removed := 0
+	for _, entry := range entries {
+		if s.expired(entry.modTime) {
+			os.Remove(entry.path)
+			removed++
+			continue
+		}
+		s.index[entry.path] = entry.modTime
+		s.order = append(s.order, entry)
+	}
+	if removed > 0 {
+		log.Printf("[ResultStore] Pruned %d expired results", removed)
+	}
+
+	s.enforceCap()
+	return nil
+}
+
+type storeEntry struct {
+	path    string
+	modTime time.Time
+}
+
+// enforceCap removes the oldest entries above maxEntries (caller holds mu)
+func (s *ResultStore) enforceCap() {
+	if s.maxEntries > 0 {
+		for len(s.index) > s.maxEntries && len(s.order) > 0 {
+			entry := s.order[0]
+			s.order = s.order[1:]
+			// Skip stale entries, the path was rewritten or deleted since
+			if storedAt, ok := s.index[entry.path]; ok && storedAt.Equal(entry.modTime) {
+				s.remove(entry.path)
+			}
+		}
+	}
+
+	// Drop stale entries once they outnumber the live ones
+	if len(s.order) > 2*len(s.index)+64 {
+		order := make([]storeEntry, 0, len(s.index))
+		for _, entry := range s.order {
+			if storedAt, ok := s.index[entry.path]; ok && storedAt.Equal(entry.modTime) {
+				order = append(order, entry)
+			}
+		}
+		s.order = order
+	}
+}
+
+// remove deletes an entry file and its index entry (caller holds mu)
+func (s *ResultStore) remove(path string) {
+	os.Remove(path)
+	delete(s.index, path)
+}
+
+// list lists the stored entries (caller holds mu)
+func (s *ResultStore) list() ([]storeEntry, error) {
+	dirEntries, err := os.ReadDir(s.dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read result store directory: %w", err)
+	}
+
+	entries := make([]storeEntry, 0, len(dirEntries))
+	for _, dirEntry := range dirEntries {
+		if dirEntry.IsDir() || !strings.HasSuffix(dirEntry.Name(), ".json") {
+			continue
+		}
+		info, err := dirEntry.Info()
+		if err != nil {
+			continue
+		}
+		entries = append(entries, storeEntry{
+			path:    filepath.Join(s.dir, dirEntry.Name()),
+			modTime: info.ModTime(),
+		})
+	}
+	return entries, nil
+}
+
+func (s *ResultStore) expired(storedAt time.Time) bool {
+	return s.ttl > 0 && s.now().Sub(storedAt) > s.ttl
+}
+
+// path returns the file path of a requestId (hashed, requestId is client supplied)
+func (s *ResultStore) path(requestID string) string {
+	sum := sha256.Sum256([]byte(requestID))
+	return filepath.Join(s.dir, hex.EncodeToString(sum[:])+".json")
+}
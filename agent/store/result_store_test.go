@@ -0,0 +1,107 @@
+package store
+
+import (
+	"testing"
+	"time"
+)
+
+type testResult struct {
+	Status  string `json:"status"`
+	Message string `json:"message"`
+}
+
+func TestResultStore(t *testing.T) {
+	dir := t.TempDir()
+
+	s, err := NewResultStore(dir, time.Hour, 0)
+	if err != nil {
+		t.Fatalf("NewResultStore() failed: %v", err)
+	}
+
+	if err := s.Put("req-1", testResult{Status: "success", Message: "done"}); err != nil {
+		t.Fatalf("Put() failed: %v", err)
+	}
+
+	// Survives a restart
+	s, err = NewResultStore(dir, time.Hour, 0)
+	if err != nil {
+		t.Fatalf("NewResultStore() failed: %v", err)
+	}
+
+	var got testResult
+	ok, err := s.Get("req-1", &got)
+	if err != nil || !ok {
+		t.Fatalf("Get() = %v, %v; want stored result", ok, err)
+	}
+	if got.Status != "success" || got.Message != "done" {
+		t.Errorf("unexpected result: %+v", got)
+	}
+
+	if ok, _ := s.Get("req-2", &got); ok {
+		t.Error("unknown request should not be found")
+	}
+
+	// Expired after TTL
+	s.now = func() time.Time { return time.Now().Add(2 * time.Hour) }
+	if ok, _ := s.Get("req-1", &got); ok {
+		t.Error("expired result should not be found")
+	}
+}
+
+func TestResultStoreCap(t *testing.T) {
+	s, err := NewResultStore(t.TempDir(), time.Hour, 2)
+	if err != nil {
+		t.Fatalf("NewResultStore() failed: %v", err)
+	}
+
+	for _, id := range []string{"a", "b", "c"} {
+		if err := s.Put(id, testResult{Status: id}); err != nil {
+			t.Fatalf("Put() failed: %v", err)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	entries, err := s.list()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 2 {
+		t.Fatalf("expected 2 entries, got %d", len(entries))
+	}
+
+	var got testResult
+	if ok, _ := s.Get("a", &got); ok {
+		t.Error("oldest result should have been evicted")
+	}
+	if ok, _ := s.Get("c", &got); !ok {
+		t.Error("newest result should be kept")
+	}
+}
+
+func TestResultStoreCapAfterOverwrite(t *testing.T) {
+	s, err := NewResultStore(t.TempDir(), time.Hour, 2)
+	if err != nil {
+		t.Fatalf("NewResultStore() failed: %v", err)
+	}
+
+	// Rewriting "a" makes "b" the oldest entry
+	for _, id := range []string{"a", "b", "a", "c"} {
+		if err := s.Put(id, testResult{Status: id}); err != nil {
+			t.Fatalf("Put() failed: %v", err)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	var got testResult
+	if ok, _ := s.Get("b", &got); ok {
+		t.Error("oldest result should have been evicted")
+	}
+	for _, id := range []string{"a", "c"} {
+		if ok, _ := s.Get(id, &got); !ok {
+			t.Errorf("result %s should be kept", id)
+		}
+	}
+	if len(s.index) != 2 {
+		t.Errorf("expected 2 indexed entries, got %d", len(s.index))
+	}
+}
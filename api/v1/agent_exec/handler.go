@@ -1,7 +1,10 @@
 package agent_exec
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
+	"fmt"
 	"go_cmdb/internal/httpx"
 	"go_cmdb/internal/model"
 	"go_cmdb/internal/service"
@@ -16,21 +19,31 @@ import (
 // Handler handles agent execution requests
 type Handler struct {
 	db *gorm.DB
+	// insecureNodeHeader trusts X-Node-Id without client certificate (dev only)
+	insecureNodeHeader bool
 }
 
 // NewHandler creates a new agent execution handler
-func NewHandler(db *gorm.DB) *Handler {
-	return &Handler{db: db}
+func NewHandler(db *gorm.DB, insecureNodeHeader bool) *Handler {
+	return &Handler{
+		db:                 db,
+		insecureNodeHeader: insecureNodeHeader,
+	}
 }
 
-// extractNodeID extracts nodeId from mTLS client cert or X-Node-Id header
+// extractNodeID extracts nodeId from the verified mTLS client cert,
+// or from the X-Node-Id header when insecure mode is enabled
 func (h *Handler) extractNodeID(c *gin.Context) (int64, error) {
-	// TODO: Priority 1 - mTLS client cert parsing (if framework supports)
-	// if nodeID, ok := extractFromClientCert(c); ok {
-	//     return nodeID, nil
-	// }
+	// Priority 1 - mTLS client cert (verified by the listener against the agent CA)
+	if c.Request.TLS != nil && len(c.Request.TLS.PeerCertificates) > 0 {
+		return h.nodeIDFromCert(c.Request.TLS.PeerCertificates[0].Raw)
+	}
+
+	// Priority 2 - X-Node-Id header (dev/testing only)
+	if !h.insecureNodeHeader {
+		return 0, httpx.ErrUnauthorized("client certificate required")
+	}
 
-	// Priority 2 - X-Node-Id header (for testing)
 	nodeIDStr := c.GetHeader("X-Node-Id")
 	if nodeIDStr == "" {
 		return 0, httpx.ErrParamInvalid("missing X-Node-Id header")
@@ -44,6 +57,26 @@ func (h *Handler) extractNodeID(c *gin.Context) (int64, error) {
 	return nodeID, nil
 }
 
+// nodeIDFromCert resolves nodeId from the client certificate fingerprint (SHA256 of DER)
+func (h *Handler) nodeIDFromCert(der []byte) (int64, error) {
+	hash := sha256.Sum256(der)
+	fingerprint := hex.EncodeToString(hash[:])
+
+	var identity model.AgentIdentity
+	if err := h.db.Where("fingerprint = ?", fingerprint).First(&identity).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return 0, httpx.ErrUnauthorized("unknown agent certificate")
+		}
+		return 0, httpx.ErrDatabaseError("failed to query agent identity", err)
+	}
+
+	if identity.Status != model.AgentIdentityStatusActive {
+		return 0, httpx.ErrForbidden(fmt.Sprintf("agent identity is %s", identity.Status))
+	}
+
+	return int64(identity.NodeID), nil
+}
+
 // mapStatusToAPI maps database status to API status
 func mapStatusToAPI(dbStatus string) string {
 	if dbStatus == "success" {
@@ -60,6 +93,13 @@ func mapStatusToDB(apiStatus string) string {
 	return apiStatus
 }
 
+// claimableTasks restricts the query to tasks the agent may claim:
+// pending tasks and retrying tasks whose backoff has elapsed
+func claimableTasks(query *gorm.DB) *gorm.DB {
+	return query.Where("(status = ? OR (status = ? AND (next_retry_at IS NULL OR next_retry_at <= NOW(3))))",
+		model.TaskStatusPending, model.TaskStatusRetrying)
+}
+
 // Pull handles GET /api/v1/agent/tasks/pull
 func (h *Handler) Pull(c *gin.Context) {
 	// Extract nodeId
@@ -90,11 +130,10 @@ func (h *Handler) Pull(c *gin.Context) {
 		// 不阻断 pull，继续返回已有任务
 	}
 
-	// Step 1: SELECT可领取任务id列表
+	// Step 1: SELECT可领取任务id列表（pending，或退避时间已到的 retrying）
 	var taskIDs []int64
-	err = h.db.Model(&model.AgentTask{}).
+	err = claimableTasks(h.db.Model(&model.AgentTask{})).
 		Where("node_id = ?", nodeID).
-		Where("status IN ?", []string{"pending", "retrying"}).
 		Order("id ASC").
 		Limit(limit).
 		Pluck("id", &taskIDs).Error
@@ -110,13 +149,14 @@ func (h *Handler) Pull(c *gin.Context) {
 		return
 	}
 
-	// Step 2: UPDATE领取任务（原子更新）
-	result := h.db.Model(&model.AgentTask{}).
+	// Step 2: UPDATE领取任务（原子更新），每次领取计一次执行
+	result := claimableTasks(h.db.Model(&model.AgentTask{})).
 		Where("id IN ?", taskIDs).
-		Where("status IN ?", []string{"pending", "retrying"}).
 		Updates(map[string]interface{}{
-			"status":     "running",
-			"updated_at": gorm.Expr("NOW()"),
+			"status":        model.TaskStatusRunning,
+			"attempts":      gorm.Expr("attempts + 1"),
+			"next_retry_at": nil,
+			"updated_at":    gorm.Expr("NOW()"),
 		})
 
 	if result.Error != nil {
@@ -149,6 +189,7 @@ func (h *Handler) Pull(c *gin.Context) {
 		Payload     map[string]interface{} `json:"payload"`
 		Status      string                 `json:"status"`
 		LastError   string                 `json:"lastError,omitempty"`
+		Attempts    int                    `json:"attempts"`
 		CreatedAt   time.Time              `json:"createdAt"`
 		UpdatedAt   time.Time              `json:"updatedAt"`
 	}
@@ -170,6 +211,7 @@ func (h *Handler) Pull(c *gin.Context) {
 			Payload:   payloadObj,
 			Status:    mapStatusToAPI(tasks[i].Status),
 			LastError: tasks[i].LastError,
+			Attempts:  tasks[i].Attempts,
 			CreatedAt: tasks[i].CreatedAt,
 			UpdatedAt: tasks[i].UpdatedAt,
 		})
@@ -202,6 +244,7 @@ func (h *Handler) UpdateStatus(c *gin.Context) {
 	// Parse request body
 	var req struct {
 		ID        int64  `json:"id" binding:"required"`
+		Attempts  int    `json:"attempts"` // 0 from agents that predate attempt tracking
 		Status    string `json:"status" binding:"required"`
 		LastError string `json:"lastError"`
 	}
@@ -226,8 +269,12 @@ func (h *Handler) UpdateStatus(c *gin.Context) {
 	}
 
 	// Call the service layer to handle the update and propagate to release_task
-	if err := service.UpdateTaskStatus(uint(nodeID), uint(req.ID), req.Status, req.LastError); err != nil {
-		httpx.FailErr(c, httpx.ErrDatabaseError("failed to update task status", err))
+	if err := service.UpdateTaskStatus(uint(nodeID), uint(req.ID), req.Attempts, req.Status, req.LastError); err != nil {
+		if appErr, ok := err.(*httpx.AppError); ok {
+			httpx.FailErr(c, appErr)
+		} else {
+			httpx.FailErr(c, httpx.ErrDatabaseError("failed to update task status", err))
+		}
 		return
 	}
 
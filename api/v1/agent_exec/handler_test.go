@@ -0,0 +1,82 @@
+package agent_exec
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"testing"
+
+	"go_cmdb/internal/httpx"
+
+	"github.com/glebarez/sqlite"
+	"gorm.io/gorm"
+)
+
+func newTestHandler(t *testing.T) *Handler {
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// MySQL enum columns do not migrate on sqlite, create the table by hand
+	if err := db.Exec(`CREATE TABLE agent_identities (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		node_id INTEGER NOT NULL,
+		fingerprint TEXT NOT NULL,
+		cert_pem TEXT NOT NULL DEFAULT '',
+		key_pem TEXT NOT NULL DEFAULT '',
+		status TEXT NOT NULL DEFAULT 'active',
+		issued_at DATETIME, revoked_at DATETIME, created_at DATETIME, updated_at DATETIME
+	)`).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	for nodeID, status := range map[int]string{7: "active", 8: "revoked"} {
+		if err := db.Exec("INSERT INTO agent_identities (node_id, fingerprint, status) VALUES (?, ?, ?)",
+			nodeID, fingerprintOf(testCert(nodeID)), status).Error; err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	return NewHandler(db, false)
+}
+
+func testCert(nodeID int) []byte {
+	return []byte{0x30, byte(nodeID)}
+}
+
+func fingerprintOf(der []byte) string {
+	hash := sha256.Sum256(der)
+	return hex.EncodeToString(hash[:])
+}
+
+func TestNodeIDFromCert(t *testing.T) {
+	h := newTestHandler(t)
+
+	tests := []struct {
+		name       string
+		der        []byte
+		wantNodeID int64
+		wantStatus int
+	}{
+		{name: "active", der: testCert(7), wantNodeID: 7},
+		{name: "revoked", der: testCert(8), wantStatus: http.StatusForbidden},
+		{name: "unknown", der: testCert(9), wantStatus: http.StatusUnauthorized},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			nodeID, err := h.nodeIDFromCert(tt.der)
+			if tt.wantStatus == 0 {
+				if err != nil || nodeID != tt.wantNodeID {
+					t.Fatalf("nodeIDFromCert() = %d, %v; want %d", nodeID, err, tt.wantNodeID)
+				}
+				return
+			}
+
+			appErr, ok := err.(*httpx.AppError)
+			if !ok || appErr.HTTPStatus != tt.wantStatus {
+				t.Fatalf("nodeIDFromCert() error = %v; want HTTP %d", err, tt.wantStatus)
+			}
+		})
+	}
+}
@@ -43,6 +43,7 @@ func (h *Handler) List(c *gin.Context) {
 	taskType := c.Query("type")
 	status := c.Query("status")
 	releaseTaskIDStr := c.Query("releaseTaskId")
+	deadLetter := c.Query("deadLetter")
 
 	if page < 1 {
 		page = 1
@@ -72,6 +73,18 @@ func (h *Handler) List(c *gin.Context) {
 		query = query.Where("status = ?", status)
 	}
 
+	// Filter by dead-letter: true = only dead tasks, false = exclude dead tasks
+	switch deadLetter {
+	case "":
+	case "true", "1":
+		query = query.Where("status = ?", model.TaskStatusDead)
+	case "false", "0":
+		query = query.Where("status <> ?", model.TaskStatusDead)
+	default:
+		httpx.FailErr(c, httpx.ErrParamInvalid("deadLetter must be true or false"))
+		return
+	}
+
 	// Filter by releaseTaskId (via payload JSON)
 	if releaseTaskIDStr != "" {
 		releaseTaskID, err := strconv.ParseInt(releaseTaskIDStr, 10, 64)
@@ -144,7 +157,7 @@ func (h *Handler) Create(c *gin.Context) {
 	}
 
 	// Validate type
-	if req.Type != model.TaskTypePurgeCache && req.Type != model.TaskTypeApplyConfig && req.Type != model.TaskTypeReload {
+	if req.Type != model.TaskTypePurgeCache && req.Type != model.TaskTypeApplyConfig && req.Type != model.TaskTypeReload && req.Type != model.TaskTypeRollbackConfig {
 		httpx.FailErr(c, httpx.ErrParamInvalid("invalid task type"))
 		return
 	}
@@ -212,15 +225,17 @@ func (h *Handler) Retry(c *gin.Context) {
 		return
 	}
 
-	// Check if task is failed
-	if task.Status != model.TaskStatusFailed {
-		httpx.FailErr(c, httpx.ErrParamInvalid("only failed tasks can be retried"))
+	// Check if task is failed, waiting for retry or dead-lettered
+	if task.Status != model.TaskStatusFailed && task.Status != model.TaskStatusRetrying && task.Status != model.TaskStatusDead {
+		httpx.FailErr(c, httpx.ErrParamInvalid("only failed, retrying or dead tasks can be retried"))
 		return
 	}
 
-	// Reset task status to pending
+	// Reset task status to pending, a manual retry starts a fresh retry budget
 	task.Status = model.TaskStatusPending
 	task.LastError = ""
+	task.Attempts = 0
+	task.NextRetryAt = nil
 	if err := h.db.Save(&task).Error; err != nil {
 		httpx.FailErr(c, httpx.ErrDatabaseError("failed to update task", err))
 		return
@@ -236,3 +251,113 @@ func (h *Handler) Retry(c *gin.Context) {
 	// Return response
 	httpx.OK(c, task)
 }
+
+// Rollback handles POST /api/v1/agent-tasks/rollback
+// Rolls back node config to an earlier version, for a single node or all nodes of a release
+func (h *Handler) Rollback(c *gin.Context) {
+	// Parse request body
+	var req struct {
+		NodeID    int   `json:"nodeId"`
+		ReleaseID int64 `json:"releaseId"`
+		Version   int64 `json:"version"` // 0 = previous successful version on each node
+	}
+
+	if err := c.ShouldBindJSON(&req); err != nil {
+		httpx.FailErr(c, httpx.ErrParamInvalid("invalid request body"))
+		return
+	}
+
+	if (req.NodeID > 0) == (req.ReleaseID > 0) {
+		httpx.FailErr(c, httpx.ErrParamInvalid("exactly one of nodeId or releaseId is required"))
+		return
+	}
+
+	payload := map[string]interface{}{}
+	if req.Version > 0 {
+		payload["version"] = req.Version
+	}
+
+	// Resolve target nodes
+	var nodeIDs []int
+	if req.NodeID > 0 {
+		var node model.Node
+		if err := h.db.First(&node, req.NodeID).Error; err != nil {
+			if err == gorm.ErrRecordNotFound {
+				httpx.FailErr(c, httpx.ErrNotFound("node not found"))
+				return
+			}
+			httpx.FailErr(c, httpx.ErrDatabaseError("failed to query node", err))
+			return
+		}
+		nodeIDs = []int{req.NodeID}
+	} else {
+		var release model.ReleaseTask
+		if err := h.db.First(&release, req.ReleaseID).Error; err != nil {
+			if err == gorm.ErrRecordNotFound {
+				httpx.FailErr(c, httpx.ErrNotFound("release not found"))
+				return
+			}
+			httpx.FailErr(c, httpx.ErrDatabaseError("failed to query release", err))
+			return
+		}
+
+		// Only nodes that received the release need to be rolled back
+		if err := h.db.Model(&model.ReleaseTaskNode{}).
+			Where("release_task_id = ? AND status IN ?", release.ID, []string{
+				string(model.ReleaseTaskNodeStatusRunning),
+				string(model.ReleaseTaskNodeStatusSuccess),
+				string(model.ReleaseTaskNodeStatusFailed),
+			}).
+			Order("node_id ASC").
+			Pluck("node_id", &nodeIDs).Error; err != nil {
+			httpx.FailErr(c, httpx.ErrDatabaseError("failed to query release nodes", err))
+			return
+		}
+		if len(nodeIDs) == 0 {
+			httpx.FailErr(c, httpx.ErrStateConflict("release has no dispatched nodes"))
+			return
+		}
+
+		payload["fromVersion"] = release.Version
+		payload["releaseId"] = release.ID
+	}
+
+	payloadJSON, err := json.Marshal(payload)
+	if err != nil {
+		httpx.FailErr(c, httpx.ErrInternalError("failed to marshal payload", err))
+		return
+	}
+
+	// Create one rollback_config task per node
+	tasks := make([]model.AgentTask, 0, len(nodeIDs))
+	for _, nodeID := range nodeIDs {
+		task := model.AgentTask{
+			NodeID:    uint(nodeID),
+			Type:      model.TaskTypeRollbackConfig,
+			Payload:   string(payloadJSON),
+			Status:    model.TaskStatusPending,
+			RequestID: uuid.New().String(),
+		}
+		if err := h.db.Create(&task).Error; err != nil {
+			httpx.FailErr(c, httpx.ErrDatabaseError("failed to create task", err))
+			return
+		}
+		tasks = append(tasks, task)
+	}
+
+	// Dispatch tasks asynchronously
+	for i := range tasks {
+		task := tasks[i]
+		go func() {
+			if err := h.dispatcher.DispatchTask(&task); err != nil {
+				log.Printf("[AgentTask] Failed to dispatch rollback task %d to node %d: %v", task.ID, task.NodeID, err)
+			}
+		}()
+	}
+
+	// Return response
+	httpx.OK(c, gin.H{
+		"items": tasks,
+		"total": len(tasks),
+	})
+}
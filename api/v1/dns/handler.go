@@ -173,7 +173,7 @@ func (h *Handler) DeleteRecord(c *gin.Context) {
 			successIDs = append(successIDs, int(record.ID))
 		} else {
 			// Has provider_record_id: delete from Cloudflare first
-			deleted, err := h.service.DeleteRecordFromCloudflare(int(record.ID))
+			deleted, err := h.service.DeleteRecordFromProvider(int(record.ID))
 			if deleted {
 				// Cloudflare delete success or not found
 				successIDs = append(successIDs, int(record.ID))
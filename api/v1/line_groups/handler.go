@@ -6,7 +6,7 @@ import (
 	"fmt"
 	"log"
 
-	"go_cmdb/internal/dns/providers/cloudflare"
+	"go_cmdb/internal/dns"
 	"go_cmdb/internal/httpx"
 	"go_cmdb/internal/model"
 
@@ -34,17 +34,19 @@ type ListResponse struct {
 
 // CreateRequest represents create line group request
 type CreateRequest struct {
-	Name        string `json:"name" binding:"required"`
-	DomainID    int    `json:"domainId" binding:"required"`
-	NodeGroupID int    `json:"nodeGroupId" binding:"required"`
+	Name                string `json:"name" binding:"required"`
+	DomainID            int    `json:"domainId" binding:"required"`
+	NodeGroupID         int    `json:"nodeGroupId" binding:"required"`
+	MaintenanceWindowID *int64 `json:"maintenanceWindowId"`
 }
 
 // UpdateRequest represents update line group request
 type UpdateRequest struct {
-	ID          int     `json:"id" binding:"required"`
-	Name        *string `json:"name"`
-	Status      *string `json:"status"`
-	NodeGroupID *int    `json:"nodeGroupId"`
+	ID                  int     `json:"id" binding:"required"`
+	Name                *string `json:"name"`
+	Status              *string `json:"status"`
+	NodeGroupID         *int    `json:"nodeGroupId"`
+	MaintenanceWindowID *int64  `json:"maintenanceWindowId"` // 0 detaches the window
 }
 
 // DeleteRequest represents delete line groups request
@@ -62,6 +64,18 @@ func NewHandler(db *gorm.DB) *Handler {
 	return &Handler{db: db}
 }
 
+// checkMaintenanceWindow checks that the maintenance window exists
+func (h *Handler) checkMaintenanceWindow(db *gorm.DB, id int64) *httpx.AppError {
+	var count int64
+	if err := db.Model(&model.MaintenanceWindow{}).Where("id = ?", id).Count(&count).Error; err != nil {
+		return httpx.ErrDatabaseError("failed to find maintenance window", err)
+	}
+	if count == 0 {
+		return httpx.ErrNotFound("maintenance window not found")
+	}
+	return nil
+}
+
 // generateCNAMEPrefix generates a random CNAME prefix
 func generateCNAMEPrefix() string {
 	bytes := make([]byte, 8)
@@ -105,7 +119,7 @@ func (h *Handler) markDNSRecordsForDeletion(tx *gorm.DB, lineGroupID int, reason
 	return nil
 }
 
-// deleteDNSRecordsSync synchronously deletes DNS records from Cloudflare and local database
+// deleteDNSRecordsSync synchronously deletes DNS records from the DNS provider and local database
 func (h *Handler) deleteDNSRecordsSync(tx *gorm.DB, lineGroupID int) error {
 	// Step 1: Get all CNAME records for this line group
 	var records []model.DomainDNSRecord
@@ -118,7 +132,7 @@ func (h *Handler) deleteDNSRecordsSync(tx *gorm.DB, lineGroupID int) error {
 		return nil // No records to delete
 	}
 
-	// Step 2: For each record, delete from Cloudflare then from database
+	// Step 2: For each record, delete from the DNS provider then from database
 	for _, record := range records {
 		// Get domain provider info
 		var provider model.DomainDNSProvider
@@ -136,14 +150,16 @@ func (h *Handler) deleteDNSRecordsSync(tx *gorm.DB, lineGroupID int) error {
 			continue
 		}
 
-		// Delete from Cloudflare if provider_record_id exists
+		// Delete from the DNS provider if provider_record_id exists
 		if record.ProviderRecordID != "" {
-			cfProvider := cloudflare.NewCloudflareProvider(apiKey.Account, apiKey.APIToken)
-			err := cfProvider.DeleteRecord(provider.ProviderZoneID, record.ProviderRecordID)
-			if err != nil && err != cloudflare.ErrNotFound {
-				log.Printf("[Line Group] Failed to delete record %d from Cloudflare: %v, deleting local record anyway\n", record.ID, err)
+			dnsProvider, err := dns.NewProvider(provider.Provider, &apiKey)
+			if err == nil {
+				err = dnsProvider.DeleteRecord(provider.ProviderZoneID, record.ProviderRecordID)
+			}
+			if err != nil && !dns.IsNotFound(err) {
+				log.Printf("[Line Group] Failed to delete record %d from %s: %v, deleting local record anyway\n", record.ID, provider.Provider, err)
 			} else {
-				log.Printf("[Line Group] Deleted record %d from Cloudflare\n", record.ID)
+				log.Printf("[Line Group] Deleted record %d from %s\n", record.ID, provider.Provider)
 			}
 		}
 
@@ -230,17 +246,18 @@ func (h *Handler) List(c *gin.Context) {
 		}
 		
 		items[i] = LineGroupItemDTO{
-			ID:            int(lg.ID),
-			Name:          lg.Name,
-			DomainID:      int(lg.DomainID),
-			DomainName:    domainName,
-			NodeGroupID:   int(lg.NodeGroupID),
-			NodeGroupName: nodeGroupName,
-			CNAMEPrefix:   lg.CNAMEPrefix,
-			CNAME:         lg.CNAMEPrefix + "." + domainName,
-			Status:        lg.Status,
-			CreatedAt:     lg.CreatedAt.Format("2006-01-02T15:04:05-07:00"),
-			UpdatedAt:     lg.UpdatedAt.Format("2006-01-02T15:04:05-07:00"),
+			ID:                  int(lg.ID),
+			Name:                lg.Name,
+			DomainID:            int(lg.DomainID),
+			DomainName:          domainName,
+			NodeGroupID:         int(lg.NodeGroupID),
+			NodeGroupName:       nodeGroupName,
+			CNAMEPrefix:         lg.CNAMEPrefix,
+			CNAME:               lg.CNAMEPrefix + "." + domainName,
+			Status:              lg.Status,
+			MaintenanceWindowID: lg.MaintenanceWindowID,
+			CreatedAt:           lg.CreatedAt.Format("2006-01-02T15:04:05-07:00"),
+			UpdatedAt:           lg.UpdatedAt.Format("2006-01-02T15:04:05-07:00"),
 		}
 	}
 
@@ -310,6 +327,14 @@ func (h *Handler) Create(c *gin.Context) {
 		return
 	}
 
+	// Check maintenance window exists
+	if req.MaintenanceWindowID != nil && *req.MaintenanceWindowID > 0 {
+		if err := h.checkMaintenanceWindow(h.db, *req.MaintenanceWindowID); err != nil {
+			httpx.FailErr(c, err)
+			return
+		}
+	}
+
 	// Generate CNAME prefix
 	var cnamePrefix string
 	for {
@@ -339,6 +364,9 @@ func (h *Handler) Create(c *gin.Context) {
 		CNAMEPrefix: cnamePrefix,
 		Status:      model.LineGroupStatusActive,
 	}
+	if req.MaintenanceWindowID != nil && *req.MaintenanceWindowID > 0 {
+		lineGroup.MaintenanceWindowID = req.MaintenanceWindowID
+	}
 
 	if err := tx.Create(&lineGroup).Error; err != nil {
 		tx.Rollback()
@@ -376,17 +404,18 @@ func (h *Handler) Create(c *gin.Context) {
 	}
 	
 	item := LineGroupItemDTO{
-		ID:            int(lineGroup.ID),
-		Name:          lineGroup.Name,
-		DomainID:      int(lineGroup.DomainID),
-		DomainName:    domainName,
-		NodeGroupID:   int(lineGroup.NodeGroupID),
-		NodeGroupName: nodeGroupName,
-		CNAMEPrefix:   lineGroup.CNAMEPrefix,
-		CNAME:         lineGroup.CNAMEPrefix + "." + domainName,
-		Status:        lineGroup.Status,
-		CreatedAt:     lineGroup.CreatedAt.Format("2006-01-02T15:04:05-07:00"),
-		UpdatedAt:     lineGroup.UpdatedAt.Format("2006-01-02T15:04:05-07:00"),
+		ID:                  int(lineGroup.ID),
+		Name:                lineGroup.Name,
+		DomainID:            int(lineGroup.DomainID),
+		DomainName:          domainName,
+		NodeGroupID:         int(lineGroup.NodeGroupID),
+		NodeGroupName:       nodeGroupName,
+		CNAMEPrefix:         lineGroup.CNAMEPrefix,
+		CNAME:               lineGroup.CNAMEPrefix + "." + domainName,
+		Status:              lineGroup.Status,
+		MaintenanceWindowID: lineGroup.MaintenanceWindowID,
+		CreatedAt:           lineGroup.CreatedAt.Format("2006-01-02T15:04:05-07:00"),
+		UpdatedAt:           lineGroup.UpdatedAt.Format("2006-01-02T15:04:05-07:00"),
 	}
 
 	httpx.OK(c, gin.H{"item": item})
@@ -438,6 +467,19 @@ func (h *Handler) Update(c *gin.Context) {
 		updates["status"] = *req.Status
 	}
 
+	if req.MaintenanceWindowID != nil {
+		if *req.MaintenanceWindowID > 0 {
+			if err := h.checkMaintenanceWindow(tx, *req.MaintenanceWindowID); err != nil {
+				tx.Rollback()
+				httpx.FailErr(c, err)
+				return
+			}
+			updates["maintenance_window_id"] = *req.MaintenanceWindowID
+		} else {
+			updates["maintenance_window_id"] = nil
+		}
+	}
+
 		if req.NodeGroupID != nil {
 		// Check node group exists
 		var nodeGroup model.NodeGroup
@@ -527,17 +569,18 @@ func (h *Handler) Update(c *gin.Context) {
 	}
 	
 	item := LineGroupItemDTO{
-		ID:            int(lineGroup.ID),
-		Name:          lineGroup.Name,
-		DomainID:      int(lineGroup.DomainID),
-		DomainName:    domainName,
-		NodeGroupID:   int(lineGroup.NodeGroupID),
-		NodeGroupName: nodeGroupName,
-		CNAMEPrefix:   lineGroup.CNAMEPrefix,
-		CNAME:         lineGroup.CNAMEPrefix + "." + domainName,
-		Status:        lineGroup.Status,
-		CreatedAt:     lineGroup.CreatedAt.Format("2006-01-02T15:04:05-07:00"),
-		UpdatedAt:     lineGroup.UpdatedAt.Format("2006-01-02T15:04:05-07:00"),
+		ID:                  int(lineGroup.ID),
+		Name:                lineGroup.Name,
+		DomainID:            int(lineGroup.DomainID),
+		DomainName:          domainName,
+		NodeGroupID:         int(lineGroup.NodeGroupID),
+		NodeGroupName:       nodeGroupName,
+		CNAMEPrefix:         lineGroup.CNAMEPrefix,
+		CNAME:               lineGroup.CNAMEPrefix + "." + domainName,
+		Status:              lineGroup.Status,
+		MaintenanceWindowID: lineGroup.MaintenanceWindowID,
+		CreatedAt:           lineGroup.CreatedAt.Format("2006-01-02T15:04:05-07:00"),
+		UpdatedAt:           lineGroup.UpdatedAt.Format("2006-01-02T15:04:05-07:00"),
 	}
 
 	httpx.OK(c, gin.H{"item": item})
@@ -0,0 +1,303 @@
+package maintenance_windows
+
+import (
+	"time"
+
+	"go_cmdb/internal/httpx"
+	"go_cmdb/internal/maintenance"
+	"go_cmdb/internal/model"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// ListRequest represents list maintenance windows request
+type ListRequest struct {
+	Page     int    `form:"page"`
+	PageSize int    `form:"pageSize"`
+	Name     string `form:"name"`
+}
+
+// ListResponse represents list maintenance windows response
+type ListResponse struct {
+	Items    []ItemDTO `json:"items"`
+	Total    int64     `json:"total"`
+	Page     int       `json:"page"`
+	PageSize int       `json:"pageSize"`
+}
+
+// ItemDTO represents a maintenance window in list or detail response
+type ItemDTO struct {
+	ID              int64  `json:"id"`
+	Name            string `json:"name"`
+	Schedule        string `json:"schedule"`
+	DurationMinutes int    `json:"durationMinutes"`
+	Timezone        string `json:"timezone"`
+	Enabled         bool   `json:"enabled"`
+	Description     string `json:"description"`
+	IsOpen          bool   `json:"isOpen"`         // Computed: whether the window is open now
+	LineGroupCount  int64  `json:"lineGroupCount"` // Line groups attached to this window
+	CreatedAt       string `json:"createdAt"`
+	UpdatedAt       string `json:"updatedAt"`
+}
+
+// CreateRequest represents create maintenance window request
+type CreateRequest struct {
+	Name            string `json:"name" binding:"required,max=128"`
+	Schedule        string `json:"schedule" binding:"required"`
+	DurationMinutes int    `json:"durationMinutes" binding:"required"`
+	Timezone        string `json:"timezone"`
+	Enabled         *bool  `json:"enabled"`
+	Description     string `json:"description" binding:"max=255"`
+}
+
+// UpdateRequest represents update maintenance window request
+type UpdateRequest struct {
+	ID              int64   `json:"id" binding:"required"`
+	Name            *string `json:"name" binding:"omitempty,max=128"`
+	Schedule        *string `json:"schedule"`
+	DurationMinutes *int    `json:"durationMinutes"`
+	Timezone        *string `json:"timezone"`
+	Enabled         *bool   `json:"enabled"`
+	Description     *string `json:"description" binding:"omitempty,max=255"`
+}
+
+// DeleteRequest represents delete maintenance windows request
+type DeleteRequest struct {
+	IDs []int64 `json:"ids" binding:"required,min=1"`
+}
+
+// Handler handles maintenance windows API
+type Handler struct {
+	db *gorm.DB
+}
+
+// NewHandler creates a new maintenance windows handler
+func NewHandler(db *gorm.DB) *Handler {
+	return &Handler{db: db}
+}
+
+// List handles GET /api/v1/maintenance-windows
+func (h *Handler) List(c *gin.Context) {
+	var req ListRequest
+	if err := c.ShouldBindQuery(&req); err != nil {
+		httpx.FailErr(c, httpx.ErrParamInvalid(err.Error()))
+		return
+	}
+
+	if req.Page < 1 {
+		req.Page = 1
+	}
+	if req.PageSize < 1 {
+		req.PageSize = 15
+	}
+
+	query := h.db.Model(&model.MaintenanceWindow{})
+	if req.Name != "" {
+		query = query.Where("name LIKE ?", "%"+req.Name+"%")
+	}
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		httpx.FailErr(c, httpx.ErrDatabaseError("failed to count maintenance windows", err))
+		return
+	}
+
+	var windows []model.MaintenanceWindow
+	offset := (req.Page - 1) * req.PageSize
+	if err := query.Offset(offset).Limit(req.PageSize).Order("id DESC").Find(&windows).Error; err != nil {
+		httpx.FailErr(c, httpx.ErrDatabaseError("failed to fetch maintenance windows", err))
+		return
+	}
+
+	items := make([]ItemDTO, 0, len(windows))
+	for i := range windows {
+		item, err := h.toDTO(&windows[i])
+		if err != nil {
+			httpx.FailErr(c, httpx.ErrDatabaseError("failed to count line groups", err))
+			return
+		}
+		items = append(items, item)
+	}
+
+	httpx.OK(c, ListResponse{
+		Items:    items,
+		Total:    total,
+		Page:     req.Page,
+		PageSize: req.PageSize,
+	})
+}
+
+// Create handles POST /api/v1/maintenance-windows/create
+func (h *Handler) Create(c *gin.Context) {
+	var req CreateRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		httpx.FailErr(c, httpx.ErrParamMissing(err.Error()))
+		return
+	}
+
+	if err := maintenance.Validate(req.Schedule, req.DurationMinutes, req.Timezone); err != nil {
+		httpx.FailErr(c, httpx.ErrParamInvalid(err.Error()))
+		return
+	}
+
+	var count int64
+	if err := h.db.Model(&model.MaintenanceWindow{}).Where("name = ?", req.Name).Count(&count).Error; err != nil {
+		httpx.FailErr(c, httpx.ErrDatabaseError("failed to check name uniqueness", err))
+		return
+	}
+	if count > 0 {
+		httpx.FailErr(c, httpx.ErrAlreadyExists("maintenance window name already exists"))
+		return
+	}
+
+	window := model.MaintenanceWindow{
+		Name:            req.Name,
+		Schedule:        req.Schedule,
+		DurationMinutes: req.DurationMinutes,
+		Timezone:        req.Timezone,
+		Enabled:         req.Enabled == nil || *req.Enabled,
+		Description:     req.Description,
+	}
+	if err := h.db.Create(&window).Error; err != nil {
+		httpx.FailErr(c, httpx.ErrDatabaseError("failed to create maintenance window", err))
+		return
+	}
+
+	item, err := h.toDTO(&window)
+	if err != nil {
+		httpx.FailErr(c, httpx.ErrDatabaseError("failed to count line groups", err))
+		return
+	}
+	httpx.OK(c, gin.H{"item": item})
+}
+
+// Update handles POST /api/v1/maintenance-windows/update
+func (h *Handler) Update(c *gin.Context) {
+	var req UpdateRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		httpx.FailErr(c, httpx.ErrParamMissing(err.Error()))
+		return
+	}
+
+	var window model.MaintenanceWindow
+	if err := h.db.First(&window, req.ID).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			httpx.FailErr(c, httpx.ErrNotFound("maintenance window not found"))
+			return
+		}
+		httpx.FailErr(c, httpx.ErrDatabaseError("failed to find maintenance window", err))
+		return
+	}
+
+	updates := make(map[string]interface{})
+
+	if req.Name != nil {
+		var count int64
+		if err := h.db.Model(&model.MaintenanceWindow{}).Where("name = ? AND id != ?", *req.Name, req.ID).Count(&count).Error; err != nil {
+			httpx.FailErr(c, httpx.ErrDatabaseError("failed to check name uniqueness", err))
+			return
+		}
+		if count > 0 {
+			httpx.FailErr(c, httpx.ErrAlreadyExists("maintenance window name already exists"))
+			return
+		}
+		updates["name"] = *req.Name
+	}
+	if req.Schedule != nil {
+		window.Schedule = *req.Schedule
+		updates["schedule"] = *req.Schedule
+	}
+	if req.DurationMinutes != nil {
+		window.DurationMinutes = *req.DurationMinutes
+		updates["duration_minutes"] = *req.DurationMinutes
+	}
+	if req.Timezone != nil {
+		window.Timezone = *req.Timezone
+		updates["timezone"] = *req.Timezone
+	}
+	if req.Enabled != nil {
+		updates["enabled"] = *req.Enabled
+	}
+	if req.Description != nil {
+		updates["description"] = *req.Description
+	}
+
+	if err := maintenance.Validate(window.Schedule, window.DurationMinutes, window.Timezone); err != nil {
+		httpx.FailErr(c, httpx.ErrParamInvalid(err.Error()))
+		return
+	}
+
+	if len(updates) > 0 {
+		if err := h.db.Model(&window).Updates(updates).Error; err != nil {
+			httpx.FailErr(c, httpx.ErrDatabaseError("failed to update maintenance window", err))
+			return
+		}
+	}
+
+	if err := h.db.First(&window, req.ID).Error; err != nil {
+		httpx.FailErr(c, httpx.ErrDatabaseError("failed to reload maintenance window", err))
+		return
+	}
+
+	item, err := h.toDTO(&window)
+	if err != nil {
+		httpx.FailErr(c, httpx.ErrDatabaseError("failed to count line groups", err))
+		return
+	}
+	httpx.OK(c, gin.H{"item": item})
+}
+
+// Delete handles POST /api/v1/maintenance-windows/delete
+// Windows still attached to line groups cannot be deleted
+func (h *Handler) Delete(c *gin.Context) {
+	var req DeleteRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		httpx.FailErr(c, httpx.ErrParamMissing(err.Error()))
+		return
+	}
+
+	var attached int64
+	if err := h.db.Model(&model.LineGroup{}).Where("maintenance_window_id IN ?", req.IDs).Count(&attached).Error; err != nil {
+		httpx.FailErr(c, httpx.ErrDatabaseError("failed to check line groups", err))
+		return
+	}
+	if attached > 0 {
+		httpx.FailErr(c, httpx.ErrStateConflict("maintenance window is attached to line groups"))
+		return
+	}
+
+	result := h.db.Delete(&model.MaintenanceWindow{}, req.IDs)
+	if result.Error != nil {
+		httpx.FailErr(c, httpx.ErrDatabaseError("failed to delete maintenance windows", result.Error))
+		return
+	}
+
+	httpx.OK(c, gin.H{
+		"deletedCount": result.RowsAffected,
+	})
+}
+
+// toDTO converts a maintenance window to DTO
+func (h *Handler) toDTO(window *model.MaintenanceWindow) (ItemDTO, error) {
+	var lineGroupCount int64
+	if err := h.db.Model(&model.LineGroup{}).Where("maintenance_window_id = ?", window.ID).Count(&lineGroupCount).Error; err != nil {
+		return ItemDTO{}, err
+	}
+
+	isOpen, _ := maintenance.IsWindowOpen(window, time.Now())
+
+	return ItemDTO{
+		ID:              window.ID,
+		Name:            window.Name,
+		Schedule:        window.Schedule,
+		DurationMinutes: window.DurationMinutes,
+		Timezone:        window.Timezone,
+		Enabled:         window.Enabled,
+		Description:     window.Description,
+		IsOpen:          isOpen,
+		LineGroupCount:  lineGroupCount,
+		CreatedAt:       window.CreatedAt.Format("2006-01-02T15:04:05-07:00"),
+		UpdatedAt:       window.UpdatedAt.Format("2006-01-02T15:04:05-07:00"),
+	}, nil
+}
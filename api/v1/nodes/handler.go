@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"log"
 	"strings"
+	"time"
 
 	"go_cmdb/internal/dto"
 	"go_cmdb/internal/httpx"
@@ -164,6 +165,16 @@ func (h *Handler) List(c *gin.Context) {
 		return
 	}
 
+	nodeIDs := make([]int, 0, len(nodes))
+	for _, node := range nodes {
+		nodeIDs = append(nodeIDs, node.ID)
+	}
+	expected, err := h.expectedVersions(nodeIDs)
+	if err != nil {
+		httpx.FailErr(c, httpx.ErrDatabaseError("failed to query expected config versions", err))
+		return
+	}
+
 	// Convert to DTO
 	items := make([]dto.NodeListItemDTO, len(nodes))
 	for i, node := range nodes {
@@ -197,6 +208,7 @@ func (h *Handler) List(c *gin.Context) {
 			LastSeenAt:      node.LastSeenAt,
 			LastHealthError: node.LastHealthError,
 			HealthFailCount: node.HealthFailCount,
+			AgentState:      toAgentStateDTO(&node, expected[node.ID]),
 			Ips: dto.NodeIPsContainerDTO{
 				Items: ipItems,
 			},
@@ -516,6 +528,7 @@ func (h *Handler) Update(c *gin.Context) {
 		LastSeenAt:      node.LastSeenAt,
 		LastHealthError: node.LastHealthError,
 		HealthFailCount: node.HealthFailCount,
+		AgentState:      h.agentState(&node),
 		Ips: dto.NodeIPsContainerDTO{
 			Items: ipItems,
 		},
@@ -608,6 +621,7 @@ func (h *Handler) Get(c *gin.Context) {
 		LastSeenAt:      node.LastSeenAt,
 		LastHealthError: node.LastHealthError,
 		HealthFailCount: node.HealthFailCount,
+		AgentState:      h.agentState(&node),
 		Ips: dto.NodeIPsContainerDTO{
 			Items: ipItems,
 		},
@@ -1001,3 +1015,101 @@ func (h *Handler) RevokeIdentity(c *gin.Context) {
 
 	httpx.OK(c, gin.H{"message": "identity revoked"})
 }
+
+// toAgentStateDTO builds the agent state of a node (nil if the agent never reported one).
+// expected is the version the node should run according to its latest successful release (nil if unknown).
+func toAgentStateDTO(node *model.Node, expected *int64) *dto.NodeAgentStateDTO {
+	if node.AgentStateAt == nil {
+		return nil
+	}
+
+	state := &dto.NodeAgentStateDTO{
+		AppliedVersion:     node.AgentAppliedVersion,
+		LastSuccessVersion: node.AgentLastSuccessVersion,
+		LiveVersion:        node.AgentLiveVersion,
+		ExpectedVersion:    expected,
+		LastError:          node.AgentLastError,
+		NginxVersion:       node.NginxVersion,
+		NginxConfigOK:      node.NginxConfigOK,
+		UptimeSec:          node.AgentUptimeSec,
+		ReportedAt:         node.AgentStateAt,
+	}
+	if node.NginxConfigOK != nil && !*node.NginxConfigOK {
+		state.ConfigDrift = true
+	}
+	if node.AgentLiveVersion != nil && expected != nil && *node.AgentLiveVersion != *expected {
+		state.ConfigDrift = true
+	}
+	return state
+}
+
+// agentState builds the agent state of a single node (drift unknown if the expected version cannot be queried)
+func (h *Handler) agentState(node *model.Node) *dto.NodeAgentStateDTO {
+	expected, err := h.expectedVersions([]int{node.ID})
+	if err != nil {
+		log.Printf("[Nodes] Failed to query expected config version of node %d: %v", node.ID, err)
+	}
+	return toAgentStateDTO(node, expected[node.ID])
+}
+
+// expectedVersions returns, per node, the config version of the latest successful release on the node:
+// CDN releases (release_task_nodes) and website/origin set releases (config_versions), whichever finished last.
+// Rollbacks that let the agent pick the target version leave the node without an expected version.
+func (h *Handler) expectedVersions(nodeIDs []int) (map[int]*int64, error) {
+	expected := make(map[int]*int64, len(nodeIDs))
+	finishedAt := make(map[int]time.Time, len(nodeIDs))
+	if len(nodeIDs) == 0 {
+		return expected, nil
+	}
+
+	var releaseRows []struct {
+		NodeID     int
+		Type       model.ReleaseTaskType
+		Version    int64
+		Payload    *model.ReleaseTaskPayload
+		FinishedAt *time.Time
+	}
+	if err := h.db.Table("release_task_nodes AS rtn").
+		Select("rtn.node_id, rt.type, rt.version, rt.payload, rtn.finished_at").
+		Joins("JOIN release_tasks AS rt ON rt.id = rtn.release_task_id").
+		Where("rtn.id IN (?)", h.db.Model(&model.ReleaseTaskNode{}).
+			Select("MAX(id)").
+			Where("node_id IN ? AND status = ?", nodeIDs, model.ReleaseTaskNodeStatusSuccess).
+			Group("node_id")).
+		Scan(&releaseRows).Error; err != nil {
+		return nil, err
+	}
+	for _, row := range releaseRows {
+		version := row.Version
+		if row.Type == model.ReleaseTaskTypeRollbackConfig {
+			version = 0
+			if row.Payload != nil {
+				version = row.Payload.RollbackToVersion
+			}
+		}
+		if version > 0 {
+			expected[row.NodeID] = &version
+		}
+		if row.FinishedAt != nil {
+			finishedAt[row.NodeID] = *row.FinishedAt
+		}
+	}
+
+	var configVersions []model.ConfigVersion
+	if err := h.db.Where("id IN (?)", h.db.Model(&model.ConfigVersion{}).
+		Select("MAX(id)").
+		Where("node_id IN ? AND status = ?", nodeIDs, model.ConfigVersionStatusApplied).
+		Group("node_id")).
+		Find(&configVersions).Error; err != nil {
+		return nil, err
+	}
+	for _, cv := range configVersions {
+		if cv.AppliedAt != nil && cv.AppliedAt.Before(finishedAt[cv.NodeID]) {
+			continue
+		}
+		version := cv.Version
+		expected[cv.NodeID] = &version
+	}
+
+	return expected, nil
+}
@@ -0,0 +1,90 @@
+package nodes
+
+import (
+	"testing"
+	"time"
+
+	"go_cmdb/internal/model"
+
+	"github.com/glebarez/sqlite"
+	"gorm.io/gorm"
+)
+
+func int64Ptr(v int64) *int64 { return &v }
+
+func boolPtr(v bool) *bool { return &v }
+
+func TestToAgentStateDTOConfigDrift(t *testing.T) {
+	now := time.Now()
+	tests := []struct {
+		name     string
+		live     *int64
+		expected *int64
+		configOK *bool
+		want     bool
+	}{
+		{name: "live is expected", live: int64Ptr(7), expected: int64Ptr(7), configOK: boolPtr(true)},
+		{name: "live behind expected", live: int64Ptr(5), expected: int64Ptr(7), configOK: boolPtr(true), want: true},
+		{name: "no expected version", live: int64Ptr(5), configOK: boolPtr(true)},
+		{name: "nginx -t fails", live: int64Ptr(7), expected: int64Ptr(7), configOK: boolPtr(false), want: true},
+	}
+
+	for _, tt := range tests {
+		node := &model.Node{AgentLiveVersion: tt.live, NginxConfigOK: tt.configOK, AgentStateAt: &now}
+		if got := toAgentStateDTO(node, tt.expected).ConfigDrift; got != tt.want {
+			t.Errorf("%s: ConfigDrift = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestExpectedVersions(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// MySQL enum columns do not migrate on sqlite, create the tables by hand
+	for _, ddl := range []string{
+		`CREATE TABLE release_tasks (id INTEGER PRIMARY KEY, type TEXT, version INTEGER, payload TEXT)`,
+		`CREATE TABLE release_task_nodes (id INTEGER PRIMARY KEY, release_task_id INTEGER, node_id INTEGER, status TEXT, finished_at DATETIME)`,
+		`CREATE TABLE config_versions (id INTEGER PRIMARY KEY, version INTEGER, node_id INTEGER, payload TEXT, status TEXT, applied_at DATETIME)`,
+	} {
+		if err := db.Exec(ddl).Error; err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	earlier := time.Now().Add(-time.Hour)
+	later := time.Now()
+	for _, stmt := range []struct {
+		sql  string
+		args []interface{}
+	}{
+		// payload as bytes, like the MySQL driver returns it
+		{`INSERT INTO release_tasks VALUES (1, 'apply_config', 7, NULL), (2, 'rollback_config', 8, ?), (3, 'apply_config', 9, NULL)`,
+			[]interface{}{[]byte(`{"rollbackToVersion":5}`)}},
+		// node 1: apply 7 then rollback to 5; node 2: apply 7, release 9 failed; node 3: CDN release, then a later website release
+		{`INSERT INTO release_task_nodes VALUES (1, 1, 1, 'success', ?), (2, 2, 1, 'success', ?), (3, 1, 2, 'success', ?), (4, 3, 2, 'failed', ?), (5, 1, 3, 'success', ?)`,
+			[]interface{}{earlier, later, earlier, later, earlier}},
+		{`INSERT INTO config_versions VALUES (1, 11, 3, '{}', 'applied', ?), (2, 4, 1, '{}', 'applied', ?)`, []interface{}{later, earlier}},
+	} {
+		if err := db.Exec(stmt.sql, stmt.args...).Error; err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	h := &Handler{db: db}
+	expected, err := h.expectedVersions([]int{1, 2, 3, 4})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := map[int]int64{1: 5, 2: 7, 3: 11}
+	for nodeID, version := range want {
+		if expected[nodeID] == nil || *expected[nodeID] != version {
+			t.Errorf("node %d: expected version %v, want %d", nodeID, expected[nodeID], version)
+		}
+	}
+	if expected[4] != nil {
+		t.Errorf("node 4: expected no version, got %d", *expected[4])
+	}
+}
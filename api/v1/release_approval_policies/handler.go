@@ -0,0 +1,209 @@
+package release_approval_policies
+
+import (
+	"go_cmdb/internal/approval"
+	"go_cmdb/internal/httpx"
+	"go_cmdb/internal/model"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// ListResponse represents list approval policies response
+type ListResponse struct {
+	Items []model.ReleaseApprovalPolicy `json:"items"`
+	Total int64                         `json:"total"`
+}
+
+// CreateRequest represents create approval policy request
+type CreateRequest struct {
+	Name        string                          `json:"name" binding:"required,max=128"`
+	Kind        model.ReleaseApprovalPolicyKind `json:"kind" binding:"required"`
+	Threshold   int                             `json:"threshold"`
+	Enabled     *bool                           `json:"enabled"`
+	Description string                          `json:"description" binding:"max=255"`
+}
+
+// UpdateRequest represents update approval policy request
+type UpdateRequest struct {
+	ID          int64                            `json:"id" binding:"required"`
+	Name        *string                          `json:"name" binding:"omitempty,max=128"`
+	Kind        *model.ReleaseApprovalPolicyKind `json:"kind"`
+	Threshold   *int                             `json:"threshold"`
+	Enabled     *bool                            `json:"enabled"`
+	Description *string                          `json:"description" binding:"omitempty,max=255"`
+}
+
+// DeleteRequest represents delete approval policies request
+type DeleteRequest struct {
+	IDs []int64 `json:"ids" binding:"required,min=1"`
+}
+
+// Handler handles release approval policies API
+type Handler struct {
+	db *gorm.DB
+}
+
+// NewHandler creates a new release approval policies handler
+func NewHandler(db *gorm.DB) *Handler {
+	return &Handler{db: db}
+}
+
+// List handles GET /api/v1/release-approval-policies
+func (h *Handler) List(c *gin.Context) {
+	var policies []model.ReleaseApprovalPolicy
+	if err := h.db.Order("id ASC").Find(&policies).Error; err != nil {
+		httpx.FailErr(c, httpx.ErrDatabaseError("failed to fetch approval policies", err))
+		return
+	}
+
+	httpx.OK(c, ListResponse{
+		Items: policies,
+		Total: int64(len(policies)),
+	})
+}
+
+// Create handles POST /api/v1/release-approval-policies/create
+func (h *Handler) Create(c *gin.Context) {
+	if !requireAdmin(c) {
+		return
+	}
+
+	var req CreateRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		httpx.FailErr(c, httpx.ErrParamMissing(err.Error()))
+		return
+	}
+
+	if err := approval.ValidatePolicy(req.Kind, req.Threshold); err != nil {
+		httpx.FailErr(c, httpx.ErrParamInvalid(err.Error()))
+		return
+	}
+
+	var count int64
+	if err := h.db.Model(&model.ReleaseApprovalPolicy{}).Where("name = ?", req.Name).Count(&count).Error; err != nil {
+		httpx.FailErr(c, httpx.ErrDatabaseError("failed to check name uniqueness", err))
+		return
+	}
+	if count > 0 {
+		httpx.FailErr(c, httpx.ErrAlreadyExists("approval policy name already exists"))
+		return
+	}
+
+	policy := model.ReleaseApprovalPolicy{
+		Name:        req.Name,
+		Kind:        req.Kind,
+		Threshold:   req.Threshold,
+		Enabled:     req.Enabled == nil || *req.Enabled,
+		Description: req.Description,
+	}
+	if err := h.db.Create(&policy).Error; err != nil {
+		httpx.FailErr(c, httpx.ErrDatabaseError("failed to create approval policy", err))
+		return
+	}
+
+	httpx.OK(c, gin.H{"item": policy})
+}
+
+// Update handles POST /api/v1/release-approval-policies/update
+func (h *Handler) Update(c *gin.Context) {
+	if !requireAdmin(c) {
+		return
+	}
+
+	var req UpdateRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		httpx.FailErr(c, httpx.ErrParamMissing(err.Error()))
+		return
+	}
+
+	var policy model.ReleaseApprovalPolicy
+	if err := h.db.First(&policy, req.ID).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			httpx.FailErr(c, httpx.ErrNotFound("approval policy not found"))
+			return
+		}
+		httpx.FailErr(c, httpx.ErrDatabaseError("failed to find approval policy", err))
+		return
+	}
+
+	updates := make(map[string]interface{})
+
+	if req.Name != nil {
+		var count int64
+		if err := h.db.Model(&model.ReleaseApprovalPolicy{}).Where("name = ? AND id != ?", *req.Name, req.ID).Count(&count).Error; err != nil {
+			httpx.FailErr(c, httpx.ErrDatabaseError("failed to check name uniqueness", err))
+			return
+		}
+		if count > 0 {
+			httpx.FailErr(c, httpx.ErrAlreadyExists("approval policy name already exists"))
+			return
+		}
+		updates["name"] = *req.Name
+	}
+	if req.Kind != nil {
+		policy.Kind = *req.Kind
+		updates["kind"] = *req.Kind
+	}
+	if req.Threshold != nil {
+		policy.Threshold = *req.Threshold
+		updates["threshold"] = *req.Threshold
+	}
+	if req.Enabled != nil {
+		updates["enabled"] = *req.Enabled
+	}
+	if req.Description != nil {
+		updates["description"] = *req.Description
+	}
+
+	if err := approval.ValidatePolicy(policy.Kind, policy.Threshold); err != nil {
+		httpx.FailErr(c, httpx.ErrParamInvalid(err.Error()))
+		return
+	}
+
+	if len(updates) > 0 {
+		if err := h.db.Model(&policy).Updates(updates).Error; err != nil {
+			httpx.FailErr(c, httpx.ErrDatabaseError("failed to update approval policy", err))
+			return
+		}
+	}
+
+	if err := h.db.First(&policy, req.ID).Error; err != nil {
+		httpx.FailErr(c, httpx.ErrDatabaseError("failed to reload approval policy", err))
+		return
+	}
+
+	httpx.OK(c, gin.H{"item": policy})
+}
+
+// Delete handles POST /api/v1/release-approval-policies/delete
+func (h *Handler) Delete(c *gin.Context) {
+	if !requireAdmin(c) {
+		return
+	}
+
+	var req DeleteRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		httpx.FailErr(c, httpx.ErrParamMissing(err.Error()))
+		return
+	}
+
+	result := h.db.Delete(&model.ReleaseApprovalPolicy{}, req.IDs)
+	if result.Error != nil {
+		httpx.FailErr(c, httpx.ErrDatabaseError("failed to delete approval policies", result.Error))
+		return
+	}
+
+	httpx.OK(c, gin.H{
+		"deletedCount": result.RowsAffected,
+	})
+}
+
+// requireAdmin only lets admins change approval policies
+func requireAdmin(c *gin.Context) bool {
+	if c.GetString("role") != model.UserRoleAdmin {
+		httpx.FailErr(c, httpx.ErrForbidden("admin role required"))
+		return false
+	}
+	return true
+}
@@ -0,0 +1,91 @@
+package releases
+
+import (
+	"log"
+
+	"go_cmdb/api/v1/websites"
+	"go_cmdb/internal/httpx"
+	"go_cmdb/internal/model"
+	"go_cmdb/internal/release"
+	"go_cmdb/internal/service"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ApproveRelease 审批通过发布任务
+// POST /api/v1/releases/:id/approve
+func (h *Handler) ApproveRelease(c *gin.Context) {
+	id, comment, ok := parseApprovalRequest(c)
+	if !ok {
+		return
+	}
+
+	task, err := h.service.ApproveRelease(id, c.GetString("username"), c.GetString("role"), comment)
+	if err != nil {
+		failControl(c, "failed to approve release", err)
+		return
+	}
+
+	// website 发布任务审批通过后立即派发；cdn 发布由 Executor 拉起
+	// 派发失败时撤销审批，发布回到 awaiting_approval，可重新审批或驳回
+	if task.TargetType == "website" && task.Target != model.ReleaseTaskTargetCDN {
+		if err := h.dispatchApproved(task); err != nil {
+			log.Printf("[Release] Failed to dispatch approved release task %d: %v", task.ID, err)
+			if revertErr := h.service.RevertApproval(task.ID); revertErr != nil {
+				log.Printf("[Release] Failed to revert approval of release task %d: %v", task.ID, revertErr)
+			}
+			if appErr, ok := err.(*httpx.AppError); ok {
+				httpx.FailErr(c, appErr)
+				return
+			}
+			httpx.FailErr(c, httpx.ErrInternalError("failed to dispatch approved release", err))
+			return
+		}
+	}
+
+	httpx.OK(c, &release.ControlReleaseResponse{ReleaseID: task.ID, Status: string(task.Status)})
+}
+
+// dispatchApproved 派发审批通过的网站发布任务，更新任务先写入审批前保留的网站更新
+func (h *Handler) dispatchApproved(task *model.ReleaseTask) error {
+	db := h.service.GetDB()
+	if task.Payload != nil && task.Payload.Action == "update" {
+		if err := websites.ApplyPendingUpdate(db, task); err != nil {
+			return err
+		}
+	}
+	_, err := service.NewWebsiteReleaseService(db).DispatchApproved(task)
+	return err
+}
+
+// RejectRelease 驳回发布任务
+// POST /api/v1/releases/:id/reject
+func (h *Handler) RejectRelease(c *gin.Context) {
+	id, comment, ok := parseApprovalRequest(c)
+	if !ok {
+		return
+	}
+
+	task, err := h.service.RejectRelease(id, c.GetString("username"), c.GetString("role"), comment)
+	if err != nil {
+		failControl(c, "failed to reject release", err)
+		return
+	}
+
+	httpx.OK(c, &release.ControlReleaseResponse{ReleaseID: task.ID, Status: string(task.Status)})
+}
+
+// parseApprovalRequest 解析发布任务ID和审批意见
+func parseApprovalRequest(c *gin.Context) (int64, string, bool) {
+	id, ok := parseReleaseID(c)
+	if !ok {
+		return 0, "", false
+	}
+
+	var req release.ApprovalDecisionRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		httpx.FailErr(c, httpx.ErrParamInvalid(err.Error()))
+		return 0, "", false
+	}
+	return id, req.Comment, true
+}
@@ -0,0 +1,88 @@
+package releases
+
+import (
+	"strconv"
+
+	"go_cmdb/internal/httpx"
+	"go_cmdb/internal/release"
+
+	"github.com/gin-gonic/gin"
+)
+
+// PauseRelease 暂停发布任务
+// POST /api/v1/releases/:id/pause
+func (h *Handler) PauseRelease(c *gin.Context) {
+	id, ok := parseReleaseID(c)
+	if !ok {
+		return
+	}
+
+	resp, err := h.service.PauseRelease(id, c.GetString("username"))
+	if err != nil {
+		failControl(c, "failed to pause release", err)
+		return
+	}
+
+	httpx.OK(c, resp)
+}
+
+// ResumeRelease 恢复发布任务
+// POST /api/v1/releases/:id/resume
+func (h *Handler) ResumeRelease(c *gin.Context) {
+	id, ok := parseReleaseID(c)
+	if !ok {
+		return
+	}
+
+	resp, err := h.service.ResumeRelease(id, c.GetString("username"))
+	if err != nil {
+		failControl(c, "failed to resume release", err)
+		return
+	}
+
+	httpx.OK(c, resp)
+}
+
+// CancelRelease 取消发布任务
+// POST /api/v1/releases/:id/cancel
+func (h *Handler) CancelRelease(c *gin.Context) {
+	id, ok := parseReleaseID(c)
+	if !ok {
+		return
+	}
+
+	var req release.ControlReleaseRequest
+	if c.Request.ContentLength > 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			httpx.FailErr(c, httpx.ErrParamInvalid(err.Error()))
+			return
+		}
+	}
+
+	resp, err := h.service.CancelRelease(id, c.GetString("username"), req.Reason)
+	if err != nil {
+		failControl(c, "failed to cancel release", err)
+		return
+	}
+
+	httpx.OK(c, resp)
+}
+
+// parseReleaseID 解析路径中的发布任务ID
+func parseReleaseID(c *gin.Context) (int64, bool) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil || id <= 0 {
+		httpx.FailErr(c, httpx.ErrParamInvalid("invalid id"))
+		return 0, false
+	}
+	return id, true
+}
+
+// failControl 返回控制操作错误：AppError直接返回，否则包装为内部错误
+func failControl(c *gin.Context, message string, err error) {
+	if appErr, ok := err.(*httpx.AppError); ok {
+		httpx.FailErr(c, appErr)
+		return
+	}
+	httpx.FailErr(c, httpx.ErrInternalError(message, err))
+}
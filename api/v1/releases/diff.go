@@ -0,0 +1,28 @@
+package releases
+
+import (
+	"go_cmdb/internal/httpx"
+
+	"github.com/gin-gonic/gin"
+)
+
+// GetReleaseDiff 对比两个发布版本的配置（结构化差异 + 渲染后 nginx 文件的 unified diff）
+// GET /api/v1/releases/:id/diff?against=<id|previous>
+func (h *Handler) GetReleaseDiff(c *gin.Context) {
+	id, ok := parseReleaseID(c)
+	if !ok {
+		return
+	}
+
+	resp, err := h.service.DiffRelease(id, c.DefaultQuery("against", "previous"))
+	if err != nil {
+		if appErr, ok := err.(*httpx.AppError); ok {
+			httpx.FailErr(c, appErr)
+		} else {
+			httpx.FailErr(c, httpx.ErrInternalError("failed to diff release", err))
+		}
+		return
+	}
+
+	httpx.OK(c, resp)
+}
@@ -0,0 +1,63 @@
+package releases
+
+import (
+	"sync"
+
+	"go_cmdb/internal/agentclient"
+	"go_cmdb/internal/config"
+	"go_cmdb/internal/httpx"
+	"go_cmdb/internal/service"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// DryRunHandler 发布 dry-run 处理器
+type DryRunHandler struct {
+	db  *gorm.DB
+	cfg *config.Config
+
+	clientOnce  sync.Once
+	agentClient *agentclient.Client
+	clientErr   error
+}
+
+// NewDryRunHandler 创建发布 dry-run 处理器
+func NewDryRunHandler(db *gorm.DB, cfg *config.Config) *DryRunHandler {
+	return &DryRunHandler{db: db, cfg: cfg}
+}
+
+// DryRun 预览网站/回源组发布：目标节点 + 节点将渲染的 nginx 配置，可选在节点上 nginx -t
+// POST /api/v1/releases/dry-run
+func (h *DryRunHandler) DryRun(c *gin.Context) {
+	var req service.ReleaseDryRunRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		httpx.FailErr(c, httpx.ErrParamInvalid(err.Error()))
+		return
+	}
+
+	// 仅校验时需要 Agent 客户端（mTLS）
+	var agentClient *agentclient.Client
+	if req.Validate {
+		h.clientOnce.Do(func() {
+			h.agentClient, h.clientErr = agentclient.NewClient(h.cfg)
+		})
+		if h.clientErr != nil {
+			httpx.FailErr(c, httpx.ErrParamInvalid("validate unavailable: "+h.clientErr.Error()))
+			return
+		}
+		agentClient = h.agentClient
+	}
+
+	result, err := service.NewReleaseDryRunService(h.db, agentClient).DryRun(&req)
+	if err != nil {
+		if appErr, ok := err.(*httpx.AppError); ok {
+			httpx.FailErr(c, appErr)
+		} else {
+			httpx.FailErr(c, httpx.ErrInternalError("failed to dry-run release", err))
+		}
+		return
+	}
+
+	httpx.OK(c, result)
+}
@@ -38,6 +38,8 @@ func (h *Handler) CreateRelease(c *gin.Context) {
 		return
 	}
 
+	req.CreatedBy = c.GetString("username")
+
 	resp, err := h.service.CreateRelease(&req)
 	if err != nil {
 		// 如果是AppError，直接返回；否则包装为内部错误
@@ -17,6 +17,8 @@ import (
 	dnsHandler "go_cmdb/api/v1/dns"
 	"go_cmdb/api/v1/domains"
 	"go_cmdb/api/v1/line_groups"
+	"go_cmdb/api/v1/maintenance_windows"
+	"go_cmdb/api/v1/release_approval_policies"
 	"go_cmdb/api/v1/middleware"
 	"go_cmdb/api/v1/node_groups"
 	"go_cmdb/api/v1/node_ips"
@@ -40,6 +42,21 @@ import (
 	"gorm.io/gorm"
 )
 
+// SetupAgentRouter sets up the agent-facing routes served on the mTLS listener
+func SetupAgentRouter(r *gin.Engine, db *gorm.DB, cfg *config.Config) {
+	setupAgentExecRoutes(r.Group("/api/v1"), db, cfg)
+}
+
+// setupAgentExecRoutes registers agent task pull/update-status routes
+func setupAgentExecRoutes(v1 *gin.RouterGroup, db *gorm.DB, cfg *config.Config) {
+	agentExecHandler := agent_exec.NewHandler(db, cfg.AgentAPI.InsecureNodeHeader)
+	agentExecGroup := v1.Group("/agent")
+	{
+		agentExecGroup.GET("/tasks/pull", agentExecHandler.Pull)
+		agentExecGroup.POST("/tasks/update-status", agentExecHandler.UpdateStatus)
+	}
+}
+
 	// SetupRouter sets up the API v1 routes
 func SetupRouter(r *gin.Engine, db *gorm.DB, cfg *config.Config, acmeWorker *acmePkg.Worker, tokenStore *bootstrapPkg.TokenStore, caManager *pki.CAManager, healthWorker *nodehealth.Worker) {
 	// Mount Socket.IO server with JWT authentication
@@ -70,12 +87,10 @@ func SetupRouter(r *gin.Engine, db *gorm.DB, cfg *config.Config, acmeWorker *acm
 				authGroup.POST("/login", auth.LoginHandler(db, cfg))
 			}
 
-			// Agent execution routes (no JWT auth, uses mTLS or X-Node-Id)
-			agentExecHandler := agent_exec.NewHandler(db)
-			agentExecGroup := v1.Group("/agent")
-			{
-				agentExecGroup.GET("/tasks/pull", agentExecHandler.Pull)
-				agentExecGroup.POST("/tasks/update-status", agentExecHandler.UpdateStatus)
+			// Agent execution routes (no JWT auth). Served by the mTLS listener (SetupAgentRouter);
+			// mounted here only in insecure mode, where the X-Node-Id header identifies the node
+			if cfg.AgentAPI.InsecureNodeHeader {
+				setupAgentExecRoutes(v1, db, cfg)
 			}
 
 		// Demo routes for testing error responses
@@ -147,6 +162,26 @@ func SetupRouter(r *gin.Engine, db *gorm.DB, cfg *config.Config, acmeWorker *acm
 			lineGroupsGroup.POST("/dns/repair-cname", lineGroupsHandler.RepairCNAME)
 		}
 
+		// Maintenance windows routes
+		maintenanceWindowsHandler := maintenance_windows.NewHandler(db)
+		maintenanceWindowsGroup := protected.Group("/maintenance-windows")
+		{
+			maintenanceWindowsGroup.GET("", maintenanceWindowsHandler.List)
+			maintenanceWindowsGroup.POST("/create", maintenanceWindowsHandler.Create)
+			maintenanceWindowsGroup.POST("/update", maintenanceWindowsHandler.Update)
+			maintenanceWindowsGroup.POST("/delete", maintenanceWindowsHandler.Delete)
+		}
+
+		// Release approval policies routes
+		approvalPoliciesHandler := release_approval_policies.NewHandler(db)
+		approvalPoliciesGroup := protected.Group("/release-approval-policies")
+		{
+			approvalPoliciesGroup.GET("", approvalPoliciesHandler.List)
+			approvalPoliciesGroup.POST("/create", approvalPoliciesHandler.Create)
+			approvalPoliciesGroup.POST("/update", approvalPoliciesHandler.Update)
+			approvalPoliciesGroup.POST("/delete", approvalPoliciesHandler.Delete)
+		}
+
 			// Origin groups routes
 		originGroupsHandler := origin_groups.NewHandler(db)
 		originGroupsGroup := protected.Group("/origin-groups")
@@ -218,6 +253,7 @@ func SetupRouter(r *gin.Engine, db *gorm.DB, cfg *config.Config, acmeWorker *acm
 				agentTasksGroup.GET("/:id", agentTasksHandler.GetByID)
 				agentTasksGroup.POST("/create", agentTasksHandler.Create)
 				agentTasksGroup.POST("/retry", agentTasksHandler.Retry)
+				agentTasksGroup.POST("/rollback", agentTasksHandler.Rollback)
 			}
 
 			// Agent identities routes (admin only)
@@ -318,6 +354,13 @@ func SetupRouter(r *gin.Engine, db *gorm.DB, cfg *config.Config, acmeWorker *acm
 			protected.GET("/release-tasks", releaseTasksHandlerInstance.List)
 			protected.GET("/releases/detail", releasesHandlerInstance.GetReleaseDetail)
 			protected.GET("/releases/list", releasesHandlerInstance.List)
+			protected.POST("/releases/:id/pause", releasesHandlerInstance.PauseRelease)
+			protected.POST("/releases/:id/resume", releasesHandlerInstance.ResumeRelease)
+			protected.POST("/releases/:id/cancel", releasesHandlerInstance.CancelRelease)
+			protected.POST("/releases/:id/approve", releasesHandlerInstance.ApproveRelease)
+			protected.POST("/releases/:id/reject", releasesHandlerInstance.RejectRelease)
+			protected.GET("/releases/:id/diff", releasesHandlerInstance.GetReleaseDiff)
+			protected.POST("/releases/dry-run", releases.NewDryRunHandler(db, cfg).DryRun)
 
 				// Domain routes (T2-10-02, T2-10-03, T2-10-04, C1-02)
 				domainsOptionsHandler := domains.NewOptionsHandler(db)
@@ -3,6 +3,7 @@ package websites
 import (
 	"database/sql"
 	"fmt"
+	"go_cmdb/internal/approval"
 	"go_cmdb/internal/cert"
 	dnspkg "go_cmdb/internal/dns"
 	"go_cmdb/internal/domainutil"
@@ -29,6 +30,11 @@ type CreateRequest struct {
 	RedirectStatusCode *int    `json:"redirectStatusCode"`
 	HTTPSEnabled       *bool   `json:"httpsEnabled"`
 	ForceHTTPSRedirect *bool   `json:"forceHttpsRedirect"`
+	OriginHostHeader   *string `json:"originHostHeader"`
+	OriginSNI          *bool   `json:"originSni"`
+	OriginSNIName      *string `json:"originSniName"`
+	OriginSSLVerify    *bool   `json:"originSslVerify"`
+	OriginCACert       *string `json:"originCaCert"`
 }
 
 // CreateResponse 创建响应
@@ -53,6 +59,10 @@ type CreateResultItem struct {
 	AgentTaskCountAfter   int      `json:"agentTaskCountAfter"`
 	PayloadValid          bool     `json:"payloadValid"`
 	PayloadInvalidReason  string   `json:"payloadInvalidReason"`
+	AwaitingApproval      bool     `json:"awaitingApproval"`
+	ApprovalReason        string   `json:"approvalReason"`
+	QueuedBehind          int64    `json:"queuedBehind"`  // 排队等待该发布结束后派发
+	WaitingWindow         bool     `json:"waitingWindow"` // 等待计划时间或维护窗口后派发
 	// 证书决策结果
 	CertDecision string `json:"certDecision"` // existing_cert / acme_triggered / downgraded / none
 	HTTPSActual  *bool  `json:"httpsActual"`  // 实际 HTTPS 状态（可能被降级）
@@ -78,6 +88,18 @@ func (h *Handler) Create(c *gin.Context) {
 		return
 	}
 
+	// 校验回源协议（写库前）
+	if err := validateOriginSetProtocol(h.db, req.OriginMode, req.OriginSetID); err != nil {
+		httpx.FailErr(c, err)
+		return
+	}
+
+	// 校验回源请求选项
+	if err := validateOriginOptions(req.OriginHostHeader, req.OriginSNIName, req.OriginCACert); err != nil {
+		httpx.FailErr(c, err)
+		return
+	}
+
 	// 解析文本
 	lines := parseText(req.DomainsText)
 	if len(lines) == 0 {
@@ -198,6 +220,23 @@ func (h *Handler) Create(c *gin.Context) {
 				website.OriginSetID = sql.NullInt32{Valid: false}
 			}
 
+			// 回源请求选项
+			if req.OriginHostHeader != nil {
+				website.OriginHostHeader = *req.OriginHostHeader
+			}
+			if req.OriginSNI != nil {
+				website.OriginSNI = *req.OriginSNI
+			}
+			if req.OriginSNIName != nil {
+				website.OriginSNIName = *req.OriginSNIName
+			}
+			if req.OriginSSLVerify != nil {
+				website.OriginSSLVerify = *req.OriginSSLVerify
+			}
+			if req.OriginCACert != nil {
+				website.OriginCACert = *req.OriginCACert
+			}
+
 			if err := tx.Create(&website).Error; err != nil {
 				return err
 			}
@@ -334,9 +373,14 @@ func (h *Handler) Create(c *gin.Context) {
 			log.Printf("[Create] Step 6: creating release task for website %d", *result.WebsiteID)
 			releaseService := service.NewWebsiteReleaseService(h.db)
 			traceID := fmt.Sprintf("website_create_%d", *result.WebsiteID)
-			releaseResult, releaseErr := releaseService.CreateWebsiteReleaseTaskWithDispatch(int64(*result.WebsiteID), traceID)
+			releaseResult, releaseErr := releaseService.CreateWebsiteReleaseTaskWithDispatch(int64(*result.WebsiteID), traceID, approval.Change{}, c.GetString("username"))
 			if releaseErr != nil {
 				log.Printf("[Create] Step 6 failed: release task error: %v", releaseErr)
+				if appErr, ok := releaseErr.(*httpx.AppError); ok {
+					// 发布被拒绝（如回源协议混用），网站已创建
+					result.PayloadValid = false
+					result.PayloadInvalidReason = appErr.Message
+				}
 			} else {
 				result.ReleaseTaskID = int(releaseResult.ReleaseTaskID)
 				result.TaskCreated = releaseResult.TaskCreated
@@ -348,6 +392,10 @@ func (h *Handler) Create(c *gin.Context) {
 				result.AgentTaskCountAfter = releaseResult.AgentTaskCountAfter
 				result.PayloadValid = releaseResult.PayloadValid
 				result.PayloadInvalidReason = releaseResult.PayloadInvalidReason
+				result.AwaitingApproval = releaseResult.AwaitingApproval
+				result.ApprovalReason = releaseResult.ApprovalReason
+				result.QueuedBehind = releaseResult.QueuedBehind
+				result.WaitingWindow = releaseResult.WaitingWindow
 				log.Printf("[Create] Step 6 completed: releaseTaskID=%d", releaseResult.ReleaseTaskID)
 			}
 		}
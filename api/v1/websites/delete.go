@@ -2,6 +2,7 @@ package websites
 
 import (
 	"fmt"
+	"go_cmdb/internal/approval"
 	"go_cmdb/internal/httpx"
 	"go_cmdb/internal/model"
 	"go_cmdb/internal/service"
@@ -27,6 +28,8 @@ type DeleteResultItem struct {
 	SkippedAgentTaskCount  int    `json:"skippedAgentTaskCount"`
 	AgentTaskCountAfter    int    `json:"agentTaskCountAfter"`
 	SkipReason             string `json:"skipReason"`
+	AwaitingApproval       bool   `json:"awaitingApproval"`
+	ApprovalReason         string `json:"approvalReason"`
 }
 
 // Delete 删除网站
@@ -64,36 +67,29 @@ func (h *Handler) Delete(c *gin.Context) {
 		domains = append(domains, d.Domain)
 	}
 
-	// 2. 在事务中删除
-	err := h.db.Transaction(func(tx *gorm.DB) error {
-		// 删除 website_https
-		if err := tx.Where("website_id = ?", websiteID).Delete(&model.WebsiteHTTPS{}).Error; err != nil {
-			return err
-		}
-
-		// 删除 website_domains
-		if err := tx.Where("website_id = ?", websiteID).Delete(&model.WebsiteDomain{}).Error; err != nil {
-			return err
-		}
-
-		// 删除 website
-		if err := tx.Delete(&model.Website{}, websiteID).Error; err != nil {
-			return err
-		}
-
-		return nil
-	})
-
+	// 1.5. 按审批策略判断删除是否需要审批（需要审批时网站记录保留到审批通过，驳回时网站不受影响）
+	approvalReason, err := service.WebsiteReleaseApprovalReason(h.db, website.LineGroupID, approval.Change{WebsiteDelete: true})
 	if err != nil {
-		httpx.FailErr(c, httpx.ErrDatabaseError("failed to delete website", err))
+		httpx.FailErr(c, httpx.ErrDatabaseError("failed to evaluate approval policies", err))
 		return
 	}
 
+	// 2. 无需审批时直接删除
+	if approvalReason == "" {
+		if err := service.DeleteWebsiteRecords(h.db, websiteID); err != nil {
+			httpx.FailErr(c, httpx.ErrDatabaseError("failed to delete website", err))
+			return
+		}
+	}
+
 	// 3. 创建发布任务
 	deleteInfo := &service.WebsiteDeleteInfo{
 		WebsiteID:   websiteID,
 		LineGroupID: int64(website.LineGroupID),
 		Domains:     domains,
+
+		CreatedBy:      c.GetString("username"),
+		ApprovalReason: approvalReason,
 	}
 	traceID := fmt.Sprintf("website_delete_%d", websiteID)
 
@@ -104,6 +100,26 @@ func (h *Handler) Delete(c *gin.Context) {
 		return
 	}
 
+	// 3.5. 等待审批的任务不派发，审批通过后再派发
+	var releaseTask model.ReleaseTask
+	if err := h.db.Select("id", "status", "approval_reason").First(&releaseTask, releaseTaskID).Error; err != nil {
+		httpx.FailErr(c, httpx.ErrDatabaseError("failed to query release task", err))
+		return
+	}
+	if releaseTask.Status == model.ReleaseTaskStatusAwaitingApproval {
+		result := DeleteResultItem{
+			WebsiteID:        websiteID,
+			ReleaseTaskID:    releaseTaskID,
+			TaskCreated:      true,
+			AwaitingApproval: true,
+		}
+		if releaseTask.ApprovalReason != nil {
+			result.ApprovalReason = *releaseTask.ApprovalReason
+		}
+		httpx.OK(c, gin.H{"item": result})
+		return
+	}
+
 	// 4. 派发任务（使用专门的删除派发方法，不查询已删除的 website）
 	dispatcher := service.NewAgentTaskDispatcher(h.db)
 	dispatchResult, err := dispatcher.EnsureDispatchedForDelete(releaseTaskID, deleteInfo.WebsiteID, deleteInfo.LineGroupID, deleteInfo.Domains, traceID)
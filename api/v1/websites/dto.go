@@ -34,6 +34,11 @@ type WebsiteDTO struct {
 	OriginSetID        *int      `json:"originSetId"`
 	RedirectURL        string    `json:"redirectUrl,omitempty"`
 	RedirectStatusCode int       `json:"redirectStatusCode,omitempty"`
+	OriginHostHeader   string    `json:"originHostHeader"`
+	OriginSNI          bool      `json:"originSni"`
+	OriginSNIName      string    `json:"originSniName"`
+	OriginSSLVerify    bool      `json:"originSslVerify"`
+	OriginCACert       string    `json:"originCaCert,omitempty"`
 	Status             string    `json:"status"`
 	Domains            []string  `json:"domains,omitempty"`
 	CNAME              string    `json:"cname,omitempty"`
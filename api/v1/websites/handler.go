@@ -204,6 +204,11 @@ func (h *Handler) GetByID(c *gin.Context) {
 		OriginMode:         website.OriginMode,
 		RedirectURL:        website.RedirectURL,
 		RedirectStatusCode: website.RedirectStatusCode,
+		OriginHostHeader:   website.OriginHostHeader,
+		OriginSNI:          website.OriginSNI,
+		OriginSNIName:      website.OriginSNIName,
+		OriginSSLVerify:    website.OriginSSLVerify,
+		OriginCACert:       website.OriginCACert,
 		Status:             website.Status,
 		CreatedAt:          website.CreatedAt,
 		UpdatedAt:          website.UpdatedAt,
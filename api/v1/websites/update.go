@@ -2,10 +2,14 @@ package websites
 
 import (
 	"database/sql"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
 
+	"go_cmdb/internal/approval"
 	"go_cmdb/internal/cert"
+	"go_cmdb/internal/configgen"
 	dnspkg "go_cmdb/internal/dns"
 	"go_cmdb/internal/domainutil"
 	"go_cmdb/internal/httpx"
@@ -29,6 +33,11 @@ type UpdateRequest struct {
 	RedirectStatusCode *int    `json:"redirectStatusCode"`
 	HTTPSEnabled       *bool   `json:"httpsEnabled"`
 	ForceHTTPSRedirect *bool   `json:"forceHttpsRedirect"`
+	OriginHostHeader   *string `json:"originHostHeader"`
+	OriginSNI          *bool   `json:"originSni"`
+	OriginSNIName      *string `json:"originSniName"`
+	OriginSSLVerify    *bool   `json:"originSslVerify"`
+	OriginCACert       *string `json:"originCaCert"`
 }
 
 // UpdateResultItem 更新结果
@@ -44,9 +53,14 @@ type UpdateResultItem struct {
 	AgentTaskCountAfter   int    `json:"agentTaskCountAfter"`
 	PayloadValid          bool   `json:"payloadValid"`
 	PayloadInvalidReason  string `json:"payloadInvalidReason"`
+	AwaitingApproval      bool   `json:"awaitingApproval"`
+	ApprovalReason        string `json:"approvalReason"`
+	QueuedBehind          int64  `json:"queuedBehind"`  // 排队等待该发布结束后派发
+	WaitingWindow         bool   `json:"waitingWindow"` // 等待计划时间或维护窗口后派发
 }
 
 // Update 更新网站
+// 命中审批策略的更新不写入网站表，保存在等待审批的发布任务中，审批通过后由 ApplyPendingUpdate 写入
 func (h *Handler) Update(c *gin.Context) {
 	var req UpdateRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
@@ -54,349 +68,61 @@ func (h *Handler) Update(c *gin.Context) {
 		return
 	}
 
-	// 查询现有网站
-	var website model.Website
-	if err := h.db.Preload("Domains").First(&website, req.ID).Error; err != nil {
-		if err == gorm.ErrRecordNotFound {
-			httpx.FailErr(c, httpx.ErrNotFound("website not found"))
-			return
-		}
-		httpx.FailErr(c, httpx.ErrDatabaseError("failed to query website", err))
+	plan, appErr := prepareUpdate(h.db, &req)
+	if appErr != nil {
+		httpx.FailErr(c, appErr)
 		return
 	}
 
-	// 计算最终域名列表（用于证书决策和 DNS）
-	var finalDomains []string
-	if req.DomainsText != nil {
-		lines := parseText(*req.DomainsText)
-		if len(lines) == 0 {
-			httpx.FailErr(c, httpx.ErrParamMissing("domains required"))
-			return
-		}
-		if len(lines) > 1 {
-			httpx.FailErr(c, httpx.ErrParamInvalid("update only supports single website"))
-			return
-		}
-		// 规范化域名
-		for _, d := range lines[0] {
-			nd, err := domainutil.Normalize(d)
-			if err != nil {
-				httpx.FailErr(c, httpx.ErrParamInvalid(err.Error()))
-				return
-			}
-			finalDomains = append(finalDomains, nd)
-		}
-		// PSL + domains 表 active 校验
-		if err := domainutil.ValidateWebsiteDomains(h.db, finalDomains); err != nil {
-			httpx.FailErr(c, httpx.ErrParamInvalid(err.Error()))
-			return
-		}
-	} else {
-		for _, d := range website.Domains {
-			finalDomains = append(finalDomains, d.Domain)
-		}
-	}
-
-	// 计算最终 lineGroupID
-	finalLineGroupID := website.LineGroupID
-	if req.LineGroupID != nil {
-		finalLineGroupID = *req.LineGroupID
+	// 按审批策略判断是否需要审批（写库前）
+	approvalReason, err := service.WebsiteReleaseApprovalReason(h.db, plan.finalLineGroupID, approval.Change{HTTPSChange: plan.httpsChange})
+	if err != nil {
+		httpx.FailErr(c, httpx.ErrDatabaseError("failed to evaluate approval policies", err))
+		return
 	}
 
-	// [事务外] Step 1: 证书决策（如需要）
-	var certDecision *cert.DecisionResult
-	httpsRequested := req.HTTPSEnabled != nil && *req.HTTPSEnabled
-
-	if httpsRequested && len(finalDomains) > 0 {
-		log.Printf("[Update] Step 1: certificate decision for website %d, domains %v", req.ID, finalDomains)
-		var err error
-		certDecision, err = cert.DecideCertificateReadOnly(h.db, finalDomains)
+	var releaseResult *service.CreateReleaseTaskResult
+	if approvalReason != "" {
+		releaseResult, err = createPendingUpdate(h.db, &req, plan, approvalReason, c.GetString("username"))
 		if err != nil {
-			log.Printf("[Update] Step 1 failed: certificate decision error: %v", err)
-			certDecision = &cert.DecisionResult{
-				Downgraded:      true,
-				DowngradeReason: "certificate decision failed: " + err.Error(),
+			log.Printf("[Update] Failed to create pending update for website %d: %v", req.ID, err)
+			if appErr, ok := err.(*httpx.AppError); ok {
+				httpx.FailErr(c, appErr)
+				return
 			}
+			httpx.FailErr(c, httpx.ErrInternalError("failed to create release task", err))
+			return
 		}
-		log.Printf("[Update] Step 1 result: certFound=%v certID=%d acmeNeeded=%v downgraded=%v",
-			certDecision.CertFound, certDecision.CertificateID, certDecision.ACMENeeded, certDecision.Downgraded)
-	}
-
-	// [事务内] Step 2: 更新 website + domains + website_https（只写 DB）
-	log.Printf("[Update] Step 2: begin transaction for website %d update", req.ID)
-	err := h.db.Transaction(func(tx *gorm.DB) error {
-		updates := make(map[string]interface{})
-
-		// 更新 lineGroupId
-		if req.LineGroupID != nil {
-			updates["line_group_id"] = *req.LineGroupID
-		}
-
-		// 更新 cacheRuleId
-		if req.CacheRuleID != nil {
-			if *req.CacheRuleID > 0 {
-				updates["cache_rule_id"] = sql.NullInt32{Int32: int32(*req.CacheRuleID), Valid: true}
+	} else {
+		if err := applyUpdate(h.db, &req, plan); err != nil {
+			if appErr, ok := err.(*httpx.AppError); ok {
+				httpx.FailErr(c, appErr)
 			} else {
-				updates["cache_rule_id"] = sql.NullInt32{Valid: false}
-			}
-		}
-
-		// 更新 redirectUrl 和 redirectStatusCode（允许单独更新）
-		if req.RedirectURL != nil {
-			updates["redirect_url"] = *req.RedirectURL
-		}
-		if req.RedirectStatusCode != nil {
-			updates["redirect_status_code"] = *req.RedirectStatusCode
-		}
-
-		// 更新 originMode 及相关字段
-		if req.OriginMode != nil {
-			if *req.OriginMode != model.OriginModeGroup && *req.OriginMode != model.OriginModeManual && *req.OriginMode != model.OriginModeRedirect {
-				return httpx.ErrParamInvalid("originMode must be group, manual or redirect")
-			}
-			if err := validateUpdateOriginMode(&req); err != nil {
-				return err
-			}
-			if err := validateUpdateOriginReferences(tx, &req); err != nil {
-				return err
-			}
-
-			updates["origin_mode"] = *req.OriginMode
-			switch *req.OriginMode {
-			case model.OriginModeGroup:
-				updates["origin_group_id"] = *req.OriginGroupID
-				updates["origin_set_id"] = *req.OriginSetID
-				updates["redirect_url"] = ""
-				updates["redirect_status_code"] = 0
-			case model.OriginModeManual:
-				updates["origin_set_id"] = *req.OriginSetID
-				updates["origin_group_id"] = nil
-				updates["redirect_url"] = ""
-				updates["redirect_status_code"] = 0
-			case model.OriginModeRedirect:
-				updates["redirect_url"] = *req.RedirectURL
-				if req.RedirectStatusCode != nil {
-					updates["redirect_status_code"] = *req.RedirectStatusCode
-				} else {
-					updates["redirect_status_code"] = 301
-				}
-				updates["origin_group_id"] = nil
-				updates["origin_set_id"] = nil
-			}
-		}
-
-		// 更新 website
-		if len(updates) > 0 {
-			if err := tx.Model(&website).Updates(updates).Error; err != nil {
-				return err
-			}
-		}
-
-		// 更新域名
-		if req.DomainsText != nil {
-			// 检查域名是否已被其他网站使用
-			for _, domain := range finalDomains {
-				var count int64
-				if err := tx.Model(&model.WebsiteDomain{}).
-					Where("domain = ? AND website_id != ?", domain, website.ID).
-					Count(&count).Error; err != nil {
-					return err
-				}
-				if count > 0 {
-					return httpx.ErrParamInvalid("domain already exists: " + domain)
-				}
-			}
-
-			// 删除旧域名
-			if err := tx.Where("website_id = ?", website.ID).Delete(&model.WebsiteDomain{}).Error; err != nil {
-				return err
-			}
-
-			// 创建新域名
-			for idx, domain := range finalDomains {
-				wd := model.WebsiteDomain{
-					WebsiteID: website.ID,
-					Domain:    domain,
-					IsPrimary: idx == 0,
-				}
-				if err := tx.Create(&wd).Error; err != nil {
-					return err
-				}
+				httpx.FailErr(c, httpx.ErrDatabaseError("failed to update website", err))
 			}
+			return
 		}
 
-		// 写入 website_https 记录（纯 DB 写入）
-		if req.HTTPSEnabled != nil || req.ForceHTTPSRedirect != nil {
-			var websiteHTTPS model.WebsiteHTTPS
-			existErr := tx.Where("website_id = ?", website.ID).First(&websiteHTTPS).Error
-
-			forceRedir := false
-			if req.ForceHTTPSRedirect != nil {
-				forceRedir = *req.ForceHTTPSRedirect
-			}
-
-			if req.HTTPSEnabled != nil && !*req.HTTPSEnabled {
-				// HTTPS 禁用
-				if existErr == gorm.ErrRecordNotFound {
-					if err := tx.Exec(
-						"INSERT INTO website_https (website_id, enabled, force_redirect, hsts, cert_mode, certificate_id, acme_provider_id, acme_account_id, created_at, updated_at) VALUES (?, ?, ?, ?, ?, NULL, NULL, NULL, NOW(), NOW())",
-						website.ID, false, false, false, model.CertModeACME,
-					).Error; err != nil {
-						return err
-					}
-				} else if existErr == nil {
-					if err := tx.Model(&model.WebsiteHTTPS{}).Where("website_id = ?", website.ID).Updates(map[string]interface{}{
-						"enabled":        false,
-						"force_redirect": false,
-						"certificate_id": nil,
-					}).Error; err != nil {
-						return err
-					}
-				} else {
-					return existErr
-				}
-			} else if certDecision != nil && certDecision.Downgraded {
-				// HTTPS 被降级
-				log.Printf("[Update] Website %d: HTTPS explicitly downgraded - %s", website.ID, certDecision.DowngradeReason)
-				if existErr == gorm.ErrRecordNotFound {
-					if err := tx.Exec(
-						"INSERT INTO website_https (website_id, enabled, force_redirect, hsts, cert_mode, certificate_id, acme_provider_id, acme_account_id, created_at, updated_at) VALUES (?, ?, ?, ?, ?, NULL, NULL, NULL, NOW(), NOW())",
-						website.ID, false, false, false, model.CertModeACME,
-					).Error; err != nil {
-						return err
-					}
-				} else if existErr == nil {
-					if err := tx.Model(&model.WebsiteHTTPS{}).Where("website_id = ?", website.ID).Updates(map[string]interface{}{
-						"enabled":        false,
-						"force_redirect": false,
-						"certificate_id": nil,
-					}).Error; err != nil {
-						return err
-					}
-				} else {
-					return existErr
-				}
-			} else if certDecision != nil && certDecision.CertFound {
-				// 找到已有证书
-				if existErr == gorm.ErrRecordNotFound {
-					if err := tx.Exec(
-						"INSERT INTO website_https (website_id, enabled, force_redirect, hsts, cert_mode, certificate_id, acme_provider_id, acme_account_id, created_at, updated_at) VALUES (?, ?, ?, ?, ?, ?, NULL, NULL, NOW(), NOW())",
-						website.ID, true, forceRedir, false, model.CertModeSelect, certDecision.CertificateID,
-					).Error; err != nil {
-						return err
-					}
-				} else if existErr == nil {
-					if err := tx.Model(&model.WebsiteHTTPS{}).Where("website_id = ?", website.ID).Updates(map[string]interface{}{
-						"enabled":        true,
-						"force_redirect": forceRedir,
-						"cert_mode":      model.CertModeSelect,
-						"certificate_id": certDecision.CertificateID,
-					}).Error; err != nil {
-						return err
-					}
-				} else {
-					return existErr
-				}
-				// 创建/更新证书绑定
-				var existingBinding model.CertificateBinding
-				if err := tx.Where("website_id = ?", website.ID).First(&existingBinding).Error; err == gorm.ErrRecordNotFound {
-					binding := model.CertificateBinding{
-						CertificateID: certDecision.CertificateID,
-						WebsiteID:     website.ID,
-						Status:        model.CertificateBindingStatusActive,
-					}
-					if err := tx.Create(&binding).Error; err != nil {
-						return err
-					}
-				} else if err == nil {
-					if err := tx.Model(&existingBinding).Updates(map[string]interface{}{
-						"certificate_id": certDecision.CertificateID,
-						"status":         model.CertificateBindingStatusActive,
-					}).Error; err != nil {
-						return err
-					}
-				}
-			} else if certDecision != nil && certDecision.ACMENeeded {
-				// 需要 ACME 申请
-				if existErr == gorm.ErrRecordNotFound {
-					if err := tx.Exec(
-						"INSERT INTO website_https (website_id, enabled, force_redirect, hsts, cert_mode, certificate_id, acme_provider_id, acme_account_id, created_at, updated_at) VALUES (?, ?, ?, ?, ?, NULL, ?, ?, NOW(), NOW())",
-						website.ID, true, forceRedir, false, model.CertModeACME, certDecision.ACMEProviderID, certDecision.ACMEAccountID,
-					).Error; err != nil {
-						return err
-					}
-				} else if existErr == nil {
-					if err := tx.Model(&model.WebsiteHTTPS{}).Where("website_id = ?", website.ID).Updates(map[string]interface{}{
-						"enabled":          true,
-						"force_redirect":   forceRedir,
-						"cert_mode":        model.CertModeACME,
-						"certificate_id":   nil,
-						"acme_provider_id": certDecision.ACMEProviderID,
-						"acme_account_id":  certDecision.ACMEAccountID,
-					}).Error; err != nil {
-						return err
-					}
-				} else {
-					return existErr
-				}
-			} else if req.ForceHTTPSRedirect != nil && req.HTTPSEnabled == nil {
-				// 只更新 forceRedirect
-				if existErr == nil {
-					if err := tx.Model(&model.WebsiteHTTPS{}).Where("website_id = ?", website.ID).Updates(map[string]interface{}{
-						"force_redirect": forceRedir,
-					}).Error; err != nil {
-						return err
-					}
-				}
+		// [事务外] Step 5: 触发发布任务
+		log.Printf("[Update] Step 5: creating release task for website %d", req.ID)
+		releaseService := service.NewWebsiteReleaseService(h.db)
+		traceID := fmt.Sprintf("website_update_%d", req.ID)
+		var releaseErr error
+		releaseResult, releaseErr = releaseService.CreateWebsiteReleaseTaskWithDispatch(int64(req.ID), traceID, approval.Change{HTTPSChange: plan.httpsChange}, c.GetString("username"))
+		if releaseErr != nil {
+			log.Printf("[Update] Step 5 failed: release task error: %v", releaseErr)
+			if appErr, ok := releaseErr.(*httpx.AppError); ok {
+				httpx.FailErr(c, appErr)
+				return
 			}
+			httpx.FailErr(c, httpx.ErrInternalError("failed to create release task", releaseErr))
+			return
 		}
-
-		return nil
-	})
-	log.Printf("[Update] Step 2: transaction completed for website %d, err=%v", req.ID, err)
-
-	if err != nil {
-		if appErr, ok := err.(*httpx.AppError); ok {
-			httpx.FailErr(c, appErr)
-		} else {
-			httpx.FailErr(c, httpx.ErrDatabaseError("failed to update website", err))
-		}
-		return
-	}
-
-	// [事务外] Step 3: 触发 ACME 申请（如需要）
-	if certDecision != nil && certDecision.ACMENeeded {
-		log.Printf("[Update] Step 3: triggering ACME request for website %d, accountID=%d", req.ID, certDecision.ACMEAccountID)
-		if err := cert.TriggerACMERequest(h.db, req.ID, certDecision.ACMEAccountID, finalDomains); err != nil {
-			log.Printf("[Update] Step 3 failed: ACME request trigger error: %v", err)
-		} else {
-			log.Printf("[Update] Step 3 completed: ACME request created for website %d", req.ID)
-		}
-	}
-
-	// [事务外] Step 4: DNS CNAME 补齐（无论证书状态如何）
-	if len(finalDomains) > 0 && finalLineGroupID > 0 {
-		log.Printf("[Update] Step 4: creating DNS CNAME records for website %d, domains=%v, lineGroupId=%d", req.ID, finalDomains, finalLineGroupID)
-		if err := dnspkg.EnsureWebsiteDomainCNAMEs(h.db, req.ID, finalDomains, finalLineGroupID); err != nil {
-			log.Printf("[Update] Step 4 failed: DNS CNAME creation error: %v", err)
-		} else {
-			log.Printf("[Update] Step 4 completed: DNS CNAME records created for website %d", req.ID)
-		}
-	}
-
-	// [事务外] Step 5: 触发发布任务
-	log.Printf("[Update] Step 5: creating release task for website %d", req.ID)
-	releaseService := service.NewWebsiteReleaseService(h.db)
-	traceID := fmt.Sprintf("website_update_%d", req.ID)
-	releaseResult, releaseErr := releaseService.CreateWebsiteReleaseTaskWithDispatch(int64(req.ID), traceID)
-	if releaseErr != nil {
-		log.Printf("[Update] Step 5 failed: release task error: %v", releaseErr)
-		httpx.FailErr(c, httpx.ErrInternalError("failed to create release task", releaseErr))
-		return
+		log.Printf("[Update] Step 5 completed: releaseTaskID=%d", releaseResult.ReleaseTaskID)
 	}
-	log.Printf("[Update] Step 5 completed: releaseTaskID=%d", releaseResult.ReleaseTaskID)
 
-	// 重新查询返回
+	// 重新查询返回（等待审批时为更新前的网站）
+	var website model.Website
 	if err := h.db.
 		Preload("LineGroup").
 		Preload("OriginGroup").
@@ -414,6 +140,11 @@ func (h *Handler) Update(c *gin.Context) {
 		OriginMode:         website.OriginMode,
 		RedirectURL:        website.RedirectURL,
 		RedirectStatusCode: website.RedirectStatusCode,
+		OriginHostHeader:   website.OriginHostHeader,
+		OriginSNI:          website.OriginSNI,
+		OriginSNIName:      website.OriginSNIName,
+		OriginSSLVerify:    website.OriginSSLVerify,
+		OriginCACert:       website.OriginCACert,
 		Status:             website.Status,
 		CreatedAt:          website.CreatedAt,
 		UpdatedAt:          website.UpdatedAt,
@@ -478,11 +209,484 @@ func (h *Handler) Update(c *gin.Context) {
 		result.AgentTaskCountAfter = releaseResult.AgentTaskCountAfter
 		result.PayloadValid = releaseResult.PayloadValid
 		result.PayloadInvalidReason = releaseResult.PayloadInvalidReason
+		result.AwaitingApproval = releaseResult.AwaitingApproval
+		result.ApprovalReason = releaseResult.ApprovalReason
+		result.QueuedBehind = releaseResult.QueuedBehind
+		result.WaitingWindow = releaseResult.WaitingWindow
 	}
 
 	httpx.OK(c, result)
 }
 
+// updatePlan 更新请求校验后的最终状态
+type updatePlan struct {
+	website          model.Website
+	finalDomains     []string
+	finalLineGroupID int
+	httpsChange      bool
+	certDecision     *cert.DecisionResult
+}
+
+// prepareUpdate 校验更新请求并计算最终状态（只读，不写库）
+func prepareUpdate(db *gorm.DB, req *UpdateRequest) (*updatePlan, *httpx.AppError) {
+	// 校验回源请求选项
+	if err := validateOriginOptions(req.OriginHostHeader, req.OriginSNIName, req.OriginCACert); err != nil {
+		return nil, err
+	}
+
+	// 查询现有网站
+	plan := &updatePlan{}
+	if err := db.Preload("Domains").First(&plan.website, req.ID).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return nil, httpx.ErrNotFound("website not found")
+		}
+		return nil, httpx.ErrDatabaseError("failed to query website", err)
+	}
+	website := &plan.website
+
+	// 计算最终域名列表（用于证书决策和 DNS）
+	if req.DomainsText != nil {
+		lines := parseText(*req.DomainsText)
+		if len(lines) == 0 {
+			return nil, httpx.ErrParamMissing("domains required")
+		}
+		if len(lines) > 1 {
+			return nil, httpx.ErrParamInvalid("update only supports single website")
+		}
+		// 规范化域名
+		for _, d := range lines[0] {
+			nd, err := domainutil.Normalize(d)
+			if err != nil {
+				return nil, httpx.ErrParamInvalid(err.Error())
+			}
+			plan.finalDomains = append(plan.finalDomains, nd)
+		}
+		// PSL + domains 表 active 校验
+		if err := domainutil.ValidateWebsiteDomains(db, plan.finalDomains); err != nil {
+			return nil, httpx.ErrParamInvalid(err.Error())
+		}
+	} else {
+		for _, d := range website.Domains {
+			plan.finalDomains = append(plan.finalDomains, d.Domain)
+		}
+	}
+
+	// 判断 HTTPS 配置是否变化（用于审批策略）
+	httpsChange, err := httpsChanged(db, req.ID, req)
+	if err != nil {
+		return nil, httpx.ErrDatabaseError("failed to query website https", err)
+	}
+	plan.httpsChange = httpsChange
+
+	// 计算最终 lineGroupID
+	plan.finalLineGroupID = website.LineGroupID
+	if req.LineGroupID != nil {
+		plan.finalLineGroupID = *req.LineGroupID
+	}
+
+	// 校验最终回源分组的协议（写库前）
+	finalOriginMode := website.OriginMode
+	var finalOriginSetID *int
+	if website.OriginSetID.Valid {
+		originSetID := int(website.OriginSetID.Int32)
+		finalOriginSetID = &originSetID
+	}
+	if req.OriginMode != nil {
+		finalOriginMode = *req.OriginMode
+		finalOriginSetID = req.OriginSetID
+	}
+	if err := validateOriginSetProtocol(db, finalOriginMode, finalOriginSetID); err != nil {
+		return nil, err
+	}
+
+	// [事务外] Step 1: 证书决策（如需要）
+	httpsRequested := req.HTTPSEnabled != nil && *req.HTTPSEnabled
+
+	if httpsRequested && len(plan.finalDomains) > 0 {
+		log.Printf("[Update] Step 1: certificate decision for website %d, domains %v", req.ID, plan.finalDomains)
+		certDecision, err := cert.DecideCertificateReadOnly(db, plan.finalDomains)
+		if err != nil {
+			log.Printf("[Update] Step 1 failed: certificate decision error: %v", err)
+			certDecision = &cert.DecisionResult{
+				Downgraded:      true,
+				DowngradeReason: "certificate decision failed: " + err.Error(),
+			}
+		}
+		log.Printf("[Update] Step 1 result: certFound=%v certID=%d acmeNeeded=%v downgraded=%v",
+			certDecision.CertFound, certDecision.CertificateID, certDecision.ACMENeeded, certDecision.Downgraded)
+		plan.certDecision = certDecision
+	}
+
+	return plan, nil
+}
+
+// applyUpdate 写入更新，并在事务外触发 ACME 申请和 DNS 补齐
+func applyUpdate(db *gorm.DB, req *UpdateRequest, plan *updatePlan) error {
+	// [事务内] Step 2: 更新 website + domains + website_https（只写 DB）
+	log.Printf("[Update] Step 2: begin transaction for website %d update", req.ID)
+	err := db.Transaction(func(tx *gorm.DB) error {
+		return writeUpdate(tx, req, plan)
+	})
+	log.Printf("[Update] Step 2: transaction completed for website %d, err=%v", req.ID, err)
+	if err != nil {
+		return err
+	}
+
+	certDecision := plan.certDecision
+	finalDomains := plan.finalDomains
+	finalLineGroupID := plan.finalLineGroupID
+
+	// [事务外] Step 3: 触发 ACME 申请（如需要）
+	if certDecision != nil && certDecision.ACMENeeded {
+		log.Printf("[Update] Step 3: triggering ACME request for website %d, accountID=%d", req.ID, certDecision.ACMEAccountID)
+		if err := cert.TriggerACMERequest(db, req.ID, certDecision.ACMEAccountID, finalDomains); err != nil {
+			log.Printf("[Update] Step 3 failed: ACME request trigger error: %v", err)
+		} else {
+			log.Printf("[Update] Step 3 completed: ACME request created for website %d", req.ID)
+		}
+	}
+
+	// [事务外] Step 4: DNS CNAME 补齐（无论证书状态如何）
+	if len(finalDomains) > 0 && finalLineGroupID > 0 {
+		log.Printf("[Update] Step 4: creating DNS CNAME records for website %d, domains=%v, lineGroupId=%d", req.ID, finalDomains, finalLineGroupID)
+		if err := dnspkg.EnsureWebsiteDomainCNAMEs(db, req.ID, finalDomains, finalLineGroupID); err != nil {
+			log.Printf("[Update] Step 4 failed: DNS CNAME creation error: %v", err)
+		} else {
+			log.Printf("[Update] Step 4 completed: DNS CNAME records created for website %d", req.ID)
+		}
+	}
+
+	return nil
+}
+
+// errPreviewRollback 预演更新后回滚事务
+var errPreviewRollback = errors.New("preview rollback")
+
+// createPendingUpdate 创建等待审批的更新发布任务，网站表保持不变
+// 配置快照在事务内预演更新后生成（事务回滚），用于审批时对比
+func createPendingUpdate(db *gorm.DB, req *UpdateRequest, plan *updatePlan, approvalReason, createdBy string) (*service.CreateReleaseTaskResult, error) {
+	var snapshot *string
+	err := db.Transaction(func(tx *gorm.DB) error {
+		if err := writeUpdate(tx, req, plan); err != nil {
+			return err
+		}
+		if s, err := configgen.NewAggregator(tx).Snapshot([]int64{int64(req.ID)}); err != nil {
+			log.Printf("[Update] Failed to snapshot pending update for website %d: %v", req.ID, err)
+		} else {
+			snapshot = &s
+		}
+		return errPreviewRollback
+	})
+	if !errors.Is(err, errPreviewRollback) {
+		return nil, err
+	}
+
+	request, err := json.Marshal(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal update request: %w", err)
+	}
+	return service.CreatePendingWebsiteUpdate(db, int64(req.ID), request, snapshot, approvalReason, createdBy)
+}
+
+// ApplyPendingUpdate 审批通过后写入发布任务中保存的网站更新，并刷新配置快照
+// 写入前重新校验：审批期间网站或引用的资源可能已变化
+func ApplyPendingUpdate(db *gorm.DB, task *model.ReleaseTask) error {
+	if task.Payload == nil || len(task.Payload.PendingUpdate) == 0 {
+		return fmt.Errorf("release task %d has no pending update", task.ID)
+	}
+
+	var req UpdateRequest
+	if err := json.Unmarshal(task.Payload.PendingUpdate, &req); err != nil {
+		return fmt.Errorf("failed to parse pending update of release task %d: %w", task.ID, err)
+	}
+	plan, appErr := prepareUpdate(db, &req)
+	if appErr != nil {
+		return appErr
+	}
+	if err := applyUpdate(db, &req, plan); err != nil {
+		return err
+	}
+
+	snapshot, err := configgen.NewAggregator(db).Snapshot([]int64{task.TargetID})
+	if err != nil {
+		log.Printf("[Update] Failed to snapshot website %d after applying release task %d: %v", task.TargetID, task.ID, err)
+		return nil
+	}
+	return db.Model(&model.ReleaseTask{}).Where("id = ?", task.ID).Update("config_snapshot", snapshot).Error
+}
+
+// writeUpdate 写入 website + domains + website_https（调用方提供事务）
+func writeUpdate(tx *gorm.DB, req *UpdateRequest, plan *updatePlan) error {
+	website := plan.website
+	finalDomains := plan.finalDomains
+	certDecision := plan.certDecision
+
+	updates := make(map[string]interface{})
+
+	// 更新 lineGroupId
+	if req.LineGroupID != nil {
+		updates["line_group_id"] = *req.LineGroupID
+	}
+
+	// 更新 cacheRuleId
+	if req.CacheRuleID != nil {
+		if *req.CacheRuleID > 0 {
+			updates["cache_rule_id"] = sql.NullInt32{Int32: int32(*req.CacheRuleID), Valid: true}
+		} else {
+			updates["cache_rule_id"] = sql.NullInt32{Valid: false}
+		}
+	}
+
+	// 更新 redirectUrl 和 redirectStatusCode（允许单独更新）
+	if req.RedirectURL != nil {
+		updates["redirect_url"] = *req.RedirectURL
+	}
+	if req.RedirectStatusCode != nil {
+		updates["redirect_status_code"] = *req.RedirectStatusCode
+	}
+
+	// 更新 originMode 及相关字段
+	if req.OriginMode != nil {
+		if *req.OriginMode != model.OriginModeGroup && *req.OriginMode != model.OriginModeManual && *req.OriginMode != model.OriginModeRedirect {
+			return httpx.ErrParamInvalid("originMode must be group, manual or redirect")
+		}
+		if err := validateUpdateOriginMode(req); err != nil {
+			return err
+		}
+		if err := validateUpdateOriginReferences(tx, req); err != nil {
+			return err
+		}
+
+		updates["origin_mode"] = *req.OriginMode
+		switch *req.OriginMode {
+		case model.OriginModeGroup:
+			updates["origin_group_id"] = *req.OriginGroupID
+			updates["origin_set_id"] = *req.OriginSetID
+			updates["redirect_url"] = ""
+			updates["redirect_status_code"] = 0
+		case model.OriginModeManual:
+			updates["origin_set_id"] = *req.OriginSetID
+			updates["origin_group_id"] = nil
+			updates["redirect_url"] = ""
+			updates["redirect_status_code"] = 0
+		case model.OriginModeRedirect:
+			updates["redirect_url"] = *req.RedirectURL
+			if req.RedirectStatusCode != nil {
+				updates["redirect_status_code"] = *req.RedirectStatusCode
+			} else {
+				updates["redirect_status_code"] = 301
+			}
+			updates["origin_group_id"] = nil
+			updates["origin_set_id"] = nil
+		}
+	}
+
+	// 更新回源请求选项
+	if req.OriginHostHeader != nil {
+		updates["origin_host_header"] = *req.OriginHostHeader
+	}
+	if req.OriginSNI != nil {
+		updates["origin_sni"] = *req.OriginSNI
+	}
+	if req.OriginSNIName != nil {
+		updates["origin_sni_name"] = *req.OriginSNIName
+	}
+	if req.OriginSSLVerify != nil {
+		updates["origin_ssl_verify"] = *req.OriginSSLVerify
+	}
+	if req.OriginCACert != nil {
+		updates["origin_ca_cert"] = *req.OriginCACert
+	}
+
+	// 更新 website
+	if len(updates) > 0 {
+		if err := tx.Model(&website).Updates(updates).Error; err != nil {
+			return err
+		}
+	}
+
+	// 更新域名
+	if req.DomainsText != nil {
+		// 检查域名是否已被其他网站使用
+		for _, domain := range finalDomains {
+			var count int64
+			if err := tx.Model(&model.WebsiteDomain{}).
+				Where("domain = ? AND website_id != ?", domain, website.ID).
+				Count(&count).Error; err != nil {
+				return err
+			}
+			if count > 0 {
+				return httpx.ErrParamInvalid("domain already exists: " + domain)
+			}
+		}
+
+		// 删除旧域名
+		if err := tx.Where("website_id = ?", website.ID).Delete(&model.WebsiteDomain{}).Error; err != nil {
+			return err
+		}
+
+		// 创建新域名
+		for idx, domain := range finalDomains {
+			wd := model.WebsiteDomain{
+				WebsiteID: website.ID,
+				Domain:    domain,
+				IsPrimary: idx == 0,
+			}
+			if err := tx.Create(&wd).Error; err != nil {
+				return err
+			}
+		}
+	}
+
+	// 写入 website_https 记录（纯 DB 写入）
+	if req.HTTPSEnabled != nil || req.ForceHTTPSRedirect != nil {
+		var websiteHTTPS model.WebsiteHTTPS
+		existErr := tx.Where("website_id = ?", website.ID).First(&websiteHTTPS).Error
+
+		forceRedir := false
+		if req.ForceHTTPSRedirect != nil {
+			forceRedir = *req.ForceHTTPSRedirect
+		}
+
+		if req.HTTPSEnabled != nil && !*req.HTTPSEnabled {
+			// HTTPS 禁用
+			if existErr == gorm.ErrRecordNotFound {
+				if err := tx.Exec(
+					"INSERT INTO website_https (website_id, enabled, force_redirect, hsts, cert_mode, certificate_id, acme_provider_id, acme_account_id, created_at, updated_at) VALUES (?, ?, ?, ?, ?, NULL, NULL, NULL, NOW(), NOW())",
+					website.ID, false, false, false, model.CertModeACME,
+				).Error; err != nil {
+					return err
+				}
+			} else if existErr == nil {
+				if err := tx.Model(&model.WebsiteHTTPS{}).Where("website_id = ?", website.ID).Updates(map[string]interface{}{
+					"enabled":        false,
+					"force_redirect": false,
+					"certificate_id": nil,
+				}).Error; err != nil {
+					return err
+				}
+			} else {
+				return existErr
+			}
+		} else if certDecision != nil && certDecision.Downgraded {
+			// HTTPS 被降级
+			log.Printf("[Update] Website %d: HTTPS explicitly downgraded - %s", website.ID, certDecision.DowngradeReason)
+			if existErr == gorm.ErrRecordNotFound {
+				if err := tx.Exec(
+					"INSERT INTO website_https (website_id, enabled, force_redirect, hsts, cert_mode, certificate_id, acme_provider_id, acme_account_id, created_at, updated_at) VALUES (?, ?, ?, ?, ?, NULL, NULL, NULL, NOW(), NOW())",
+					website.ID, false, false, false, model.CertModeACME,
+				).Error; err != nil {
+					return err
+				}
+			} else if existErr == nil {
+				if err := tx.Model(&model.WebsiteHTTPS{}).Where("website_id = ?", website.ID).Updates(map[string]interface{}{
+					"enabled":        false,
+					"force_redirect": false,
+					"certificate_id": nil,
+				}).Error; err != nil {
+					return err
+				}
+			} else {
+				return existErr
+			}
+		} else if certDecision != nil && certDecision.CertFound {
+			// 找到已有证书
+			if existErr == gorm.ErrRecordNotFound {
+				if err := tx.Exec(
+					"INSERT INTO website_https (website_id, enabled, force_redirect, hsts, cert_mode, certificate_id, acme_provider_id, acme_account_id, created_at, updated_at) VALUES (?, ?, ?, ?, ?, ?, NULL, NULL, NOW(), NOW())",
+					website.ID, true, forceRedir, false, model.CertModeSelect, certDecision.CertificateID,
+				).Error; err != nil {
+					return err
+				}
+			} else if existErr == nil {
+				if err := tx.Model(&model.WebsiteHTTPS{}).Where("website_id = ?", website.ID).Updates(map[string]interface{}{
+					"enabled":        true,
+					"force_redirect": forceRedir,
+					"cert_mode":      model.CertModeSelect,
+					"certificate_id": certDecision.CertificateID,
+				}).Error; err != nil {
+					return err
+				}
+			} else {
+				return existErr
+			}
+			// 创建/更新证书绑定
+			var existingBinding model.CertificateBinding
+			if err := tx.Where("website_id = ?", website.ID).First(&existingBinding).Error; err == gorm.ErrRecordNotFound {
+				binding := model.CertificateBinding{
+					CertificateID: certDecision.CertificateID,
+					WebsiteID:     website.ID,
+					Status:        model.CertificateBindingStatusActive,
+				}
+				if err := tx.Create(&binding).Error; err != nil {
+					return err
+				}
+			} else if err == nil {
+				if err := tx.Model(&existingBinding).Updates(map[string]interface{}{
+					"certificate_id": certDecision.CertificateID,
+					"status":         model.CertificateBindingStatusActive,
+				}).Error; err != nil {
+					return err
+				}
+			}
+		} else if certDecision != nil && certDecision.ACMENeeded {
+			// 需要 ACME 申请
+			if existErr == gorm.ErrRecordNotFound {
+				if err := tx.Exec(
+					"INSERT INTO website_https (website_id, enabled, force_redirect, hsts, cert_mode, certificate_id, acme_provider_id, acme_account_id, created_at, updated_at) VALUES (?, ?, ?, ?, ?, NULL, ?, ?, NOW(), NOW())",
+					website.ID, true, forceRedir, false, model.CertModeACME, certDecision.ACMEProviderID, certDecision.ACMEAccountID,
+				).Error; err != nil {
+					return err
+				}
+			} else if existErr == nil {
+				if err := tx.Model(&model.WebsiteHTTPS{}).Where("website_id = ?", website.ID).Updates(map[string]interface{}{
+					"enabled":          true,
+					"force_redirect":   forceRedir,
+					"cert_mode":        model.CertModeACME,
+					"certificate_id":   nil,
+					"acme_provider_id": certDecision.ACMEProviderID,
+					"acme_account_id":  certDecision.ACMEAccountID,
+				}).Error; err != nil {
+					return err
+				}
+			} else {
+				return existErr
+			}
+		} else if req.ForceHTTPSRedirect != nil && req.HTTPSEnabled == nil {
+			// 只更新 forceRedirect
+			if existErr == nil {
+				if err := tx.Model(&model.WebsiteHTTPS{}).Where("website_id = ?", website.ID).Updates(map[string]interface{}{
+					"force_redirect": forceRedir,
+				}).Error; err != nil {
+					return err
+				}
+			}
+		}
+	}
+
+	return nil
+}
+
+// httpsChanged 判断更新请求是否修改了网站的 HTTPS 开关或强制跳转
+func httpsChanged(db *gorm.DB, websiteID int, req *UpdateRequest) (bool, error) {
+	if req.HTTPSEnabled == nil && req.ForceHTTPSRedirect == nil {
+		return false, nil
+	}
+
+	var current model.WebsiteHTTPS
+	if err := db.Where("website_id = ?", websiteID).Limit(1).Find(&current).Error; err != nil {
+		return false, err
+	}
+
+	if req.HTTPSEnabled != nil && *req.HTTPSEnabled != current.Enabled {
+		return true, nil
+	}
+	if req.ForceHTTPSRedirect != nil && *req.ForceHTTPSRedirect != current.ForceRedirect {
+		return true, nil
+	}
+	return false, nil
+}
+
 // validateUpdateOriginMode 校验更新请求中的 originMode 字段组合
 func validateUpdateOriginMode(req *UpdateRequest) *httpx.AppError {
 	if req.OriginMode == nil {
@@ -1,12 +1,35 @@
 package websites
 
 import (
+	"crypto/x509"
+	"encoding/pem"
+	"fmt"
+	"regexp"
+
+	"go_cmdb/internal/configgen"
 	"go_cmdb/internal/httpx"
 	"go_cmdb/internal/model"
 
 	"gorm.io/gorm"
 )
 
+// validateOriginSetProtocol 校验回源分组的地址协议一致（同一 upstream 不允许混用 http/https）
+// 在写库前调用，避免网站已提交后才在发布时被拒绝
+func validateOriginSetProtocol(db *gorm.DB, originMode string, originSetID *int) *httpx.AppError {
+	if originMode == model.OriginModeRedirect || originSetID == nil || *originSetID <= 0 {
+		return nil
+	}
+
+	addresses, err := configgen.LoadOriginSetAddresses(db, int64(*originSetID))
+	if err != nil {
+		return httpx.ErrDatabaseError("failed to load origin set addresses", err)
+	}
+	if _, err := configgen.ResolveOriginProtocol(addresses); err != nil {
+		return httpx.ErrParamInvalid(fmt.Sprintf("origin set %d: %v", *originSetID, err))
+	}
+	return nil
+}
+
 // validateOriginReferences 校验 originGroupId 和 originSetId 的存在性和关联性
 func validateOriginReferences(db *gorm.DB, req *CreateRequest) *httpx.AppError {
 	switch req.OriginMode {
@@ -58,3 +81,40 @@ func validateOriginReferences(db *gorm.DB, req *CreateRequest) *httpx.AppError {
 
 	return nil
 }
+
+// originHostPattern 回源 Host / SNI 名称（hostname[:port]），会写入 nginx 配置，不允许其他字符
+var originHostPattern = regexp.MustCompile(`^[A-Za-z0-9]([A-Za-z0-9.-]{0,252})(:[0-9]{1,5})?$`)
+
+// validateOriginOptions 校验回源 Host、SNI 名称和自定义 CA
+func validateOriginOptions(hostHeader, sniName, caCert *string) *httpx.AppError {
+	if hostHeader != nil && *hostHeader != "" && !originHostPattern.MatchString(*hostHeader) {
+		return httpx.ErrParamInvalid("originHostHeader must be a hostname")
+	}
+	if sniName != nil && *sniName != "" && !originHostPattern.MatchString(*sniName) {
+		return httpx.ErrParamInvalid("originSniName must be a hostname")
+	}
+
+	if caCert != nil && *caCert != "" {
+		rest := []byte(*caCert)
+		count := 0
+		for {
+			var block *pem.Block
+			block, rest = pem.Decode(rest)
+			if block == nil {
+				break
+			}
+			if block.Type != "CERTIFICATE" {
+				return httpx.ErrParamInvalid("originCaCert must contain only certificates")
+			}
+			if _, err := x509.ParseCertificate(block.Bytes); err != nil {
+				return httpx.ErrParamInvalid("originCaCert contains an invalid certificate")
+			}
+			count++
+		}
+		if count == 0 {
+			return httpx.ErrParamInvalid("originCaCert must be PEM encoded certificates")
+		}
+	}
+
+	return nil
+}
@@ -1,6 +1,7 @@
 package main
 
 import (
+	"context"
 	"crypto/sha256"
 	"crypto/tls"
 	"crypto/x509"
@@ -8,8 +9,12 @@ import (
 	"log"
 	"net/http"
 	"os"
+	"strconv"
+	"strings"
+	"time"
 
 	"go_cmdb/agent/api/v1"
+	"go_cmdb/agent/pull"
 
 	"github.com/gin-gonic/gin"
 	"github.com/joho/godotenv"
@@ -70,7 +75,13 @@ func main() {
 	r := gin.Default()
 
 	// Setup routes (no token, mTLS only)
-	v1.SetupRouter(r)
+	executor := v1.NewTaskExecutor()
+	v1.SetupRouter(r, executor)
+
+	// Pull mode (optional): poll the control plane for tasks, for nodes it cannot reach
+	if getEnv("AGENT_PULL_ENABLED", "false") == "true" {
+		startPuller(executor, cert)
+	}
 
 	// Create HTTPS server
 	server := &http.Server{
@@ -85,6 +96,55 @@ func main() {
 	}
 }
 
+// startPuller starts the pull loop in the background
+func startPuller(executor *v1.TaskExecutor, cert tls.Certificate) {
+	serverURL := getEnv("CONTROL_PLANE_URL", "")
+	if serverURL == "" {
+		log.Fatal("CONTROL_PLANE_URL is required when AGENT_PULL_ENABLED=true")
+	}
+
+	// Optional: the control plane identifies the node by its client certificate,
+	// AGENT_NODE_ID is only needed when it trusts the X-Node-Id header instead
+	var nodeID int64
+	if value := getEnv("AGENT_NODE_ID", ""); value != "" {
+		id, err := strconv.ParseInt(value, 10, 64)
+		if err != nil || id <= 0 {
+			log.Fatalf("Invalid AGENT_NODE_ID: %s", value)
+		}
+		nodeID = id
+	}
+
+	intervalSec, _ := strconv.Atoi(getEnv("AGENT_PULL_INTERVAL_SEC", "5"))
+	limit, _ := strconv.Atoi(getEnv("AGENT_PULL_LIMIT", "10"))
+
+	// Present the agent certificate; verify the control plane with its CA if given, system roots otherwise
+	tlsConfig := &tls.Config{
+		Certificates: []tls.Certificate{cert},
+		MinVersion:   tls.VersionTLS12,
+	}
+	if caFile := getEnv("CONTROL_PLANE_CA", ""); caFile != "" {
+		caBytes, err := os.ReadFile(caFile)
+		if err != nil {
+			log.Fatalf("Failed to load control plane CA: %v", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(caBytes) {
+			log.Fatal("Failed to append control plane CA")
+		}
+		tlsConfig.RootCAs = pool
+	}
+
+	puller := pull.NewPuller(pull.Config{
+		ServerURL: strings.TrimRight(serverURL, "/"),
+		NodeID:    nodeID,
+		Interval:  time.Duration(intervalSec) * time.Second,
+		Limit:     limit,
+		TLSConfig: tlsConfig,
+	}, executor)
+
+	go puller.Run(context.Background())
+}
+
 func getEnv(key, defaultValue string) string {
 	if value := os.Getenv(key); value != "" {
 		return value
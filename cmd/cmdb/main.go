@@ -2,15 +2,19 @@ package main
 
 import (
 	"context"
+	"crypto/tls"
+	"crypto/x509"
 	"flag"
 	"fmt"
 	"log"
+	"net/http"
 	"os"
 	"time"
 
 	"go_cmdb/api/v1"
 	
 	"go_cmdb/internal/acme"
+	"go_cmdb/internal/agent"
 	"go_cmdb/internal/agentclient"
 	"go_cmdb/internal/auth"
 	"go_cmdb/internal/bootstrap"
@@ -19,10 +23,12 @@ import (
 	"go_cmdb/internal/config"
 	"go_cmdb/internal/db"
 	"go_cmdb/internal/dns"
+	"go_cmdb/internal/model"
 	"go_cmdb/internal/nodehealth"
 	"go_cmdb/internal/pki"
 	"go_cmdb/internal/release"
 	"go_cmdb/internal/risk"
+	"go_cmdb/internal/service"
 	"go_cmdb/internal/ws"
 
 	"github.com/gin-gonic/gin"
@@ -79,6 +85,13 @@ func main() {
 	auth.InitJWT(cfg.JWT.Secret)
 	log.Println("✓ JWT initialized")
 
+	// 3.5 Agent task retry policy (backoff + dead-letter)
+	agent.SetRetryPolicy(agent.RetryPolicy{
+		MaxAttempts: cfg.AgentTaskRetry.MaxAttempts,
+		BaseDelay:   time.Duration(cfg.AgentTaskRetry.BaseDelaySec) * time.Second,
+		MaxDelay:    time.Duration(cfg.AgentTaskRetry.MaxDelaySec) * time.Second,
+	})
+
 	// 4. Initialize Redis
 	if err := cache.InitRedis(cfg.Redis.Addr, cfg.Redis.Password, cfg.Redis.DB); err != nil {
 		log.Fatalf("Failed to initialize Redis: %v", err)
@@ -127,6 +140,7 @@ func main() {
 				db.GetDB(),
 				agentClient,
 				time.Duration(cfg.ReleaseExecutor.IntervalSec)*time.Second,
+				cfg.ReleaseExecutor.MaxWorkers,
 			)
 
 			ctx, cancel := context.WithCancel(context.Background())
@@ -135,6 +149,13 @@ func main() {
 			go executor.RunLoop(ctx)
 			log.Println("✓ Release Executor initialized")
 		}
+
+		// Website releases go through agent_tasks and need no mTLS; the scheduler dispatches queued ones
+		scheduler := service.NewWebsiteReleaseScheduler(db.GetDB(), time.Duration(cfg.ReleaseExecutor.IntervalSec)*time.Second)
+		schedulerCtx, cancelScheduler := context.WithCancel(context.Background())
+		defer cancelScheduler()
+		go scheduler.RunLoop(schedulerCtx)
+		log.Println("✓ Website Release Scheduler initialized")
 	} else {
 		log.Println("✓ Release Executor disabled (RELEASE_EXECUTOR_ENABLED=0)")
 	}
@@ -186,6 +207,43 @@ func main() {
 		log.Println("✓ Certificate Cleaner disabled (CERT_FAILED_CLEANER_ENABLED=0)")
 	}
 
+	// 8.6 Start Agent Task Reaper (tasks stuck in running)
+	if cfg.AgentTaskReaper.Enabled {
+		reaperConfig := service.AgentTaskReaperConfig{
+			Enabled:        cfg.AgentTaskReaper.Enabled,
+			IntervalSec:    cfg.AgentTaskReaper.IntervalSec,
+			DefaultTimeout: time.Duration(cfg.AgentTaskReaper.TimeoutSec) * time.Second,
+			Timeouts: map[string]time.Duration{
+				model.TaskTypeApplyConfig:    time.Duration(cfg.AgentTaskReaper.ApplyConfigTimeoutSec) * time.Second,
+				model.TaskTypeRollbackConfig: time.Duration(cfg.AgentTaskReaper.RollbackConfigTimeoutSec) * time.Second,
+				model.TaskTypeReload:         time.Duration(cfg.AgentTaskReaper.ReloadTimeoutSec) * time.Second,
+				model.TaskTypePurgeCache:     time.Duration(cfg.AgentTaskReaper.PurgeCacheTimeoutSec) * time.Second,
+			},
+		}
+		reaper := service.NewAgentTaskReaper(db.GetDB(), reaperConfig)
+		reaper.Start()
+		defer reaper.Stop()
+		log.Println("✓ Agent Task Reaper initialized")
+	} else {
+		log.Println("✓ Agent Task Reaper disabled (AGENT_TASK_REAPER_ENABLED=0)")
+	}
+
+	// 8.7 Start Agent Task Retry Scheduler (due retries of tasks pushed over mTLS)
+	if cfg.MTLS.Enabled {
+		retryDispatcher, err := agent.NewDispatcher(db.GetDB(), cfg)
+		if err != nil {
+			log.Printf("⚠ Failed to create dispatcher for retry scheduler: %v", err)
+		} else {
+			retryScheduler := agent.NewRetryScheduler(db.GetDB(), retryDispatcher, time.Duration(cfg.AgentTaskRetry.IntervalSec)*time.Second)
+			retryCtx, cancelRetry := context.WithCancel(context.Background())
+			defer cancelRetry()
+			go retryScheduler.Run(retryCtx)
+			log.Println("✓ Agent Task Retry Scheduler initialized")
+		}
+	} else {
+		log.Println("⚠ Agent Task Retry Scheduler requires mTLS but mTLS is not enabled, pushed tasks are not retried")
+	}
+
 	// 9. Initialize Socket.IO server
 	if err := ws.InitServer(); err != nil {
 		log.Fatalf("Failed to initialize Socket.IO server: %v", err)
@@ -233,6 +291,15 @@ func main() {
 	// Setup API v1 routes
 	v1.SetupRouter(r, db.GetDB(), cfg, acmeWorker, tokenStore, caManager, healthWorker)
 
+	// Agent API on mTLS listener (client certificate = agent identity)
+	if cfg.AgentAPI.MTLSAddr != "" {
+		startAgentAPIServer(cfg, caManager)
+	} else if cfg.AgentAPI.InsecureNodeHeader {
+		log.Println("⚠ Agent API mTLS listener disabled, agent routes trust X-Node-Id header (AGENT_API_INSECURE_NODE_HEADER=1)")
+	} else {
+		log.Println("✓ Agent API mTLS listener disabled (AGENT_API_MTLS_ADDR not set)")
+	}
+
 	log.Printf("✓ Server starting on %s", cfg.HTTPAddr)
 
 	// 打印 boot 信息
@@ -255,3 +322,42 @@ func main() {
 		os.Exit(1)
 	}
 }
+
+// startAgentAPIServer serves the agent routes on a listener that requires a client certificate signed by the agent CA
+func startAgentAPIServer(cfg *config.Config, caManager *pki.CAManager) {
+	if cfg.AgentAPI.ServerCert == "" || cfg.AgentAPI.ServerKey == "" {
+		log.Fatal("AGENT_API_CERT and AGENT_API_KEY are required when AGENT_API_MTLS_ADDR is set")
+	}
+
+	serverCert, err := tls.LoadX509KeyPair(cfg.AgentAPI.ServerCert, cfg.AgentAPI.ServerKey)
+	if err != nil {
+		log.Fatalf("Failed to load agent API server certificate: %v", err)
+	}
+
+	clientCAs := x509.NewCertPool()
+	if !clientCAs.AppendCertsFromPEM([]byte(caManager.GetCACertPEM())) {
+		log.Fatal("Failed to load agent CA certificate")
+	}
+
+	agentRouter := gin.New()
+	agentRouter.Use(gin.Logger(), gin.Recovery())
+	v1.SetupAgentRouter(agentRouter, db.GetDB(), cfg)
+
+	server := &http.Server{
+		Addr:    cfg.AgentAPI.MTLSAddr,
+		Handler: agentRouter,
+		TLSConfig: &tls.Config{
+			Certificates: []tls.Certificate{serverCert},
+			ClientCAs:    clientCAs,
+			ClientAuth:   tls.RequireAndVerifyClientCert,
+			MinVersion:   tls.VersionTLS12,
+		},
+	}
+
+	go func() {
+		if err := server.ListenAndServeTLS("", ""); err != nil && err != http.ErrServerClosed {
+			log.Fatalf("Failed to start agent API server: %v", err)
+		}
+	}()
+	log.Printf("✓ Agent API mTLS server starting on %s", cfg.AgentAPI.MTLSAddr)
+}
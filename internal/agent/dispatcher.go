@@ -4,6 +4,7 @@ import (
 	"encoding/json"
 	"fmt"
 	"log"
+	"time"
 
 	"go_cmdb/internal/config"
 	"go_cmdb/internal/model"
@@ -41,43 +42,46 @@ func (d *Dispatcher) DispatchTask(task *model.AgentTask) error {
 	// Verify agent identity (MUST check before dispatching)
 	var identity model.AgentIdentity
 	if err := d.db.Where("node_id = ?", node.ID).First(&identity).Error; err != nil {
-		// Identity not found
-		task.Status = model.TaskStatusFailed
-		task.LastError = "agent identity not found"
-		task.Attempts++
-		if saveErr := d.db.Save(task).Error; saveErr != nil {
-			log.Printf("Failed to update task status: %v", saveErr)
-		}
-		// Update config_versions status if task type is apply_config
-		if task.Type == model.TaskTypeApplyConfig {
-			d.updateConfigVersionStatus(task, model.ConfigVersionStatusFailed, task.LastError)
-		}
+		// Identity not found, retrying cannot help until an operator steps in
+		d.markFailed(task, "agent identity not found", false)
 		return fmt.Errorf("agent identity not found for node %d", node.ID)
 	}
 
 	// Check identity status
 	if identity.Status != model.AgentIdentityStatusActive {
 		// Identity revoked
-		task.Status = model.TaskStatusFailed
-		task.LastError = fmt.Sprintf("agent identity is %s", identity.Status)
-		task.Attempts++
-		if saveErr := d.db.Save(task).Error; saveErr != nil {
-			log.Printf("Failed to update task status: %v", saveErr)
-		}
-		// Update config_versions status if task type is apply_config
-		if task.Type == model.TaskTypeApplyConfig {
-			d.updateConfigVersionStatus(task, model.ConfigVersionStatusFailed, task.LastError)
-		}
+		d.markFailed(task, fmt.Sprintf("agent identity is %s", identity.Status), false)
 		return fmt.Errorf("agent identity is %s for node %d", identity.Status, node.ID)
 	}
 
 	// Build agent URL (HTTPS only)
 	agentURL := fmt.Sprintf("https://%s:%d", node.MainIP, node.AgentPort)
 
-	// Update task status to running
+	// Claim the task: running, each dispatch counts as one attempt.
+	// The claim only succeeds from the status the task was loaded with, so a pulling agent
+	// and the retry scheduler never run the same attempt twice.
+	loadedStatus := task.Status
+	attempts := task.Attempts + 1
+	claim := d.db.Model(task).Where("status = ?", loadedStatus).Updates(map[string]interface{}{
+		"status":        model.TaskStatusRunning,
+		"attempts":      attempts,
+		"next_retry_at": nil,
+		"pushed":        true,
+	})
+	if claim.Error != nil {
+		return fmt.Errorf("failed to update task status: %w", claim.Error)
+	}
+	if claim.RowsAffected == 0 {
+		return fmt.Errorf("task %d is no longer %s", task.ID, loadedStatus)
+	}
 	task.Status = model.TaskStatusRunning
-	if err := d.db.Save(task).Error; err != nil {
-		return fmt.Errorf("failed to update task status: %w", err)
+	task.Attempts = attempts
+	task.NextRetryAt = nil
+	task.Pushed = true
+
+	// Each attempt gets its own requestId, the agent returns the stored result for a known one
+	if task.RequestID == "" {
+		task.RequestID = fmt.Sprintf("agent-task-%d-%d-%d", task.ID, task.Attempts, time.Now().UnixMilli())
 	}
 
 	// Parse payload
@@ -98,28 +102,32 @@ func (d *Dispatcher) DispatchTask(task *model.AgentTask) error {
 	// Send request to agent via mTLS
 	resp, err := d.client.ExecuteTask(agentURL, req)
 	if err != nil {
-		// Task failed (could be mTLS handshake failure or execution error)
-		task.Status = model.TaskStatusFailed
-		task.LastError = err.Error()
-		task.Attempts++
-		if saveErr := d.db.Save(task).Error; saveErr != nil {
-			log.Printf("Failed to update task status after error: %v", saveErr)
-		}
-		// Update config_versions status if task type is apply_config
-		if task.Type == model.TaskTypeApplyConfig {
-			d.updateConfigVersionStatus(task, model.ConfigVersionStatusFailed, task.LastError)
-		}
+		// Task failed (could be mTLS handshake failure or execution error), retried with backoff
+		d.markFailed(task, err.Error(), true)
 		return err
 	}
 
+	// The agent answers code 0 for executed tasks and reports a failed execution in data.status
+	if resp.Data.Status == "failed" {
+		d.markFailed(task, resp.Data.Message, true)
+		return fmt.Errorf("task %d failed on agent: %s", task.ID, resp.Data.Message)
+	}
+
 	// Task succeeded
-	task.Status = model.TaskStatusSuccess
-	task.LastError = ""
-	task.Attempts++
-	if err := d.db.Save(task).Error; err != nil {
+	settled, err := d.settle(task, model.TaskStatusRunning, map[string]interface{}{
+		"status":     model.TaskStatusSuccess,
+		"last_error": "",
+	})
+	if err != nil {
 		log.Printf("Failed to update task status after success: %v", err)
 		return err
 	}
+	if !settled {
+		log.Printf("Task %d attempt %d succeeded after it was reaped or reclaimed, result dropped", task.ID, task.Attempts)
+		return fmt.Errorf("task %d attempt %d is stale", task.ID, task.Attempts)
+	}
+	task.Status = model.TaskStatusSuccess
+	task.LastError = ""
 
 	// Update config_versions status if task type is apply_config
 	if task.Type == model.TaskTypeApplyConfig {
@@ -130,6 +138,55 @@ func (d *Dispatcher) DispatchTask(task *model.AgentTask) error {
 	return nil
 }
 
+// markFailed records a failed attempt. Retryable failures go to retrying with backoff
+// until the attempts are exhausted, everything else is dead-lettered right away.
+// Pulling agents pick up retrying tasks once next_retry_at has passed, the RetryScheduler pushes the pushed ones.
+func (d *Dispatcher) markFailed(task *model.AgentTask, lastError string, retryable bool) {
+	if len(lastError) > 255 {
+		lastError = lastError[:252] + "..."
+	}
+	status := model.TaskStatusDead
+	var nextRetryAt *time.Time
+	if retryable {
+		status, nextRetryAt = GetRetryPolicy().OnFailure(task.Attempts, time.Now())
+	}
+
+	settled, err := d.settle(task, task.Status, map[string]interface{}{
+		"status":        status,
+		"last_error":    lastError,
+		"next_retry_at": nextRetryAt,
+	})
+	if err != nil {
+		log.Printf("Failed to update task status after error: %v", err)
+		return
+	}
+	if !settled {
+		log.Printf("Task %d attempt %d failed after it was reaped or reclaimed, result dropped: %s", task.ID, task.Attempts, lastError)
+		return
+	}
+	task.Status = status
+	task.LastError = lastError
+	task.NextRetryAt = nextRetryAt
+
+	// Update config_versions status once the task has failed for good
+	if task.Status == model.TaskStatusDead && task.Type == model.TaskTypeApplyConfig {
+		d.updateConfigVersionStatus(task, model.ConfigVersionStatusFailed, task.LastError)
+	}
+}
+
+// settle writes the outcome of the attempt the task is on. The update only lands while the row
+// is still in fromStatus with the same attempts, so a result that arrives after the reaper or
+// another claim moved the task on does not overwrite their state.
+func (d *Dispatcher) settle(task *model.AgentTask, fromStatus string, updates map[string]interface{}) (bool, error) {
+	result := d.db.Model(&model.AgentTask{}).
+		Where("id = ? AND status = ? AND attempts = ?", task.ID, fromStatus, task.Attempts).
+		Updates(updates)
+	if result.Error != nil {
+		return false, result.Error
+	}
+	return result.RowsAffected > 0, nil
+}
+
 // updateConfigVersionStatus updates config_versions status based on task result
 func (d *Dispatcher) updateConfigVersionStatus(task *model.AgentTask, status string, lastError string) {
 	// Parse payload to extract version
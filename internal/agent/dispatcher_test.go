@@ -0,0 +1,87 @@
+package agent
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"testing"
+
+	"go_cmdb/internal/model"
+
+	"github.com/glebarez/sqlite"
+	"gorm.io/gorm"
+)
+
+// newStubAgent starts a TLS agent answering /tasks/execute with the given data,
+// and returns a dispatcher wired to it with one pending reload task for its node.
+func newStubAgent(t *testing.T, data map[string]interface{}, onExecute func(db *gorm.DB)) (*Dispatcher, *gorm.DB, *model.AgentTask) {
+	t.Helper()
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Every connection to :memory: is a separate database, the stub agent shares the test's one
+	sqlDB, _ := db.DB()
+	sqlDB.SetMaxOpenConns(1)
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if onExecute != nil {
+			onExecute(db)
+		}
+		// The agent answers code 0 and reports the execution in data.status
+		json.NewEncoder(w).Encode(map[string]interface{}{"code": 0, "message": "success", "data": data})
+	}))
+	t.Cleanup(server.Close)
+	serverURL, _ := url.Parse(server.URL)
+	port, _ := strconv.Atoi(serverURL.Port())
+
+	for _, stmt := range []string{
+		`CREATE TABLE agent_tasks (id INTEGER PRIMARY KEY, node_id INTEGER, type TEXT, payload TEXT, status TEXT, last_error TEXT,
+			attempts INTEGER NOT NULL DEFAULT 0, next_retry_at DATETIME, pushed BOOLEAN NOT NULL DEFAULT 0, created_at DATETIME, updated_at DATETIME)`,
+		`CREATE TABLE nodes (id INTEGER PRIMARY KEY, name TEXT, main_ip TEXT, agent_port INTEGER, created_at DATETIME, updated_at DATETIME)`,
+		`CREATE TABLE agent_identities (id INTEGER PRIMARY KEY, node_id INTEGER, status TEXT)`,
+		fmt.Sprintf(`INSERT INTO nodes (id, name, main_ip, agent_port) VALUES (1, 'edge-1', '%s', %d)`, serverURL.Hostname(), port),
+		`INSERT INTO agent_identities VALUES (1, 1, 'active')`,
+	} {
+		if err := db.Exec(stmt).Error; err != nil {
+			t.Fatal(err)
+		}
+	}
+	task := &model.AgentTask{NodeID: 1, Type: model.TaskTypeReload, Payload: `{}`, Status: model.TaskStatusPending}
+	if err := db.Create(task).Error; err != nil {
+		t.Fatal(err)
+	}
+	return &Dispatcher{db: db, client: &Client{httpClient: server.Client()}}, db, task
+}
+
+func TestDispatchTaskAgentReportsFailure(t *testing.T) {
+	d, db, task := newStubAgent(t, map[string]interface{}{"requestId": "r1", "status": "failed", "message": "nginx reload failed"}, nil)
+	if err := d.DispatchTask(task); err == nil {
+		t.Fatal("expected DispatchTask() to fail")
+	}
+
+	var saved model.AgentTask
+	db.First(&saved, task.ID)
+	if saved.Status != model.TaskStatusRetrying || saved.LastError != "nginx reload failed" || saved.Attempts != 1 {
+		t.Errorf("task = status %s, lastError %q, attempts %d; want retrying after attempt 1", saved.Status, saved.LastError, saved.Attempts)
+	}
+}
+
+func TestDispatchTaskDropsStaleResult(t *testing.T) {
+	// The reaper fails the attempt while the agent is still executing it
+	reap := func(db *gorm.DB) {
+		db.Exec(`UPDATE agent_tasks SET status = 'failed', last_error = 'reaped' WHERE status = 'running'`)
+	}
+	d, db, task := newStubAgent(t, map[string]interface{}{"requestId": "r1", "status": "success"}, reap)
+	if err := d.DispatchTask(task); err == nil {
+		t.Fatal("expected DispatchTask() to report the stale result")
+	}
+
+	var saved model.AgentTask
+	db.First(&saved, task.ID)
+	if saved.Status != model.TaskStatusFailed || saved.LastError != "reaped" {
+		t.Errorf("task = status %s, lastError %q; want the reaper's failed state kept", saved.Status, saved.LastError)
+	}
+}
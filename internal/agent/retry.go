@@ -0,0 +1,91 @@
+package agent
+
+import (
+	"math/rand"
+	"sync"
+	"time"
+
+	"go_cmdb/internal/model"
+)
+
+// RetryPolicy controls automatic retries of failed agent tasks
+type RetryPolicy struct {
+	MaxAttempts int           // Executions allowed before a task is dead-lettered
+	BaseDelay   time.Duration // Backoff before the first retry
+	MaxDelay    time.Duration // Upper bound of the backoff
+}
+
+// DefaultRetryPolicy is used until SetRetryPolicy is called
+var DefaultRetryPolicy = RetryPolicy{
+	MaxAttempts: 5,
+	BaseDelay:   10 * time.Second,
+	MaxDelay:    10 * time.Minute,
+}
+
+var (
+	retryPolicyMu sync.RWMutex
+	retryPolicy   = DefaultRetryPolicy
+)
+
+// SetRetryPolicy replaces the process-wide retry policy, zero fields fall back to the defaults
+func SetRetryPolicy(p RetryPolicy) {
+	if p.MaxAttempts <= 0 {
+		p.MaxAttempts = DefaultRetryPolicy.MaxAttempts
+	}
+	if p.BaseDelay <= 0 {
+		p.BaseDelay = DefaultRetryPolicy.BaseDelay
+	}
+	if p.MaxDelay <= 0 {
+		p.MaxDelay = DefaultRetryPolicy.MaxDelay
+	}
+	if p.MaxDelay < p.BaseDelay {
+		p.MaxDelay = p.BaseDelay
+	}
+
+	retryPolicyMu.Lock()
+	retryPolicy = p
+	retryPolicyMu.Unlock()
+}
+
+// GetRetryPolicy returns the process-wide retry policy
+func GetRetryPolicy() RetryPolicy {
+	retryPolicyMu.RLock()
+	defer retryPolicyMu.RUnlock()
+	return retryPolicy
+}
+
+// Backoff returns the delay before the next retry after the given number of attempts.
+// The delay doubles per attempt up to MaxDelay; jitter in [0,1) randomizes its upper half
+// so that tasks failing together do not retry together.
+func (p RetryPolicy) Backoff(attempts int, jitter float64) time.Duration {
+	delay := p.BaseDelay
+	for i := 1; i < attempts && delay < p.MaxDelay; i++ {
+		delay *= 2
+	}
+	if delay > p.MaxDelay {
+		delay = p.MaxDelay
+	}
+
+	if jitter < 0 {
+		jitter = 0
+	} else if jitter >= 1 {
+		jitter = 0.999
+	}
+	half := delay / 2
+	return half + time.Duration(float64(delay-half)*jitter)
+}
+
+// Decide returns the status of a task that failed its attempts-th execution,
+// and the time of the next retry when it is retried
+func (p RetryPolicy) Decide(attempts int, now time.Time, jitter float64) (string, *time.Time) {
+	if attempts >= p.MaxAttempts {
+		return model.TaskStatusDead, nil
+	}
+	next := now.Add(p.Backoff(attempts, jitter))
+	return model.TaskStatusRetrying, &next
+}
+
+// OnFailure is Decide with a random jitter
+func (p RetryPolicy) OnFailure(attempts int, now time.Time) (string, *time.Time) {
+	return p.Decide(attempts, now, rand.Float64())
+}
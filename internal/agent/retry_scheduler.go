@@ -0,0 +1,86 @@
+package agent
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"go_cmdb/internal/model"
+
+	"gorm.io/gorm"
+)
+
+// retryBatchSize is the maximum number of retries pushed per run
+const retryBatchSize = 100
+
+// RetryScheduler pushes due retries of tasks that were pushed over mTLS.
+// Pulling agents claim their retrying tasks themselves, pushed tasks have nobody else to retry them.
+type RetryScheduler struct {
+	db         *gorm.DB
+	dispatcher *Dispatcher
+	interval   time.Duration
+}
+
+// NewRetryScheduler creates a new retry scheduler
+func NewRetryScheduler(db *gorm.DB, dispatcher *Dispatcher, interval time.Duration) *RetryScheduler {
+	if interval <= 0 {
+		interval = 10 * time.Second
+	}
+	return &RetryScheduler{
+		db:         db,
+		dispatcher: dispatcher,
+		interval:   interval,
+	}
+}
+
+// dueRetries returns pushed tasks whose backoff has passed, earliest first
+func dueRetries(db *gorm.DB, now time.Time, limit int) ([]model.AgentTask, error) {
+	var tasks []model.AgentTask
+	if err := db.Where("status = ? AND pushed = ? AND next_retry_at <= ?", model.TaskStatusRetrying, true, now).
+		Order("next_retry_at ASC").
+		Limit(limit).
+		Find(&tasks).Error; err != nil {
+		return nil, fmt.Errorf("failed to query retrying tasks: %w", err)
+	}
+	return tasks, nil
+}
+
+// RunOnce pushes the due retries, returns the number of tasks pushed
+func (s *RetryScheduler) RunOnce() (int, error) {
+	tasks, err := dueRetries(s.db, time.Now(), retryBatchSize)
+	if err != nil {
+		return 0, err
+	}
+
+	pushed := 0
+	for i := range tasks {
+		// Failures are recorded on the task by the dispatcher (retrying again or dead)
+		if err := s.dispatcher.DispatchTask(&tasks[i]); err != nil {
+			log.Printf("[Agent Task Retry] Retry of task %d (attempt %d) failed: %v", tasks[i].ID, tasks[i].Attempts, err)
+			continue
+		}
+		pushed++
+	}
+	return pushed, nil
+}
+
+// Run pushes due retries until ctx is done
+func (s *RetryScheduler) Run(ctx context.Context) {
+	log.Printf("[Agent Task Retry] Starting retry scheduler (interval=%v)", s.interval)
+
+	ticker := time.NewTicker(s.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			log.Println("[Agent Task Retry] Retry scheduler stopped")
+			return
+		case <-ticker.C:
+			if _, err := s.RunOnce(); err != nil {
+				log.Printf("[Agent Task Retry] Run failed: %v", err)
+			}
+		}
+	}
+}
@@ -0,0 +1,69 @@
+package agent
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"go_cmdb/internal/model"
+
+	"github.com/glebarez/sqlite"
+	"gorm.io/gorm"
+)
+
+func TestRetrySchedulerDueRetries(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Date(2026, 10, 17, 8, 0, 0, 0, time.UTC)
+
+	// MySQL enum/json columns do not migrate on sqlite, create the tables by hand
+	for _, stmt := range []string{
+		`CREATE TABLE agent_tasks (id INTEGER PRIMARY KEY, node_id INTEGER, type TEXT, payload TEXT, status TEXT, last_error TEXT,
+			attempts INTEGER NOT NULL DEFAULT 0, next_retry_at DATETIME, pushed BOOLEAN NOT NULL DEFAULT 0, created_at DATETIME, updated_at DATETIME)`,
+		`CREATE TABLE nodes (id INTEGER PRIMARY KEY, name TEXT, main_ip TEXT, agent_port INTEGER, created_at DATETIME, updated_at DATETIME)`,
+		`CREATE TABLE agent_identities (id INTEGER PRIMARY KEY, node_id INTEGER, status TEXT)`,
+		`INSERT INTO nodes (id, name, main_ip, agent_port) VALUES (1, 'edge-1', '10.0.0.1', 9443)`,
+		`INSERT INTO agent_identities VALUES (1, 1, 'active')`,
+	} {
+		if err := db.Exec(stmt).Error; err != nil {
+			t.Fatal(err)
+		}
+	}
+	// 1: pushed and due; 2: pushed, backoff not passed; 3: pulled task; 4: pushed, dead
+	for _, task := range []model.AgentTask{
+		{BaseModel: model.BaseModel{ID: 1}, NodeID: 1, Type: model.TaskTypeReload, Status: model.TaskStatusRetrying, Attempts: 1, NextRetryAt: timePtr(now.Add(-time.Second)), Pushed: true},
+		{BaseModel: model.BaseModel{ID: 2}, NodeID: 1, Type: model.TaskTypeReload, Status: model.TaskStatusRetrying, Attempts: 1, NextRetryAt: timePtr(now.Add(time.Minute)), Pushed: true},
+		{BaseModel: model.BaseModel{ID: 3}, NodeID: 1, Type: model.TaskTypeReload, Status: model.TaskStatusRetrying, Attempts: 1, NextRetryAt: timePtr(now.Add(-time.Minute))},
+		{BaseModel: model.BaseModel{ID: 4}, NodeID: 1, Type: model.TaskTypeReload, Status: model.TaskStatusDead, Attempts: 5, Pushed: true},
+	} {
+		if err := db.Create(&task).Error; err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tasks, err := dueRetries(db, now, retryBatchSize)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(tasks) != 1 || tasks[0].ID != 1 {
+		t.Fatalf("dueRetries() = %+v, want task 1 only", tasks)
+	}
+
+	// A pulling agent claimed the task in the meantime: the push must not run the attempt again
+	db.Exec("UPDATE agent_tasks SET status = 'running', attempts = 2 WHERE id = 1")
+	d := &Dispatcher{db: db}
+	if err := d.DispatchTask(&tasks[0]); err == nil || !strings.Contains(err.Error(), "no longer retrying") {
+		t.Fatalf("DispatchTask() = %v, want claim conflict", err)
+	}
+	var attempts int
+	db.Raw("SELECT attempts FROM agent_tasks WHERE id = 1").Scan(&attempts)
+	if attempts != 2 {
+		t.Errorf("attempts = %d, want 2", attempts)
+	}
+}
+
+func timePtr(t time.Time) *time.Time {
+	return &t
+}
@@ -0,0 +1,64 @@
+package agent
+
+import (
+	"testing"
+	"time"
+
+	"go_cmdb/internal/model"
+)
+
+func TestRetryPolicyBackoff(t *testing.T) {
+	p := RetryPolicy{MaxAttempts: 5, BaseDelay: 10 * time.Second, MaxDelay: 60 * time.Second}
+
+	tests := []struct {
+		attempts int
+		jitter   float64
+		want     time.Duration
+	}{
+		{attempts: 1, jitter: 0, want: 5 * time.Second},
+		{attempts: 1, jitter: 0.5, want: 7500 * time.Millisecond},
+		{attempts: 2, jitter: 0, want: 10 * time.Second},
+		{attempts: 3, jitter: 0, want: 20 * time.Second},
+		{attempts: 4, jitter: 0, want: 30 * time.Second},
+		{attempts: 10, jitter: 0, want: 30 * time.Second},
+		{attempts: 1000, jitter: 0, want: 30 * time.Second},
+	}
+
+	for _, tt := range tests {
+		if got := p.Backoff(tt.attempts, tt.jitter); got != tt.want {
+			t.Errorf("Backoff(%d, %v) = %v, want %v", tt.attempts, tt.jitter, got, tt.want)
+		}
+	}
+
+	if got := p.Backoff(4, 0.999999); got >= 60*time.Second {
+		t.Errorf("Backoff must stay below MaxDelay, got %v", got)
+	}
+}
+
+func TestRetryPolicyDecide(t *testing.T) {
+	p := RetryPolicy{MaxAttempts: 3, BaseDelay: 10 * time.Second, MaxDelay: time.Minute}
+	now := time.Date(2026, 10, 17, 12, 0, 0, 0, time.UTC)
+
+	status, next := p.Decide(1, now, 0)
+	if status != model.TaskStatusRetrying {
+		t.Fatalf("status = %s, want %s", status, model.TaskStatusRetrying)
+	}
+	if next == nil || !next.Equal(now.Add(5*time.Second)) {
+		t.Fatalf("nextRetryAt = %v, want %v", next, now.Add(5*time.Second))
+	}
+
+	status, next = p.Decide(3, now, 0)
+	if status != model.TaskStatusDead || next != nil {
+		t.Fatalf("Decide(3) = %s, %v, want dead, nil", status, next)
+	}
+}
+
+func TestSetRetryPolicyDefaults(t *testing.T) {
+	defer SetRetryPolicy(DefaultRetryPolicy)
+
+	SetRetryPolicy(RetryPolicy{MaxAttempts: 2})
+	got := GetRetryPolicy()
+	if got.MaxAttempts != 2 || got.BaseDelay != DefaultRetryPolicy.BaseDelay || got.MaxDelay != DefaultRetryPolicy.MaxDelay {
+		t.Fatalf("unexpected policy: %+v", got)
+	}
+}
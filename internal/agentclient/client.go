@@ -8,6 +8,7 @@ import (
 	"fmt"
 	"io"
 	"net/http"
+	"net/url"
 	"os"
 	"time"
 
@@ -66,23 +67,26 @@ func NewClient(cfg *config.Config) (*Client, error) {
 	}, nil
 }
 
-// Dispatch 派发apply_config任务到Agent
-// nodeIP: Agent的IP地址
-// version: 配置版本号
-// 返回: taskID（用于后续查询）和错误
-func (c *Client) Dispatch(nodeIP string, version int64) (string, error) {
-	// 构造Agent URL（假设Agent监听8443端口）
-	agentURL := fmt.Sprintf("https://%s:8443", nodeIP)
+// TaskID 生成任务ID（使用taskType + nodeIP + version，重复派发由Agent幂等处理）
+func TaskID(taskType, nodeIP string, version int64) string {
+	return fmt.Sprintf("%s_%s_%d", taskType, nodeIP, version)
+}
 
-	// 生成taskID（使用nodeIP + version）
-	taskID := fmt.Sprintf("apply_config_%s_%d", nodeIP, version)
+// Dispatch 派发任务到Agent（异步，Agent接受后立即返回）
+// nodeIP: Agent的IP地址
+// agentPort: Agent的端口
+// taskType: 任务类型（apply_config/rollback_config）
+// taskID: 任务ID（requestId，见 TaskID）
+// payload: 任务 payload（apply_config 由 configgen 按节点生成）
+// 返回: taskID（即requestId，用于后续查询）和错误
+func (c *Client) Dispatch(nodeIP string, agentPort int, taskType, taskID string, payload json.RawMessage) (string, error) {
+	agentURL := agentBaseURL(nodeIP, agentPort)
 
 	// 构造请求
 	req := DispatchRequest{
-		TaskID:  taskID,
-		Type:    "apply_config",
-		Version: version,
-		Payload: json.RawMessage("{}"), // apply_config不需要额外payload
+		RequestID: taskID,
+		Type:      taskType,
+		Payload:   payload,
 	}
 
 	// 序列化请求
@@ -92,7 +96,7 @@ func (c *Client) Dispatch(nodeIP string, version int64) (string, error) {
 	}
 
 	// 创建HTTP请求
-	httpReq, err := http.NewRequest("POST", agentURL+"/tasks/dispatch", bytes.NewBuffer(body))
+	httpReq, err := http.NewRequest("POST", agentURL+"/agent/v1/tasks", bytes.NewBuffer(body))
 	if err != nil {
 		return "", fmt.Errorf("failed to create request: %w", err)
 	}
@@ -113,8 +117,8 @@ func (c *Client) Dispatch(nodeIP string, version int64) (string, error) {
 		return "", fmt.Errorf("failed to read response: %w", err)
 	}
 
-	// 检查HTTP状态码
-	if httpResp.StatusCode != http.StatusOK {
+	// 检查HTTP状态码（202=已接受，200=重复requestId返回已有状态）
+	if httpResp.StatusCode != http.StatusAccepted && httpResp.StatusCode != http.StatusOK {
 		return "", fmt.Errorf("agent %s returned status %d: %s", nodeIP, httpResp.StatusCode, string(respBody))
 	}
 
@@ -129,19 +133,19 @@ func (c *Client) Dispatch(nodeIP string, version int64) (string, error) {
 		return "", fmt.Errorf("agent %s returned error code %d: %s", nodeIP, resp.Code, resp.Message)
 	}
 
-	return resp.Data.TaskID, nil
+	return resp.Data.RequestID, nil
 }
 
 // Query 查询任务状态
 // nodeIP: Agent的IP地址
-// taskID: 任务ID
+// agentPort: Agent的端口
+// taskID: 任务ID（requestId）
 // 返回: status（pending/running/success/failed）、lastError和错误
-func (c *Client) Query(nodeIP string, taskID string) (string, string, error) {
-	// 构造Agent URL
-	agentURL := fmt.Sprintf("https://%s:8443", nodeIP)
+func (c *Client) Query(nodeIP string, agentPort int, taskID string) (string, string, error) {
+	agentURL := agentBaseURL(nodeIP, agentPort)
 
 	// 创建HTTP请求
-	httpReq, err := http.NewRequest("GET", agentURL+"/tasks/"+taskID, nil)
+	httpReq, err := http.NewRequest("GET", agentURL+"/agent/v1/tasks/"+url.PathEscape(taskID), nil)
 	if err != nil {
 		return "", "", fmt.Errorf("failed to create request: %w", err)
 	}
@@ -181,3 +185,43 @@ func (c *Client) Query(nodeIP string, taskID string) (string, string, error) {
 
 	return resp.Data.Status, resp.Data.LastError, nil
 }
+
+// Ping 查询Agent状态（GET /agent/v1/ping）
+// 返回: Agent上报的配置状态（applied/lastSuccess/live版本）和错误
+func (c *Client) Ping(nodeIP string, agentPort int) (*AgentState, error) {
+	agentURL := agentBaseURL(nodeIP, agentPort)
+
+	httpResp, err := c.httpClient.Get(agentURL + "/agent/v1/ping")
+	if err != nil {
+		return nil, fmt.Errorf("failed to send request to agent %s: %w", nodeIP, err)
+	}
+	defer httpResp.Body.Close()
+
+	respBody, err := io.ReadAll(httpResp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read response: %w", err)
+	}
+
+	if httpResp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("agent %s returned status %d: %s", nodeIP, httpResp.StatusCode, string(respBody))
+	}
+
+	var resp PingResponse
+	if err := json.Unmarshal(respBody, &resp); err != nil {
+		return nil, fmt.Errorf("failed to parse response: %w", err)
+	}
+
+	if resp.Code != 0 || resp.Data == nil {
+		return nil, fmt.Errorf("agent %s returned error code %d: %s", nodeIP, resp.Code, resp.Message)
+	}
+
+	return resp.Data, nil
+}
+
+// agentBaseURL 构造Agent URL（端口未配置时使用8443）
+func agentBaseURL(nodeIP string, agentPort int) string {
+	if agentPort <= 0 {
+		agentPort = 8443
+	}
+	return fmt.Sprintf("https://%s:%d", nodeIP, agentPort)
+}
@@ -4,10 +4,9 @@ import "encoding/json"
 
 // DispatchRequest 派发任务请求
 type DispatchRequest struct {
-	TaskID  string          `json:"taskId"`
-	Type    string          `json:"type"`
-	Version int64           `json:"version,omitempty"`
-	Payload json.RawMessage `json:"payload"`
+	RequestID string          `json:"requestId"`
+	Type      string          `json:"type"`
+	Payload   json.RawMessage `json:"payload"`
 }
 
 // DispatchResponse 派发任务响应
@@ -15,8 +14,8 @@ type DispatchResponse struct {
 	Code    int    `json:"code"`
 	Message string `json:"message"`
 	Data    struct {
-		TaskID string `json:"taskId"`
-		Status string `json:"status"`
+		RequestID string `json:"requestId"`
+		Status    string `json:"status"`
 	} `json:"data"`
 }
 
@@ -25,12 +24,28 @@ type QueryResponse struct {
 	Code    int    `json:"code"`
 	Message string `json:"message"`
 	Data    struct {
-		TaskID     string  `json:"taskId"`
+		RequestID  string  `json:"requestId"`
 		Type       string  `json:"type"`
 		Status     string  `json:"status"`
+		Message    string  `json:"message,omitempty"`
 		LastError  string  `json:"lastError,omitempty"`
 		CreatedAt  string  `json:"createdAt"`
 		StartedAt  *string `json:"startedAt,omitempty"`
 		FinishedAt *string `json:"finishedAt,omitempty"`
 	} `json:"data"`
 }
+
+// AgentState Agent上报的配置状态
+type AgentState struct {
+	AppliedVersion     int64 `json:"appliedVersion"`
+	LastSuccessVersion int64 `json:"lastSuccessVersion"`
+	LiveVersion        int64 `json:"liveVersion"`
+	ConfigTestOK       bool  `json:"configTestOk"`
+}
+
+// PingResponse 探活响应
+type PingResponse struct {
+	Code    int         `json:"code"`
+	Message string      `json:"message"`
+	Data    *AgentState `json:"data"`
+}
@@ -128,8 +128,13 @@ func List(ctx context.Context, params ListParams) (*ListResult, error) {
 // Create creates a new API key
 func Create(ctx context.Context, params CreateParams) error {
 	// Validate provider
-	if params.Provider != "cloudflare" {
-		return fmt.Errorf("invalid provider: %s (only 'cloudflare' is supported)", params.Provider)
+	if params.Provider != string(model.APIKeyProviderCloudflare) && params.Provider != string(model.APIKeyProviderAliyun) {
+		return fmt.Errorf("invalid provider: %s (only 'cloudflare' and 'aliyun' are supported)", params.Provider)
+	}
+
+	// Aliyun signs requests with the AccessKey ID (account) and secret (apiToken)
+	if params.Provider == string(model.APIKeyProviderAliyun) && strings.TrimSpace(params.Account) == "" {
+		return fmt.Errorf("account (AccessKey ID) is required for aliyun")
 	}
 
 	// Validate required fields
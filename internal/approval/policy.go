@@ -0,0 +1,77 @@
+package approval
+
+import (
+	"fmt"
+	"strings"
+
+	"go_cmdb/internal/model"
+
+	"gorm.io/gorm"
+)
+
+// Change describes what a release changes; policies decide from it whether approval is needed
+type Change struct {
+	NodeCount     int  // Number of nodes the release goes to
+	WebsiteDelete bool // The release deletes a website
+	HTTPSChange   bool // The release changes the HTTPS settings of a website
+}
+
+// ValidatePolicy checks the kind and threshold of an approval policy
+func ValidatePolicy(kind model.ReleaseApprovalPolicyKind, threshold int) error {
+	switch kind {
+	case model.ReleaseApprovalPolicyWebsiteDelete, model.ReleaseApprovalPolicyHTTPSChange:
+		return nil
+	case model.ReleaseApprovalPolicyNodeCount:
+		if threshold < 0 {
+			return fmt.Errorf("threshold must be >= 0")
+		}
+		return nil
+	default:
+		return fmt.Errorf("invalid kind %q", kind)
+	}
+}
+
+// Reasons returns why the change needs approval under the enabled policies, empty if it does not
+func Reasons(policies []model.ReleaseApprovalPolicy, change Change) []string {
+	var reasons []string
+	for _, p := range policies {
+		if !p.Enabled {
+			continue
+		}
+
+		switch p.Kind {
+		case model.ReleaseApprovalPolicyWebsiteDelete:
+			if change.WebsiteDelete {
+				reasons = append(reasons, fmt.Sprintf("%s: website delete", p.Name))
+			}
+		case model.ReleaseApprovalPolicyHTTPSChange:
+			if change.HTTPSChange {
+				reasons = append(reasons, fmt.Sprintf("%s: https change", p.Name))
+			}
+		case model.ReleaseApprovalPolicyNodeCount:
+			if change.NodeCount > p.Threshold {
+				reasons = append(reasons, fmt.Sprintf("%s: %d nodes > %d", p.Name, change.NodeCount, p.Threshold))
+			}
+		}
+	}
+	return reasons
+}
+
+// Evaluate loads the enabled policies and returns the approval reason for the change ("" if none)
+func Evaluate(db *gorm.DB, change Change) (string, error) {
+	var policies []model.ReleaseApprovalPolicy
+	if err := db.Where("enabled = ?", true).Order("id ASC").Find(&policies).Error; err != nil {
+		return "", fmt.Errorf("failed to query approval policies: %w", err)
+	}
+
+	reason := strings.Join(Reasons(policies, change), "; ")
+	if r := []rune(reason); len(r) > 500 {
+		reason = string(r[:500])
+	}
+	return reason, nil
+}
+
+// CanApprove reports whether a user with the role may approve or reject releases
+func CanApprove(role string) bool {
+	return role == model.UserRoleAdmin || role == model.UserRoleApprover
+}
@@ -0,0 +1,44 @@
+package approval
+
+import (
+	"testing"
+
+	"go_cmdb/internal/model"
+)
+
+func TestReasons(t *testing.T) {
+	policies := []model.ReleaseApprovalPolicy{
+		{Name: "delete", Kind: model.ReleaseApprovalPolicyWebsiteDelete, Enabled: true},
+		{Name: "https", Kind: model.ReleaseApprovalPolicyHTTPSChange, Enabled: true},
+		{Name: "large", Kind: model.ReleaseApprovalPolicyNodeCount, Threshold: 10, Enabled: true},
+		{Name: "disabled", Kind: model.ReleaseApprovalPolicyNodeCount, Threshold: 0, Enabled: false},
+	}
+
+	tests := []struct {
+		name   string
+		change Change
+		want   int
+	}{
+		{"no match", Change{NodeCount: 10}, 0},
+		{"node count", Change{NodeCount: 11}, 1},
+		{"delete", Change{NodeCount: 1, WebsiteDelete: true}, 1},
+		{"https and node count", Change{NodeCount: 20, HTTPSChange: true}, 2},
+	}
+	for _, tt := range tests {
+		if got := Reasons(policies, tt.change); len(got) != tt.want {
+			t.Errorf("%s: Reasons() = %v, want %d reasons", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestValidatePolicy(t *testing.T) {
+	if err := ValidatePolicy(model.ReleaseApprovalPolicyNodeCount, 5); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+	if err := ValidatePolicy(model.ReleaseApprovalPolicyNodeCount, -1); err == nil {
+		t.Error("expected error for negative threshold")
+	}
+	if err := ValidatePolicy("unknown", 0); err == nil {
+		t.Error("expected error for unknown kind")
+	}
+}
@@ -24,6 +24,9 @@ type Config struct {
 	ACMEWorker       ACMEWorkerConfig
 	CertCleaner      CertCleanerConfig
 	NodeHealthWorker NodeHealthWorkerConfig
+	AgentAPI         AgentAPIConfig
+	AgentTaskRetry   AgentTaskRetryConfig
+	AgentTaskReaper  AgentTaskReaperConfig
 }
 
 // MySQLConfig holds MySQL configuration
@@ -53,6 +56,33 @@ type MTLSConfig struct {
 	CACert     string
 }
 
+// AgentAPIConfig holds configuration of the agent-facing API (tasks pull/update-status)
+type AgentAPIConfig struct {
+	MTLSAddr           string // mTLS listener address, empty = disabled
+	ServerCert         string // Server certificate of the mTLS listener
+	ServerKey          string // Server key of the mTLS listener
+	InsecureNodeHeader bool   // Trust X-Node-Id header without client certificate (dev only)
+}
+
+// AgentTaskRetryConfig holds the retry policy of failed agent tasks
+type AgentTaskRetryConfig struct {
+	MaxAttempts  int // Executions before a task is dead-lettered
+	BaseDelaySec int // Backoff before the first retry, doubled per attempt
+	MaxDelaySec  int // Upper bound of the backoff
+	IntervalSec  int // Interval of the scheduler pushing due retries of pushed tasks
+}
+
+// AgentTaskReaperConfig holds configuration of the reaper for agent tasks stuck in running
+type AgentTaskReaperConfig struct {
+	Enabled                  bool
+	IntervalSec              int
+	TimeoutSec               int // Timeout of task types without a specific timeout
+	ApplyConfigTimeoutSec    int
+	RollbackConfigTimeoutSec int
+	ReloadTimeoutSec         int
+	PurgeCacheTimeoutSec     int
+}
+
 // RiskScannerConfig holds risk scanner configuration
 type RiskScannerConfig struct {
 	Enabled               bool
@@ -66,6 +96,7 @@ type RiskScannerConfig struct {
 type ReleaseExecutorConfig struct {
 	Enabled     bool
 	IntervalSec int
+	MaxWorkers  int // Maximum number of releases executed concurrently
 }
 
 // DNSWorkerConfig holds DNS worker configuration
@@ -136,12 +167,34 @@ func Load() (*Config, error) {
 		ReleaseExecutor: ReleaseExecutorConfig{
 			Enabled:     getEnv("RELEASE_EXECUTOR_ENABLED", "1") == "1",
 			IntervalSec: getEnvInt("RELEASE_EXECUTOR_INTERVAL_SEC", 5),
+			MaxWorkers:  getEnvInt("RELEASE_EXECUTOR_MAX_WORKERS", 4),
 		},
 		DNSWorker: DNSWorkerConfig{
 			Enabled:     getEnv("DNS_WORKER_ENABLED", "1") == "1",
 			IntervalSec: getEnvInt("DNS_WORKER_INTERVAL_SEC", 30),
 			BatchSize:   getEnvInt("DNS_WORKER_BATCH_SIZE", 10),
 		},
+		AgentAPI: AgentAPIConfig{
+			MTLSAddr:           getEnv("AGENT_API_MTLS_ADDR", ""),
+			ServerCert:         getEnv("AGENT_API_CERT", ""),
+			ServerKey:          getEnv("AGENT_API_KEY", ""),
+			InsecureNodeHeader: getEnv("AGENT_API_INSECURE_NODE_HEADER", "0") == "1",
+		},
+		AgentTaskRetry: AgentTaskRetryConfig{
+			MaxAttempts:  getEnvInt("AGENT_TASK_MAX_ATTEMPTS", 5),
+			BaseDelaySec: getEnvInt("AGENT_TASK_RETRY_BASE_SEC", 10),
+			MaxDelaySec:  getEnvInt("AGENT_TASK_RETRY_MAX_SEC", 600),
+			IntervalSec:  getEnvInt("AGENT_TASK_RETRY_INTERVAL_SEC", 10),
+		},
+		AgentTaskReaper: AgentTaskReaperConfig{
+			Enabled:                  getEnv("AGENT_TASK_REAPER_ENABLED", "1") == "1",
+			IntervalSec:              getEnvInt("AGENT_TASK_REAPER_INTERVAL_SEC", 60),
+			TimeoutSec:               getEnvInt("AGENT_TASK_TIMEOUT_SEC", 300),
+			ApplyConfigTimeoutSec:    getEnvInt("AGENT_TASK_APPLY_CONFIG_TIMEOUT_SEC", 300),
+			RollbackConfigTimeoutSec: getEnvInt("AGENT_TASK_ROLLBACK_CONFIG_TIMEOUT_SEC", 300),
+			ReloadTimeoutSec:         getEnvInt("AGENT_TASK_RELOAD_TIMEOUT_SEC", 120),
+			PurgeCacheTimeoutSec:     getEnvInt("AGENT_TASK_PURGE_CACHE_TIMEOUT_SEC", 600),
+		},
 	}
 
 	// Validate required fields
@@ -256,6 +309,7 @@ func LoadFromINI(iniPath string) (*Config, error) {
 		ReleaseExecutor: ReleaseExecutorConfig{
 			Enabled:     getValueBool("RELEASE_EXECUTOR_ENABLED", "release_executor", "enabled", true),
 			IntervalSec: getValueInt("RELEASE_EXECUTOR_INTERVAL_SEC", "release_executor", "interval_sec", 5),
+			MaxWorkers:  getValueInt("RELEASE_EXECUTOR_MAX_WORKERS", "release_executor", "max_workers", 4),
 		},
 		DNSWorker: DNSWorkerConfig{
 			Enabled:     getValueBool("DNS_WORKER_ENABLED", "dns", "worker_enabled", true),
@@ -279,6 +333,27 @@ ACMEWorker: ACMEWorkerConfig{
 				Concurrency:          getValueInt("NODE_HEALTH_WORKER_CONCURRENCY", "nodeHealthWorker", "concurrency", 10),
 				OfflineFailThreshold: getValueInt("NODE_HEALTH_WORKER_OFFLINE_THRESHOLD", "nodeHealthWorker", "offlineFailThreshold", 2),
 			},
+			AgentAPI: AgentAPIConfig{
+				MTLSAddr:           getValue("AGENT_API_MTLS_ADDR", "agent_api", "mtls_addr", ""),
+				ServerCert:         getValue("AGENT_API_CERT", "agent_api", "server_cert", ""),
+				ServerKey:          getValue("AGENT_API_KEY", "agent_api", "server_key", ""),
+				InsecureNodeHeader: getValueBool("AGENT_API_INSECURE_NODE_HEADER", "agent_api", "insecure_node_header", false),
+			},
+			AgentTaskRetry: AgentTaskRetryConfig{
+				MaxAttempts:  getValueInt("AGENT_TASK_MAX_ATTEMPTS", "agent_task", "max_attempts", 5),
+				BaseDelaySec: getValueInt("AGENT_TASK_RETRY_BASE_SEC", "agent_task", "retry_base_sec", 10),
+				MaxDelaySec:  getValueInt("AGENT_TASK_RETRY_MAX_SEC", "agent_task", "retry_max_sec", 600),
+				IntervalSec:  getValueInt("AGENT_TASK_RETRY_INTERVAL_SEC", "agent_task", "retry_interval_sec", 10),
+			},
+			AgentTaskReaper: AgentTaskReaperConfig{
+				Enabled:                  getValueBool("AGENT_TASK_REAPER_ENABLED", "agent_task", "reaper_enabled", true),
+				IntervalSec:              getValueInt("AGENT_TASK_REAPER_INTERVAL_SEC", "agent_task", "reaper_interval_sec", 60),
+				TimeoutSec:               getValueInt("AGENT_TASK_TIMEOUT_SEC", "agent_task", "timeout_sec", 300),
+				ApplyConfigTimeoutSec:    getValueInt("AGENT_TASK_APPLY_CONFIG_TIMEOUT_SEC", "agent_task", "apply_config_timeout_sec", 300),
+				RollbackConfigTimeoutSec: getValueInt("AGENT_TASK_ROLLBACK_CONFIG_TIMEOUT_SEC", "agent_task", "rollback_config_timeout_sec", 300),
+				ReloadTimeoutSec:         getValueInt("AGENT_TASK_RELOAD_TIMEOUT_SEC", "agent_task", "reload_timeout_sec", 120),
+				PurgeCacheTimeoutSec:     getValueInt("AGENT_TASK_PURGE_CACHE_TIMEOUT_SEC", "agent_task", "purge_cache_timeout_sec", 600),
+			},
 		}
 
 	// Validate required fields
@@ -19,17 +19,30 @@ func NewAggregator(db *gorm.DB) *Aggregator {
 	return &Aggregator{db: db}
 }
 
-// GeneratePayload generates the complete apply_config payload for a node
+// GeneratePayload generates the apply_config payload for a node.
+// Only websites served by the node are included: a website is served by a node when
+// the node group of the website's line group contains one of the node's enabled IPs.
 func (a *Aggregator) GeneratePayload(nodeID int, version int64) (*ApplyConfigPayload, error) {
-	// Get all active websites
-	var websites []model.Website
-	if err := a.db.Where("status = ?", model.WebsiteStatusActive).Find(&websites).Error; err != nil {
-		return nil, fmt.Errorf("failed to query websites: %w", err)
+	lineGroupIDs, err := NodeLineGroupIDs(a.db, nodeID)
+	if err != nil {
+		return nil, err
 	}
 
 	payload := &ApplyConfigPayload{
 		Version:  version,
-		Websites: make([]WebsiteConfig, 0, len(websites)),
+		Websites: make([]WebsiteConfig, 0),
+	}
+
+	if len(lineGroupIDs) == 0 {
+		return payload, nil
+	}
+
+	// Get active websites of those line groups
+	var websites []model.Website
+	if err := a.db.Where("status = ? AND line_group_id IN ?", model.WebsiteStatusActive, lineGroupIDs).
+		Order("id ASC").
+		Find(&websites).Error; err != nil {
+		return nil, fmt.Errorf("failed to query websites: %w", err)
 	}
 
 	for _, website := range websites {
@@ -38,12 +51,48 @@ func (a *Aggregator) GeneratePayload(nodeID int, version int64) (*ApplyConfigPay
 			return nil, fmt.Errorf("failed to build config for website %d: %w", website.ID, err)
 		}
 
+		// Websites without an origin set have no upstream to proxy to yet
+		if websiteConfig.Origin.Mode != model.OriginModeRedirect && websiteConfig.Origin.UpstreamName == "" {
+			continue
+		}
+
 		payload.Websites = append(payload.Websites, *websiteConfig)
 	}
 
 	return payload, nil
 }
 
+// NodeLineGroupIDs returns the line groups whose node group contains an enabled IP of the node
+// (line_groups.node_group_id -> node_group_ips.ip_id -> node_ips.node_id)
+func NodeLineGroupIDs(db *gorm.DB, nodeID int) ([]int64, error) {
+	lineGroupIDs, err := NodesLineGroupIDs(db, []int{nodeID})
+	if err != nil {
+		return nil, fmt.Errorf("failed to query line groups of node %d: %w", nodeID, err)
+	}
+	return lineGroupIDs, nil
+}
+
+// NodesLineGroupIDs returns the line groups served by any of the nodes
+func NodesLineGroupIDs(db *gorm.DB, nodeIDs []int) ([]int64, error) {
+	var lineGroupIDs []int64
+	if len(nodeIDs) == 0 {
+		return lineGroupIDs, nil
+	}
+
+	if err := db.Table("line_groups").
+		Select("DISTINCT line_groups.id").
+		Joins("JOIN node_group_ips ON line_groups.node_group_id = node_group_ips.node_group_id").
+		Joins("JOIN node_ips ON node_group_ips.ip_id = node_ips.id").
+		Where("node_ips.node_id IN ?", nodeIDs).
+		Where("node_ips.enabled = ?", true).
+		Order("line_groups.id ASC").
+		Pluck("line_groups.id", &lineGroupIDs).Error; err != nil {
+		return nil, err
+	}
+
+	return lineGroupIDs, nil
+}
+
 // buildWebsiteConfig builds configuration for a single website
 func (a *Aggregator) buildWebsiteConfig(website *model.Website) (*WebsiteConfig, error) {
 	config := &WebsiteConfig{
@@ -72,6 +121,18 @@ func (a *Aggregator) buildWebsiteConfig(website *model.Website) (*WebsiteConfig,
 	}
 	config.HTTPS = *https
 
+	// Build cache rules (redirect mode never caches)
+	if website.OriginMode != model.OriginModeRedirect && website.CacheRuleID.Valid && website.CacheRuleID.Int32 > 0 {
+		cacheItems, err := a.buildCacheItems(int(website.CacheRuleID.Int32))
+		if err != nil {
+			return nil, err
+		}
+		if len(cacheItems) > 0 {
+			config.CacheRuleID = int(website.CacheRuleID.Int32)
+			config.CacheItems = cacheItems
+		}
+	}
+
 	return config, nil
 }
 
@@ -106,40 +167,36 @@ func (a *Aggregator) buildOrigin(website *model.Website) (*OriginConfig, error)
 		return config, nil
 
 	case model.OriginModeGroup, model.OriginModeManual:
-		// Get origin set (snapshot)
-		var originSet model.OriginSet
-		if err := a.db.Where("website_id = ?", website.ID).
-			Order("snapshot_at DESC").
-			First(&originSet).Error; err != nil {
-			if err == gorm.ErrRecordNotFound {
-				// No origin set yet, return empty addresses
-				// 如果没有 origin_set，不生成 upstream
-				config.Addresses = []AddressConfig{}
-				return config, nil
-			}
-			return nil, fmt.Errorf("failed to query origin set: %w", err)
+		// No origin set bound yet, do not generate an upstream
+		if !website.OriginSetID.Valid || website.OriginSetID.Int32 <= 0 {
+			config.Addresses = []AddressConfig{}
+			return config, nil
 		}
 
-		// Get origin addresses
-		var addresses []model.OriginAddress
-		if err := a.db.Where("origin_set_id = ?", originSet.ID).
-			Order("role, id").
-			Find(&addresses).Error; err != nil {
-			return nil, fmt.Errorf("failed to query origin addresses: %w", err)
+		originSetID := int64(website.OriginSetID.Int32)
+		addresses, err := LoadOriginSetAddresses(a.db, originSetID)
+		if err != nil {
+			return nil, err
 		}
 
-			// upstream 命名改为以 origin_set_id 为键
-			config.UpstreamName = fmt.Sprintf("upstream_originset_%d", originSet.ID)
-			config.Addresses = make([]AddressConfig, 0, len(addresses))
+		// upstream 命名以 origin_set_id 为键
+		config.UpstreamName = fmt.Sprintf("upstream_originset_%d", originSetID)
+		config.Addresses = addresses
 
-		for _, addr := range addresses {
-			config.Addresses = append(config.Addresses, AddressConfig{
-				Role:     addr.Role,
-				Protocol: addr.Protocol,
-				Address:  addr.Address,
-				Weight:   addr.Weight,
-				Enabled:  addr.Enabled,
-			})
+		// Origin protocol and request options
+		protocol, err := ResolveOriginProtocol(config.Addresses)
+		if err != nil {
+			return nil, err
+		}
+		config.Protocol = protocol
+		config.HostHeader = website.OriginHostHeader
+		if protocol == model.OriginProtocolHTTPS {
+			config.SNI = website.OriginSNI
+			config.SNIName = website.OriginSNIName
+			config.Verify = website.OriginSSLVerify
+			if website.OriginSSLVerify {
+				config.CACertPem = website.OriginCACert
+			}
 		}
 
 		return config, nil
@@ -149,6 +206,41 @@ func (a *Aggregator) buildOrigin(website *model.Website) (*OriginConfig, error)
 	}
 }
 
+// buildCacheItems builds cache rule items of an enabled cache rule
+func (a *Aggregator) buildCacheItems(cacheRuleID int) ([]CacheItemConfig, error) {
+	var cacheRule model.CacheRule
+	if err := a.db.First(&cacheRule, cacheRuleID).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			// Cache rule deleted, render without cache
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to query cache rule: %w", err)
+	}
+
+	if !cacheRule.Enabled {
+		return nil, nil
+	}
+
+	var items []model.CacheRuleItem
+	if err := a.db.Where("cache_rule_id = ? AND enabled = ?", cacheRuleID, true).
+		Order("id ASC").
+		Find(&items).Error; err != nil {
+		return nil, fmt.Errorf("failed to query cache rule items: %w", err)
+	}
+
+	configs := make([]CacheItemConfig, 0, len(items))
+	for _, item := range items {
+		configs = append(configs, CacheItemConfig{
+			MatchType:  item.MatchType,
+			MatchValue: item.MatchValue,
+			Mode:       item.Mode,
+			TTLSeconds: item.TTLSeconds,
+		})
+	}
+
+	return configs, nil
+}
+
 // buildHTTPS builds HTTPS configuration
 func (a *Aggregator) buildHTTPS(websiteID int) (*HTTPSConfig, error) {
 	// Get website HTTPS config
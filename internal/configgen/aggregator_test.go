@@ -0,0 +1,140 @@
+package configgen
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/glebarez/sqlite"
+	"gorm.io/gorm"
+)
+
+// newTestDB creates the tables the aggregator reads:
+// node 1 serves line group 100 (node group 1), node 2 serves line group 200 (node group 2)
+// because its IP in node group 1 is disabled, node 3 has no IP in any node group.
+func newTestDB(t *testing.T) *gorm.DB {
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// MySQL enum/json columns do not migrate on sqlite, create the tables by hand
+	for _, stmt := range []string{
+		`CREATE TABLE node_ips (id INTEGER PRIMARY KEY, node_id INTEGER, enabled BOOLEAN)`,
+		`CREATE TABLE node_group_ips (id INTEGER PRIMARY KEY, node_group_id INTEGER, ip_id INTEGER)`,
+		`CREATE TABLE line_groups (id INTEGER PRIMARY KEY, node_group_id INTEGER)`,
+		`CREATE TABLE websites (id INTEGER PRIMARY KEY, line_group_id INTEGER, cache_rule_id INTEGER, origin_mode TEXT,
+			origin_set_id INTEGER, redirect_url TEXT NOT NULL DEFAULT '', redirect_status_code INTEGER NOT NULL DEFAULT 0, status TEXT)`,
+		`CREATE TABLE website_domains (id INTEGER PRIMARY KEY, website_id INTEGER, domain TEXT, is_primary BOOLEAN)`,
+		`CREATE TABLE website_https (id INTEGER PRIMARY KEY, website_id INTEGER, enabled BOOLEAN, force_redirect BOOLEAN, hsts BOOLEAN, cert_mode TEXT, certificate_id INTEGER)`,
+		`CREATE TABLE origin_set_items (id INTEGER PRIMARY KEY, origin_set_id INTEGER, origin_group_id INTEGER, snapshot_json TEXT)`,
+
+		`INSERT INTO node_ips VALUES (10, 1, 1), (11, 2, 0), (12, 2, 1)`,
+		`INSERT INTO node_group_ips VALUES (1, 1, 10), (2, 1, 11), (3, 2, 12)`,
+		`INSERT INTO line_groups VALUES (100, 1), (200, 2)`,
+		`INSERT INTO websites (id, line_group_id, origin_mode, origin_set_id, redirect_url, redirect_status_code, status) VALUES
+			(1, 100, 'group', 5, '', 0, 'active'),
+			(2, 100, 'group', 5, '', 0, 'inactive'),
+			(3, 200, 'manual', 6, '', 0, 'active'),
+			(4, 100, 'group', NULL, '', 0, 'active'),
+			(5, 100, 'redirect', NULL, 'https://example.org', 301, 'active')`,
+		`INSERT INTO website_domains VALUES (1, 1, 'a.example.com', 1), (2, 3, 'c.example.com', 1), (3, 5, 'e.example.com', 1)`,
+		`INSERT INTO origin_set_items VALUES
+			(1, 5, 1, '{"addresses":[{"role":"primary","protocol":"http","address":"10.0.0.1:80","weight":10,"enabled":true}]}'),
+			(2, 5, 2, '{"addresses":[{"role":"backup","protocol":"http","address":"10.0.0.2:80","weight":5,"enabled":true}]}'),
+			(3, 6, 3, '{"addresses":[{"role":"primary","protocol":"https","address":"origin.example.com:443","weight":1,"enabled":true}]}')`,
+	} {
+		if err := db.Exec(stmt).Error; err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	return db
+}
+
+func TestNodeLineGroupIDs(t *testing.T) {
+	db := newTestDB(t)
+
+	tests := map[int][]int64{1: {100}, 2: {200}, 3: nil}
+	for nodeID, want := range tests {
+		got, err := NodeLineGroupIDs(db, nodeID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(got) != len(want) || (len(want) > 0 && !reflect.DeepEqual(got, want)) {
+			t.Errorf("node %d: line groups = %v, want %v", nodeID, got, want)
+		}
+	}
+
+	got, err := NodesLineGroupIDs(db, []int{1, 2, 3})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, []int64{100, 200}) {
+		t.Errorf("nodes 1-3: line groups = %v, want [100 200]", got)
+	}
+}
+
+func TestGeneratePayloadScopesWebsitesToNode(t *testing.T) {
+	aggregator := NewAggregator(newTestDB(t))
+
+	tests := map[int][]int{
+		// website 2 is inactive, website 4 has no origin set yet
+		1: {1, 5},
+		2: {3},
+		3: {},
+	}
+	for nodeID, want := range tests {
+		payload, err := aggregator.GeneratePayload(nodeID, 42)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if payload.Version != 42 {
+			t.Errorf("node %d: version = %d, want 42", nodeID, payload.Version)
+		}
+
+		got := make([]int, 0, len(payload.Websites))
+		for _, website := range payload.Websites {
+			got = append(got, website.WebsiteID)
+		}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("node %d: websites = %v, want %v", nodeID, got, want)
+		}
+	}
+}
+
+func TestGeneratePayloadResolvesOriginSet(t *testing.T) {
+	aggregator := NewAggregator(newTestDB(t))
+
+	payload, err := aggregator.GeneratePayload(1, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	origin := payload.Websites[0].Origin
+	if origin.UpstreamName != "upstream_originset_5" || origin.Protocol != "http" {
+		t.Errorf("unexpected origin %+v", origin)
+	}
+	// addresses of all items of the origin set, in item order
+	wantAddresses := []AddressConfig{
+		{Role: "primary", Protocol: "http", Address: "10.0.0.1:80", Weight: 10, Enabled: true},
+		{Role: "backup", Protocol: "http", Address: "10.0.0.2:80", Weight: 5, Enabled: true},
+	}
+	if !reflect.DeepEqual(origin.Addresses, wantAddresses) {
+		t.Errorf("addresses = %+v, want %+v", origin.Addresses, wantAddresses)
+	}
+	if domains := payload.Websites[0].Domains; len(domains) != 1 || domains[0].Domain != "a.example.com" {
+		t.Errorf("unexpected domains %+v", domains)
+	}
+
+	redirect := payload.Websites[1].Origin
+	if redirect.UpstreamName != "" || redirect.RedirectURL != "https://example.org" || redirect.RedirectStatusCode != 301 {
+		t.Errorf("unexpected redirect origin %+v", redirect)
+	}
+
+	payload, err = aggregator.GeneratePayload(2, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if origin := payload.Websites[0].Origin; origin.UpstreamName != "upstream_originset_6" || origin.Protocol != "https" {
+		t.Errorf("unexpected https origin %+v", origin)
+	}
+}
@@ -0,0 +1,108 @@
+package configgen
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"go_cmdb/internal/model"
+
+	"gorm.io/gorm"
+)
+
+// ErrMixedOriginProtocols is returned when one upstream has both http and https addresses
+var ErrMixedOriginProtocols = errors.New("mixed http/https origin addresses in one upstream")
+
+// originSnapshot is the snapshot_json format of origin_set_items
+type originSnapshot struct {
+	Addresses []AddressConfig `json:"addresses"`
+}
+
+// LoadOriginSetAddresses loads the origin addresses of an origin set from its item snapshots
+func LoadOriginSetAddresses(db *gorm.DB, originSetID int64) ([]AddressConfig, error) {
+	var items []model.OriginSetItem
+	if err := db.Where("origin_set_id = ?", originSetID).Order("id ASC").Find(&items).Error; err != nil {
+		return nil, fmt.Errorf("failed to query origin set items: %w", err)
+	}
+
+	addresses := make([]AddressConfig, 0)
+	for _, item := range items {
+		var snapshot originSnapshot
+		if err := json.Unmarshal([]byte(item.SnapshotJSON), &snapshot); err != nil {
+			return nil, fmt.Errorf("failed to parse snapshot of origin set item %d: %w", item.ID, err)
+		}
+		addresses = append(addresses, snapshot.Addresses...)
+	}
+
+	return addresses, nil
+}
+
+// ResolveOriginProtocol returns the protocol shared by the enabled addresses of one upstream.
+// nginx proxies an upstream with a single scheme, so mixed http/https addresses are rejected.
+func ResolveOriginProtocol(addresses []AddressConfig) (string, error) {
+	protocol := ""
+	for _, addr := range addresses {
+		if !addr.Enabled {
+			continue
+		}
+
+		p := addr.Protocol
+		if p == "" {
+			p = model.OriginProtocolHTTP
+		}
+
+		if protocol == "" {
+			protocol = p
+		} else if protocol != p {
+			return "", ErrMixedOriginProtocols
+		}
+	}
+
+	if protocol == "" {
+		protocol = model.OriginProtocolHTTP
+	}
+	return protocol, nil
+}
+
+// ValidateWebsiteOrigin checks that the origin addresses of a website can be rendered as one upstream
+func ValidateWebsiteOrigin(db *gorm.DB, website *model.Website) error {
+	if website.OriginMode == model.OriginModeRedirect || !website.OriginSetID.Valid || website.OriginSetID.Int32 <= 0 {
+		return nil
+	}
+
+	addresses, err := LoadOriginSetAddresses(db, int64(website.OriginSetID.Int32))
+	if err != nil {
+		return err
+	}
+
+	if _, err := ResolveOriginProtocol(addresses); err != nil {
+		return fmt.Errorf("website %d (origin set %d): %w", website.ID, website.OriginSetID.Int32, err)
+	}
+	return nil
+}
+
+// ValidateNodeWebsiteOrigins validates the origins of the active websites served by the nodes,
+// which are the websites a release to those nodes renders
+func ValidateNodeWebsiteOrigins(db *gorm.DB, nodeIDs []int) error {
+	lineGroupIDs, err := NodesLineGroupIDs(db, nodeIDs)
+	if err != nil {
+		return fmt.Errorf("failed to query line groups: %w", err)
+	}
+	if len(lineGroupIDs) == 0 {
+		return nil
+	}
+
+	var websites []model.Website
+	if err := db.Where("status = ? AND line_group_id IN ?", model.WebsiteStatusActive, lineGroupIDs).
+		Order("id ASC").
+		Find(&websites).Error; err != nil {
+		return fmt.Errorf("failed to query websites: %w", err)
+	}
+
+	for i := range websites {
+		if err := ValidateWebsiteOrigin(db, &websites[i]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,32 @@
+package configgen
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestResolveOriginProtocol(t *testing.T) {
+	tests := []struct {
+		name      string
+		addresses []AddressConfig
+		want      string
+		wantErr   error
+	}{
+		{"empty", nil, "http", nil},
+		{"http", []AddressConfig{{Protocol: "http", Enabled: true}}, "http", nil},
+		{"https", []AddressConfig{{Protocol: "https", Enabled: true}, {Protocol: "https", Role: "backup", Enabled: true}}, "https", nil},
+		{"missing protocol is http", []AddressConfig{{Enabled: true}, {Protocol: "http", Enabled: true}}, "http", nil},
+		{"disabled address ignored", []AddressConfig{{Protocol: "https", Enabled: true}, {Protocol: "http", Enabled: false}}, "https", nil},
+		{"mixed", []AddressConfig{{Protocol: "https", Enabled: true}, {Protocol: "http", Enabled: true}}, "", ErrMixedOriginProtocols},
+	}
+
+	for _, tt := range tests {
+		got, err := ResolveOriginProtocol(tt.addresses)
+		if !errors.Is(err, tt.wantErr) {
+			t.Errorf("%s: expected error %v, got %v", tt.name, tt.wantErr, err)
+		}
+		if got != tt.want {
+			t.Errorf("%s: expected %q, got %q", tt.name, tt.want, got)
+		}
+	}
+}
@@ -2,17 +2,19 @@ package configgen
 
 // ApplyConfigPayload represents the complete payload for apply_config task
 type ApplyConfigPayload struct {
-	Version  int64             `json:"version"`
+	Version  int64           `json:"version"`
 	Websites []WebsiteConfig `json:"websites"`
 }
 
 // WebsiteConfig represents a single website configuration
 type WebsiteConfig struct {
-	WebsiteID int              `json:"websiteId"`
-	Status    string           `json:"status"`
-	Domains   []DomainConfig   `json:"domains"`
-	Origin    OriginConfig     `json:"origin"`
-	HTTPS     HTTPSConfig      `json:"https"`
+	WebsiteID   int               `json:"websiteId"`
+	Status      string            `json:"status"`
+	Domains     []DomainConfig    `json:"domains"`
+	Origin      OriginConfig      `json:"origin"`
+	HTTPS       HTTPSConfig       `json:"https"`
+	CacheRuleID int               `json:"cacheRuleId,omitempty"`
+	CacheItems  []CacheItemConfig `json:"cacheItems,omitempty"`
 }
 
 // DomainConfig represents a domain configuration
@@ -23,11 +25,17 @@ type DomainConfig struct {
 
 // OriginConfig represents origin configuration
 type OriginConfig struct {
-	Mode               string            `json:"mode"` // group|manual|redirect
-	RedirectURL        string            `json:"redirectUrl,omitempty"`
-	RedirectStatusCode int               `json:"redirectStatusCode,omitempty"`
-	UpstreamName       string            `json:"upstreamName,omitempty"`
-	Addresses          []AddressConfig   `json:"addresses,omitempty"`
+	Mode               string          `json:"mode"` // group|manual|redirect
+	RedirectURL        string          `json:"redirectUrl,omitempty"`
+	RedirectStatusCode int             `json:"redirectStatusCode,omitempty"`
+	UpstreamName       string          `json:"upstreamName,omitempty"`
+	Protocol           string          `json:"protocol,omitempty"`   // http|https, shared by all addresses
+	HostHeader         string          `json:"hostHeader,omitempty"` // Host sent to the origin, $host if empty
+	SNI                bool            `json:"sni,omitempty"`        // proxy_ssl_server_name
+	SNIName            string          `json:"sniName,omitempty"`    // proxy_ssl_name, Host header if empty
+	Verify             bool            `json:"verify,omitempty"`     // proxy_ssl_verify
+	CACertPem          string          `json:"caCertPem,omitempty"`  // CA to verify the origin, system bundle if empty
+	Addresses          []AddressConfig `json:"addresses,omitempty"`
 }
 
 // AddressConfig represents an origin address
@@ -41,10 +49,10 @@ type AddressConfig struct {
 
 // HTTPSConfig represents HTTPS configuration
 type HTTPSConfig struct {
-	Enabled       bool                `json:"enabled"`
-	ForceRedirect bool                `json:"forceRedirect"`
-	HSTS          bool                `json:"hsts"`
-	Certificate   *CertificateConfig  `json:"certificate,omitempty"`
+	Enabled       bool               `json:"enabled"`
+	ForceRedirect bool               `json:"forceRedirect"`
+	HSTS          bool               `json:"hsts"`
+	Certificate   *CertificateConfig `json:"certificate,omitempty"`
 }
 
 // CertificateConfig represents certificate configuration
@@ -53,3 +61,11 @@ type CertificateConfig struct {
 	CertPem       string `json:"certPem"`
 	KeyPem        string `json:"keyPem"`
 }
+
+// CacheItemConfig represents a cache rule item
+type CacheItemConfig struct {
+	MatchType  string `json:"matchType"` // path|suffix|exact
+	MatchValue string `json:"matchValue"`
+	Mode       string `json:"mode"` // default|follow|force|bypass
+	TTLSeconds int    `json:"ttlSeconds"`
+}
@@ -0,0 +1,59 @@
+package configgen
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"go_cmdb/internal/model"
+)
+
+// Snapshot returns the JSON config snapshot of active websites, stored on release tasks for diffs.
+// websiteIDs limits the snapshot to those websites; nil means all active websites (cdn releases).
+// Certificate PEMs are dropped, only the certificate ID is kept.
+func (a *Aggregator) Snapshot(websiteIDs []int64) (string, error) {
+	query := a.db.Where("status = ?", model.WebsiteStatusActive)
+	if websiteIDs != nil {
+		if len(websiteIDs) == 0 {
+			return "[]", nil
+		}
+		query = query.Where("id IN ?", websiteIDs)
+	}
+
+	var websites []model.Website
+	if err := query.Order("id ASC").Find(&websites).Error; err != nil {
+		return "", fmt.Errorf("failed to query websites: %w", err)
+	}
+
+	configs := make([]WebsiteConfig, 0, len(websites))
+	for _, website := range websites {
+		websiteConfig, err := a.buildWebsiteConfig(&website)
+		if err != nil {
+			return "", fmt.Errorf("failed to build config for website %d: %w", website.ID, err)
+		}
+
+		// Same filter as GeneratePayload: no upstream means nothing is rendered
+		if websiteConfig.Origin.Mode != model.OriginModeRedirect && websiteConfig.Origin.UpstreamName == "" {
+			continue
+		}
+
+		if websiteConfig.HTTPS.Certificate != nil {
+			websiteConfig.HTTPS.Certificate = &CertificateConfig{CertificateID: websiteConfig.HTTPS.Certificate.CertificateID}
+		}
+		configs = append(configs, *websiteConfig)
+	}
+
+	data, err := json.Marshal(configs)
+	if err != nil {
+		return "", fmt.Errorf("failed to marshal snapshot: %w", err)
+	}
+	return string(data), nil
+}
+
+// ParseSnapshot parses a config snapshot produced by Snapshot
+func ParseSnapshot(snapshot string) ([]WebsiteConfig, error) {
+	var configs []WebsiteConfig
+	if err := json.Unmarshal([]byte(snapshot), &configs); err != nil {
+		return nil, fmt.Errorf("failed to parse config snapshot: %w", err)
+	}
+	return configs, nil
+}
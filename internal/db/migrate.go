@@ -25,6 +25,9 @@ func Migrate(db *gorm.DB) error {
 		&model.NodeGroup{},
 		&model.NodeGroupIP{},
 		&model.LineGroup{},
+		&model.MaintenanceWindow{},
+		&model.ReleaseApprovalPolicy{},
+		&model.ReleaseApproval{},
 		&model.OriginGroup{},
 		&model.OriginGroupAddress{},
 		&model.OriginSet{},
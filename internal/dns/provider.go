@@ -1,6 +1,14 @@
 package dns
 
-import "go_cmdb/internal/dnstypes"
+import (
+	"errors"
+	"fmt"
+
+	"go_cmdb/internal/dns/providers/aliyun"
+	"go_cmdb/internal/dns/providers/cloudflare"
+	"go_cmdb/internal/dnstypes"
+	"go_cmdb/internal/model"
+)
 
 // Provider defines the interface for DNS providers
 type Provider interface {
@@ -16,3 +24,27 @@ type Provider interface {
 	// Returns: providerRecordID, error (ErrNotFound if not found)
 	FindRecord(zoneID string, recordType string, name string, value string) (providerRecordID string, err error)
 }
+
+// SupportedProviders lists the DNS providers with a Provider implementation
+var SupportedProviders = []model.DNSProvider{
+	model.DNSProviderCloudflare,
+	model.DNSProviderAliyun,
+}
+
+// NewProvider creates the Provider of a domain's DNS provider binding from its API key
+func NewProvider(provider model.DNSProvider, apiKey *model.APIKey) (Provider, error) {
+	switch provider {
+	case model.DNSProviderCloudflare:
+		return cloudflare.NewCloudflareProvider(apiKey.Account, apiKey.APIToken), nil
+	case model.DNSProviderAliyun:
+		// Account holds the AccessKey ID, APIToken the AccessKey secret
+		return aliyun.NewAliyunProvider(apiKey.Account, apiKey.APIToken), nil
+	default:
+		return nil, fmt.Errorf("unsupported DNS provider: %s", provider)
+	}
+}
+
+// IsNotFound reports whether a provider error means the record does not exist
+func IsNotFound(err error) bool {
+	return errors.Is(err, cloudflare.ErrNotFound) || errors.Is(err, aliyun.ErrNotFound)
+}
@@ -0,0 +1,360 @@
+package aliyun
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha1"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"go_cmdb/internal/dnstypes"
+)
+
+const (
+	aliyunAPIEndpoint = "https://alidns.aliyuncs.com/"
+	aliyunAPIVersion  = "2015-01-09"
+	requestTimeout    = 10 * time.Second
+
+	// pageSize is the maximum page size of DescribeDomainRecords
+	pageSize = 500
+
+	// domainsPageSize is the maximum page size of DescribeDomains
+	domainsPageSize = 100
+
+	// defaultTTL is used when the record asks for automatic TTL (Cloudflare uses ttl=1)
+	defaultTTL = 600
+)
+
+var (
+	// ErrNotFound is returned when a DNS record is not found
+	ErrNotFound = errors.New("DNS record not found")
+)
+
+// Error codes meaning the record does not exist (or not in this account)
+var notFoundCodes = map[string]bool{
+	"DomainRecordNotBelongToUser": true,
+	"InvalidRecordId.NotFound":    true,
+}
+
+// AliyunProvider implements dns.Provider for the Aliyun (Alibaba Cloud) Alidns API.
+// Alidns addresses zones by domain name, so zoneID is the domain name (example.com).
+type AliyunProvider struct {
+	accessKeyID     string
+	accessKeySecret string
+	endpoint        string
+	client          *http.Client
+}
+
+// NewAliyunProvider creates a new Aliyun DNS provider
+func NewAliyunProvider(accessKeyID, accessKeySecret string) *AliyunProvider {
+	return NewAliyunProviderWithEndpoint(aliyunAPIEndpoint, accessKeyID, accessKeySecret)
+}
+
+// NewAliyunProviderWithEndpoint creates a new Aliyun DNS provider against a custom endpoint
+// (regional endpoint or a local stand-in of the Alidns API)
+func NewAliyunProviderWithEndpoint(endpoint, accessKeyID, accessKeySecret string) *AliyunProvider {
+	return &AliyunProvider{
+		accessKeyID:     accessKeyID,
+		accessKeySecret: accessKeySecret,
+		endpoint:        endpoint,
+		client: &http.Client{
+			Timeout: requestTimeout,
+		},
+	}
+}
+
+// AliyunRecord represents an Alidns DNS record (API response)
+type AliyunRecord struct {
+	RecordID   string `json:"RecordId"`
+	RR         string `json:"RR"` // Relative name (@, www, a.b)
+	Type       string `json:"Type"`
+	Value      string `json:"Value"`
+	TTL        int    `json:"TTL"`
+	Line       string `json:"Line"`
+	Status     string `json:"Status"` // ENABLE / DISABLE
+	DomainName string `json:"DomainName"`
+}
+
+// AliyunError represents an Alidns API error response
+type AliyunError struct {
+	RequestID string `json:"RequestId"`
+	Code      string `json:"Code"`
+	Message   string `json:"Message"`
+}
+
+func (e *AliyunError) Error() string {
+	return fmt.Sprintf("aliyun API error: [%s] %s", e.Code, e.Message)
+}
+
+// describeDomainRecordsResponse represents the response of DescribeDomainRecords
+type describeDomainRecordsResponse struct {
+	TotalCount    int `json:"TotalCount"`
+	DomainRecords struct {
+		Record []AliyunRecord `json:"Record"`
+	} `json:"DomainRecords"`
+}
+
+// recordIDResponse represents the response of Add/Update/DeleteDomainRecord
+type recordIDResponse struct {
+	RecordID string `json:"RecordId"`
+}
+
+// EnsureRecord ensures a DNS record exists with the correct values
+func (p *AliyunProvider) EnsureRecord(zoneID string, record dnstypes.DNSRecord) (string, bool, error) {
+	ttl := record.TTL
+	if ttl <= 1 {
+		ttl = defaultTTL
+	}
+
+	// Step 1: Find existing record
+	existing, err := p.findRecord(context.Background(), zoneID, record.Type, record.Name, record.Value)
+	if err != nil && err != ErrNotFound {
+		return "", false, fmt.Errorf("failed to find existing record: %w", err)
+	}
+
+	// Step 2: If record exists, update TTL if needed
+	if existing != nil {
+		if existing.TTL == ttl {
+			// No change needed
+			return existing.RecordID, false, nil
+		}
+
+		params := map[string]string{
+			"RecordId": existing.RecordID,
+			"RR":       existing.RR,
+			"Type":     existing.Type,
+			"Value":    existing.Value,
+			"TTL":      strconv.Itoa(ttl),
+		}
+		if err := p.call(context.Background(), "UpdateDomainRecord", params, nil); err != nil {
+			return existing.RecordID, false, fmt.Errorf("failed to update record: %w", err)
+		}
+
+		return existing.RecordID, true, nil
+	}
+
+	// Step 3: Create new record
+	params := map[string]string{
+		"DomainName": zoneID,
+		"RR":         toRR(record.Name, zoneID),
+		"Type":       record.Type,
+		"Value":      record.Value,
+		"TTL":        strconv.Itoa(ttl),
+	}
+	var resp recordIDResponse
+	if err := p.call(context.Background(), "AddDomainRecord", params, &resp); err != nil {
+		return "", false, fmt.Errorf("failed to create record: %w", err)
+	}
+
+	return resp.RecordID, true, nil
+}
+
+// DeleteRecord deletes a DNS record by its provider-specific ID
+// Returns ErrNotFound if the record doesn't exist (treated as success for deletion)
+func (p *AliyunProvider) DeleteRecord(zoneID string, providerRecordID string) error {
+	params := map[string]string{
+		"RecordId": providerRecordID,
+	}
+	if err := p.call(context.Background(), "DeleteDomainRecord", params, nil); err != nil {
+		var apiErr *AliyunError
+		if errors.As(err, &apiErr) && notFoundCodes[apiErr.Code] {
+			return ErrNotFound
+		}
+		return err
+	}
+
+	return nil
+}
+
+// FindRecord finds a DNS record by type, name, and value
+func (p *AliyunProvider) FindRecord(zoneID string, recordType string, name string, value string) (string, error) {
+	record, err := p.findRecord(context.Background(), zoneID, recordType, name, value)
+	if err != nil {
+		return "", err
+	}
+	return record.RecordID, nil
+}
+
+// findRecord returns the record matching type, name and value exactly
+// (RRKeyWord is a fuzzy match on the Alidns side)
+func (p *AliyunProvider) findRecord(ctx context.Context, zoneID, recordType, name, value string) (*AliyunRecord, error) {
+	rr := toRR(name, zoneID)
+	records, err := p.describeRecords(ctx, zoneID, map[string]string{
+		"RRKeyWord":   rr,
+		"TypeKeyWord": recordType,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	for i := range records {
+		if records[i].RR == rr && strings.EqualFold(records[i].Type, recordType) && sameValue(records[i].Value, value) {
+			return &records[i], nil
+		}
+	}
+
+	return nil, ErrNotFound
+}
+
+// ListRecords lists all DNS records for a zone
+func (p *AliyunProvider) ListRecords(ctx context.Context, zoneID string) ([]AliyunRecord, error) {
+	return p.describeRecords(ctx, zoneID, nil)
+}
+
+// describeRecords pages through DescribeDomainRecords
+func (p *AliyunProvider) describeRecords(ctx context.Context, zoneID string, filters map[string]string) ([]AliyunRecord, error) {
+	var records []AliyunRecord
+	for page := 1; ; page++ {
+		params := map[string]string{
+			"DomainName": zoneID,
+			"PageNumber": strconv.Itoa(page),
+			"PageSize":   strconv.Itoa(pageSize),
+		}
+		for k, v := range filters {
+			params[k] = v
+		}
+
+		var resp describeDomainRecordsResponse
+		if err := p.call(ctx, "DescribeDomainRecords", params, &resp); err != nil {
+			return nil, err
+		}
+
+		records = append(records, resp.DomainRecords.Record...)
+		if len(resp.DomainRecords.Record) == 0 || len(records) >= resp.TotalCount {
+			return records, nil
+		}
+	}
+}
+
+// call invokes an Alidns RPC action and decodes the JSON response into out (may be nil)
+func (p *AliyunProvider) call(ctx context.Context, action string, params map[string]string, out interface{}) error {
+	query := p.signedQuery(action, params, time.Now().UTC(), newNonce())
+
+	req, err := http.NewRequestWithContext(ctx, "GET", p.endpoint+"?"+query, nil)
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to send request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return fmt.Errorf("failed to read response: %w", err)
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		var apiErr AliyunError
+		if err := json.Unmarshal(body, &apiErr); err != nil || apiErr.Code == "" {
+			return fmt.Errorf("aliyun API error: status=%d body=%s", resp.StatusCode, string(body))
+		}
+		return &apiErr
+	}
+
+	if out == nil {
+		return nil
+	}
+	if err := json.Unmarshal(body, out); err != nil {
+		return fmt.Errorf("failed to parse response: %w", err)
+	}
+
+	return nil
+}
+
+// signedQuery builds the query string of an Alidns RPC request, including the signature
+// (signature version 1.0: HMAC-SHA1 over the canonicalized query, keyed with secret + "&")
+func (p *AliyunProvider) signedQuery(action string, params map[string]string, now time.Time, nonce string) string {
+	values := map[string]string{
+		"Action":           action,
+		"Format":           "JSON",
+		"Version":          aliyunAPIVersion,
+		"AccessKeyId":      p.accessKeyID,
+		"SignatureMethod":  "HMAC-SHA1",
+		"SignatureVersion": "1.0",
+		"SignatureNonce":   nonce,
+		"Timestamp":        now.Format("2006-01-02T15:04:05Z"),
+	}
+	for k, v := range params {
+		values[k] = v
+	}
+
+	canonicalized := canonicalizedQuery(values)
+	signature := sign("GET", canonicalized, p.accessKeySecret)
+
+	return canonicalized + "&Signature=" + percentEncode(signature)
+}
+
+// canonicalizedQuery joins the percent-encoded parameters sorted by name
+func canonicalizedQuery(values map[string]string) string {
+	keys := make([]string, 0, len(values))
+	for k := range values {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	pairs := make([]string, 0, len(keys))
+	for _, k := range keys {
+		pairs = append(pairs, percentEncode(k)+"="+percentEncode(values[k]))
+	}
+	return strings.Join(pairs, "&")
+}
+
+// sign computes the request signature for the canonicalized query
+func sign(method, canonicalized, accessKeySecret string) string {
+	stringToSign := method + "&" + percentEncode("/") + "&" + percentEncode(canonicalized)
+
+	mac := hmac.New(sha1.New, []byte(accessKeySecret+"&"))
+	mac.Write([]byte(stringToSign))
+	return base64.StdEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// percentEncode encodes a value as required by the Aliyun signature (RFC 3986)
+func percentEncode(s string) string {
+	encoded := url.QueryEscape(s)
+	encoded = strings.ReplaceAll(encoded, "+", "%20")
+	encoded = strings.ReplaceAll(encoded, "*", "%2A")
+	encoded = strings.ReplaceAll(encoded, "%7E", "~")
+	return encoded
+}
+
+// newNonce returns a unique SignatureNonce
+func newNonce() string {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return strconv.FormatInt(time.Now().UnixNano(), 10)
+	}
+	return hex.EncodeToString(b)
+}
+
+// toRR converts a FQDN (www.example.com) to the Alidns RR (www) of the zone
+func toRR(name, zone string) string {
+	name = strings.TrimSuffix(strings.TrimSpace(name), ".")
+	zone = strings.TrimSuffix(strings.TrimSpace(zone), ".")
+
+	if name == "" || name == zone {
+		return "@"
+	}
+	if strings.HasSuffix(name, "."+zone) {
+		return strings.TrimSuffix(name, "."+zone)
+	}
+	return name
+}
+
+// sameValue compares record values, ignoring case and the trailing dot of hostnames
+func sameValue(a, b string) bool {
+	return strings.EqualFold(strings.TrimSuffix(a, "."), strings.TrimSuffix(b, "."))
+}
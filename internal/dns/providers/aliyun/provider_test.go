@@ -0,0 +1,274 @@
+package aliyun
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"go_cmdb/internal/dnstypes"
+)
+
+const (
+	testAccessKeyID     = "testid"
+	testAccessKeySecret = "testsecret"
+)
+
+// fakeAlidns is a local stand-in of the Alidns RPC API
+type fakeAlidns struct {
+	mu      sync.Mutex
+	nextID  int
+	records map[string]AliyunRecord
+	domains []string
+	actions []string
+}
+
+func newFakeAlidns() *fakeAlidns {
+	return &fakeAlidns{nextID: 1000, records: map[string]AliyunRecord{}, domains: []string{"example.com"}}
+}
+
+func (f *fakeAlidns) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	q := r.URL.Query()
+	values := map[string]string{}
+	for k := range q {
+		if k != "Signature" {
+			values[k] = q.Get(k)
+		}
+	}
+	if q.Get("AccessKeyId") != testAccessKeyID || q.Get("Signature") != sign(r.Method, canonicalizedQuery(values), testAccessKeySecret) {
+		writeError(w, http.StatusBadRequest, "SignatureDoesNotMatch", "signature mismatch")
+		return
+	}
+
+	action := q.Get("Action")
+	f.actions = append(f.actions, action)
+
+	switch action {
+	case "DescribeDomainRecords":
+		var matched []AliyunRecord
+		for _, rec := range f.records {
+			if rec.DomainName != q.Get("DomainName") {
+				continue
+			}
+			if kw := q.Get("RRKeyWord"); kw != "" && !strings.Contains(rec.RR, kw) {
+				continue
+			}
+			if kw := q.Get("TypeKeyWord"); kw != "" && rec.Type != kw {
+				continue
+			}
+			matched = append(matched, rec)
+		}
+		resp := describeDomainRecordsResponse{TotalCount: len(matched)}
+		resp.DomainRecords.Record = matched
+		json.NewEncoder(w).Encode(resp)
+	case "AddDomainRecord":
+		f.nextID++
+		id := strconv.Itoa(f.nextID)
+		ttl, _ := strconv.Atoi(q.Get("TTL"))
+		f.records[id] = AliyunRecord{RecordID: id, RR: q.Get("RR"), Type: q.Get("Type"), Value: q.Get("Value"), TTL: ttl, DomainName: q.Get("DomainName")}
+		json.NewEncoder(w).Encode(recordIDResponse{RecordID: id})
+	case "UpdateDomainRecord":
+		rec, ok := f.records[q.Get("RecordId")]
+		if !ok {
+			writeError(w, http.StatusBadRequest, "DomainRecordNotBelongToUser", "record not found")
+			return
+		}
+		rec.TTL, _ = strconv.Atoi(q.Get("TTL"))
+		f.records[rec.RecordID] = rec
+		json.NewEncoder(w).Encode(recordIDResponse{RecordID: rec.RecordID})
+	case "DeleteDomainRecord":
+		if _, ok := f.records[q.Get("RecordId")]; !ok {
+			writeError(w, http.StatusBadRequest, "DomainRecordNotBelongToUser", "record not found")
+			return
+		}
+		delete(f.records, q.Get("RecordId"))
+		json.NewEncoder(w).Encode(recordIDResponse{RecordID: q.Get("RecordId")})
+	case "DescribeDomains":
+		page, _ := strconv.Atoi(q.Get("PageNumber"))
+		size, _ := strconv.Atoi(q.Get("PageSize"))
+		if page < 1 || size < 1 || size > 100 {
+			writeError(w, http.StatusBadRequest, "InvalidPageSize", "PageSize must be between 1 and 100")
+			return
+		}
+		items := []map[string]interface{}{}
+		for i := (page - 1) * size; i < page*size && i < len(f.domains); i++ {
+			items = append(items, map[string]interface{}{
+				"DomainId":   fmt.Sprintf("d-%d", i+1),
+				"DomainName": f.domains[i],
+				"DnsServers": map[string]interface{}{"DnsServer": []string{"dns1.hichina.com", "dns2.hichina.com"}},
+			})
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{"TotalCount": len(f.domains), "Domains": map[string]interface{}{"Domain": items}})
+	default:
+		writeError(w, http.StatusBadRequest, "InvalidAction.NotFound", "unknown action")
+	}
+}
+
+func writeError(w http.ResponseWriter, status int, code, message string) {
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(AliyunError{RequestID: "req", Code: code, Message: message})
+}
+
+func newTestProvider(t *testing.T) (*AliyunProvider, *fakeAlidns) {
+	fake := newFakeAlidns()
+	server := httptest.NewServer(fake)
+	t.Cleanup(server.Close)
+	return NewAliyunProviderWithEndpoint(server.URL+"/", testAccessKeyID, testAccessKeySecret), fake
+}
+
+func TestEnsureFindDeleteRecord(t *testing.T) {
+	p, fake := newTestProvider(t)
+	record := dnstypes.DNSRecord{Type: "CNAME", Name: "www.example.com", Value: "cdn.example.net", TTL: 1}
+
+	id, changed, err := p.EnsureRecord("example.com", record)
+	if err != nil || !changed || id == "" {
+		t.Fatalf("EnsureRecord create = %q, %v, %v", id, changed, err)
+	}
+	if got := fake.records[id]; got.RR != "www" || got.TTL != defaultTTL {
+		t.Fatalf("unexpected stored record: %+v", got)
+	}
+
+	// Same record again: no change
+	again, changed, err := p.EnsureRecord("example.com", record)
+	if err != nil || changed || again != id {
+		t.Fatalf("EnsureRecord unchanged = %q, %v, %v", again, changed, err)
+	}
+
+	// TTL change: updated in place
+	record.TTL = 1200
+	updated, changed, err := p.EnsureRecord("example.com", record)
+	if err != nil || !changed || updated != id || fake.records[id].TTL != 1200 {
+		t.Fatalf("EnsureRecord update = %q, %v, %v (ttl=%d)", updated, changed, err, fake.records[id].TTL)
+	}
+
+	found, err := p.FindRecord("example.com", "CNAME", "www.example.com", "cdn.example.net.")
+	if err != nil || found != id {
+		t.Fatalf("FindRecord = %q, %v", found, err)
+	}
+	if _, err := p.FindRecord("example.com", "CNAME", "w.example.com", "cdn.example.net"); err != ErrNotFound {
+		t.Fatalf("FindRecord on fuzzy match: err = %v, want ErrNotFound", err)
+	}
+
+	if err := p.DeleteRecord("example.com", id); err != nil {
+		t.Fatalf("DeleteRecord: %v", err)
+	}
+	if err := p.DeleteRecord("example.com", id); err != ErrNotFound {
+		t.Fatalf("DeleteRecord twice: err = %v, want ErrNotFound", err)
+	}
+}
+
+func TestListRecordsAndZones(t *testing.T) {
+	p, _ := newTestProvider(t)
+	for _, name := range []string{"example.com", "api.example.com"} {
+		if _, _, err := p.EnsureRecord("example.com", dnstypes.DNSRecord{Type: "A", Name: name, Value: "1.2.3.4", TTL: 600}); err != nil {
+			t.Fatalf("EnsureRecord %s: %v", name, err)
+		}
+	}
+
+	records, err := p.ListRecords(context.Background(), "example.com")
+	if err != nil || len(records) != 2 {
+		t.Fatalf("ListRecords = %d records, %v", len(records), err)
+	}
+
+	zones, err := p.ListZones(context.Background())
+	if err != nil || len(zones) != 1 {
+		t.Fatalf("ListZones = %v, %v", zones, err)
+	}
+	if zones[0].ID != "example.com" || len(zones[0].NameServers) != 2 {
+		t.Fatalf("unexpected zone: %+v", zones[0])
+	}
+}
+
+func TestListZonesPaging(t *testing.T) {
+	p, fake := newTestProvider(t)
+	fake.domains = nil
+	for i := 1; i <= 150; i++ {
+		fake.domains = append(fake.domains, fmt.Sprintf("site%d.example.com", i))
+	}
+
+	zones, err := p.ListZones(context.Background())
+	if err != nil {
+		t.Fatalf("ListZones: %v", err)
+	}
+	if len(zones) != 150 || zones[0].Name != "site1.example.com" || zones[149].Name != "site150.example.com" {
+		t.Fatalf("ListZones = %d zones, want all 150 in order", len(zones))
+	}
+	if calls := strings.Count(strings.Join(fake.actions, ","), "DescribeDomains"); calls != 2 {
+		t.Errorf("DescribeDomains called %d times, want 2 pages", calls)
+	}
+}
+
+func TestAPIErrorIsReturned(t *testing.T) {
+	p, _ := newTestProvider(t)
+	p.accessKeySecret = "wrong"
+
+	_, err := p.FindRecord("example.com", "A", "www.example.com", "1.2.3.4")
+	apiErr, ok := err.(*AliyunError)
+	if !ok || apiErr.Code != "SignatureDoesNotMatch" {
+		t.Fatalf("err = %v, want SignatureDoesNotMatch", err)
+	}
+}
+
+func TestSignedQuery(t *testing.T) {
+	p := NewAliyunProvider(testAccessKeyID, testAccessKeySecret)
+	now := time.Date(2026, 10, 17, 8, 0, 0, 0, time.UTC)
+
+	query := p.signedQuery("DescribeDomainRecords", map[string]string{"DomainName": "example.com", "RRKeyWord": "a b*~"}, now, "nonce")
+
+	if !strings.HasPrefix(query, "AccessKeyId=testid&Action=DescribeDomainRecords&DomainName=example.com&Format=JSON&RRKeyWord=a%20b%2A~&") {
+		t.Fatalf("query is not canonicalized: %s", query)
+	}
+	if !strings.Contains(query, "&Timestamp=2026-10-17T08%3A00%3A00Z&Version=2015-01-09&Signature=") {
+		t.Fatalf("missing timestamp/version/signature: %s", query)
+	}
+}
+
+// TestSignKnownAnswer checks the signature against the example of the Alidns signature documentation
+func TestSignKnownAnswer(t *testing.T) {
+	canonicalized := canonicalizedQuery(map[string]string{
+		"Format":           "XML",
+		"AccessKeyId":      "testid",
+		"Action":           "DescribeDomainRecords",
+		"SignatureMethod":  "HMAC-SHA1",
+		"DomainName":       "example.com",
+		"SignatureNonce":   "f59ed6a9-83fc-473b-9cc6-99c95df3856e",
+		"SignatureVersion": "1.0",
+		"Version":          "2015-01-09",
+		"Timestamp":        "2016-03-24T16:41:54Z",
+	})
+
+	if got, want := "GET&%2F&"+percentEncode(canonicalized), "GET&%2F&AccessKeyId%3Dtestid%26Action%3DDescribeDomainRecords"+
+		"%26DomainName%3Dexample.com%26Format%3DXML%26SignatureMethod%3DHMAC-SHA1"+
+		"%26SignatureNonce%3Df59ed6a9-83fc-473b-9cc6-99c95df3856e%26SignatureVersion%3D1.0"+
+		"%26Timestamp%3D2016-03-24T16%253A41%253A54Z%26Version%3D2015-01-09"; got != want {
+		t.Errorf("string to sign = %s, want %s", got, want)
+	}
+	if got := sign("GET", canonicalized, "testsecret"); got != "uRpHwaSEt3J+6KQD//svCh/x+pI=" {
+		t.Errorf("signature = %s, want uRpHwaSEt3J+6KQD//svCh/x+pI=", got)
+	}
+}
+
+func TestToRR(t *testing.T) {
+	tests := map[string]string{
+		"example.com":      "@",
+		"example.com.":     "@",
+		"www.example.com":  "www",
+		"a.b.example.com.": "a.b",
+		"www":              "www",
+		"":                 "@",
+	}
+	for name, want := range tests {
+		if got := toRR(name, "example.com"); got != want {
+			t.Errorf("toRR(%q) = %q, want %q", name, got, want)
+		}
+	}
+}
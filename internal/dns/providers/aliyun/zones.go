@@ -0,0 +1,57 @@
+package aliyun
+
+import (
+	"context"
+	"strconv"
+)
+
+// Zone represents an Alidns domain. ID is the domain name, which is how Alidns addresses zones.
+type Zone struct {
+	ID          string   `json:"id"`
+	DomainID    string   `json:"domainId"`
+	Name        string   `json:"name"`
+	NameServers []string `json:"name_servers"`
+}
+
+// describeDomainsResponse represents the response of DescribeDomains
+type describeDomainsResponse struct {
+	TotalCount int `json:"TotalCount"`
+	Domains    struct {
+		Domain []struct {
+			DomainID   string `json:"DomainId"`
+			DomainName string `json:"DomainName"`
+			DnsServers struct {
+				DnsServer []string `json:"DnsServer"`
+			} `json:"DnsServers"`
+		} `json:"Domain"`
+	} `json:"Domains"`
+}
+
+// ListZones retrieves all domains of the Aliyun account
+func (p *AliyunProvider) ListZones(ctx context.Context) ([]Zone, error) {
+	var zones []Zone
+	for page := 1; ; page++ {
+		params := map[string]string{
+			"PageNumber": strconv.Itoa(page),
+			"PageSize":   strconv.Itoa(domainsPageSize),
+		}
+
+		var resp describeDomainsResponse
+		if err := p.call(ctx, "DescribeDomains", params, &resp); err != nil {
+			return nil, err
+		}
+
+		for _, d := range resp.Domains.Domain {
+			zones = append(zones, Zone{
+				ID:          d.DomainName,
+				DomainID:    d.DomainID,
+				Name:        d.DomainName,
+				NameServers: d.DnsServers.DnsServer,
+			})
+		}
+
+		if len(resp.Domains.Domain) == 0 || len(zones) >= resp.TotalCount {
+			return zones, nil
+		}
+	}
+}
@@ -7,20 +7,31 @@ import (
 	"time"
 
 	"go_cmdb/internal/db"
+	"go_cmdb/internal/dns/providers/aliyun"
 	"go_cmdb/internal/dns/providers/cloudflare"
 	"go_cmdb/internal/model"
 )
 
 // PullSyncResult represents the result of DNS records pull synchronization
 type PullSyncResult struct {
-	Fetched int `json:"fetched"` // Total records fetched from Cloudflare
+	Fetched int `json:"fetched"` // Total records fetched from the provider
 	Created int `json:"created"` // New external records created
 	Updated int `json:"updated"` // Existing records updated
-	Deleted int `json:"deleted"` // Local records deleted (not in Cloudflare)
+	Deleted int `json:"deleted"` // Local records deleted (not at the provider)
 }
 
-// PullSyncRecords pulls DNS records from Cloudflare and syncs to local database
-// Core principle: Cloudflare is the source of truth
+// providerRecord is a DNS record listed from a provider
+type providerRecord struct {
+	ID      string
+	Type    string
+	Name    string // FQDN (cloudflare) or relative name (aliyun RR)
+	Content string
+	TTL     int
+	Proxied bool
+}
+
+// PullSyncRecords pulls DNS records from the DNS provider (cloudflare, aliyun) and syncs to local database
+// Core principle: the provider is the source of truth
 // Sync unit: provider_record_id (not name/value)
 func PullSyncRecords(ctx context.Context, domainID int) (*PullSyncResult, error) {
 	// 1. Validate domain exists
@@ -35,9 +46,9 @@ func PullSyncRecords(ctx context.Context, domainID int) (*PullSyncResult, error)
 		return nil, fmt.Errorf("active provider not found: %w", err)
 	}
 
-	// 3. Validate provider is cloudflare
-	if provider.Provider != model.DNSProviderCloudflare {
-		return nil, fmt.Errorf("only cloudflare provider is supported, got: %s", provider.Provider)
+	// 3. Validate provider is supported
+	if provider.Provider != model.DNSProviderCloudflare && provider.Provider != model.DNSProviderAliyun {
+		return nil, fmt.Errorf("only cloudflare and aliyun providers are supported, got: %s", provider.Provider)
 	}
 
 	// 4. Get API key
@@ -51,11 +62,10 @@ func PullSyncRecords(ctx context.Context, domainID int) (*PullSyncResult, error)
 		return nil, fmt.Errorf("api_key not found: %w", err)
 	}
 
-	// 5. Call Cloudflare API: List Records
-	cfProvider := cloudflare.NewCloudflareProvider(apiKey.Account, apiKey.APIToken)
-	records, err := cfProvider.ListRecords(ctx, provider.ProviderZoneID)
+	// 5. Call provider API: List Records
+	records, err := listProviderRecords(ctx, provider.Provider, provider.ProviderZoneID, apiKey.Account, apiKey.APIToken)
 	if err != nil {
-		return nil, fmt.Errorf("failed to list cloudflare records: %w", err)
+		return nil, fmt.Errorf("failed to list %s records: %w", provider.Provider, err)
 	}
 
 	result := &PullSyncResult{
@@ -65,13 +75,13 @@ func PullSyncRecords(ctx context.Context, domainID int) (*PullSyncResult, error)
 	// 6. Record sync start time
 	syncStartedAt := time.Now()
 
-	// 7. Build map of Cloudflare record IDs for quick lookup
-	cfRecordIDs := make(map[string]bool)
+	// 7. Build map of provider record IDs for quick lookup
+	remoteRecordIDs := make(map[string]bool)
 	for _, record := range records {
-		cfRecordIDs[record.ID] = true
+		remoteRecordIDs[record.ID] = true
 	}
 
-	// 8. Sync each Cloudflare record
+	// 8. Sync each provider record
 	for _, record := range records {
 		// Only sync A, AAAA, CNAME, TXT records
 		if !isSupportedRecordType(record.Type) {
@@ -91,19 +101,19 @@ func PullSyncRecords(ctx context.Context, domainID int) (*PullSyncResult, error)
 		}
 	}
 
-	// 9. Delete local records that are not in Cloudflare
-	// Rule: If local record has provider_record_id but not in Cloudflare, delete it
+	// 9. Delete local records that are not at the provider
+	// Rule: If local record has provider_record_id but not at the provider, delete it
 	var localRecords []model.DomainDNSRecord
 	if err := db.DB.Where("domain_id = ? AND provider_record_id IS NOT NULL AND provider_record_id != ''", domainID).Find(&localRecords).Error; err != nil {
 		log.Printf("[DNSPullSync] Failed to query local records: %v", err)
 	} else {
 		for _, localRecord := range localRecords {
-			if !cfRecordIDs[localRecord.ProviderRecordID] {
-				// Record exists locally but not in Cloudflare, delete it
+			if !remoteRecordIDs[localRecord.ProviderRecordID] {
+				// Record exists locally but not at the provider, delete it
 				if err := db.DB.Delete(&localRecord).Error; err != nil {
 					log.Printf("[DNSPullSync] Failed to delete local record %d: %v", localRecord.ID, err)
 				} else {
-					log.Printf("[DNSPullSync] Deleted local record %d (provider_record_id=%s, not in Cloudflare)", 
+					log.Printf("[DNSPullSync] Deleted local record %d (provider_record_id=%s, not at provider)", 
 						localRecord.ID, localRecord.ProviderRecordID)
 					result.Deleted++
 				}
@@ -114,11 +124,39 @@ func PullSyncRecords(ctx context.Context, domainID int) (*PullSyncResult, error)
 	return result, nil
 }
 
-// syncSingleRecord syncs a single Cloudflare record to local database
+// listProviderRecords lists the records of a zone from a DNS provider
+func listProviderRecords(ctx context.Context, provider model.DNSProvider, zoneID, account, apiToken string) ([]providerRecord, error) {
+	var records []providerRecord
+
+	switch provider {
+	case model.DNSProviderCloudflare:
+		cfRecords, err := cloudflare.NewCloudflareProvider(account, apiToken).ListRecords(ctx, zoneID)
+		if err != nil {
+			return nil, err
+		}
+		for _, r := range cfRecords {
+			records = append(records, providerRecord{ID: r.ID, Type: r.Type, Name: r.Name, Content: r.Content, TTL: r.TTL, Proxied: r.Proxied})
+		}
+	case model.DNSProviderAliyun:
+		aliRecords, err := aliyun.NewAliyunProvider(account, apiToken).ListRecords(ctx, zoneID)
+		if err != nil {
+			return nil, err
+		}
+		for _, r := range aliRecords {
+			records = append(records, providerRecord{ID: r.RecordID, Type: r.Type, Name: r.RR, Content: r.Value, TTL: r.TTL})
+		}
+	default:
+		return nil, fmt.Errorf("unsupported DNS provider: %s", provider)
+	}
+
+	return records, nil
+}
+
+// syncSingleRecord syncs a single provider record to local database
 // Returns (created, updated, error)
 // Core principle: provider_record_id is the unique identity
-func syncSingleRecord(domainID int, zoneDomain string, record cloudflare.CloudflareRecord, syncStartedAt time.Time) (bool, bool, error) {
-	// 1. Normalize name from Cloudflare (may be FQDN) to relative name
+func syncSingleRecord(domainID int, zoneDomain string, record providerRecord, syncStartedAt time.Time) (bool, bool, error) {
+	// 1. Normalize name from the provider (may be FQDN) to relative name
 	normalizedName := NormalizeRelativeName(record.Name, zoneDomain)
 
 	// 2. Try to find existing record by provider_record_id
@@ -127,8 +165,8 @@ func syncSingleRecord(domainID int, zoneDomain string, record cloudflare.Cloudfl
 	err := db.DB.Where("provider_record_id = ?", record.ID).First(&existingRecord).Error
 
 	if err != nil {
-		// Record does not exist locally (new record in Cloudflare)
-		// Rule: Pull can INSERT new records from Cloudflare
+		// Record does not exist locally (new record at the provider)
+		// Rule: Pull can INSERT new records from the provider
 		newRecord := model.DomainDNSRecord{
 			DomainID:         domainID,
 			Type:             model.DNSRecordType(record.Type),
@@ -155,7 +193,7 @@ func syncSingleRecord(domainID int, zoneDomain string, record cloudflare.Cloudfl
 
 	// 3. Record exists locally, UPDATE it
 	// Rule: Same record_id = UPDATE (not delete + insert)
-	// Update all fields from Cloudflare (Cloudflare is source of truth)
+	// Update all fields from the provider (provider is source of truth)
 	updates := map[string]interface{}{
 		"type":               model.DNSRecordType(record.Type),
 		"name":               normalizedName,
@@ -6,7 +6,6 @@ import (
 	"math"
 	"time"
 
-	"go_cmdb/internal/dns/providers/cloudflare"
 	"go_cmdb/internal/model"
 
 	"gorm.io/gorm"
@@ -32,7 +31,7 @@ func (s *Service) GetDB() *gorm.DB {
 // - status in ('pending', 'error')
 // - next_retry_at is null or <= now
 // - desired_state = 'present'
-// - domain_dns_providers.provider = 'cloudflare'
+// - domain_dns_providers.provider has a Provider implementation (cloudflare, aliyun)
 // - domain_dns_providers.status = 'active'
 // - domains.status = 'active'
 func (s *Service) GetPendingRecords(limit int) ([]model.DomainDNSRecord, error) {
@@ -44,7 +43,7 @@ func (s *Service) GetPendingRecords(limit int) ([]model.DomainDNSRecord, error)
 		Where("domain_dns_records.status IN ?", []string{string(model.DNSRecordStatusPending), string(model.DNSRecordStatusError)}).
 		Where("(domain_dns_records.next_retry_at IS NULL OR domain_dns_records.next_retry_at <= ?)", time.Now()).
 		Where("domain_dns_records.desired_state = ?", model.DNSRecordDesiredStatePresent).
-		Where("domain_dns_providers.provider = ?", "cloudflare").
+		Where("domain_dns_providers.provider IN ?", SupportedProviders).
 		Where("domain_dns_providers.status = ?", "active").
 		Where("domains.status = ?", "active").
 		Limit(limit).
@@ -184,11 +183,11 @@ func (s *Service) GetDomain(domainID int) (*model.Domain, error) {
 	return &domain, nil
 }
 
-// DeleteRecordFromCloudflare deletes a DNS record from Cloudflare
+// DeleteRecordFromProvider deletes a DNS record from its DNS provider
 // Returns (success, error)
-// - success=true: Cloudflare delete success or record not found
-// - success=false: Cloudflare delete failed (real error)
-func (s *Service) DeleteRecordFromCloudflare(recordID int) (bool, error) {
+// - success=true: provider delete success or record not found
+// - success=false: provider delete failed (real error)
+func (s *Service) DeleteRecordFromProvider(recordID int) (bool, error) {
 	// Step 1: Get record
 	var record model.DomainDNSRecord
 	if err := s.db.First(&record, recordID).Error; err != nil {
@@ -207,21 +206,24 @@ func (s *Service) DeleteRecordFromCloudflare(recordID int) (bool, error) {
 		return false, fmt.Errorf("failed to get API key: %w", err)
 	}
 
-	// Step 4: Create Cloudflare provider
-	cfProvider := cloudflare.NewCloudflareProvider(apiKey.Account, apiKey.APIToken)
+	// Step 4: Create DNS provider
+	dnsProvider, err := NewProvider(provider.Provider, &apiKey)
+	if err != nil {
+		return false, err
+	}
 
-	// Step 5: Delete from Cloudflare
-	err = cfProvider.DeleteRecord(provider.ProviderZoneID, record.ProviderRecordID)
+	// Step 5: Delete from the provider
+	err = dnsProvider.DeleteRecord(provider.ProviderZoneID, record.ProviderRecordID)
 	if err != nil {
 		// Check if record not found
-		if err == cloudflare.ErrNotFound {
-			log.Printf("[DNS Service] Record %d: not found in Cloudflare (already deleted)\n", recordID)
+		if IsNotFound(err) {
+			log.Printf("[DNS Service] Record %d: not found in %s (already deleted)\n", recordID, provider.Provider)
 			return true, nil
 		}
 		// Real error
-		return false, fmt.Errorf("cloudflare delete failed: %w", err)
+		return false, fmt.Errorf("%s delete failed: %w", provider.Provider, err)
 	}
 
-	log.Printf("[DNS Service] Record %d: deleted from Cloudflare\n", recordID)
+	log.Printf("[DNS Service] Record %d: deleted from %s\n", recordID, provider.Provider)
 	return true, nil
 }
@@ -5,7 +5,6 @@ import (
 	"log"
 	"time"
 
-	"go_cmdb/internal/dns/providers/cloudflare"
 	"go_cmdb/internal/dnstypes"
 	"go_cmdb/internal/model"
 
@@ -19,7 +18,7 @@ type WorkerConfig struct {
 	BatchSize    int
 }
 
-// Worker periodically syncs DNS records to Cloudflare
+// Worker periodically syncs DNS records to DNS providers
 type Worker struct {
 	db      *gorm.DB
 	service *Service
@@ -233,15 +232,21 @@ func (w *Worker) processRecord(record *model.DomainDNSRecord) bool {
 		return true
 	}
 
-	// Step 5: Create Cloudflare provider
-	cfProvider := cloudflare.NewCloudflareProvider(apiKey.Account, apiKey.APIToken)
+	// Step 5: Create DNS provider (cloudflare / aliyun)
+	dnsProvider, err := NewProvider(provider.Provider, &apiKey)
+	if err != nil {
+		errMsg := err.Error()
+		log.Printf("[DNS Worker] Record %d: %s\n", record.ID, errMsg)
+		w.service.MarkAsError(int(record.ID), errMsg)
+		return true
+	}
 
-	// Step 6: Convert relative name to FQDN for Cloudflare API
+	// Step 6: Convert relative name to FQDN for the provider API
 	// record.Name is stored as relative name (@, www, a.b)
-	// Cloudflare API requires FQDN (example.com, www.example.com, a.b.example.com)
+	// Providers take FQDN (example.com, www.example.com, a.b.example.com)
 	fqdn := ToFQDN(domain.Domain, record.Name)
 
-	// Step 7: Ensure record in Cloudflare
+	// Step 7: Ensure record at the provider
 	dnsRecord := dnstypes.DNSRecord{
 		Type:    string(record.Type),
 		Name:    fqdn,
@@ -250,26 +255,26 @@ func (w *Worker) processRecord(record *model.DomainDNSRecord) bool {
 		Proxied: record.Proxied,
 	}
 
-	providerRecordID, changed, err := cfProvider.EnsureRecord(provider.ProviderZoneID, dnsRecord)
+	providerRecordID, changed, err := dnsProvider.EnsureRecord(provider.ProviderZoneID, dnsRecord)
 	if err != nil {
 		// Step 6.1: EnsureRecord failed, try FindRecord to check if record exists
 		log.Printf("[DNS Worker] Record %d: EnsureRecord failed: %v, trying FindRecord...\n", record.ID, err)
 		
-		foundID, findErr := cfProvider.FindRecord(provider.ProviderZoneID, string(record.Type), fqdn, record.Value)
+		foundID, findErr := dnsProvider.FindRecord(provider.ProviderZoneID, string(record.Type), fqdn, record.Value)
 		if findErr == nil && foundID != "" {
-			// Record exists in Cloudflare, bind it
-			log.Printf("[DNS Worker] Record %d: found in Cloudflare (provider_record_id=%s), binding...\n", record.ID, foundID)
+			// Record exists at provider, bind it
+			log.Printf("[DNS Worker] Record %d: found at provider (provider_record_id=%s), binding...\n", record.ID, foundID)
 			if err := w.service.MarkAsActive(int(record.ID), foundID); err != nil {
 				log.Printf("[DNS Worker] Record %d: failed to mark as active: %v\n", record.ID, err)
 				return true
 			}
-			log.Printf("[DNS Worker] Record %d: synced to Cloudflare (provider_record_id=%s, recovered=true)\n", 
+			log.Printf("[DNS Worker] Record %d: synced to provider (provider_record_id=%s, recovered=true)\n", 
 				record.ID, foundID)
 			return true
 		}
 		
-		// Record not found in Cloudflare, mark as error
-		errMsg := fmt.Sprintf("cloudflare API error: %v", err)
+		// Record not found at provider, mark as error
+		errMsg := fmt.Sprintf("%s API error: %v", provider.Provider, err)
 		log.Printf("[DNS Worker] Record %d: %s\n", record.ID, errMsg)
 		w.service.MarkAsError(int(record.ID), errMsg)
 		return true
@@ -282,7 +287,7 @@ func (w *Worker) processRecord(record *model.DomainDNSRecord) bool {
 	}
 
 	if changed {
-		log.Printf("[DNS Worker] Record %d: synced to Cloudflare (provider_record_id=%s, changed=true)\n", 
+		log.Printf("[DNS Worker] Record %d: synced to provider (provider_record_id=%s, changed=true)\n", 
 			record.ID, providerRecordID)
 	} else {
 		log.Printf("[DNS Worker] Record %d: already in sync (provider_record_id=%s, changed=false)\n", 
@@ -292,12 +297,12 @@ func (w *Worker) processRecord(record *model.DomainDNSRecord) bool {
 	return true
 }
 
-// deleteRecord deletes a single DNS record from Cloudflare and local database (legacy)
+// deleteRecord deletes a single DNS record from provider and local database (legacy)
 func (w *Worker) deleteRecord(record *model.DomainDNSRecord) {
 	w.deleteRecordInternal(record)
 }
 
-// deleteRecordInternal deletes a single DNS record from Cloudflare and local database
+// deleteRecordInternal deletes a single DNS record from provider and local database
 // Returns true if successfully deleted, false if error
 func (w *Worker) deleteRecordInternal(record *model.DomainDNSRecord) bool {
 	log.Printf("[DNS Worker] Deleting record %d (type=%s, name=%s, provider_record_id=%s)\n", 
@@ -321,22 +326,27 @@ func (w *Worker) deleteRecordInternal(record *model.DomainDNSRecord) bool {
 		return true
 	}
 
-	// Step 3: Create Cloudflare provider
-	cfProvider := cloudflare.NewCloudflareProvider(apiKey.Account, apiKey.APIToken)
+	// Step 3: Create DNS provider
+	dnsProvider, err := NewProvider(provider.Provider, &apiKey)
+	if err != nil {
+		log.Printf("[DNS Worker] Record %d: %v, deleting local record anyway\n", record.ID, err)
+		w.service.DeleteRecord(int(record.ID))
+		return true
+	}
 
-	// Step 4: Delete from Cloudflare
+	// Step 4: Delete from the provider
 	if record.ProviderRecordID != "" {
-		err := cfProvider.DeleteRecord(provider.ProviderZoneID, record.ProviderRecordID)
+		err := dnsProvider.DeleteRecord(provider.ProviderZoneID, record.ProviderRecordID)
 		if err != nil {
-			// If record not found in Cloudflare, treat as success
-			if err == cloudflare.ErrNotFound {
-				log.Printf("[DNS Worker] Record %d: not found in Cloudflare (already deleted), proceeding with local deletion\n", record.ID)
+			// If record not found at the provider, treat as success
+			if IsNotFound(err) {
+				log.Printf("[DNS Worker] Record %d: not found at provider (already deleted), proceeding with local deletion\n", record.ID)
 			} else {
-				log.Printf("[DNS Worker] Record %d: failed to delete from Cloudflare: %v, deleting local record anyway\n", 
+				log.Printf("[DNS Worker] Record %d: failed to delete from provider: %v, deleting local record anyway\n", 
 					record.ID, err)
 			}
 		} else {
-			log.Printf("[DNS Worker] Record %d: deleted from Cloudflare\n", record.ID)
+			log.Printf("[DNS Worker] Record %d: deleted from provider\n", record.ID)
 		}
 	}
 
@@ -7,6 +7,7 @@ import (
 	"log"
 
 	"go_cmdb/internal/db"
+	"go_cmdb/internal/dns/providers/aliyun"
 	"go_cmdb/internal/dns/providers/cloudflare"
 	"go_cmdb/internal/model"
 )
@@ -18,7 +19,14 @@ type SyncResult struct {
 	Updated int `json:"updated"`
 }
 
-// SyncDomainsByAPIKey synchronizes domains from Cloudflare to local database
+// providerZone is a zone (domain) listed from a DNS provider
+type providerZone struct {
+	ID          string // Cloudflare zone ID, or the domain name for aliyun
+	Name        string
+	NameServers []string
+}
+
+// SyncDomainsByAPIKey synchronizes domains from the API key's DNS provider (cloudflare, aliyun) to local database
 func SyncDomainsByAPIKey(ctx context.Context, apiKeyID int) (*SyncResult, error) {
 	// 1. Validate apiKeyID exists
 	var apiKey struct {
@@ -31,16 +39,11 @@ func SyncDomainsByAPIKey(ctx context.Context, apiKeyID int) (*SyncResult, error)
 		return nil, fmt.Errorf("api_key not found: %w", err)
 	}
 
-	// 2. Validate provider is cloudflare
-	if apiKey.Provider != "cloudflare" {
-		return nil, fmt.Errorf("api_key provider must be cloudflare, got: %s", apiKey.Provider)
-	}
-
-	// 3. Call Cloudflare API: List Zones
-	cfProvider := cloudflare.NewCloudflareProvider(apiKey.Account, apiKey.APIToken)
-	zones, err := cfProvider.ListZones(ctx)
+	// 2-3. Call provider API: List Zones
+	providerName := model.DNSProvider(apiKey.Provider)
+	zones, err := listProviderZones(ctx, providerName, apiKey.Account, apiKey.APIToken)
 	if err != nil {
-		return nil, fmt.Errorf("failed to list cloudflare zones: %w", err)
+		return nil, err
 	}
 
 	result := &SyncResult{
@@ -49,7 +52,7 @@ func SyncDomainsByAPIKey(ctx context.Context, apiKeyID int) (*SyncResult, error)
 
 	// 4. Sync each zone
 	for _, zone := range zones {
-		created, err := syncSingleZone(apiKeyID, zone)
+		created, err := syncSingleZone(apiKeyID, providerName, zone)
 		if err != nil {
 			log.Printf("[DomainSync] Failed to sync zone %s: %v", zone.Name, err)
 			continue
@@ -64,9 +67,37 @@ func SyncDomainsByAPIKey(ctx context.Context, apiKeyID int) (*SyncResult, error)
 	return result, nil
 }
 
-// syncSingleZone syncs a single Cloudflare zone to local database
+// listProviderZones lists the zones of the account behind an API key
+func listProviderZones(ctx context.Context, provider model.DNSProvider, account, apiToken string) ([]providerZone, error) {
+	var zones []providerZone
+
+	switch provider {
+	case model.DNSProviderCloudflare:
+		cfZones, err := cloudflare.NewCloudflareProvider(account, apiToken).ListZones(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list cloudflare zones: %w", err)
+		}
+		for _, z := range cfZones {
+			zones = append(zones, providerZone{ID: z.ID, Name: z.Name, NameServers: z.NameServers})
+		}
+	case model.DNSProviderAliyun:
+		aliZones, err := aliyun.NewAliyunProvider(account, apiToken).ListZones(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list aliyun domains: %w", err)
+		}
+		for _, z := range aliZones {
+			zones = append(zones, providerZone{ID: z.ID, Name: z.Name, NameServers: z.NameServers})
+		}
+	default:
+		return nil, fmt.Errorf("api_key provider must be cloudflare or aliyun, got: %s", provider)
+	}
+
+	return zones, nil
+}
+
+// syncSingleZone syncs a single provider zone to local database
 // Returns (created, error)
-func syncSingleZone(apiKeyID int, zone cloudflare.Zone) (bool, error) {
+func syncSingleZone(apiKeyID int, providerName model.DNSProvider, zone providerZone) (bool, error) {
 	tx := db.DB.Begin()
 	defer func() {
 		if r := recover(); r != nil {
@@ -107,13 +138,13 @@ func syncSingleZone(apiKeyID int, zone cloudflare.Zone) (bool, error) {
 		}
 	}
 
-	// 2. Check if domain is already bound to a non-cloudflare provider
+	// 2. Check if domain is already bound to another provider
 	var existingProvider model.DomainDNSProvider
 	err = tx.Where("domain_id = ?", domainID).First(&existingProvider).Error
 	if err == nil {
 		// Provider binding exists
-		if existingProvider.Provider != model.DNSProviderCloudflare {
-			// Skip if bound to non-cloudflare provider
+		if existingProvider.Provider != providerName {
+			// Skip if bound to another provider
 			tx.Rollback()
 			log.Printf("[DomainSync] Domain %s already bound to provider %s, skipping", zone.Name, existingProvider.Provider)
 			return false, nil
@@ -123,7 +154,7 @@ func syncSingleZone(apiKeyID int, zone cloudflare.Zone) (bool, error) {
 	// 3. Upsert domain_dns_providers
 	provider := model.DomainDNSProvider{
 		DomainID:       domainID,
-		Provider:       model.DNSProviderCloudflare,
+		Provider:       providerName,
 		ProviderZoneID: zone.ID,
 		APIKeyID:       apiKeyID,
 		Status:         model.DNSProviderStatusActive,
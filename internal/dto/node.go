@@ -40,6 +40,20 @@ type IdentityDTO struct {
 	Fingerprint string `json:"fingerprint"`
 }
 
+// NodeAgentStateDTO represents the agent state reported by the node's agent
+type NodeAgentStateDTO struct {
+	AppliedVersion     *int64     `json:"appliedVersion"`
+	LastSuccessVersion *int64     `json:"lastSuccessVersion"`
+	LiveVersion        *int64     `json:"liveVersion"`
+	ExpectedVersion    *int64     `json:"expectedVersion"` // version of the latest successful release on the node
+	LastError          *string    `json:"lastError"`
+	NginxVersion       *string    `json:"nginxVersion"`
+	NginxConfigOK      *bool      `json:"nginxConfigOk"`
+	UptimeSec          *int64     `json:"uptimeSec"`
+	ConfigDrift        bool       `json:"configDrift"` // live version is not the expected one, or nginx -t fails
+	ReportedAt         *time.Time `json:"reportedAt"`
+}
+
 // SubIpDTO represents a sub IP in API responses
 type SubIpDTO struct {
 	ID      int    `json:"id"`
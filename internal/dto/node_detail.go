@@ -13,6 +13,7 @@ type NodeDetailItemDTO struct {
 	LastSeenAt      *time.Time         `json:"lastSeenAt,omitempty"`
 	LastHealthError *string            `json:"lastHealthError,omitempty"`
 	HealthFailCount int                `json:"healthFailCount"`
+	AgentState      *NodeAgentStateDTO `json:"agentState,omitempty"`
 	Identity        *IdentityDTO       `json:"identity,omitempty"`
 	Ips             NodeIPsContainerDTO `json:"ips"`
 	CreatedAt       time.Time          `json:"createdAt"`
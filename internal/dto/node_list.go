@@ -13,6 +13,7 @@ type NodeListItemDTO struct {
 	LastSeenAt      *time.Time         `json:"lastSeenAt,omitempty"`
 	LastHealthError *string            `json:"lastHealthError,omitempty"`
 	HealthFailCount int                `json:"healthFailCount"`
+	AgentState      *NodeAgentStateDTO `json:"agentState,omitempty"`
 	Ips             NodeIPsContainerDTO `json:"ips"`
 	CreatedAt       time.Time          `json:"createdAt"`
 	UpdatedAt       time.Time          `json:"updatedAt"`
@@ -0,0 +1,147 @@
+package maintenance
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// MaxDurationMinutes is the longest a maintenance window can stay open after it starts
+const MaxDurationMinutes = 24 * 60
+
+// Schedule is a parsed 5-field cron expression: minute hour day-of-month month day-of-week.
+// Each field supports *, numbers, ranges (1-5), lists (1,3,5) and steps (*/15, 0-30/10).
+// Day-of-week is 0-6 (0 = Sunday, 7 is accepted as Sunday).
+type Schedule struct {
+	minute, hour, dom, month, dow uint64
+	// domStar/dowStar follow cron semantics: when both day fields are restricted,
+	// a day matches if either of them matches
+	domStar, dowStar bool
+}
+
+// fieldBounds are the allowed ranges of the 5 fields
+var fieldBounds = [5][2]int{{0, 59}, {0, 23}, {1, 31}, {1, 12}, {0, 7}}
+
+// ParseSchedule parses a 5-field cron expression
+func ParseSchedule(expr string) (*Schedule, error) {
+	fields := strings.Fields(expr)
+	if len(fields) != 5 {
+		return nil, fmt.Errorf("schedule must have 5 fields (minute hour day month weekday), got %d", len(fields))
+	}
+
+	var bits [5]uint64
+	for i, field := range fields {
+		b, err := parseField(field, fieldBounds[i][0], fieldBounds[i][1])
+		if err != nil {
+			return nil, fmt.Errorf("invalid schedule field %q: %w", field, err)
+		}
+		bits[i] = b
+	}
+
+	// 7 is Sunday as well
+	if bits[4]&(1<<7) != 0 {
+		bits[4] = (bits[4] | 1) &^ (1 << 7)
+	}
+
+	return &Schedule{
+		minute:  bits[0],
+		hour:    bits[1],
+		dom:     bits[2],
+		month:   bits[3],
+		dow:     bits[4],
+		domStar: fields[2] == "*",
+		dowStar: fields[4] == "*",
+	}, nil
+}
+
+// parseField parses one cron field into a bit set
+func parseField(field string, min, max int) (uint64, error) {
+	var bits uint64
+	for _, part := range strings.Split(field, ",") {
+		rangePart, step := part, 1
+		if idx := strings.Index(part, "/"); idx >= 0 {
+			s, err := strconv.Atoi(part[idx+1:])
+			if err != nil || s <= 0 {
+				return 0, fmt.Errorf("invalid step %q", part[idx+1:])
+			}
+			rangePart, step = part[:idx], s
+		}
+
+		lo, hi := min, max
+		switch {
+		case rangePart == "*":
+		case strings.Contains(rangePart, "-"):
+			bounds := strings.SplitN(rangePart, "-", 2)
+			var err error
+			if lo, err = strconv.Atoi(bounds[0]); err != nil {
+				return 0, fmt.Errorf("invalid range %q", rangePart)
+			}
+			if hi, err = strconv.Atoi(bounds[1]); err != nil {
+				return 0, fmt.Errorf("invalid range %q", rangePart)
+			}
+		default:
+			v, err := strconv.Atoi(rangePart)
+			if err != nil {
+				return 0, fmt.Errorf("invalid value %q", rangePart)
+			}
+			lo, hi = v, v
+			// "5/10" means starting at 5 up to max
+			if step > 1 {
+				hi = max
+			}
+		}
+
+		if lo < min || hi > max || lo > hi {
+			return 0, fmt.Errorf("value out of range %d-%d", min, max)
+		}
+		for v := lo; v <= hi; v += step {
+			bits |= 1 << uint(v)
+		}
+	}
+	return bits, nil
+}
+
+// Matches reports whether the schedule fires at the minute of t
+func (s *Schedule) Matches(t time.Time) bool {
+	if s.minute&(1<<uint(t.Minute())) == 0 ||
+		s.hour&(1<<uint(t.Hour())) == 0 ||
+		s.month&(1<<uint(t.Month())) == 0 {
+		return false
+	}
+
+	domMatch := s.dom&(1<<uint(t.Day())) != 0
+	dowMatch := s.dow&(1<<uint(t.Weekday())) != 0
+	if s.domStar || s.dowStar {
+		return domMatch && dowMatch
+	}
+	return domMatch || dowMatch
+}
+
+// IsOpen reports whether a window that starts at each schedule time and lasts
+// durationMinutes is open at now (evaluated in loc)
+func (s *Schedule) IsOpen(now time.Time, durationMinutes int, loc *time.Location) bool {
+	if durationMinutes <= 0 {
+		return false
+	}
+	if durationMinutes > MaxDurationMinutes {
+		durationMinutes = MaxDurationMinutes
+	}
+
+	t := now.In(loc).Truncate(time.Minute)
+	for i := 0; i < durationMinutes; i++ {
+		if s.Matches(t) {
+			return true
+		}
+		t = t.Add(-time.Minute)
+	}
+	return false
+}
+
+// LoadLocation resolves a window timezone, empty means the server local time
+func LoadLocation(name string) (*time.Location, error) {
+	if name == "" {
+		return time.Local, nil
+	}
+	return time.LoadLocation(name)
+}
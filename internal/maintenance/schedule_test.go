@@ -0,0 +1,71 @@
+package maintenance
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseScheduleInvalid(t *testing.T) {
+	for _, expr := range []string{
+		"",
+		"* * * *",
+		"60 * * * *",
+		"* 24 * * *",
+		"* * 0 * *",
+		"*/0 * * * *",
+		"5-1 * * * *",
+		"a * * * *",
+	} {
+		if _, err := ParseSchedule(expr); err == nil {
+			t.Errorf("ParseSchedule(%q) expected error", expr)
+		}
+	}
+}
+
+func TestScheduleIsOpen(t *testing.T) {
+	loc := time.UTC
+	at := func(s string) time.Time {
+		v, err := time.ParseInLocation("2006-01-02 15:04", s, loc)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return v
+	}
+
+	tests := []struct {
+		expr     string
+		duration int
+		now      string
+		want     bool
+	}{
+		// Every day 02:00 for 2 hours (2026-10-17 is a Saturday)
+		{"0 2 * * *", 120, "2026-10-17 01:59", false},
+		{"0 2 * * *", 120, "2026-10-17 02:00", true},
+		{"0 2 * * *", 120, "2026-10-17 03:59", true},
+		{"0 2 * * *", 120, "2026-10-17 04:00", false},
+		// Window crossing midnight
+		{"30 23 * * *", 60, "2026-10-18 00:15", true},
+		// Weekdays only
+		{"0 22 * * 1-5", 60, "2026-10-17 22:10", false},
+		{"0 22 * * 1-5", 60, "2026-10-16 22:10", true},
+		// 7 is Sunday
+		{"0 1 * * 7", 60, "2026-10-18 01:30", true},
+		// Both day fields restricted: either matches
+		{"0 0 1 * 6", 30, "2026-10-17 00:10", true},
+		{"0 0 1 * 6", 30, "2026-11-01 00:10", true},
+		{"0 0 1 * 6", 30, "2026-10-19 00:10", false},
+		// Steps and lists
+		{"*/15 9,21 * * *", 5, "2026-10-17 21:47", true},
+		{"*/15 9,21 * * *", 5, "2026-10-17 21:50", false},
+	}
+
+	for _, tt := range tests {
+		s, err := ParseSchedule(tt.expr)
+		if err != nil {
+			t.Fatalf("ParseSchedule(%q): %v", tt.expr, err)
+		}
+		if got := s.IsOpen(at(tt.now), tt.duration, loc); got != tt.want {
+			t.Errorf("%q (%d min) at %s: IsOpen = %v, want %v", tt.expr, tt.duration, tt.now, got, tt.want)
+		}
+	}
+}
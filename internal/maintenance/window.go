@@ -0,0 +1,41 @@
+package maintenance
+
+import (
+	"fmt"
+	"time"
+
+	"go_cmdb/internal/model"
+)
+
+// Validate checks the schedule, duration and timezone of a maintenance window
+func Validate(schedule string, durationMinutes int, timezone string) error {
+	if _, err := ParseSchedule(schedule); err != nil {
+		return err
+	}
+	if durationMinutes <= 0 || durationMinutes > MaxDurationMinutes {
+		return fmt.Errorf("durationMinutes must be within 1-%d", MaxDurationMinutes)
+	}
+	if _, err := LoadLocation(timezone); err != nil {
+		return fmt.Errorf("invalid timezone %q", timezone)
+	}
+	return nil
+}
+
+// IsWindowOpen reports whether the maintenance window is open at now.
+// A disabled window never blocks releases.
+func IsWindowOpen(w *model.MaintenanceWindow, now time.Time) (bool, error) {
+	if !w.Enabled {
+		return true, nil
+	}
+
+	schedule, err := ParseSchedule(w.Schedule)
+	if err != nil {
+		return false, fmt.Errorf("maintenance window %d: %w", w.ID, err)
+	}
+	loc, err := LoadLocation(w.Timezone)
+	if err != nil {
+		return false, fmt.Errorf("maintenance window %d: invalid timezone %q", w.ID, w.Timezone)
+	}
+
+	return schedule.IsOpen(now, w.DurationMinutes, loc), nil
+}
@@ -5,14 +5,15 @@ import "time"
 // AgentTask represents a task to be executed by an agent
 type AgentTask struct {
 	BaseModel
-	NodeID       uint       `gorm:"not null;index" json:"nodeId"`
-	Type         string    `gorm:"type:enum('purge_cache','apply_config','reload');not null" json:"type"`
-	Payload      string    `gorm:"type:json" json:"payload"`
-	Status       string    `gorm:"type:enum('pending','running','success','failed');default:'pending';index" json:"status"`
-	LastError    string    `gorm:"type:varchar(255)" json:"lastError,omitempty"`
-	Attempts     int       `gorm:"-" json:"attempts,omitempty"`
-	NextRetryAt  *time.Time `gorm:"-" json:"nextRetryAt,omitempty"`
-	RequestID    string    `gorm:"-" json:"requestId,omitempty"`
+	NodeID      uint       `gorm:"not null;index" json:"nodeId"`
+	Type        string     `gorm:"type:enum('purge_cache','apply_config','reload','rollback_config');not null" json:"type"`
+	Payload     string     `gorm:"type:json" json:"payload"`
+	Status      string     `gorm:"type:enum('pending','running','success','failed','retrying','dead');default:'pending';index" json:"status"`
+	LastError   string     `gorm:"type:varchar(255)" json:"lastError,omitempty"`
+	Attempts    int        `gorm:"type:int;not null;default:0" json:"attempts"`   // Executions so far (incremented when claimed)
+	NextRetryAt *time.Time `gorm:"type:datetime(3)" json:"nextRetryAt,omitempty"` // Earliest time a retrying task can be pulled
+	Pushed      bool       `gorm:"not null;default:false" json:"pushed"`          // Pushed over mTLS, retries are pushed by the RetryScheduler
+	RequestID   string     `gorm:"-" json:"requestId,omitempty"`
 }
 
 // TableName specifies the table name for AgentTask
@@ -22,15 +23,18 @@ func (AgentTask) TableName() string {
 
 // Task type constants
 const (
-	TaskTypePurgeCache  = "purge_cache"
-	TaskTypeApplyConfig = "apply_config"
-	TaskTypeReload      = "reload"
+	TaskTypePurgeCache     = "purge_cache"
+	TaskTypeApplyConfig    = "apply_config"
+	TaskTypeReload         = "reload"
+	TaskTypeRollbackConfig = "rollback_config"
 )
 
 // Task status constants
 const (
-	TaskStatusPending = "pending"
-	TaskStatusRunning = "running"
-	TaskStatusSuccess = "success"
-	TaskStatusFailed  = "failed"
+	TaskStatusPending  = "pending"
+	TaskStatusRunning  = "running"
+	TaskStatusSuccess  = "success"
+	TaskStatusFailed   = "failed"
+	TaskStatusRetrying = "retrying" // Failed, waiting for next_retry_at before it can be pulled again
+	TaskStatusDead     = "dead"     // Dead-lettered after exhausting the retry attempts, only retried manually
 )
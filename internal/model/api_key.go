@@ -13,6 +13,7 @@ type APIKeyProvider string
 
 const (
 	APIKeyProviderCloudflare APIKeyProvider = "cloudflare"
+	APIKeyProviderAliyun     APIKeyProvider = "aliyun" // account = AccessKey ID, api_token = AccessKey secret
 )
 
 // APIKey represents an API key for external services
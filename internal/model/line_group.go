@@ -13,6 +13,9 @@ type LineGroup struct {
 	Status       string    `gorm:"column:status;type:varchar(32);not null;default:'active'" json:"-"`
 	CreatedAt    time.Time `gorm:"column:created_at;not null" json:"-"`
 	UpdatedAt    time.Time `gorm:"column:updated_at;not null" json:"-"`
+
+	// Maintenance window that releases touching this line group must wait for (nil = none)
+	MaintenanceWindowID *int64 `gorm:"column:maintenance_window_id;index:idx_maintenance_window_id" json:"-"`
 	
 	// Associations
 	Domain    *Domain    `gorm:"foreignKey:DomainID" json:"-"`
@@ -0,0 +1,21 @@
+package model
+
+import "time"
+
+// MaintenanceWindow 维护窗口（变更窗口），绑定到线路分组后，相关发布只在窗口打开时执行
+type MaintenanceWindow struct {
+	ID              int64     `gorm:"column:id;primaryKey;autoIncrement" json:"id"`
+	Name            string    `gorm:"column:name;type:varchar(128);not null;uniqueIndex:uk_maintenance_windows_name" json:"name"`
+	Schedule        string    `gorm:"column:schedule;type:varchar(128);not null" json:"schedule"` // cron 表达式（分 时 日 月 周），窗口开始时间
+	DurationMinutes int       `gorm:"column:duration_minutes;type:int;not null" json:"durationMinutes"`
+	Timezone        string    `gorm:"column:timezone;type:varchar(64);not null;default:''" json:"timezone"` // IANA 时区，空表示服务器本地时区
+	Enabled         bool      `gorm:"column:enabled;type:tinyint(1);not null;default:1" json:"enabled"`
+	Description     string    `gorm:"column:description;type:varchar(255);not null;default:''" json:"description"`
+	CreatedAt       time.Time `gorm:"column:created_at;not null" json:"createdAt"`
+	UpdatedAt       time.Time `gorm:"column:updated_at;not null" json:"updatedAt"`
+}
+
+// TableName 指定表名
+func (MaintenanceWindow) TableName() string {
+	return "maintenance_windows"
+}
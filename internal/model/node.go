@@ -22,6 +22,15 @@ type Node struct {
 	LastSeenAt       *time.Time `gorm:"type:datetime;null" json:"last_seen_at"`
 	LastHealthError  *string    `gorm:"type:varchar(255);null" json:"last_health_error"`
 	HealthFailCount  int        `gorm:"type:int;not null;default:0" json:"health_fail_count"`
+	// Agent state reported by /agent/v1/ping (stored by the health worker)
+	AgentAppliedVersion     *int64     `gorm:"type:bigint;null" json:"agent_applied_version"`
+	AgentLastSuccessVersion *int64     `gorm:"type:bigint;null" json:"agent_last_success_version"`
+	AgentLiveVersion        *int64     `gorm:"type:bigint;null" json:"agent_live_version"`
+	AgentLastError          *string    `gorm:"type:varchar(1024);null" json:"agent_last_error"`
+	NginxVersion            *string    `gorm:"type:varchar(64);null" json:"nginx_version"`
+	NginxConfigOK           *bool      `gorm:"type:tinyint;null" json:"nginx_config_ok"`
+	AgentUptimeSec          *int64     `gorm:"type:bigint;null" json:"agent_uptime_sec"`
+	AgentStateAt            *time.Time `gorm:"type:datetime;null" json:"agent_state_at"`
 	IPs              []NodeIP    `gorm:"foreignKey:NodeID;constraint:OnDelete:CASCADE" json:"ips,omitempty"`
 }
 
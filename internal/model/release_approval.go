@@ -0,0 +1,52 @@
+package model
+
+import "time"
+
+// ReleaseApprovalPolicyKind 审批策略类型
+type ReleaseApprovalPolicyKind string
+
+const (
+	ReleaseApprovalPolicyWebsiteDelete ReleaseApprovalPolicyKind = "website_delete" // 删除网站
+	ReleaseApprovalPolicyHTTPSChange   ReleaseApprovalPolicyKind = "https_change"   // 修改网站 HTTPS 配置
+	ReleaseApprovalPolicyNodeCount     ReleaseApprovalPolicyKind = "node_count"     // 发布节点数超过 threshold
+)
+
+// ReleaseApprovalPolicy 发布审批策略，命中任一启用的策略时发布进入 awaiting_approval
+type ReleaseApprovalPolicy struct {
+	ID          int64                     `gorm:"column:id;primaryKey;autoIncrement" json:"id"`
+	Name        string                    `gorm:"column:name;type:varchar(128);not null;uniqueIndex:uk_release_approval_policies_name" json:"name"`
+	Kind        ReleaseApprovalPolicyKind `gorm:"column:kind;type:varchar(32);not null" json:"kind"`
+	Threshold   int                       `gorm:"column:threshold;type:int;not null;default:0" json:"threshold"` // node_count：节点数超过该值时需要审批
+	Enabled     bool                      `gorm:"column:enabled;type:tinyint(1);not null;default:1" json:"enabled"`
+	Description string                    `gorm:"column:description;type:varchar(255);not null;default:''" json:"description"`
+	CreatedAt   time.Time                 `gorm:"column:created_at;not null" json:"createdAt"`
+	UpdatedAt   time.Time                 `gorm:"column:updated_at;not null" json:"updatedAt"`
+}
+
+// TableName 指定表名
+func (ReleaseApprovalPolicy) TableName() string {
+	return "release_approval_policies"
+}
+
+// ReleaseApprovalDecision 审批结果
+type ReleaseApprovalDecision string
+
+const (
+	ReleaseApprovalDecisionApproved ReleaseApprovalDecision = "approved"
+	ReleaseApprovalDecisionRejected ReleaseApprovalDecision = "rejected"
+)
+
+// ReleaseApproval 发布审批记录
+type ReleaseApproval struct {
+	ID            int64                   `gorm:"column:id;primaryKey;autoIncrement" json:"id"`
+	ReleaseTaskID int64                   `gorm:"column:release_task_id;type:bigint;not null;index" json:"releaseTaskId"`
+	Decision      ReleaseApprovalDecision `gorm:"column:decision;type:varchar(16);not null" json:"decision"`
+	Approver      string                  `gorm:"column:approver;type:varchar(64);not null" json:"approver"`
+	Comment       string                  `gorm:"column:comment;type:varchar(500);not null;default:''" json:"comment"`
+	CreatedAt     time.Time               `gorm:"column:created_at;not null" json:"createdAt"`
+}
+
+// TableName 指定表名
+func (ReleaseApproval) TableName() string {
+	return "release_approvals"
+}
@@ -10,7 +10,8 @@ import (
 type ReleaseTaskType string
 
 const (
-	ReleaseTaskTypeApplyConfig ReleaseTaskType = "apply_config"
+	ReleaseTaskTypeApplyConfig    ReleaseTaskType = "apply_config"
+	ReleaseTaskTypeRollbackConfig ReleaseTaskType = "rollback_config" // 补偿发布：回滚到上一个成功版本
 )
 
 // ReleaseTaskTarget 发布目标类型
@@ -24,11 +25,17 @@ const (
 type ReleaseTaskStatus string
 
 const (
-	ReleaseTaskStatusPending ReleaseTaskStatus = "pending"
-	ReleaseTaskStatusRunning ReleaseTaskStatus = "running"
-	ReleaseTaskStatusSuccess ReleaseTaskStatus = "success"
-	ReleaseTaskStatusFailed  ReleaseTaskStatus = "failed"
-	ReleaseTaskStatusPaused  ReleaseTaskStatus = "paused"
+	ReleaseTaskStatusPending   ReleaseTaskStatus = "pending"
+	ReleaseTaskStatusRunning   ReleaseTaskStatus = "running"
+	ReleaseTaskStatusSuccess   ReleaseTaskStatus = "success"
+	ReleaseTaskStatusFailed    ReleaseTaskStatus = "failed"
+	ReleaseTaskStatusPaused    ReleaseTaskStatus = "paused"
+	ReleaseTaskStatusCancelled ReleaseTaskStatus = "cancelled"
+	// 等待计划时间或维护窗口（executor 判断窗口未打开时由 pending 转入）
+	ReleaseTaskStatusWaitingWindow ReleaseTaskStatus = "waiting_window"
+	// 等待审批（命中审批策略时创建为该状态），审批通过转 pending，驳回转 rejected
+	ReleaseTaskStatusAwaitingApproval ReleaseTaskStatus = "awaiting_approval"
+	ReleaseTaskStatusRejected         ReleaseTaskStatus = "rejected"
 )
 
 // ReleaseTaskPayload 发布任务 payload
@@ -45,6 +52,17 @@ type ReleaseTaskPayload struct {
 	OriginMode    string        `json:"originMode,omitempty"`
 	LineGroupID   int64         `json:"lineGroupId,omitempty"`
 	Origins       *OriginsList  `json:"origins,omitempty"`
+
+	// 回滚任务（rollback_config）
+	RollbackToVersion   int64 `json:"rollbackToVersion,omitempty"`   // 回滚目标版本，0 表示由 Agent 选择上一个成功版本
+	RollbackFromVersion int64 `json:"rollbackFromVersion,omitempty"` // 被回滚的版本
+
+	// 删除网站任务（审批通过后按此重新派发）
+	Action  string   `json:"action,omitempty"`
+	Domains []string `json:"domains,omitempty"`
+
+	// 等待审批的网站更新请求（action=update），审批通过后写入网站表
+	PendingUpdate json.RawMessage `json:"pendingUpdate,omitempty"`
 }
 
 // OriginsList origins 列表
@@ -84,6 +102,78 @@ func (p *ReleaseTaskPayload) Scan(value interface{}) error {
 	return json.Unmarshal(bytes, p)
 }
 
+// RolloutStrategyType 发布批次策略类型
+type RolloutStrategyType string
+
+const (
+	RolloutStrategyCanary     RolloutStrategyType = "canary"     // 前 N 个节点为金丝雀批次，其余节点一批
+	RolloutStrategyPercentage RolloutStrategyType = "percentage" // 按累计百分比分批（如 1/10/50/100）
+	RolloutStrategyNodeGroup  RolloutStrategyType = "node_group" // 每个节点分组一批
+	RolloutStrategyLineGroup  RolloutStrategyType = "line_group" // 每个线路分组一批
+)
+
+// RolloutStrategy 发布批次策略
+type RolloutStrategy struct {
+	Type         RolloutStrategyType `json:"type"`
+	CanaryCount  int                 `json:"canaryCount,omitempty"`  // canary：金丝雀节点数，默认 1
+	Percentages  []int               `json:"percentages,omitempty"`  // percentage：累计百分比，须递增且以 100 结尾
+	MaxBatchSize int                 `json:"maxBatchSize,omitempty"` // 单批最大节点数，0 表示不限制，超出则拆分
+}
+
+// Value 实现 driver.Valuer 接口
+func (s RolloutStrategy) Value() (driver.Value, error) {
+	return json.Marshal(s)
+}
+
+// Scan 实现 sql.Scanner 接口
+func (s *RolloutStrategy) Scan(value interface{}) error {
+	if value == nil {
+		return nil
+	}
+	switch v := value.(type) {
+	case []byte:
+		return json.Unmarshal(v, s)
+	case string:
+		return json.Unmarshal([]byte(v), s)
+	}
+	return nil
+}
+
+// ReleaseHealthGates 批次之间的健康检查门禁，任一检查失败时暂停发布
+type ReleaseHealthGates struct {
+	WaitSeconds       int            `json:"waitSeconds,omitempty"`       // 批次完成后等待时间（秒）
+	CheckAgentVersion bool           `json:"checkAgentVersion,omitempty"` // 检查 Agent 上报的 live 版本为本次发布版本
+	HTTPProbe         *HTTPProbeGate `json:"httpProbe,omitempty"`         // 通过节点IP探测网站主域名
+}
+
+// HTTPProbeGate HTTP 探测门禁
+type HTTPProbeGate struct {
+	Scheme         string `json:"scheme,omitempty"`         // http/https，默认 http
+	Path           string `json:"path,omitempty"`           // 请求路径，默认 /
+	ExpectedStatus int    `json:"expectedStatus,omitempty"` // 期望状态码，默认 200
+	TimeoutSec     int    `json:"timeoutSec,omitempty"`     // 单次探测超时（秒），默认 5
+	Port           int    `json:"port,omitempty"`           // 探测端口，默认按 scheme 取 80/443
+}
+
+// Value 实现 driver.Valuer 接口
+func (g ReleaseHealthGates) Value() (driver.Value, error) {
+	return json.Marshal(g)
+}
+
+// Scan 实现 sql.Scanner 接口
+func (g *ReleaseHealthGates) Scan(value interface{}) error {
+	if value == nil {
+		return nil
+	}
+	switch v := value.(type) {
+	case []byte:
+		return json.Unmarshal(v, g)
+	case string:
+		return json.Unmarshal([]byte(v), g)
+	}
+	return nil
+}
+
 // ReleaseTask 发布任务
 type ReleaseTask struct {
 	// 旧字段（保留兼容）
@@ -105,6 +195,34 @@ type ReleaseTask struct {
 	RetryCount  int                 `gorm:"type:int;not null;default:0" json:"retryCount"`
 	NextRetryAt *time.Time          `gorm:"type:datetime(3)" json:"nextRetryAt"`
 
+	// 发布批次策略（为空时使用默认 canary 策略）
+	RolloutStrategy *RolloutStrategy `gorm:"type:text" json:"rolloutStrategy"`
+
+	// 失败预算：maxFailedNodes 为整个发布允许失败的节点数，maxFailedPercent 为单批允许失败的百分比
+	// 两者都为 0 时任一节点失败即超出预算；超出预算后按 autoRollback 创建回滚发布
+	MaxFailedNodes   int    `gorm:"type:int;not null;default:0" json:"maxFailedNodes"`
+	MaxFailedPercent int    `gorm:"type:int;not null;default:0" json:"maxFailedPercent"`
+	AutoRollback     bool   `gorm:"type:tinyint(1);not null;default:0" json:"autoRollback"`
+	RollbackOfID     *int64 `gorm:"type:bigint;index" json:"rollbackOfId"` // 回滚发布关联的原发布
+
+	// 计划执行时间（为空时立即执行）
+	ScheduledAt *time.Time `gorm:"type:datetime(3)" json:"scheduledAt"`
+
+	// 批次之间的健康检查门禁（为空时不检查）
+	HealthGates *ReleaseHealthGates `gorm:"type:text" json:"healthGates"`
+
+	// 取消信息
+	CancelledBy  *string    `gorm:"type:varchar(64)" json:"cancelledBy"`
+	CancelReason *string    `gorm:"type:varchar(255)" json:"cancelReason"`
+	CancelledAt  *time.Time `gorm:"type:datetime(3)" json:"cancelledAt"`
+
+	// 创建人和审批原因（命中的审批策略，为空表示无需审批），审批记录见 release_approvals
+	CreatedBy      *string `gorm:"type:varchar(64)" json:"createdBy"`
+	ApprovalReason *string `gorm:"type:varchar(500)" json:"approvalReason"`
+
+	// 创建时的网站配置快照（configgen.WebsiteConfig 列表 JSON，不含证书内容），用于版本对比
+	ConfigSnapshot *string `gorm:"type:longtext" json:"-"`
+
 	CreatedAt time.Time `gorm:"type:datetime(3);not null;default:CURRENT_TIMESTAMP(3)" json:"createdAt"`
 	UpdatedAt time.Time `gorm:"type:datetime(3);not null;default:CURRENT_TIMESTAMP(3) ON UPDATE CURRENT_TIMESTAMP(3)" json:"updatedAt"`
 }
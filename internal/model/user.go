@@ -8,6 +8,12 @@ const (
 	UserStatusInactive UserStatus = "inactive"
 )
 
+// User roles
+const (
+	UserRoleAdmin    = "admin"
+	UserRoleApprover = "approver" // May approve or reject releases
+)
+
 // User represents a user in the system
 type User struct {
 	BaseModel
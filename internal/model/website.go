@@ -15,6 +15,13 @@ type Website struct {
 	RedirectURL        string        `gorm:"type:varchar(2048)" json:"redirectUrl"`        // redirect模式时有值
 	RedirectStatusCode int           `gorm:"default:0" json:"redirectStatusCode"`          // redirect模式时有值
 
+	// 回源请求选项（https 回源时 SNI/证书校验生效）
+	OriginHostHeader string `gorm:"type:varchar(255);not null;default:''" json:"originHostHeader"` // 回源 Host，为空时透传 $host
+	OriginSNI        bool   `gorm:"not null;default:false" json:"originSni"`                       // proxy_ssl_server_name
+	OriginSNIName    string `gorm:"type:varchar(255);not null;default:''" json:"originSniName"`    // proxy_ssl_name，为空时使用回源 Host
+	OriginSSLVerify  bool   `gorm:"not null;default:false" json:"originSslVerify"`                 // 校验源站证书
+	OriginCACert     string `gorm:"type:text" json:"-"`                                            // 自定义 CA（PEM），为空时使用系统 CA
+
 	Status string `gorm:"type:enum('active','inactive');default:'active'" json:"status"`
 
 	// 关联
@@ -37,8 +37,28 @@ type Config struct {
 }
 // PingResponse is the expected response from the agent's ping endpoint
 type PingResponse struct {
-	Code    int    `json:"code"`
-	Message string `json:"message"`
+	Code    int         `json:"code"`
+	Message string      `json:"message"`
+	Data    *AgentState `json:"data"`
+}
+
+// AgentState is the agent state returned by the ping endpoint
+type AgentState struct {
+	AppliedVersion     int64           `json:"appliedVersion"`
+	LastSuccessVersion int64           `json:"lastSuccessVersion"`
+	LiveVersion        int64           `json:"liveVersion"`
+	LastError          *AgentLastError `json:"lastError"`
+	NginxVersion       string          `json:"nginxVersion"`
+	ConfigTestOK       bool            `json:"configTestOk"`
+	ConfigTestError    string          `json:"configTestError"`
+	UptimeSec          int64           `json:"uptimeSec"`
+}
+
+// AgentLastError is the content of the agent's last_error.json
+type AgentLastError struct {
+	Version int64  `json:"version"`
+	Error   string `json:"error"`
+	Time    string `json:"time"`
 }
 // CheckResult holds the result of a single manual health check
 type CheckResult struct {
@@ -115,7 +135,7 @@ func (w *Worker) runHealthChecks() {
 }
 
 func (w *Worker) checkNode(node *model.Node) {
-	url := fmt.Sprintf("https://%s:%d/api/v1/ping", node.MainIP, node.AgentPort)
+	url := fmt.Sprintf("https://%s:%d/agent/v1/ping", node.MainIP, node.AgentPort)
 	req, err := http.NewRequestWithContext(w.ctx, "GET", url, nil)
 	if err != nil {
 		w.handleFailure(node, fmt.Errorf("failed to create request: %w", err))
@@ -151,14 +171,17 @@ func (w *Worker) checkNode(node *model.Node) {
 		return
 	}
 
-	w.handleSuccess(node)
+	w.handleSuccess(node, pingResp.Data)
 }
 
-func (w *Worker) handleSuccess(node *model.Node) {
+func (w *Worker) handleSuccess(node *model.Node, state *AgentState) {
 	updates := map[string]interface{}{
-		"last_seen_at":       time.Now(),
-		"last_health_error":  nil,
-		"health_fail_count":  0,
+		"last_seen_at":      time.Now(),
+		"last_health_error": nil,
+		"health_fail_count": 0,
+	}
+	for column, value := range agentStateUpdates(state) {
+		updates[column] = value
 	}
 
 	if node.Status != model.NodeStatusMaintenance {
@@ -170,6 +193,45 @@ func (w *Worker) handleSuccess(node *model.Node) {
 	}
 }
 
+// agentStateUpdates maps the reported agent state to node columns (nothing if the agent sent no state)
+func agentStateUpdates(state *AgentState) map[string]interface{} {
+	if state == nil {
+		return nil
+	}
+
+	var lastError *string
+	if state.LastError != nil && state.LastError.Error != "" {
+		msg := fmt.Sprintf("version %d: %s", state.LastError.Version, state.LastError.Error)
+		if state.LastError.Time != "" {
+			msg = fmt.Sprintf("[%s] %s", state.LastError.Time, msg)
+		}
+		lastError = truncateString(msg, 1024)
+	}
+
+	var nginxVersion *string
+	if state.NginxVersion != "" {
+		nginxVersion = truncateString(state.NginxVersion, 64)
+	}
+
+	return map[string]interface{}{
+		"agent_applied_version":      state.AppliedVersion,
+		"agent_last_success_version": state.LastSuccessVersion,
+		"agent_live_version":         state.LiveVersion,
+		"agent_last_error":           lastError,
+		"nginx_version":              nginxVersion,
+		"nginx_config_ok":            state.ConfigTestOK,
+		"agent_uptime_sec":           state.UptimeSec,
+		"agent_state_at":             time.Now(),
+	}
+}
+
+func truncateString(s string, max int) *string {
+	if len(s) > max {
+		s = s[:max]
+	}
+	return &s
+}
+
 func (w *Worker) handleFailure(node *model.Node, err error) {
 	errorMsg := err.Error()
 	if len(errorMsg) > 255 {
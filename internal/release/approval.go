@@ -0,0 +1,110 @@
+package release
+
+import (
+	"log"
+
+	"go_cmdb/internal/approval"
+	"go_cmdb/internal/httpx"
+	"go_cmdb/internal/model"
+
+	"gorm.io/gorm"
+)
+
+// ApprovalDecisionRequest 审批通过/驳回请求
+type ApprovalDecisionRequest struct {
+	Comment string `json:"comment" binding:"required,max=500"` // 审批意见
+}
+
+// ApproveRelease 审批通过：awaiting_approval -> pending，记录审批人和意见
+// website 发布任务由调用方在通过后派发；cdn 发布由 Executor 拉起
+func (s *Service) ApproveRelease(releaseID int64, operator, role, comment string) (*model.ReleaseTask, error) {
+	return s.decideRelease(releaseID, operator, role, comment, model.ReleaseApprovalDecisionApproved)
+}
+
+// RejectRelease 审批驳回：awaiting_approval -> rejected，剩余 pending 节点标记为 skipped
+func (s *Service) RejectRelease(releaseID int64, operator, role, comment string) (*model.ReleaseTask, error) {
+	return s.decideRelease(releaseID, operator, role, comment, model.ReleaseApprovalDecisionRejected)
+}
+
+// RevertApproval 审批通过后派发失败时撤销审批：pending -> awaiting_approval，删除审批通过记录
+// 已派发到节点的发布（total_nodes>0）不能撤销
+func (s *Service) RevertApproval(releaseID int64) error {
+	return s.db.Transaction(func(tx *gorm.DB) error {
+		result := tx.Model(&model.ReleaseTask{}).
+			Where("id = ? AND status IN ? AND total_nodes = 0", releaseID, []model.ReleaseTaskStatus{
+				model.ReleaseTaskStatusPending,
+				model.ReleaseTaskStatusWaitingWindow,
+			}).
+			Updates(map[string]interface{}{"status": model.ReleaseTaskStatusAwaitingApproval})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return httpx.ErrStateConflict("release task already dispatched")
+		}
+
+		return tx.Where("release_task_id = ? AND decision = ?", releaseID, model.ReleaseApprovalDecisionApproved).
+			Delete(&model.ReleaseApproval{}).Error
+	})
+}
+
+// decideRelease 记录审批结果并转换发布状态
+// 只有审批角色可以审批，且不能审批自己创建的发布
+func (s *Service) decideRelease(releaseID int64, operator, role, comment string, decision model.ReleaseApprovalDecision) (*model.ReleaseTask, error) {
+	if !approval.CanApprove(role) {
+		return nil, httpx.ErrForbidden("approver role required")
+	}
+
+	var task model.ReleaseTask
+	var skipped []model.ReleaseTaskNode
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.First(&task, releaseID).Error; err != nil {
+			if err == gorm.ErrRecordNotFound {
+				return httpx.ErrNotFound("release task not found")
+			}
+			return err
+		}
+		if task.CreatedBy != nil && *task.CreatedBy == operator {
+			return httpx.ErrForbidden("cannot approve your own release")
+		}
+
+		status := model.ReleaseTaskStatusPending
+		if decision == model.ReleaseApprovalDecisionRejected {
+			status = model.ReleaseTaskStatusRejected
+		}
+		if err := transitRelease(tx, releaseID, []model.ReleaseTaskStatus{
+			model.ReleaseTaskStatusAwaitingApproval,
+		}, map[string]interface{}{
+			"status": status,
+		}); err != nil {
+			return err
+		}
+		task.Status = status
+
+		if decision == model.ReleaseApprovalDecisionRejected {
+			var err error
+			if skipped, err = skipPendingNodes(tx, releaseID, 0); err != nil {
+				return err
+			}
+		}
+
+		return tx.Create(&model.ReleaseApproval{
+			ReleaseTaskID: releaseID,
+			Decision:      decision,
+			Approver:      operator,
+			Comment:       comment,
+		}).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	log.Printf("[Release] Release task %d %s by %s", releaseID, decision, operator)
+	eventType := ReleaseEventApproved
+	if decision == model.ReleaseApprovalDecisionRejected {
+		eventType = ReleaseEventRejected
+	}
+	publishReleaseEvent(s.db, eventType, releaseID, operator, comment)
+	publishSkippedNodes(skipped)
+	return &task, nil
+}
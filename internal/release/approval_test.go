@@ -0,0 +1,52 @@
+package release
+
+import (
+	"testing"
+
+	"go_cmdb/internal/model"
+
+	"github.com/glebarez/sqlite"
+	"gorm.io/gorm"
+)
+
+func TestRevertApproval(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, stmt := range []string{
+		`CREATE TABLE release_tasks (id INTEGER PRIMARY KEY, status TEXT, total_nodes INTEGER NOT NULL DEFAULT 0, updated_at DATETIME)`,
+		`CREATE TABLE release_approvals (id INTEGER PRIMARY KEY, release_task_id INTEGER, decision TEXT, approver TEXT, comment TEXT, created_at DATETIME)`,
+		// 1: approved, not dispatched yet; 2: approved and dispatched
+		`INSERT INTO release_tasks VALUES (1, 'pending', 0, NULL), (2, 'pending', 3, NULL)`,
+		`INSERT INTO release_approvals VALUES (1, 1, 'approved', 'alice', 'ok', NULL), (2, 2, 'approved', 'alice', 'ok', NULL)`,
+	} {
+		if err := db.Exec(stmt).Error; err != nil {
+			t.Fatal(err)
+		}
+	}
+	s := NewService(db)
+
+	if err := s.RevertApproval(1); err != nil {
+		t.Fatalf("RevertApproval(1) = %v", err)
+	}
+	var status string
+	db.Raw("SELECT status FROM release_tasks WHERE id = 1").Scan(&status)
+	if status != string(model.ReleaseTaskStatusAwaitingApproval) {
+		t.Errorf("release 1 status = %s, want awaiting_approval", status)
+	}
+	var approvals int64
+	db.Model(&model.ReleaseApproval{}).Where("release_task_id = 1").Count(&approvals)
+	if approvals != 0 {
+		t.Errorf("release 1 still has %d approvals", approvals)
+	}
+
+	// A release already dispatched to nodes stays approved
+	if err := s.RevertApproval(2); err == nil {
+		t.Error("expected RevertApproval(2) to fail for a dispatched release")
+	}
+	db.Model(&model.ReleaseApproval{}).Where("release_task_id = 2").Count(&approvals)
+	if approvals != 1 {
+		t.Errorf("release 2 approvals = %d, want 1", approvals)
+	}
+}
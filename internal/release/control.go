@@ -0,0 +1,118 @@
+package release
+
+import (
+	"fmt"
+	"log"
+	"time"
+
+	"go_cmdb/internal/httpx"
+	"go_cmdb/internal/model"
+
+	"gorm.io/gorm"
+)
+
+// ControlReleaseRequest 暂停/恢复/取消发布请求
+type ControlReleaseRequest struct {
+	Reason string `json:"reason" binding:"max=255"` // 原因（取消时记录）
+}
+
+// ControlReleaseResponse 暂停/恢复/取消发布响应
+type ControlReleaseResponse struct {
+	ReleaseID int64  `json:"releaseId"`
+	Status    string `json:"status"`
+}
+
+// PauseRelease 暂停发布
+// pending/waiting_window/running -> paused，Runner 在节点/批次之间检测到后停止下发，已下发的节点会继续等待结果
+func (s *Service) PauseRelease(releaseID int64, operator string) (*ControlReleaseResponse, error) {
+	if err := transitRelease(s.db, releaseID, []model.ReleaseTaskStatus{
+		model.ReleaseTaskStatusPending,
+		model.ReleaseTaskStatusWaitingWindow,
+		model.ReleaseTaskStatusRunning,
+	}, map[string]interface{}{
+		"status": model.ReleaseTaskStatusPaused,
+	}); err != nil {
+		return nil, err
+	}
+
+	log.Printf("[Release] Release task %d paused by %s", releaseID, operator)
+	publishReleaseEvent(s.db, ReleaseEventPaused, releaseID, operator, "")
+	return &ControlReleaseResponse{ReleaseID: releaseID, Status: string(model.ReleaseTaskStatusPaused)}, nil
+}
+
+// ResumeRelease 恢复发布
+// paused -> pending，由 Executor 在维护窗口打开时重新拉起 Runner，从未完成的批次继续
+func (s *Service) ResumeRelease(releaseID int64, operator string) (*ControlReleaseResponse, error) {
+	if err := transitRelease(s.db, releaseID, []model.ReleaseTaskStatus{
+		model.ReleaseTaskStatusPaused,
+	}, map[string]interface{}{
+		"status": model.ReleaseTaskStatusPending,
+	}); err != nil {
+		return nil, err
+	}
+
+	log.Printf("[Release] Release task %d resumed by %s", releaseID, operator)
+	publishReleaseEvent(s.db, ReleaseEventResumed, releaseID, operator, "")
+	return &ControlReleaseResponse{ReleaseID: releaseID, Status: string(model.ReleaseTaskStatusPending)}, nil
+}
+
+// CancelRelease 取消发布
+// awaiting_approval/pending/waiting_window/running/paused -> cancelled，剩余 pending 节点标记为 skipped，记录取消人和原因
+func (s *Service) CancelRelease(releaseID int64, operator, reason string) (*ControlReleaseResponse, error) {
+	now := time.Now()
+	var skipped []model.ReleaseTaskNode
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		updates := map[string]interface{}{
+			"status":       model.ReleaseTaskStatusCancelled,
+			"cancelled_by": operator,
+			"cancelled_at": &now,
+		}
+		if reason != "" {
+			updates["cancel_reason"] = reason
+		}
+		if err := transitRelease(tx, releaseID, []model.ReleaseTaskStatus{
+			model.ReleaseTaskStatusAwaitingApproval,
+			model.ReleaseTaskStatusPending,
+			model.ReleaseTaskStatusWaitingWindow,
+			model.ReleaseTaskStatusRunning,
+			model.ReleaseTaskStatusPaused,
+		}, updates); err != nil {
+			return err
+		}
+
+		var err error
+		skipped, err = skipPendingNodes(tx, releaseID, 0)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	log.Printf("[Release] Release task %d cancelled by %s (reason=%s)", releaseID, operator, reason)
+	publishReleaseEvent(s.db, ReleaseEventCancelled, releaseID, operator, reason)
+	publishSkippedNodes(skipped)
+	return &ControlReleaseResponse{ReleaseID: releaseID, Status: string(model.ReleaseTaskStatusCancelled)}, nil
+}
+
+// transitRelease 条件更新发布任务状态（仅当前状态在 from 中时生效），避免并发操作互相覆盖
+func transitRelease(db *gorm.DB, releaseID int64, from []model.ReleaseTaskStatus, updates map[string]interface{}) error {
+	result := db.Model(&model.ReleaseTask{}).
+		Where("id = ? AND status IN ?", releaseID, from).
+		Updates(updates)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected > 0 {
+		return nil
+	}
+
+	// 区分不存在和状态不允许
+	var task model.ReleaseTask
+	if err := db.Select("id", "status").First(&task, releaseID).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return httpx.ErrNotFound("release task not found")
+		}
+		return err
+	}
+	return httpx.ErrStateConflict(fmt.Sprintf("release task is %s", task.Status))
+}
@@ -0,0 +1,490 @@
+package release
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	agentconfig "go_cmdb/agent/config"
+	"go_cmdb/agent/executor"
+	"go_cmdb/internal/configgen"
+	"go_cmdb/internal/httpx"
+	"go_cmdb/internal/model"
+	"go_cmdb/internal/textdiff"
+
+	"gorm.io/gorm"
+)
+
+// 网站/文件变更类型
+const (
+	DiffChangeAdded    = "added"
+	DiffChangeRemoved  = "removed"
+	DiffChangeModified = "modified"
+)
+
+// DiffAgainstPrevious against 参数：与同一目标的上一次发布对比
+const DiffAgainstPrevious = "previous"
+
+// generatedHeaderPrefix 渲染文件首部的生成时间行，对比前去掉，避免每个文件都有差异
+const generatedHeaderPrefix = "# Generated by CMDB Agent at "
+
+// ReleaseDiffResponse 发布版本对比结果（against -> release）
+type ReleaseDiffResponse struct {
+	ReleaseID      int64         `json:"releaseId"`
+	Version        int64         `json:"version"`
+	AgainstID      int64         `json:"againstId"` // 0 表示没有上一次发布，与空配置对比
+	AgainstVersion int64         `json:"againstVersion"`
+	Websites       []WebsiteDiff `json:"websites"`
+	Files          []FileDiff    `json:"files"`
+}
+
+// WebsiteDiff 单个网站的结构化差异
+type WebsiteDiff struct {
+	WebsiteID              int                       `json:"websiteId"`
+	PrimaryDomain          string                    `json:"primaryDomain"`
+	Change                 string                    `json:"change"`
+	DomainsAdded           []string                  `json:"domainsAdded,omitempty"`
+	DomainsRemoved         []string                  `json:"domainsRemoved,omitempty"`
+	OriginAddressesAdded   []configgen.AddressConfig `json:"originAddressesAdded,omitempty"`
+	OriginAddressesRemoved []configgen.AddressConfig `json:"originAddressesRemoved,omitempty"`
+	OriginAddressesChanged []OriginAddressChange     `json:"originAddressesChanged,omitempty"`
+	Fields                 []FieldChange             `json:"fields,omitempty"` // 其余字段变更（https.enabled、origin.mode 等）
+}
+
+// OriginAddressChange 同一回源地址的属性变化（角色、权重、启用、协议）
+type OriginAddressChange struct {
+	Address string                  `json:"address"`
+	From    configgen.AddressConfig `json:"from"`
+	To      configgen.AddressConfig `json:"to"`
+}
+
+// FieldChange 字段变化
+type FieldChange struct {
+	Field string `json:"field"`
+	From  string `json:"from"`
+	To    string `json:"to"`
+}
+
+// FileDiff 渲染后 nginx 文件的差异
+type FileDiff struct {
+	Path   string `json:"path"`
+	Change string `json:"change"`
+	Diff   string `json:"diff"` // unified diff
+}
+
+// DiffRelease 对比发布与 against（发布ID 或 previous）的网站配置和渲染后的 nginx 文件
+func (s *Service) DiffRelease(releaseID int64, against string) (*ReleaseDiffResponse, error) {
+	task, err := s.loadDiffRelease(releaseID)
+	if err != nil {
+		return nil, err
+	}
+
+	// 1. 确定对比基准
+	var base *model.ReleaseTask
+	if against == "" || against == DiffAgainstPrevious {
+		base, err = s.previousRelease(task)
+		if err != nil {
+			return nil, err
+		}
+	} else {
+		againstID, parseErr := strconv.ParseInt(against, 10, 64)
+		if parseErr != nil || againstID <= 0 {
+			return nil, httpx.ErrParamInvalid("against must be a release id or 'previous'")
+		}
+		if againstID == releaseID {
+			return nil, httpx.ErrParamInvalid("cannot diff a release against itself")
+		}
+		base, err = s.loadDiffRelease(againstID)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	// 2. 解析快照（没有上一次发布时与空配置对比）
+	to, err := configgen.ParseSnapshot(*task.ConfigSnapshot)
+	if err != nil {
+		return nil, httpx.ErrInternalError("failed to parse config snapshot", err)
+	}
+	from := []configgen.WebsiteConfig{}
+	resp := &ReleaseDiffResponse{
+		ReleaseID: task.ID,
+		Version:   task.Version,
+	}
+	if base != nil {
+		resp.AgainstID = base.ID
+		resp.AgainstVersion = base.Version
+		if from, err = configgen.ParseSnapshot(*base.ConfigSnapshot); err != nil {
+			return nil, httpx.ErrInternalError("failed to parse config snapshot", err)
+		}
+		from, to = scopeSnapshots(base, task, from, to)
+	}
+
+	// 3. 结构化差异 + 渲染文件差异（两边使用同一版本号，证书路径不产生差异）
+	resp.Websites = DiffWebsites(from, to)
+
+	renderer, err := executor.NewApplyConfigExecutor(agentconfig.NewDirConfig())
+	if err != nil {
+		return nil, httpx.ErrInternalError("failed to create config renderer", err)
+	}
+	fromFiles, err := renderSnapshot(renderer, task.Version, from)
+	if err != nil {
+		return nil, httpx.ErrInternalError("failed to render config", err)
+	}
+	toFiles, err := renderSnapshot(renderer, task.Version, to)
+	if err != nil {
+		return nil, httpx.ErrInternalError("failed to render config", err)
+	}
+	resp.Files = DiffFiles(fromFiles, toFiles)
+
+	return resp, nil
+}
+
+// loadDiffRelease 查询带配置快照的发布
+func (s *Service) loadDiffRelease(releaseID int64) (*model.ReleaseTask, error) {
+	var task model.ReleaseTask
+	if err := s.db.First(&task, releaseID).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return nil, httpx.ErrNotFound(fmt.Sprintf("release %d not found", releaseID))
+		}
+		return nil, httpx.ErrDatabaseError("failed to query release", err)
+	}
+	if task.ConfigSnapshot == nil {
+		return nil, httpx.ErrStateConflict(fmt.Sprintf("release %d has no config snapshot (created before snapshots were recorded)", releaseID))
+	}
+	return &task, nil
+}
+
+// previousRelease 查询同一目标的上一次发布（有快照、未被驳回），不存在时返回 nil
+// cdn 发布（含回滚）按 target 归类，website/origin_set 发布按 targetType+targetId 归类
+func (s *Service) previousRelease(task *model.ReleaseTask) (*model.ReleaseTask, error) {
+	query := s.db.Where("id < ? AND config_snapshot IS NOT NULL AND status != ?", task.ID, model.ReleaseTaskStatusRejected)
+	if task.Target == model.ReleaseTaskTargetCDN {
+		query = query.Where("target = ?", model.ReleaseTaskTargetCDN)
+	} else {
+		query = query.Where("target <> ? AND target_type = ? AND target_id = ?", model.ReleaseTaskTargetCDN, task.TargetType, task.TargetID)
+	}
+
+	var prev model.ReleaseTask
+	if err := query.Order("id DESC").First(&prev).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return nil, nil
+		}
+		return nil, httpx.ErrDatabaseError("failed to query previous release", err)
+	}
+	return &prev, nil
+}
+
+// scopeSnapshots cdn 发布的快照包含全部网站，与 website/origin_set 发布对比时只保留后者涉及的网站
+func scopeSnapshots(base, task *model.ReleaseTask, from, to []configgen.WebsiteConfig) ([]configgen.WebsiteConfig, []configgen.WebsiteConfig) {
+	baseCDN := base.Target == model.ReleaseTaskTargetCDN
+	taskCDN := task.Target == model.ReleaseTaskTargetCDN
+	if baseCDN == taskCDN {
+		return from, to
+	}
+
+	scoped, scopedSnapshot := task, to
+	if taskCDN {
+		scoped, scopedSnapshot = base, from
+	}
+	ids := make(map[int]bool)
+	for _, w := range scopedSnapshot {
+		ids[w.WebsiteID] = true
+	}
+	// 删除网站的发布快照为空，按目标网站归类
+	if scoped.TargetType == "website" {
+		ids[int(scoped.TargetID)] = true
+	}
+
+	return filterWebsites(from, ids), filterWebsites(to, ids)
+}
+
+// filterWebsites 按网站ID过滤快照
+func filterWebsites(websites []configgen.WebsiteConfig, ids map[int]bool) []configgen.WebsiteConfig {
+	result := make([]configgen.WebsiteConfig, 0, len(websites))
+	for _, w := range websites {
+		if ids[w.WebsiteID] {
+			result = append(result, w)
+		}
+	}
+	return result
+}
+
+// DiffWebsites 计算两份网站配置快照的结构化差异（按网站ID排序，未变化的网站不返回）
+func DiffWebsites(from, to []configgen.WebsiteConfig) []WebsiteDiff {
+	fromMap := make(map[int]*configgen.WebsiteConfig, len(from))
+	for i := range from {
+		fromMap[from[i].WebsiteID] = &from[i]
+	}
+	toMap := make(map[int]*configgen.WebsiteConfig, len(to))
+	for i := range to {
+		toMap[to[i].WebsiteID] = &to[i]
+	}
+
+	ids := make([]int, 0, len(fromMap)+len(toMap))
+	for id := range fromMap {
+		ids = append(ids, id)
+	}
+	for id := range toMap {
+		if _, ok := fromMap[id]; !ok {
+			ids = append(ids, id)
+		}
+	}
+	sort.Ints(ids)
+
+	empty := &configgen.WebsiteConfig{}
+	diffs := make([]WebsiteDiff, 0)
+	for _, id := range ids {
+		a, inFrom := fromMap[id]
+		b, inTo := toMap[id]
+
+		d := WebsiteDiff{WebsiteID: id, Change: DiffChangeModified}
+		switch {
+		case !inFrom:
+			d.Change = DiffChangeAdded
+			a = empty
+		case !inTo:
+			d.Change = DiffChangeRemoved
+			b = empty
+		}
+		d.PrimaryDomain = primaryDomain(b)
+		if d.PrimaryDomain == "" {
+			d.PrimaryDomain = primaryDomain(a)
+		}
+
+		d.DomainsAdded, d.DomainsRemoved = diffStrings(domainNames(a), domainNames(b))
+		d.OriginAddressesAdded, d.OriginAddressesRemoved, d.OriginAddressesChanged = diffAddresses(a.Origin.Addresses, b.Origin.Addresses)
+		d.Fields = diffFields(a, b)
+
+		if d.Change == DiffChangeModified && len(d.DomainsAdded) == 0 && len(d.DomainsRemoved) == 0 &&
+			len(d.OriginAddressesAdded) == 0 && len(d.OriginAddressesRemoved) == 0 &&
+			len(d.OriginAddressesChanged) == 0 && len(d.Fields) == 0 {
+			continue
+		}
+		diffs = append(diffs, d)
+	}
+	return diffs
+}
+
+// primaryDomain 网站主域名（无主域名时取第一个）
+func primaryDomain(w *configgen.WebsiteConfig) string {
+	for _, d := range w.Domains {
+		if d.IsPrimary {
+			return d.Domain
+		}
+	}
+	if len(w.Domains) > 0 {
+		return w.Domains[0].Domain
+	}
+	return ""
+}
+
+// domainNames 网站域名列表
+func domainNames(w *configgen.WebsiteConfig) []string {
+	names := make([]string, 0, len(w.Domains))
+	for _, d := range w.Domains {
+		names = append(names, d.Domain)
+	}
+	return names
+}
+
+// diffStrings 返回 b 相对 a 新增和删除的元素（排序）
+func diffStrings(a, b []string) ([]string, []string) {
+	inA := make(map[string]bool, len(a))
+	for _, s := range a {
+		inA[s] = true
+	}
+	inB := make(map[string]bool, len(b))
+	for _, s := range b {
+		inB[s] = true
+	}
+
+	var added, removed []string
+	for s := range inB {
+		if !inA[s] {
+			added = append(added, s)
+		}
+	}
+	for s := range inA {
+		if !inB[s] {
+			removed = append(removed, s)
+		}
+	}
+	sort.Strings(added)
+	sort.Strings(removed)
+	return added, removed
+}
+
+// diffAddresses 按地址对比回源地址
+func diffAddresses(a, b []configgen.AddressConfig) ([]configgen.AddressConfig, []configgen.AddressConfig, []OriginAddressChange) {
+	aMap := make(map[string]configgen.AddressConfig, len(a))
+	for _, addr := range a {
+		aMap[addr.Address] = addr
+	}
+	bMap := make(map[string]configgen.AddressConfig, len(b))
+	for _, addr := range b {
+		bMap[addr.Address] = addr
+	}
+
+	var added, removed []configgen.AddressConfig
+	var changed []OriginAddressChange
+	for _, addr := range b {
+		old, ok := aMap[addr.Address]
+		if !ok {
+			added = append(added, addr)
+		} else if old != addr {
+			changed = append(changed, OriginAddressChange{Address: addr.Address, From: old, To: addr})
+		}
+	}
+	for _, addr := range a {
+		if _, ok := bMap[addr.Address]; !ok {
+			removed = append(removed, addr)
+		}
+	}
+	return added, removed, changed
+}
+
+// diffFields 对比域名和回源地址以外的字段
+func diffFields(a, b *configgen.WebsiteConfig) []FieldChange {
+	var changes []FieldChange
+	add := func(field, from, to string) {
+		if from != to {
+			changes = append(changes, FieldChange{Field: field, From: from, To: to})
+		}
+	}
+
+	add("status", a.Status, b.Status)
+	add("primaryDomain", primaryDomain(a), primaryDomain(b))
+
+	add("origin.mode", a.Origin.Mode, b.Origin.Mode)
+	add("origin.redirectUrl", a.Origin.RedirectURL, b.Origin.RedirectURL)
+	add("origin.redirectStatusCode", intString(a.Origin.RedirectStatusCode), intString(b.Origin.RedirectStatusCode))
+	add("origin.upstreamName", a.Origin.UpstreamName, b.Origin.UpstreamName)
+	add("origin.protocol", a.Origin.Protocol, b.Origin.Protocol)
+	add("origin.hostHeader", a.Origin.HostHeader, b.Origin.HostHeader)
+	add("origin.sni", strconv.FormatBool(a.Origin.SNI), strconv.FormatBool(b.Origin.SNI))
+	add("origin.sniName", a.Origin.SNIName, b.Origin.SNIName)
+	add("origin.verify", strconv.FormatBool(a.Origin.Verify), strconv.FormatBool(b.Origin.Verify))
+	if a.Origin.CACertPem != b.Origin.CACertPem {
+		changes = append(changes, FieldChange{Field: "origin.caCert", From: pemState(a.Origin.CACertPem), To: pemState(b.Origin.CACertPem)})
+	}
+
+	add("https.enabled", strconv.FormatBool(a.HTTPS.Enabled), strconv.FormatBool(b.HTTPS.Enabled))
+	add("https.forceRedirect", strconv.FormatBool(a.HTTPS.ForceRedirect), strconv.FormatBool(b.HTTPS.ForceRedirect))
+	add("https.hsts", strconv.FormatBool(a.HTTPS.HSTS), strconv.FormatBool(b.HTTPS.HSTS))
+	add("https.certificateId", certificateID(&a.HTTPS), certificateID(&b.HTTPS))
+
+	add("cacheRuleId", intString(a.CacheRuleID), intString(b.CacheRuleID))
+	add("cacheItems", cacheItemsString(a.CacheItems), cacheItemsString(b.CacheItems))
+
+	return changes
+}
+
+// intString 0 显示为空
+func intString(v int) string {
+	if v == 0 {
+		return ""
+	}
+	return strconv.Itoa(v)
+}
+
+// pemState CA 证书内容不返回，只标记是否配置
+func pemState(pem string) string {
+	if pem == "" {
+		return ""
+	}
+	return "configured"
+}
+
+// certificateID HTTPS 证书ID
+func certificateID(h *configgen.HTTPSConfig) string {
+	if h.Certificate == nil {
+		return ""
+	}
+	return intString(h.Certificate.CertificateID)
+}
+
+// cacheItemsString 缓存规则项摘要，如 "suffix:.jpg=force/3600; path:/api=bypass/0"
+func cacheItemsString(items []configgen.CacheItemConfig) string {
+	parts := make([]string, 0, len(items))
+	for _, item := range items {
+		parts = append(parts, fmt.Sprintf("%s:%s=%s/%d", item.MatchType, item.MatchValue, item.Mode, item.TTLSeconds))
+	}
+	return strings.Join(parts, "; ")
+}
+
+// renderSnapshot 用 Agent 渲染器渲染快照对应的 upstream/server 文件
+func renderSnapshot(renderer *executor.ApplyConfigExecutor, version int64, websites []configgen.WebsiteConfig) ([]executor.RenderedFile, error) {
+	if len(websites) == 0 {
+		return nil, nil
+	}
+
+	// configgen 与 Agent 的 payload 结构按 JSON 对齐
+	data, err := json.Marshal(configgen.ApplyConfigPayload{Version: version, Websites: websites})
+	if err != nil {
+		return nil, err
+	}
+	var payload executor.ApplyConfigPayload
+	if err := json.Unmarshal(data, &payload); err != nil {
+		return nil, err
+	}
+
+	return renderer.Preview(&payload)
+}
+
+// DiffFiles 对比两组渲染文件，返回有变化的文件（按路径排序）
+func DiffFiles(from, to []executor.RenderedFile) []FileDiff {
+	fromMap := make(map[string]string, len(from))
+	for _, f := range from {
+		fromMap[f.Path] = stripGeneratedHeader(f.Content)
+	}
+	toMap := make(map[string]string, len(to))
+	for _, f := range to {
+		toMap[f.Path] = stripGeneratedHeader(f.Content)
+	}
+
+	paths := make([]string, 0, len(fromMap)+len(toMap))
+	for p := range fromMap {
+		paths = append(paths, p)
+	}
+	for p := range toMap {
+		if _, ok := fromMap[p]; !ok {
+			paths = append(paths, p)
+		}
+	}
+	sort.Strings(paths)
+
+	diffs := make([]FileDiff, 0)
+	for _, p := range paths {
+		a, inFrom := fromMap[p]
+		b, inTo := toMap[p]
+
+		fromName, toName, change := "a/"+p, "b/"+p, DiffChangeModified
+		switch {
+		case !inFrom:
+			fromName, change = "/dev/null", DiffChangeAdded
+		case !inTo:
+			toName, change = "/dev/null", DiffChangeRemoved
+		}
+
+		diff := textdiff.Unified(fromName, toName, a, b, textdiff.DefaultContext)
+		if diff == "" {
+			continue
+		}
+		diffs = append(diffs, FileDiff{Path: p, Change: change, Diff: diff})
+	}
+	return diffs
+}
+
+// stripGeneratedHeader 去掉渲染时间行
+func stripGeneratedHeader(content string) string {
+	lines := strings.Split(content, "\n")
+	kept := lines[:0]
+	for _, line := range lines {
+		if !strings.HasPrefix(line, generatedHeaderPrefix) {
+			kept = append(kept, line)
+		}
+	}
+	return strings.Join(kept, "\n")
+}
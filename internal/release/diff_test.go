@@ -0,0 +1,117 @@
+package release
+
+import (
+	"strings"
+	"testing"
+
+	"go_cmdb/agent/executor"
+	"go_cmdb/internal/configgen"
+	"go_cmdb/internal/model"
+)
+
+func TestDiffWebsites(t *testing.T) {
+	from := []configgen.WebsiteConfig{
+		{
+			WebsiteID: 1,
+			Status:    "active",
+			Domains:   []configgen.DomainConfig{{Domain: "a.example.com", IsPrimary: true}, {Domain: "old.example.com"}},
+			Origin: configgen.OriginConfig{Mode: "group", UpstreamName: "upstream_originset_1", Protocol: "http", Addresses: []configgen.AddressConfig{
+				{Role: "primary", Protocol: "http", Address: "10.0.0.1:80", Weight: 10, Enabled: true},
+				{Role: "backup", Protocol: "http", Address: "10.0.0.2:80", Weight: 10, Enabled: true},
+			}},
+		},
+		{WebsiteID: 2, Status: "active", Domains: []configgen.DomainConfig{{Domain: "b.example.com", IsPrimary: true}}},
+		{WebsiteID: 3, Status: "active", Domains: []configgen.DomainConfig{{Domain: "c.example.com", IsPrimary: true}}},
+	}
+	to := []configgen.WebsiteConfig{
+		{
+			WebsiteID: 1,
+			Status:    "active",
+			Domains:   []configgen.DomainConfig{{Domain: "a.example.com", IsPrimary: true}, {Domain: "new.example.com"}},
+			Origin: configgen.OriginConfig{Mode: "group", UpstreamName: "upstream_originset_1", Protocol: "http", Addresses: []configgen.AddressConfig{
+				{Role: "primary", Protocol: "http", Address: "10.0.0.1:80", Weight: 20, Enabled: true},
+				{Role: "primary", Protocol: "http", Address: "10.0.0.3:80", Weight: 10, Enabled: true},
+			}},
+			HTTPS: configgen.HTTPSConfig{Enabled: true, Certificate: &configgen.CertificateConfig{CertificateID: 7}},
+		},
+		{WebsiteID: 3, Status: "active", Domains: []configgen.DomainConfig{{Domain: "c.example.com", IsPrimary: true}}},
+		{WebsiteID: 4, Status: "active", Domains: []configgen.DomainConfig{{Domain: "d.example.com", IsPrimary: true}}},
+	}
+
+	diffs := DiffWebsites(from, to)
+	if len(diffs) != 3 {
+		t.Fatalf("expected 3 website diffs, got %+v", diffs)
+	}
+
+	d := diffs[0]
+	if d.WebsiteID != 1 || d.Change != DiffChangeModified || d.PrimaryDomain != "a.example.com" {
+		t.Errorf("unexpected website 1 diff: %+v", d)
+	}
+	if strings.Join(d.DomainsAdded, ",") != "new.example.com" || strings.Join(d.DomainsRemoved, ",") != "old.example.com" {
+		t.Errorf("unexpected domain diff: added=%v removed=%v", d.DomainsAdded, d.DomainsRemoved)
+	}
+	if len(d.OriginAddressesAdded) != 1 || d.OriginAddressesAdded[0].Address != "10.0.0.3:80" ||
+		len(d.OriginAddressesRemoved) != 1 || d.OriginAddressesRemoved[0].Address != "10.0.0.2:80" ||
+		len(d.OriginAddressesChanged) != 1 || d.OriginAddressesChanged[0].To.Weight != 20 {
+		t.Errorf("unexpected origin diff: %+v", d)
+	}
+	fields := make(map[string]FieldChange)
+	for _, f := range d.Fields {
+		fields[f.Field] = f
+	}
+	if f := fields["https.enabled"]; f.From != "false" || f.To != "true" {
+		t.Errorf("expected https toggle, got %+v", d.Fields)
+	}
+	if f := fields["https.certificateId"]; f.To != "7" {
+		t.Errorf("expected certificate change, got %+v", d.Fields)
+	}
+	if len(fields) != 2 {
+		t.Errorf("unexpected field changes: %+v", d.Fields)
+	}
+
+	if diffs[1].WebsiteID != 2 || diffs[1].Change != DiffChangeRemoved || strings.Join(diffs[1].DomainsRemoved, ",") != "b.example.com" {
+		t.Errorf("unexpected website 2 diff: %+v", diffs[1])
+	}
+	if diffs[2].WebsiteID != 4 || diffs[2].Change != DiffChangeAdded || diffs[2].PrimaryDomain != "d.example.com" {
+		t.Errorf("unexpected website 4 diff: %+v", diffs[2])
+	}
+}
+
+func TestDiffFiles(t *testing.T) {
+	from := []executor.RenderedFile{
+		{Path: "servers/server_site_1.conf", Content: "# Generated by CMDB Agent at 2026-01-01T00:00:00Z\nserver {\n    listen 80;\n}\n"},
+		{Path: "servers/server_site_2.conf", Content: "# Generated by CMDB Agent at 2026-01-01T00:00:00Z\nserver {}\n"},
+	}
+	to := []executor.RenderedFile{
+		{Path: "servers/server_site_1.conf", Content: "# Generated by CMDB Agent at 2026-01-02T00:00:00Z\nserver {\n    listen 80;\n}\n"},
+		{Path: "servers/server_site_3.conf", Content: "server {}\n"},
+	}
+
+	diffs := DiffFiles(from, to)
+	if len(diffs) != 2 {
+		t.Fatalf("expected 2 file diffs (generated header ignored), got %+v", diffs)
+	}
+	if diffs[0].Path != "servers/server_site_2.conf" || diffs[0].Change != DiffChangeRemoved || !strings.HasPrefix(diffs[0].Diff, "--- a/servers/server_site_2.conf\n+++ /dev/null\n") {
+		t.Errorf("unexpected removed file diff: %+v", diffs[0])
+	}
+	if diffs[1].Path != "servers/server_site_3.conf" || diffs[1].Change != DiffChangeAdded {
+		t.Errorf("unexpected added file diff: %+v", diffs[1])
+	}
+}
+
+func TestScopeSnapshots(t *testing.T) {
+	cdn := &model.ReleaseTask{Target: model.ReleaseTaskTargetCDN}
+	website := &model.ReleaseTask{TargetType: "website", TargetID: 2}
+	all := []configgen.WebsiteConfig{{WebsiteID: 1}, {WebsiteID: 2}, {WebsiteID: 3}}
+
+	// Deleted website: the release snapshot is empty, the cdn side is scoped to the target website
+	from, to := scopeSnapshots(cdn, website, all, []configgen.WebsiteConfig{})
+	if len(from) != 1 || from[0].WebsiteID != 2 || len(to) != 0 {
+		t.Errorf("unexpected scoped snapshots: from=%+v to=%+v", from, to)
+	}
+
+	from, to = scopeSnapshots(cdn, cdn, all, all)
+	if len(from) != 3 || len(to) != 3 {
+		t.Errorf("cdn releases must not be scoped: from=%d to=%d", len(from), len(to))
+	}
+}
@@ -1,9 +1,27 @@
 package release
 
+import (
+	"time"
+
+	"go_cmdb/internal/model"
+)
+
 // CreateReleaseRequest 创建发布任务请求
 type CreateReleaseRequest struct {
-	Target string  `json:"target" binding:"required"` // 目标类型（cdn）
-	Reason *string `json:"reason"`                    // 原因（可选）
+	Target string  `json:"target" binding:"required,oneof=cdn"` // 目标类型（cdn）
+	Reason *string `json:"reason"`                              // 原因（可选）
+
+	RolloutStrategy *model.RolloutStrategy `json:"rolloutStrategy"` // 发布批次策略（可选，默认canary 1个节点）
+
+	// 失败预算（可选，默认任一节点失败即超出预算）
+	MaxFailedNodes   int   `json:"maxFailedNodes" binding:"min=0"`           // 整个发布允许失败的节点数
+	MaxFailedPercent int   `json:"maxFailedPercent" binding:"min=0,max=100"` // 单批允许失败的百分比
+	AutoRollback     *bool `json:"autoRollback"`                             // 超出预算时自动回滚，默认true
+
+	HealthGates *model.ReleaseHealthGates `json:"healthGates"` // 批次之间的健康检查门禁（可选）
+	ScheduledAt *time.Time                `json:"scheduledAt"` // 计划执行时间（可选，RFC3339）
+
+	CreatedBy string `json:"-"` // 创建人（由handler从登录用户填充）
 }
 
 // BatchAllocation 批次分配
@@ -18,4 +36,9 @@ type CreateReleaseResponse struct {
 	Version    int64              `json:"version"`    // 版本号
 	TotalNodes int                `json:"totalNodes"` // 总节点数
 	Batches    []BatchAllocation  `json:"batches"`    // 批次分配
+
+	RolloutStrategy model.RolloutStrategy `json:"rolloutStrategy"` // 发布批次策略
+
+	Status         string `json:"status"`                   // pending 或 awaiting_approval
+	ApprovalReason string `json:"approvalReason,omitempty"` // 命中的审批策略
 }
@@ -0,0 +1,143 @@
+package release
+
+import (
+	"log"
+
+	"go_cmdb/internal/model"
+	"go_cmdb/internal/ws"
+
+	"gorm.io/gorm"
+)
+
+// 发布事件类型（ws_events topic=releases，Socket.IO 事件 releases:update）
+const (
+	ReleaseEventCreated       = "created"
+	ReleaseEventStarted       = "started"
+	ReleaseEventWaitingWindow = "waiting_window"
+	ReleaseEventPaused        = "paused"
+	ReleaseEventResumed       = "resumed"
+	ReleaseEventApproved      = "approved"
+	ReleaseEventRejected      = "rejected"
+	ReleaseEventCancelled     = "cancelled"
+	ReleaseEventFinished      = "finished" // success/failed
+	ReleaseEventNode          = "node"     // release_task_nodes 状态变化
+)
+
+// ReleaseFinishedPartial 发布按成功结束但有失败节点（在失败预算内）时 finished 事件的 reason
+const ReleaseFinishedPartial = "partial_success"
+
+// ReleaseEvent 发布任务事件数据
+type ReleaseEvent struct {
+	ReleaseID    int64  `json:"releaseId"`
+	Version      int64  `json:"version"`
+	Status       string `json:"status"`
+	TotalNodes   int    `json:"totalNodes"`
+	SuccessNodes int    `json:"successNodes"`
+	FailedNodes  int    `json:"failedNodes"`
+	Operator     string `json:"operator,omitempty"`
+	Reason       string `json:"reason,omitempty"`
+}
+
+// ReleaseNodeEvent 发布节点事件数据
+type ReleaseNodeEvent struct {
+	ReleaseID int64  `json:"releaseId"`
+	NodeID    int    `json:"nodeId"`
+	Batch     int    `json:"batch"`
+	Status    string `json:"status"`
+	ErrorMsg  string `json:"errorMsg,omitempty"`
+}
+
+// publishEvent 事件推送入口（测试中替换）
+var publishEvent = ws.PublishReleaseEvent
+
+// publishReleaseEvent 读取发布任务最新状态并推送事件，推送失败不影响发布流程
+func publishReleaseEvent(db *gorm.DB, eventType string, releaseID int64, operator, reason string) {
+	var task model.ReleaseTask
+	if err := db.Select("id", "version", "status", "total_nodes", "success_nodes", "failed_nodes").
+		First(&task, releaseID).Error; err != nil {
+		log.Printf("[Release] Failed to load release task %d for %s event: %v", releaseID, eventType, err)
+		return
+	}
+	if eventType == ReleaseEventFinished && reason == "" &&
+		task.Status == model.ReleaseTaskStatusSuccess && task.FailedNodes > 0 {
+		reason = ReleaseFinishedPartial
+	}
+
+	if err := publishEvent(eventType, &ReleaseEvent{
+		ReleaseID:    task.ID,
+		Version:      task.Version,
+		Status:       string(task.Status),
+		TotalNodes:   task.TotalNodes,
+		SuccessNodes: task.SuccessNodes,
+		FailedNodes:  task.FailedNodes,
+		Operator:     operator,
+		Reason:       reason,
+	}); err != nil {
+		log.Printf("[Release] Failed to publish %s event of release task %d: %v", eventType, releaseID, err)
+	}
+}
+
+// PublishReleaseCreated 推送发布创建事件（release 包外创建发布时调用）
+func PublishReleaseCreated(db *gorm.DB, releaseID int64, operator string) {
+	publishReleaseEvent(db, ReleaseEventCreated, releaseID, operator, "")
+}
+
+// PublishReleaseFinished 推送发布结束事件（release 包外结算网站发布时调用）
+func PublishReleaseFinished(db *gorm.DB, releaseID int64) {
+	publishReleaseEvent(db, ReleaseEventFinished, releaseID, "", "")
+}
+
+// PublishReleaseWaitingWindow 推送等待计划时间或维护窗口事件（release 包外调用）
+func PublishReleaseWaitingWindow(db *gorm.DB, releaseID int64, reason string) {
+	publishReleaseEvent(db, ReleaseEventWaitingWindow, releaseID, "", reason)
+}
+
+// PublishReleaseNode 推送节点状态变化（release 包外调用，如网站发布的 agent 任务结果）
+func PublishReleaseNode(releaseID int64, nodeID int, status model.ReleaseTaskNodeStatus, errorMsg string) {
+	publishNodeEvent(&model.ReleaseTaskNode{ReleaseTaskID: releaseID, NodeID: nodeID}, status, errorMsg)
+}
+
+// publishNodeEvent 推送节点状态变化
+func publishNodeEvent(node *model.ReleaseTaskNode, status model.ReleaseTaskNodeStatus, errorMsg string) {
+	if err := publishEvent(ReleaseEventNode, &ReleaseNodeEvent{
+		ReleaseID: node.ReleaseTaskID,
+		NodeID:    node.NodeID,
+		Batch:     node.Batch,
+		Status:    string(status),
+		ErrorMsg:  errorMsg,
+	}); err != nil {
+		log.Printf("[Release] Failed to publish node event of release task %d node %d: %v", node.ReleaseTaskID, node.NodeID, err)
+	}
+}
+
+// skipPendingNodes 将发布中 batch > afterBatch 的 pending 节点标记为 skipped，返回被跳过的节点（提交后推送事件）
+func skipPendingNodes(db *gorm.DB, releaseID int64, afterBatch int) ([]model.ReleaseTaskNode, error) {
+	var nodes []model.ReleaseTaskNode
+	if err := db.Where("release_task_id = ? AND batch > ? AND status = ?",
+		releaseID, afterBatch, model.ReleaseTaskNodeStatusPending).
+		Order("batch ASC, node_id ASC").
+		Find(&nodes).Error; err != nil {
+		return nil, err
+	}
+	if len(nodes) == 0 {
+		return nil, nil
+	}
+
+	ids := make([]int64, 0, len(nodes))
+	for _, n := range nodes {
+		ids = append(ids, n.ID)
+	}
+	if err := db.Model(&model.ReleaseTaskNode{}).
+		Where("id IN ? AND status = ?", ids, model.ReleaseTaskNodeStatusPending).
+		Update("status", model.ReleaseTaskNodeStatusSkipped).Error; err != nil {
+		return nil, err
+	}
+	return nodes, nil
+}
+
+// publishSkippedNodes 推送被跳过节点的事件
+func publishSkippedNodes(nodes []model.ReleaseTaskNode) {
+	for i := range nodes {
+		publishNodeEvent(&nodes[i], model.ReleaseTaskNodeStatusSkipped, "")
+	}
+}
@@ -0,0 +1,75 @@
+package release
+
+import (
+	"testing"
+
+	"go_cmdb/internal/model"
+
+	"github.com/glebarez/sqlite"
+	"gorm.io/gorm"
+)
+
+func TestPublishNodeEvent(t *testing.T) {
+	var gotType string
+	var gotPayload interface{}
+	original := publishEvent
+	defer func() { publishEvent = original }()
+	publishEvent = func(eventType string, payload interface{}) error {
+		gotType, gotPayload = eventType, payload
+		return nil
+	}
+
+	node := &model.ReleaseTaskNode{ReleaseTaskID: 7, NodeID: 3, Batch: 2, Status: model.ReleaseTaskNodeStatusRunning}
+	publishNodeEvent(node, model.ReleaseTaskNodeStatusFailed, "nginx test failed")
+
+	event, ok := gotPayload.(*ReleaseNodeEvent)
+	if gotType != ReleaseEventNode || !ok {
+		t.Fatalf("unexpected event %q %T", gotType, gotPayload)
+	}
+	want := ReleaseNodeEvent{ReleaseID: 7, NodeID: 3, Batch: 2, Status: "failed", ErrorMsg: "nginx test failed"}
+	if *event != want {
+		t.Errorf("got %+v, want %+v", *event, want)
+	}
+}
+
+func TestHandleSuccessRecordsPartialFailure(t *testing.T) {
+	var events []*ReleaseEvent
+	original := publishEvent
+	defer func() { publishEvent = original }()
+	publishEvent = func(eventType string, payload interface{}) error {
+		if event, ok := payload.(*ReleaseEvent); ok && eventType == ReleaseEventFinished {
+			events = append(events, event)
+		}
+		return nil
+	}
+
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, stmt := range []string{
+		`CREATE TABLE release_tasks (id INTEGER PRIMARY KEY, version INTEGER, status TEXT, total_nodes INTEGER NOT NULL DEFAULT 0,
+			success_nodes INTEGER NOT NULL DEFAULT 0, failed_nodes INTEGER NOT NULL DEFAULT 0, last_error TEXT, updated_at DATETIME)`,
+		`CREATE TABLE release_task_nodes (id INTEGER PRIMARY KEY, release_task_id INTEGER, node_id INTEGER, batch INTEGER, status TEXT)`,
+		// 1: one of three nodes failed within the budget; 2: all nodes succeeded
+		`INSERT INTO release_tasks (id, version, status, total_nodes) VALUES (1, 7, 'running', 3), (2, 8, 'running', 1)`,
+		`INSERT INTO release_task_nodes VALUES (1, 1, 1, 1, 'success'), (2, 1, 2, 1, 'failed'), (3, 1, 3, 2, 'success'), (4, 2, 1, 1, 'success')`,
+	} {
+		if err := db.Exec(stmt).Error; err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	for _, id := range []int64{1, 2} {
+		(&Runner{db: db, task: &model.ReleaseTask{ID: id}}).handleSuccess()
+	}
+
+	var task model.ReleaseTask
+	db.First(&task, 1)
+	if task.Status != model.ReleaseTaskStatusSuccess || task.FailedNodes != 1 || task.LastError == nil {
+		t.Errorf("release 1 = status %s, failedNodes %d, lastError %v; want success with the failed node recorded", task.Status, task.FailedNodes, task.LastError)
+	}
+	if len(events) != 2 || events[0].Reason != ReleaseFinishedPartial || events[1].Reason != "" {
+		t.Errorf("unexpected finished events: %+v", events)
+	}
+}
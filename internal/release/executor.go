@@ -3,6 +3,7 @@ package release
 import (
 	"context"
 	"log"
+	"sync"
 	"time"
 
 	"go_cmdb/internal/agentclient"
@@ -15,26 +16,42 @@ type Executor struct {
 	db          *gorm.DB
 	agentClient *agentclient.Client
 	interval    time.Duration
+	maxWorkers  int
+	locks       *targetLocks
+
+	mu     sync.Mutex
+	active int // 正在执行的发布数
 }
 
 // NewExecutor 创建发布执行器
-func NewExecutor(db *gorm.DB, agentClient *agentclient.Client, interval time.Duration) *Executor {
+// maxWorkers: 同时执行的发布数上限（<=0 时为1）
+func NewExecutor(db *gorm.DB, agentClient *agentclient.Client, interval time.Duration, maxWorkers int) *Executor {
+	if maxWorkers <= 0 {
+		maxWorkers = 1
+	}
 	return &Executor{
 		db:          db,
 		agentClient: agentClient,
 		interval:    interval,
+		maxWorkers:  maxWorkers,
+		locks:       newTargetLocks(),
 	}
 }
 
-// RunOnce 执行一次扫描
+// RunOnce 执行一次扫描，按ID升序为可执行的发布分配worker并异步执行
+// 互不相关的发布并发执行；同一目标上较早的发布（包括已暂停的）结束前，较晚的发布不会开始
 func (e *Executor) RunOnce() error {
 	log.Println("[Executor] Running once...")
 
-	// 查询可执行的release_task（status=pending或running）
+	// 查询可执行的release_task（target=cdn，status=pending/waiting_window/running）
+	// website 发布任务（target为空）由 agent_tasks 链路执行，不在此处理
+	// paused 的发布不执行，但仍占用其目标，保证目标内的发布顺序
 	var tasks []model.ReleaseTask
-	if err := e.db.Where("status IN ?", []string{
+	if err := e.db.Where("target = ? AND status IN ?", model.ReleaseTaskTargetCDN, []string{
 		string(model.ReleaseTaskStatusPending),
+		string(model.ReleaseTaskStatusWaitingWindow),
 		string(model.ReleaseTaskStatusRunning),
+		string(model.ReleaseTaskStatusPaused),
 	}).Order("id ASC").Find(&tasks).Error; err != nil {
 		log.Printf("[Executor] Failed to query tasks: %v", err)
 		return err
@@ -45,47 +62,154 @@ func (e *Executor) RunOnce() error {
 		return nil
 	}
 
-	log.Printf("[Executor] Found %d tasks to execute", len(tasks))
+	log.Printf("[Executor] Found %d tasks", len(tasks))
+
+	// 本轮已被较早发布占用的目标
+	blocked := make(map[string]bool)
+	now := time.Now()
+
+	for i := range tasks {
+		task := &tasks[i]
+
+		keys, err := releaseLockKeys(e.db, task)
+		if err != nil {
+			log.Printf("[Executor] Failed to resolve targets of task %d: %v", task.ID, err)
+			continue
+		}
+
+		wait := task.Status == model.ReleaseTaskStatusPaused
+		for _, key := range keys {
+			if blocked[key] {
+				wait = true
+			}
+			blocked[key] = true
+		}
+		if wait {
+			continue
+		}
 
-	// P0简化：只处理第一个任务（避免并发发布）
-	task := &tasks[0]
-	log.Printf("[Executor] Processing task %d (status=%s)", task.ID, task.Status)
+		// 尚未开始的发布须等计划时间和维护窗口
+		if task.Status != model.ReleaseTaskStatusRunning && !e.waitForWindow(task, now) {
+			continue
+		}
+
+		// 正在执行（本进程持有锁）或与执行中的发布目标冲突
+		if !e.locks.tryAcquire(task.ID, keys) {
+			continue
+		}
+
+		if !e.reserveWorker() {
+			e.locks.release(task.ID, keys)
+			log.Printf("[Executor] All %d workers busy, task %d waits for next round", e.maxWorkers, task.ID)
+			break
+		}
+
+		if !e.claim(task) {
+			e.releaseWorker()
+			e.locks.release(task.ID, keys)
+			continue
+		}
+
+		go e.execute(task, keys)
+	}
+
+	return nil
+}
+
+// waitForWindow 检查计划时间和维护窗口，未就绪时将pending标记为waiting_window
+// 返回是否可以开始
+func (e *Executor) waitForWindow(task *model.ReleaseTask, now time.Time) bool {
+	ready, reason, err := releaseReady(e.db, task, now)
+	if err != nil {
+		log.Printf("[Executor] Failed to check window of task %d: %v", task.ID, err)
+		return false
+	}
+	if ready {
+		return true
+	}
 
-	// 状态抢占：尝试将pending状态的任务标记为running
 	if task.Status == model.ReleaseTaskStatusPending {
 		result := e.db.Model(&model.ReleaseTask{}).
 			Where("id = ? AND status = ?", task.ID, model.ReleaseTaskStatusPending).
-			Update("status", model.ReleaseTaskStatusRunning)
-
+			Update("status", model.ReleaseTaskStatusWaitingWindow)
 		if result.Error != nil {
-			log.Printf("[Executor] Failed to update task status: %v", result.Error)
-			return result.Error
+			log.Printf("[Executor] Failed to update task %d status: %v", task.ID, result.Error)
+		} else if result.RowsAffected > 0 {
+			publishReleaseEvent(e.db, ReleaseEventWaitingWindow, task.ID, "", reason)
 		}
+		log.Printf("[Executor] Task %d is waiting: %s", task.ID, reason)
+	}
+	return false
+}
 
-		if result.RowsAffected == 0 {
-			// 已被其他进程抢占，跳过
-			log.Printf("[Executor] Task %d already taken by another process", task.ID)
-			return nil
-		}
+// claim 状态抢占：将pending/waiting_window状态的任务标记为running
+func (e *Executor) claim(task *model.ReleaseTask) bool {
+	if task.Status == model.ReleaseTaskStatusRunning {
+		return true
+	}
+
+	result := e.db.Model(&model.ReleaseTask{}).
+		Where("id = ? AND status IN ?", task.ID, []model.ReleaseTaskStatus{
+			model.ReleaseTaskStatusPending,
+			model.ReleaseTaskStatusWaitingWindow,
+		}).
+		Update("status", model.ReleaseTaskStatusRunning)
 
-		log.Printf("[Executor] Task %d status updated to running", task.ID)
-		task.Status = model.ReleaseTaskStatusRunning
+	if result.Error != nil {
+		log.Printf("[Executor] Failed to update task status: %v", result.Error)
+		return false
 	}
 
-	// 创建Runner并执行
+	if result.RowsAffected == 0 {
+		// 已被其他进程抢占，跳过
+		log.Printf("[Executor] Task %d already taken by another process", task.ID)
+		return false
+	}
+
+	log.Printf("[Executor] Task %d status updated to running", task.ID)
+	task.Status = model.ReleaseTaskStatusRunning
+	publishReleaseEvent(e.db, ReleaseEventStarted, task.ID, "", "")
+	return true
+}
+
+// execute 执行发布任务，结束后释放worker和目标锁
+func (e *Executor) execute(task *model.ReleaseTask, keys []string) {
+	defer e.releaseWorker()
+	defer e.locks.release(task.ID, keys)
+
+	log.Printf("[Executor] Processing task %d (status=%s, targets=%d)", task.ID, task.Status, len(keys))
+
 	runner := NewRunner(e.db, e.agentClient, task)
 	if err := runner.Run(); err != nil {
 		log.Printf("[Executor] Task %d execution failed: %v", task.ID, err)
-		return err
+		return
 	}
 
 	log.Printf("[Executor] Task %d execution completed", task.ID)
-	return nil
+}
+
+// reserveWorker 占用一个worker，已满时返回false
+func (e *Executor) reserveWorker() bool {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	if e.active >= e.maxWorkers {
+		return false
+	}
+	e.active++
+	return true
+}
+
+// releaseWorker 释放worker
+func (e *Executor) releaseWorker() {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.active--
 }
 
 // RunLoop 循环执行
 func (e *Executor) RunLoop(ctx context.Context) {
-	log.Printf("[Executor] Starting executor loop (interval=%v)", e.interval)
+	log.Printf("[Executor] Starting executor loop (interval=%v, maxWorkers=%d)", e.interval, e.maxWorkers)
 
 	ticker := time.NewTicker(e.interval)
 	defer ticker.Stop()
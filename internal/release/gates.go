@@ -0,0 +1,245 @@
+package release
+
+import (
+	"crypto/tls"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"strconv"
+	"time"
+
+	"go_cmdb/internal/configgen"
+	"go_cmdb/internal/httpx"
+	"go_cmdb/internal/model"
+
+	"gorm.io/gorm"
+)
+
+const (
+	maxGateWaitSeconds  = 3600 // 门禁最长等待时间
+	defaultProbeTimeout = 5    // HTTP 探测默认超时（秒）
+	maxProbeTimeout     = 60   // HTTP 探测最长超时（秒）
+)
+
+// gateWaitStep 门禁等待期间检查发布是否被暂停/取消的间隔
+var gateWaitStep = 5 * time.Second
+
+// NormalizeHealthGates 校验健康检查门禁并填充默认值（nil 表示不检查）
+func NormalizeHealthGates(gates *model.ReleaseHealthGates) (*model.ReleaseHealthGates, error) {
+	if gates == nil {
+		return nil, nil
+	}
+
+	g := *gates
+	if g.WaitSeconds < 0 || g.WaitSeconds > maxGateWaitSeconds {
+		return nil, httpx.ErrParamInvalid(fmt.Sprintf("waitSeconds must be within 0-%d", maxGateWaitSeconds))
+	}
+
+	if g.HTTPProbe != nil {
+		probe := *g.HTTPProbe
+		if probe.Scheme == "" {
+			probe.Scheme = "http"
+		}
+		if probe.Scheme != "http" && probe.Scheme != "https" {
+			return nil, httpx.ErrParamInvalid("httpProbe.scheme must be http or https")
+		}
+		if probe.Path == "" {
+			probe.Path = "/"
+		}
+		if probe.Path[0] != '/' {
+			return nil, httpx.ErrParamInvalid("httpProbe.path must start with /")
+		}
+		if probe.ExpectedStatus == 0 {
+			probe.ExpectedStatus = http.StatusOK
+		}
+		if probe.ExpectedStatus < 100 || probe.ExpectedStatus > 599 {
+			return nil, httpx.ErrParamInvalid("httpProbe.expectedStatus must be a valid HTTP status code")
+		}
+		if probe.TimeoutSec == 0 {
+			probe.TimeoutSec = defaultProbeTimeout
+		}
+		if probe.TimeoutSec < 0 || probe.TimeoutSec > maxProbeTimeout {
+			return nil, httpx.ErrParamInvalid(fmt.Sprintf("httpProbe.timeoutSec must be within 1-%d", maxProbeTimeout))
+		}
+		if probe.Port < 0 || probe.Port > 65535 {
+			return nil, httpx.ErrParamInvalid("httpProbe.port must be within 1-65535")
+		}
+		g.HTTPProbe = &probe
+	}
+
+	return &g, nil
+}
+
+// checkHealthGates 批次完成后执行健康检查门禁，返回第一个未通过的检查
+// 等待期间发布被暂停或取消时返回 errReleaseHalted
+func (r *Runner) checkHealthGates(batch int) error {
+	gates := r.task.HealthGates
+	if gates == nil {
+		return nil
+	}
+
+	if gates.WaitSeconds > 0 {
+		log.Printf("[Runner] Batch %d health gate: waiting %ds", batch, gates.WaitSeconds)
+		if !r.waitGate(time.Duration(gates.WaitSeconds) * time.Second) {
+			return errReleaseHalted
+		}
+	}
+
+	if !gates.CheckAgentVersion && gates.HTTPProbe == nil {
+		return nil
+	}
+
+	// 仅检查本批次成功的节点（预算内失败的节点不参与）
+	var nodes []model.Node
+	if err := r.db.Table("nodes").
+		Joins("JOIN release_task_nodes ON release_task_nodes.node_id = nodes.id").
+		Where("release_task_nodes.release_task_id = ? AND release_task_nodes.batch = ? AND release_task_nodes.status = ?",
+			r.task.ID, batch, model.ReleaseTaskNodeStatusSuccess).
+		Order("nodes.id ASC").
+		Find(&nodes).Error; err != nil {
+		return fmt.Errorf("failed to get batch nodes: %w", err)
+	}
+
+	for _, node := range nodes {
+		if gates.CheckAgentVersion {
+			state, err := r.agentClient.Ping(node.MainIP, node.AgentPort)
+			if err != nil {
+				return fmt.Errorf("node %d agent state: %w", node.ID, err)
+			}
+			if state.LiveVersion != r.task.Version {
+				return fmt.Errorf("node %d live version is %d, expected %d", node.ID, state.LiveVersion, r.task.Version)
+			}
+		}
+
+		if gates.HTTPProbe != nil {
+			domains, err := nodePrimaryDomains(r.db, node.ID)
+			if err != nil {
+				return err
+			}
+			for _, domain := range domains {
+				if err := probeWebsite(gates.HTTPProbe, node.MainIP, domain); err != nil {
+					return fmt.Errorf("node %d probe %s: %w", node.ID, domain, err)
+				}
+			}
+		}
+	}
+
+	log.Printf("[Runner] Batch %d health gates passed (%d nodes)", batch, len(nodes))
+	return nil
+}
+
+// waitGate 分段等待 d，每段结束后检查发布状态，被暂停或取消时返回 false
+func (r *Runner) waitGate(d time.Duration) bool {
+	deadline := time.Now().Add(d)
+	for {
+		remaining := time.Until(deadline)
+		if remaining <= 0 {
+			return true
+		}
+		if remaining > gateWaitStep {
+			remaining = gateWaitStep
+		}
+		time.Sleep(remaining)
+		// 每段（包括最后一段）结束后都检查，等待结束时不会错过期间的暂停
+		if r.halted() {
+			return false
+		}
+	}
+}
+
+// nodePrimaryDomains 查询节点服务的网站主域名
+func nodePrimaryDomains(db *gorm.DB, nodeID int) ([]string, error) {
+	lineGroupIDs, err := configgen.NodeLineGroupIDs(db, nodeID)
+	if err != nil {
+		return nil, err
+	}
+	if len(lineGroupIDs) == 0 {
+		return nil, nil
+	}
+
+	var domains []string
+	if err := db.Table("website_domains").
+		Joins("JOIN websites ON websites.id = website_domains.website_id").
+		Where("websites.status = ? AND websites.line_group_id IN ?", model.WebsiteStatusActive, lineGroupIDs).
+		Where("website_domains.is_primary = ?", true).
+		Order("website_domains.website_id ASC").
+		Pluck("website_domains.domain", &domains).Error; err != nil {
+		return nil, fmt.Errorf("failed to query primary domains of node %d: %w", nodeID, err)
+	}
+	return domains, nil
+}
+
+// probeWebsite 通过节点IP请求网站（Host/SNI 为网站域名），检查响应状态码
+func probeWebsite(probe *model.HTTPProbeGate, nodeIP, domain string) error {
+	port := "80"
+	if probe.Scheme == "https" {
+		port = "443"
+	}
+	if probe.Port > 0 {
+		port = strconv.Itoa(probe.Port)
+	}
+	target := fmt.Sprintf("%s://%s%s", probe.Scheme, net.JoinHostPort(nodeIP, port), probe.Path)
+	return probeURL(newProbeClient(domain, probe.TimeoutSec), target, domain, probe.ExpectedStatus)
+}
+
+// newProbeClient 创建探测用的 HTTP 客户端
+// 门禁检查的是节点能否正常服务，不校验证书（证书有效性由证书风险扫描负责）
+func newProbeClient(domain string, timeoutSec int) *http.Client {
+	return &http.Client{
+		Timeout: time.Duration(timeoutSec) * time.Second,
+		Transport: &http.Transport{
+			TLSClientConfig: &tls.Config{
+				ServerName:         domain,
+				InsecureSkipVerify: true,
+			},
+			DisableKeepAlives: true,
+		},
+		// 不跟随重定向，直接比较节点返回的状态码
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+}
+
+// probeURL 请求 target（Host 头为 domain），状态码不等于 expectedStatus 时返回错误
+func probeURL(client *http.Client, target, domain string, expectedStatus int) error {
+	req, err := http.NewRequest(http.MethodGet, target, nil)
+	if err != nil {
+		return err
+	}
+	req.Host = domain
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 64*1024))
+
+	if resp.StatusCode != expectedStatus {
+		return fmt.Errorf("status %d, expected %d", resp.StatusCode, expectedStatus)
+	}
+	return nil
+}
+
+// pauseForGate 健康检查未通过，暂停发布并记录原因，由运维决定恢复或取消
+func (r *Runner) pauseForGate(batch int, gateErr error) {
+	msg := fmt.Sprintf("health gate failed after batch %d: %v", batch, gateErr)
+	if len(msg) > 1000 {
+		msg = msg[:1000]
+	}
+	log.Printf("[Runner] Release task %d paused: %s", r.task.ID, msg)
+
+	if err := transitRelease(r.db, r.task.ID, []model.ReleaseTaskStatus{
+		model.ReleaseTaskStatusRunning,
+	}, map[string]interface{}{
+		"status":     model.ReleaseTaskStatusPaused,
+		"last_error": msg,
+	}); err != nil {
+		log.Printf("[Runner] Failed to pause release task %d: %v", r.task.ID, err)
+		return
+	}
+	publishReleaseEvent(r.db, ReleaseEventPaused, r.task.ID, "", msg)
+}
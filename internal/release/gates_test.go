@@ -0,0 +1,170 @@
+package release
+
+import (
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"go_cmdb/internal/model"
+
+	"github.com/glebarez/sqlite"
+	"gorm.io/gorm"
+)
+
+func TestProbeURL(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Host != "www.example.com" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer srv.Close()
+
+	client := newProbeClient("www.example.com", 5)
+	if err := probeURL(client, srv.URL+"/", "www.example.com", http.StatusNoContent); err != nil {
+		t.Errorf("expected probe to pass: %v", err)
+	}
+	if err := probeURL(client, srv.URL+"/", "www.example.com", http.StatusOK); err == nil {
+		t.Error("expected status mismatch error")
+	}
+	if err := probeURL(client, srv.URL+"/", "other.example.com", http.StatusNoContent); err == nil {
+		t.Error("expected probe with wrong host to fail")
+	}
+}
+
+func TestNormalizeHealthGates(t *testing.T) {
+	gates, err := NormalizeHealthGates(&model.ReleaseHealthGates{HTTPProbe: &model.HTTPProbeGate{}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	probe := gates.HTTPProbe
+	if probe.Scheme != "http" || probe.Path != "/" || probe.ExpectedStatus != http.StatusOK || probe.TimeoutSec != defaultProbeTimeout {
+		t.Errorf("unexpected defaults: %+v", probe)
+	}
+
+	invalid := []model.ReleaseHealthGates{
+		{WaitSeconds: -1},
+		{HTTPProbe: &model.HTTPProbeGate{Scheme: "ftp"}},
+		{HTTPProbe: &model.HTTPProbeGate{Path: "healthz"}},
+		{HTTPProbe: &model.HTTPProbeGate{ExpectedStatus: 1000}},
+	}
+	for _, g := range invalid {
+		if _, err := NormalizeHealthGates(&g); err == nil {
+			t.Errorf("expected error for %+v", g)
+		}
+	}
+}
+
+// newGateTestDB creates a release with node 1 (127.0.0.1) succeeded in batch 1,
+// serving website 1 with primary domain www.example.com
+func newGateTestDB(t *testing.T) *gorm.DB {
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// every connection to :memory: is a new database, the wait test updates from another goroutine
+	sqlDB, err := db.DB()
+	if err != nil {
+		t.Fatal(err)
+	}
+	sqlDB.SetMaxOpenConns(1)
+
+	// MySQL enum columns do not migrate on sqlite, create the tables by hand
+	for _, stmt := range []string{
+		`CREATE TABLE release_tasks (id INTEGER PRIMARY KEY, status TEXT)`,
+		`CREATE TABLE release_task_nodes (id INTEGER PRIMARY KEY, release_task_id INTEGER, node_id INTEGER, batch INTEGER, status TEXT)`,
+		`CREATE TABLE nodes (id INTEGER PRIMARY KEY, created_at DATETIME, updated_at DATETIME, name TEXT, main_ip TEXT, agent_port INTEGER,
+			enabled BOOLEAN, status TEXT, last_seen_at DATETIME, last_health_error TEXT, health_fail_count INTEGER NOT NULL DEFAULT 0,
+			agent_applied_version INTEGER, agent_last_success_version INTEGER, agent_live_version INTEGER, agent_last_error TEXT,
+			nginx_version TEXT, nginx_config_ok BOOLEAN, agent_uptime_sec INTEGER, agent_state_at DATETIME)`,
+		`CREATE TABLE node_ips (id INTEGER PRIMARY KEY, node_id INTEGER, enabled BOOLEAN)`,
+		`CREATE TABLE node_group_ips (id INTEGER PRIMARY KEY, node_group_id INTEGER, ip_id INTEGER)`,
+		`CREATE TABLE line_groups (id INTEGER PRIMARY KEY, node_group_id INTEGER)`,
+		`CREATE TABLE websites (id INTEGER PRIMARY KEY, line_group_id INTEGER, status TEXT)`,
+		`CREATE TABLE website_domains (id INTEGER PRIMARY KEY, website_id INTEGER, domain TEXT, is_primary BOOLEAN)`,
+
+		`INSERT INTO release_tasks VALUES (1, 'running')`,
+		`INSERT INTO release_task_nodes VALUES (1, 1, 1, 1, 'success')`,
+		`INSERT INTO nodes (id, name, main_ip, agent_port, enabled, status) VALUES (1, 'node-1', '127.0.0.1', 8080, 1, 'online')`,
+		`INSERT INTO node_ips VALUES (1, 1, 1)`,
+		`INSERT INTO node_group_ips VALUES (1, 1, 1)`,
+		`INSERT INTO line_groups VALUES (1, 1)`,
+		`INSERT INTO websites VALUES (1, 1, 'active')`,
+		`INSERT INTO website_domains VALUES (1, 1, 'www.example.com', 1), (2, 1, 'alias.example.com', 0)`,
+	} {
+		if err := db.Exec(stmt).Error; err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	return db
+}
+
+func TestCheckHealthGatesHTTPProbe(t *testing.T) {
+	var status atomic.Int32
+	status.Store(http.StatusOK)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Host != "www.example.com" || r.URL.Path != "/healthz" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.WriteHeader(int(status.Load()))
+	}))
+	defer srv.Close()
+
+	_, port, err := net.SplitHostPort(srv.Listener.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	probePort, _ := strconv.Atoi(port)
+
+	gates, err := NormalizeHealthGates(&model.ReleaseHealthGates{
+		HTTPProbe: &model.HTTPProbeGate{Path: "/healthz", TimeoutSec: 2, Port: probePort},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := &Runner{db: newGateTestDB(t), task: &model.ReleaseTask{ID: 1, HealthGates: gates}}
+
+	if err := r.checkHealthGates(1); err != nil {
+		t.Fatalf("expected gates to pass: %v", err)
+	}
+
+	status.Store(http.StatusBadGateway)
+	if err := r.checkHealthGates(1); err == nil {
+		t.Fatal("expected gates to fail on 502")
+	}
+
+	// Nodes of other batches are not probed
+	if err := r.checkHealthGates(2); err != nil {
+		t.Fatalf("expected empty batch to pass: %v", err)
+	}
+}
+
+func TestCheckHealthGatesWaitHalted(t *testing.T) {
+	step := gateWaitStep
+	gateWaitStep = 10 * time.Millisecond
+	defer func() { gateWaitStep = step }()
+
+	db := newGateTestDB(t)
+	r := &Runner{db: db, task: &model.ReleaseTask{ID: 1, HealthGates: &model.ReleaseHealthGates{WaitSeconds: 60}}}
+
+	go func() {
+		time.Sleep(50 * time.Millisecond)
+		db.Exec("UPDATE release_tasks SET status = ? WHERE id = 1", model.ReleaseTaskStatusPaused)
+	}()
+
+	start := time.Now()
+	if err := r.checkHealthGates(1); !errors.Is(err, errReleaseHalted) {
+		t.Fatalf("expected errReleaseHalted, got %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Fatalf("wait was not interrupted (%s)", elapsed)
+	}
+}
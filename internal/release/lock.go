@@ -0,0 +1,85 @@
+package release
+
+import (
+	"fmt"
+	"sync"
+
+	"go_cmdb/internal/model"
+
+	"gorm.io/gorm"
+)
+
+// targetLocks 发布目标锁（进程内）
+// 一个发布需要同时持有其全部目标 key 才能执行，保证同一目标上的发布不会交错
+type targetLocks struct {
+	mu   sync.Mutex
+	held map[string]int64 // key -> 持有锁的发布ID
+}
+
+// newTargetLocks 创建发布目标锁
+func newTargetLocks() *targetLocks {
+	return &targetLocks{held: make(map[string]int64)}
+}
+
+// tryAcquire 原子获取全部 key，任一 key 已被持有（包括被该发布自身持有，即正在执行）时不获取任何 key
+func (l *targetLocks) tryAcquire(releaseID int64, keys []string) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	for _, key := range keys {
+		if _, ok := l.held[key]; ok {
+			return false
+		}
+	}
+	for _, key := range keys {
+		l.held[key] = releaseID
+	}
+	return true
+}
+
+// release 释放发布持有的 key
+func (l *targetLocks) release(releaseID int64, keys []string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	for _, key := range keys {
+		if l.held[key] == releaseID {
+			delete(l.held, key)
+		}
+	}
+}
+
+// releaseLockKeys 计算发布的目标 key：cdn 发布下发节点全量配置，按涉及的节点加锁
+// 网站发布走 agent_tasks 链路，按网站排队（见 service.releaseBlocker）
+func releaseLockKeys(db *gorm.DB, task *model.ReleaseTask) ([]string, error) {
+	nodeIDs, err := releaseNodeIDs(db, task)
+	if err != nil {
+		return nil, err
+	}
+
+	keys := make([]string, 0, len(nodeIDs))
+	for _, nodeID := range nodeIDs {
+		keys = append(keys, fmt.Sprintf("node:%d", nodeID))
+	}
+	return keys, nil
+}
+
+// releaseNodeIDs 发布涉及的节点：已分配批次时取 release_task_nodes，否则取当前在线节点
+func releaseNodeIDs(db *gorm.DB, task *model.ReleaseTask) ([]int, error) {
+	var ids []int
+	if err := db.Model(&model.ReleaseTaskNode{}).
+		Where("release_task_id = ?", task.ID).
+		Order("node_id ASC").
+		Pluck("node_id", &ids).Error; err != nil {
+		return nil, fmt.Errorf("failed to query release nodes: %w", err)
+	}
+	if len(ids) > 0 {
+		return ids, nil
+	}
+
+	nodes, err := SelectOnlineNodes(db)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query online nodes: %w", err)
+	}
+	return nodeIDs(nodes), nil
+}
@@ -0,0 +1,31 @@
+package release
+
+import "testing"
+
+func TestTargetLocks(t *testing.T) {
+	locks := newTargetLocks()
+
+	if !locks.tryAcquire(1, []string{"node:1", "node:2"}) {
+		t.Fatal("expected release 1 to acquire its nodes")
+	}
+	if locks.tryAcquire(2, []string{"node:2", "node:3"}) {
+		t.Fatal("release 2 must not acquire node:2 held by release 1")
+	}
+	if !locks.tryAcquire(3, []string{"node:3"}) {
+		t.Fatal("a failed acquire must not hold any key")
+	}
+	if locks.tryAcquire(1, []string{"node:1"}) {
+		t.Fatal("a running release must not be acquired twice")
+	}
+
+	locks.release(1, []string{"node:1", "node:2"})
+	if !locks.tryAcquire(2, []string{"node:2"}) {
+		t.Fatal("expected node:2 to be free after release 1 finished")
+	}
+
+	// Releasing keys held by another release is a no-op
+	locks.release(1, []string{"node:3"})
+	if locks.tryAcquire(4, []string{"node:3"}) {
+		t.Fatal("node:3 must still be held by release 3")
+	}
+}
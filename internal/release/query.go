@@ -9,7 +9,7 @@ import (
 
 // ListReleasesRequest 列表查询请求
 type ListReleasesRequest struct {
-	Status   string `form:"status"`   // pending/running/success/failed/paused
+	Status   string `form:"status"`   // awaiting_approval/pending/waiting_window/running/success/failed/paused/cancelled/rejected
 	Page     int    `form:"page"`     // 页码，默认1
 	PageSize int    `form:"pageSize"` // 每页数量，默认20，最大100
 }
@@ -28,6 +28,11 @@ type ReleaseListItem struct {
 	CurrentBatch  int       `json:"currentBatch"`
 	CreatedAt     time.Time `json:"createdAt"`
 	UpdatedAt     time.Time `json:"updatedAt"`
+
+	ScheduledAt *time.Time `json:"scheduledAt"`
+
+	CreatedBy      *string `json:"createdBy"`
+	ApprovalReason *string `json:"approvalReason"`
 }
 
 // ListReleasesResponse 列表查询响应
@@ -108,6 +113,11 @@ func (s *Service) ListReleases(req *ListReleasesRequest) (*ListReleasesResponse,
 			CurrentBatch:  currentBatchValue,
 			CreatedAt:     task.CreatedAt,
 			UpdatedAt:     task.UpdatedAt,
+
+			ScheduledAt: task.ScheduledAt,
+
+			CreatedBy:      task.CreatedBy,
+			ApprovalReason: task.ApprovalReason,
 		})
 	}
 
@@ -149,6 +159,24 @@ type ReleaseDetail struct {
 	CurrentBatch  int       `json:"currentBatch"`
 	CreatedAt     time.Time `json:"createdAt"`
 	UpdatedAt     time.Time `json:"updatedAt"`
+
+	RolloutStrategy *model.RolloutStrategy `json:"rolloutStrategy"`
+
+	// 失败预算与回滚关联
+	MaxFailedNodes    int    `json:"maxFailedNodes"`
+	MaxFailedPercent  int    `json:"maxFailedPercent"`
+	AutoRollback      bool   `json:"autoRollback"`
+	RollbackOfID      *int64 `json:"rollbackOfId"`      // 本发布为回滚发布时，被回滚的原发布ID
+	RollbackReleaseID *int64 `json:"rollbackReleaseId"` // 本发布被回滚时，回滚发布ID
+
+	HealthGates *model.ReleaseHealthGates `json:"healthGates"`
+	LastError   *string                   `json:"lastError"`
+	ScheduledAt *time.Time                `json:"scheduledAt"`
+
+	// 审批：命中的审批策略和审批记录
+	CreatedBy      *string                 `json:"createdBy"`
+	ApprovalReason *string                 `json:"approvalReason"`
+	Approvals      []model.ReleaseApproval `json:"approvals"`
 }
 
 // GetReleaseDetailResponse 详情查询响应
@@ -203,6 +231,22 @@ func (s *Service) GetReleaseDetail(id int64) (*GetReleaseDetailResponse, error)
 		currentBatchValue = int(currentBatch.Int64)
 	}
 
+	// 查询回滚发布
+	var rollbackReleaseID *int64
+	var rollbackTask model.ReleaseTask
+	if err := s.db.Select("id").Where("rollback_of_id = ?", task.ID).Order("id ASC").Limit(1).Find(&rollbackTask).Error; err != nil {
+		return nil, err
+	}
+	if rollbackTask.ID > 0 {
+		rollbackReleaseID = &rollbackTask.ID
+	}
+
+	// 查询审批记录
+	approvals := make([]model.ReleaseApproval, 0)
+	if err := s.db.Where("release_task_id = ?", task.ID).Order("id ASC").Find(&approvals).Error; err != nil {
+		return nil, err
+	}
+
 	// 查询release_task_nodes（JOIN nodes获取nodeName）
 	var nodesWithTask []NodeWithTask
 	err := s.db.Table("release_task_nodes rtn").
@@ -271,6 +315,22 @@ func (s *Service) GetReleaseDetail(id int64) (*GetReleaseDetailResponse, error)
 				CurrentBatch:  currentBatchValue,
 				CreatedAt:     task.CreatedAt,
 				UpdatedAt:     task.UpdatedAt,
+
+				RolloutStrategy: task.RolloutStrategy,
+
+				MaxFailedNodes:    task.MaxFailedNodes,
+				MaxFailedPercent:  task.MaxFailedPercent,
+				AutoRollback:      task.AutoRollback,
+				RollbackOfID:      task.RollbackOfID,
+				RollbackReleaseID: rollbackReleaseID,
+
+				HealthGates: task.HealthGates,
+				LastError:   task.LastError,
+				ScheduledAt: task.ScheduledAt,
+
+				CreatedBy:      task.CreatedBy,
+				ApprovalReason: task.ApprovalReason,
+				Approvals:      approvals,
 			},
 			Batches: batches,
 		},
@@ -0,0 +1,188 @@
+package release
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+
+	"go_cmdb/internal/model"
+
+	"gorm.io/gorm"
+)
+
+// exceedsFailureBudget 判断失败节点是否超出失败预算
+// maxFailedNodes/maxFailedPercent 都为0时不允许任何失败；否则任一已配置的上限被超过即超出预算
+func exceedsFailureBudget(maxFailedNodes, maxFailedPercent, totalFailed, batchFailed, batchSize int) bool {
+	if totalFailed == 0 {
+		return false
+	}
+	if maxFailedNodes <= 0 && maxFailedPercent <= 0 {
+		return true
+	}
+	if maxFailedNodes > 0 && totalFailed > maxFailedNodes {
+		return true
+	}
+	if maxFailedPercent > 0 && batchSize > 0 && batchFailed*100 > maxFailedPercent*batchSize {
+		return true
+	}
+	return false
+}
+
+// previousSuccessVersion 查询早于 version 的最近一次成功发布的版本（不存在时返回0）
+func previousSuccessVersion(db *gorm.DB, version int64) (int64, error) {
+	var prev int64
+	err := db.Model(&model.ReleaseTask{}).
+		Where("target = ? AND type = ? AND status = ? AND version < ?",
+			model.ReleaseTaskTargetCDN, model.ReleaseTaskTypeApplyConfig, model.ReleaseTaskStatusSuccess, version).
+		Select("COALESCE(MAX(version), 0)").
+		Scan(&prev).Error
+	return prev, err
+}
+
+// CreateRollbackRelease 为超出失败预算的发布创建补偿发布（rollback_config）
+// 回滚目标为上一个成功版本，范围为原发布中已下发的节点（success/failed/running），单批执行
+func CreateRollbackRelease(db *gorm.DB, original *model.ReleaseTask) (*model.ReleaseTask, error) {
+	var rollback *model.ReleaseTask
+
+	err := db.Transaction(func(tx *gorm.DB) error {
+		// 幂等：同一发布只创建一次回滚
+		var count int64
+		if err := tx.Model(&model.ReleaseTask{}).Where("rollback_of_id = ?", original.ID).Count(&count).Error; err != nil {
+			return err
+		}
+		if count > 0 {
+			return nil
+		}
+
+		var nodeIDs []int
+		if err := tx.Model(&model.ReleaseTaskNode{}).
+			Where("release_task_id = ? AND status IN ?", original.ID, []model.ReleaseTaskNodeStatus{
+				model.ReleaseTaskNodeStatusSuccess,
+				model.ReleaseTaskNodeStatusFailed,
+				model.ReleaseTaskNodeStatusRunning,
+			}).
+			Order("node_id ASC").
+			Pluck("node_id", &nodeIDs).Error; err != nil {
+			return err
+		}
+		if len(nodeIDs) == 0 {
+			return nil
+		}
+
+		targetVersion, err := previousSuccessVersion(tx, original.Version)
+		if err != nil {
+			return err
+		}
+
+		version, err := NewService(tx).GenerateVersion(tx)
+		if err != nil {
+			return err
+		}
+
+		// 回滚后的配置即目标版本的配置快照
+		var target model.ReleaseTask
+		if targetVersion > 0 {
+			if err := tx.Select("config_snapshot").
+				Where("target = ? AND version = ?", model.ReleaseTaskTargetCDN, targetVersion).
+				First(&target).Error; err != nil && err != gorm.ErrRecordNotFound {
+				return err
+			}
+		}
+
+		originalID := original.ID
+		rollback = &model.ReleaseTask{
+			Type:       model.ReleaseTaskTypeRollbackConfig,
+			Target:     model.ReleaseTaskTargetCDN,
+			Version:    version,
+			Status:     model.ReleaseTaskStatusPending,
+			TotalNodes: len(nodeIDs),
+			Payload: &model.ReleaseTaskPayload{
+				RollbackToVersion:   targetVersion,
+				RollbackFromVersion: original.Version,
+			},
+			RollbackOfID:   &originalID,
+			ConfigSnapshot: target.ConfigSnapshot,
+		}
+		if err := tx.Create(rollback).Error; err != nil {
+			return err
+		}
+
+		return CreateBatchNodes(tx, rollback.ID, []BatchAllocation{{Batch: 1, NodeIDs: nodeIDs}})
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create rollback release: %w", err)
+	}
+
+	if rollback != nil {
+		log.Printf("[Release] Created rollback release %d for release %d (version %d -> %d, nodes=%d)",
+			rollback.ID, original.ID, original.Version, rollback.Payload.RollbackToVersion, rollback.TotalNodes)
+		publishReleaseEvent(db, ReleaseEventCreated, rollback.ID, "", fmt.Sprintf("rollback of release %d", original.ID))
+	}
+	return rollback, nil
+}
+
+// WebsiteFailureBudgetExceeded 网站发布一次下发到所有节点（只有一批），按发布的失败节点数检查失败预算
+func WebsiteFailureBudgetExceeded(task *model.ReleaseTask) bool {
+	return exceedsFailureBudget(task.MaxFailedNodes, task.MaxFailedPercent, task.FailedNodes, task.FailedNodes, task.TotalNodes)
+}
+
+// CreateWebsiteRollbackRelease 为超出失败预算的网站发布创建补偿发布（rollback_config）
+// 回滚目标为该网站上一个成功发布的 contentHash，下发其配置快照；在调用方事务内创建，由调用方提交后派发
+// 没有可回滚的成功发布时返回 nil
+func CreateWebsiteRollbackRelease(tx *gorm.DB, original *model.ReleaseTask) (*model.ReleaseTask, error) {
+	// 幂等：同一发布只创建一次回滚
+	var count int64
+	if err := tx.Model(&model.ReleaseTask{}).Where("rollback_of_id = ?", original.ID).Count(&count).Error; err != nil {
+		return nil, err
+	}
+	if count > 0 {
+		return nil, nil
+	}
+
+	var target model.ReleaseTask
+	err := tx.Where("target_type = ? AND target_id = ? AND status = ? AND id < ? AND config_snapshot IS NOT NULL",
+		original.TargetType, original.TargetID, model.ReleaseTaskStatusSuccess, original.ID).
+		Order("id DESC").
+		First(&target).Error
+	if err == gorm.ErrRecordNotFound {
+		log.Printf("[Release] No successful release before release %d of %s %d, nothing to roll back to",
+			original.ID, original.TargetType, original.TargetID)
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	originalID := original.ID
+	rollback := &model.ReleaseTask{
+		Type:        model.ReleaseTaskTypeRollbackConfig,
+		TargetType:  original.TargetType,
+		TargetID:    original.TargetID,
+		Status:      model.ReleaseTaskStatusPending,
+		ContentHash: target.ContentHash,
+		Payload: &model.ReleaseTaskPayload{
+			WebsiteID:  int(original.TargetID),
+			TargetType: original.TargetType,
+			TargetID:   original.TargetID,
+		},
+		RollbackOfID:   &originalID,
+		ConfigSnapshot: target.ConfigSnapshot,
+	}
+	if err := tx.Create(rollback).Error; err != nil {
+		return nil, err
+	}
+
+	log.Printf("[Release] Created rollback release %d for release %d of %s %d (back to release %d, contentHash=%s)",
+		rollback.ID, original.ID, original.TargetType, original.TargetID, target.ID, target.ContentHash)
+	return rollback, nil
+}
+
+// rollbackPayload 构造 rollback_config 任务 payload（与 Agent RollbackConfigPayload 对应）
+func rollbackPayload(task *model.ReleaseTask) (json.RawMessage, error) {
+	payload := map[string]int64{"releaseId": task.ID}
+	if task.Payload != nil {
+		payload["version"] = task.Payload.RollbackToVersion
+		payload["fromVersion"] = task.Payload.RollbackFromVersion
+	}
+	return json.Marshal(payload)
+}
@@ -0,0 +1,29 @@
+package release
+
+import "testing"
+
+func TestExceedsFailureBudget(t *testing.T) {
+	tests := []struct {
+		name                                string
+		maxFailedNodes, maxFailedPercent    int
+		totalFailed, batchFailed, batchSize int
+		want                                bool
+	}{
+		{"no failures", 0, 0, 0, 0, 10, false},
+		{"no budget, one failure", 0, 0, 1, 1, 10, true},
+		{"within node budget", 2, 0, 2, 1, 10, false},
+		{"over node budget", 2, 0, 3, 1, 10, true},
+		{"within batch percent", 0, 20, 5, 2, 10, false},
+		{"over batch percent", 0, 20, 3, 3, 10, true},
+		{"node budget ok but percent exceeded", 5, 10, 2, 2, 10, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := exceedsFailureBudget(tt.maxFailedNodes, tt.maxFailedPercent, tt.totalFailed, tt.batchFailed, tt.batchSize)
+			if got != tt.want {
+				t.Errorf("exceedsFailureBudget() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -1,12 +1,15 @@
 package release
 
 import (
+	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
 	"sync"
 	"time"
 
 	"go_cmdb/internal/agentclient"
+	"go_cmdb/internal/configgen"
 	"go_cmdb/internal/model"
 	"gorm.io/gorm"
 )
@@ -17,6 +20,13 @@ var (
 	tableCheckMutex              sync.Mutex
 )
 
+var (
+	// errReleaseHalted 发布任务已被暂停或取消
+	errReleaseHalted = errors.New("release task halted")
+	// errFailureBudgetExceeded 失败节点超出失败预算
+	errFailureBudgetExceeded = errors.New("failure budget exceeded")
+)
+
 // Runner 单个release_task的执行状态机
 type Runner struct {
 	db          *gorm.DB
@@ -43,6 +53,13 @@ func (r *Runner) Run() error {
 		return fmt.Errorf("failed to get batches: %w", err)
 	}
 
+	// 尚未分配批次（如非 CreateRelease 创建的任务），按任务上的发布策略分配
+	if len(batches) == 0 && nodesTableExists() {
+		if batches, err = r.buildBatches(); err != nil {
+			return fmt.Errorf("failed to build batches: %w", err)
+		}
+	}
+
 	log.Printf("[Runner] Found %d batches for release task %d", len(batches), r.task.ID)
 
 	// If no batches found (e.g., table does not exist), skip execution
@@ -52,18 +69,59 @@ func (r *Runner) Run() error {
 	}
 
 	// 按batch顺序执行
-	for _, batch := range batches {
+	for i, batch := range batches {
+		// 批次之间检查是否已暂停/取消
+		if r.halted() {
+			log.Printf("[Runner] Release task %d halted before batch %d", r.task.ID, batch)
+			return nil
+		}
+
+		// 恢复执行时跳过已完成的批次（其健康检查已执行过，失败后由运维决定继续）
+		if r.batchFinished(batch) {
+			continue
+		}
+
+		// 批次开始前检查其节点的维护窗口，未打开时转为等待窗口，窗口打开后由执行器继续
+		ready, reason, err := batchReady(r.db, r.task.ID, batch, time.Now())
+		if err != nil {
+			return fmt.Errorf("failed to check window of batch %d: %w", batch, err)
+		}
+		if !ready {
+			r.waitForWindow(batch, reason)
+			return nil
+		}
+
 		log.Printf("[Runner] Processing batch %d for release task %d", batch, r.task.ID)
 
 		// 处理当前batch
 		if err := r.processBatch(batch); err != nil {
+			if errors.Is(err, errReleaseHalted) {
+				log.Printf("[Runner] Release task %d halted during batch %d", r.task.ID, batch)
+				return nil
+			}
 			log.Printf("[Runner] Batch %d failed: %v", batch, err)
 			// 标记发布任务失败
 			r.handleFailure()
+			// 超出失败预算时创建回滚发布
+			if errors.Is(err, errFailureBudgetExceeded) {
+				r.rollback()
+			}
 			return fmt.Errorf("batch %d failed: %w", batch, err)
 		}
 
 		log.Printf("[Runner] Batch %d completed successfully", batch)
+
+		// 批次之间执行健康检查门禁，未通过时暂停发布（不标记失败）
+		if i < len(batches)-1 {
+			if err := r.checkHealthGates(batch); err != nil {
+				if errors.Is(err, errReleaseHalted) {
+					log.Printf("[Runner] Release task %d halted during health gate of batch %d", r.task.ID, batch)
+					return nil
+				}
+				r.pauseForGate(batch, err)
+				return nil
+			}
+		}
 	}
 
 	// 所有batch成功，标记发布任务成功
@@ -73,6 +131,13 @@ func (r *Runner) Run() error {
 	return nil
 }
 
+// nodesTableExists 返回release_task_nodes表是否存在（getAllBatches检查后有效）
+func nodesTableExists() bool {
+	tableCheckMutex.Lock()
+	defer tableCheckMutex.Unlock()
+	return releaseTaskNodesTableExists
+}
+
 // getAllBatches 获取所有batch（去重并排序）
 func (r *Runner) getAllBatches() ([]int, error) {
 	// Check if release_task_nodes table exists (only once)
@@ -108,6 +173,42 @@ func (r *Runner) getAllBatches() ([]int, error) {
 	return batches, err
 }
 
+// buildBatches 按任务的发布策略选择在线节点并创建release_task_nodes
+func (r *Runner) buildBatches() ([]int, error) {
+	strategy, err := NormalizeStrategy(r.task.RolloutStrategy)
+	if err != nil {
+		return nil, err
+	}
+
+	nodes, allocations, err := PlanBatches(r.db, strategy)
+	if err != nil {
+		return nil, err
+	}
+	if len(nodes) == 0 {
+		return []int{}, nil
+	}
+
+	err = r.db.Transaction(func(tx *gorm.DB) error {
+		if err := CreateBatchNodes(tx, r.task.ID, allocations); err != nil {
+			return err
+		}
+		return tx.Model(&model.ReleaseTask{}).
+			Where("id = ?", r.task.ID).
+			Update("total_nodes", len(nodes)).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	log.Printf("[Runner] Built %d batches for release task %d (strategy=%s, nodes=%d)", len(allocations), r.task.ID, strategy.Type, len(nodes))
+
+	batches := make([]int, len(allocations))
+	for i, allocation := range allocations {
+		batches[i] = allocation.Batch
+	}
+	return batches, nil
+}
+
 // processBatch 处理单个batch
 func (r *Runner) processBatch(batch int) error {
 	// 获取当前batch的所有nodes
@@ -121,12 +222,20 @@ func (r *Runner) processBatch(batch int) error {
 	log.Printf("[Runner] Batch %d has %d nodes", batch, len(nodes))
 
 	// 第一轮：dispatch所有pending节点
+	// 节点之间检查是否已暂停/取消，停止下发后仍等待已下发节点的结果
+	halted := false
 	for i := range nodes {
 		if nodes[i].Status == model.ReleaseTaskNodeStatusPending {
+			if r.halted() {
+				halted = true
+				break
+			}
 			if err := r.dispatchNode(&nodes[i]); err != nil {
-				// dispatch失败，标记节点为failed
+				// dispatch失败，标记节点为failed，未超出失败预算时继续下发
 				r.markNodeFailed(&nodes[i], err.Error())
-				return fmt.Errorf("failed to dispatch node %d: %w", nodes[i].NodeID, err)
+				if r.failureBudgetExceeded(batch) {
+					return fmt.Errorf("failed to dispatch node %d: %v: %w", nodes[i].NodeID, err, errFailureBudgetExceeded)
+				}
 			}
 		}
 	}
@@ -156,16 +265,15 @@ func (r *Runner) processBatch(batch int) error {
 					// 查询失败，标记节点为failed
 					r.markNodeFailed(&nodes[i], err.Error())
 					hasFailure = true
-					break
 				}
 			} else if nodes[i].Status == model.ReleaseTaskNodeStatusFailed {
 				hasFailure = true
 			}
 		}
 
-		// 如果有失败，立即返回
-		if hasFailure {
-			return fmt.Errorf("batch %d has failed nodes", batch)
+		// 如果失败超出预算，立即返回
+		if hasFailure && r.failureBudgetExceeded(batch) {
+			return fmt.Errorf("batch %d has failed nodes: %w", batch, errFailureBudgetExceeded)
 		}
 
 		// 如果所有节点都已完成，退出轮询
@@ -178,9 +286,13 @@ func (r *Runner) processBatch(batch int) error {
 		time.Sleep(pollInterval)
 	}
 
-	// 最终检查：确保所有节点都是success状态
+	if halted {
+		return errReleaseHalted
+	}
+
+	// 最终检查：确保所有节点都是success状态（预算内的failed节点除外）
 	for i := range nodes {
-		if nodes[i].Status != model.ReleaseTaskNodeStatusSuccess {
+		if nodes[i].Status != model.ReleaseTaskNodeStatusSuccess && nodes[i].Status != model.ReleaseTaskNodeStatusFailed {
 			return fmt.Errorf("node %d is not in success status (current: %s)", nodes[i].NodeID, nodes[i].Status)
 		}
 	}
@@ -188,6 +300,82 @@ func (r *Runner) processBatch(batch int) error {
 	return nil
 }
 
+// failureBudgetExceeded 按已失败节点数检查是否超出发布的失败预算（查询失败时按超出处理）
+func (r *Runner) failureBudgetExceeded(batch int) bool {
+	var totalFailed, batchFailed, batchSize int64
+	base := r.db.Model(&model.ReleaseTaskNode{}).Where("release_task_id = ?", r.task.ID)
+	if err := base.Session(&gorm.Session{}).Where("status = ?", model.ReleaseTaskNodeStatusFailed).Count(&totalFailed).Error; err != nil {
+		log.Printf("[Runner] Failed to count failed nodes: %v", err)
+		return true
+	}
+	if err := base.Session(&gorm.Session{}).Where("batch = ? AND status = ?", batch, model.ReleaseTaskNodeStatusFailed).Count(&batchFailed).Error; err != nil {
+		log.Printf("[Runner] Failed to count failed nodes: %v", err)
+		return true
+	}
+	if err := base.Session(&gorm.Session{}).Where("batch = ?", batch).Count(&batchSize).Error; err != nil {
+		log.Printf("[Runner] Failed to count batch nodes: %v", err)
+		return true
+	}
+
+	exceeded := exceedsFailureBudget(r.task.MaxFailedNodes, r.task.MaxFailedPercent, int(totalFailed), int(batchFailed), int(batchSize))
+	if !exceeded && totalFailed > 0 {
+		log.Printf("[Runner] Release task %d has %d failed nodes (batch %d: %d/%d), within failure budget",
+			r.task.ID, totalFailed, batch, batchFailed, batchSize)
+	}
+	return exceeded
+}
+
+// rollback 超出失败预算后创建回滚发布（回滚发布本身不再回滚）
+func (r *Runner) rollback() {
+	if !r.task.AutoRollback || r.task.Type != model.ReleaseTaskTypeApplyConfig {
+		return
+	}
+	if _, err := CreateRollbackRelease(r.db, r.task); err != nil {
+		log.Printf("[Runner] Failed to roll back release task %d: %v", r.task.ID, err)
+	}
+}
+
+// batchFinished 检查批次是否已没有待执行（pending/running）的节点
+func (r *Runner) batchFinished(batch int) bool {
+	var unfinished int64
+	if err := r.db.Model(&model.ReleaseTaskNode{}).
+		Where("release_task_id = ? AND batch = ? AND status IN ?", r.task.ID, batch, []model.ReleaseTaskNodeStatus{
+			model.ReleaseTaskNodeStatusPending,
+			model.ReleaseTaskNodeStatusRunning,
+		}).
+		Count(&unfinished).Error; err != nil {
+		log.Printf("[Runner] Failed to check batch %d: %v", batch, err)
+		return false
+	}
+	return unfinished == 0
+}
+
+// waitForWindow 批次的维护窗口未打开，发布转为 waiting_window 并释放执行器
+func (r *Runner) waitForWindow(batch int, reason string) {
+	log.Printf("[Runner] Release task %d waits before batch %d: %s", r.task.ID, batch, reason)
+
+	if err := transitRelease(r.db, r.task.ID, []model.ReleaseTaskStatus{
+		model.ReleaseTaskStatusRunning,
+	}, map[string]interface{}{
+		"status":     model.ReleaseTaskStatusWaitingWindow,
+		"last_error": reason,
+	}); err != nil {
+		log.Printf("[Runner] Failed to move release task %d to waiting_window: %v", r.task.ID, err)
+		return
+	}
+	publishReleaseEvent(r.db, ReleaseEventWaitingWindow, r.task.ID, "", reason)
+}
+
+// halted 检查发布任务是否已被暂停或取消（读取失败时按未暂停处理）
+func (r *Runner) halted() bool {
+	var task model.ReleaseTask
+	if err := r.db.Select("id", "status").First(&task, r.task.ID).Error; err != nil {
+		log.Printf("[Runner] Failed to check status of release task %d: %v", r.task.ID, err)
+		return false
+	}
+	return task.Status == model.ReleaseTaskStatusPaused || task.Status == model.ReleaseTaskStatusCancelled
+}
+
 // dispatchNode dispatch单个节点
 func (r *Runner) dispatchNode(node *model.ReleaseTaskNode) error {
 	// 获取节点信息
@@ -196,10 +384,16 @@ func (r *Runner) dispatchNode(node *model.ReleaseTaskNode) error {
 		return fmt.Errorf("failed to get node info: %w", err)
 	}
 
-	log.Printf("[Runner] Dispatching node %d (IP=%s)", node.NodeID, n.MainIP)
+	payloadJSON, err := r.buildPayload(node)
+	if err != nil {
+		return err
+	}
+
+	log.Printf("[Runner] Dispatching %s to node %d (IP=%s)", r.taskType(), node.NodeID, n.MainIP)
 
 	// 调用Agent dispatch接口
-	taskID, err := r.agentClient.Dispatch(n.MainIP, r.task.Version)
+	taskID, err := r.agentClient.Dispatch(n.MainIP, n.AgentPort, r.taskType(),
+		agentclient.TaskID(r.taskType(), n.MainIP, r.task.Version), payloadJSON)
 	if err != nil {
 		return fmt.Errorf("failed to dispatch to agent: %w", err)
 	}
@@ -214,10 +408,37 @@ func (r *Runner) dispatchNode(node *model.ReleaseTaskNode) error {
 	}).Error; err != nil {
 		return fmt.Errorf("failed to update node status: %w", err)
 	}
+	publishNodeEvent(node, model.ReleaseTaskNodeStatusRunning, "")
 
 	return nil
 }
 
+// taskType Agent任务类型（rollback_config 发布回滚，其余为 apply_config）
+func (r *Runner) taskType() string {
+	if r.task.Type == model.ReleaseTaskTypeRollbackConfig {
+		return string(model.ReleaseTaskTypeRollbackConfig)
+	}
+	return string(model.ReleaseTaskTypeApplyConfig)
+}
+
+// buildPayload 构造节点的任务payload
+func (r *Runner) buildPayload(node *model.ReleaseTaskNode) (json.RawMessage, error) {
+	if r.task.Type == model.ReleaseTaskTypeRollbackConfig {
+		return rollbackPayload(r.task)
+	}
+
+	// 生成该节点的配置（只包含该节点服务的网站）
+	payload, err := configgen.NewAggregator(r.db).GeneratePayload(node.NodeID, r.task.Version)
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate payload: %w", err)
+	}
+	payloadJSON, err := json.Marshal(payload)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal payload: %w", err)
+	}
+	return payloadJSON, nil
+}
+
 // pollNode 轮询单个节点状态
 func (r *Runner) pollNode(node *model.ReleaseTaskNode) error {
 	// 获取节点信息
@@ -227,10 +448,10 @@ func (r *Runner) pollNode(node *model.ReleaseTaskNode) error {
 	}
 
 	// 生成taskID（与dispatch时一致）
-	taskID := fmt.Sprintf("apply_config_%s_%d", n.MainIP, r.task.Version)
+	taskID := agentclient.TaskID(r.taskType(), n.MainIP, r.task.Version)
 
 	// 调用Agent query接口
-	status, lastError, err := r.agentClient.Query(n.MainIP, taskID)
+	status, lastError, err := r.agentClient.Query(n.MainIP, n.AgentPort, taskID)
 	if err != nil {
 		return fmt.Errorf("failed to query agent: %w", err)
 	}
@@ -260,6 +481,8 @@ func (r *Runner) markNodeSuccess(node *model.ReleaseTaskNode) {
 		"finished_at": &now,
 	}).Error; err != nil {
 		log.Printf("[Runner] Failed to mark node %d as success: %v", node.NodeID, err)
+	} else {
+		publishNodeEvent(node, model.ReleaseTaskNodeStatusSuccess, "")
 	}
 
 	// 更新release_tasks的success_nodes计数
@@ -279,6 +502,8 @@ func (r *Runner) markNodeFailed(node *model.ReleaseTaskNode, errorMsg string) {
 		"finished_at": &now,
 	}).Error; err != nil {
 		log.Printf("[Runner] Failed to mark node %d as failed: %v", node.NodeID, err)
+	} else {
+		publishNodeEvent(node, model.ReleaseTaskNodeStatusFailed, errorMsg)
 	}
 
 	// 更新release_tasks的failed_nodes计数
@@ -293,11 +518,17 @@ func (r *Runner) markNodeFailed(node *model.ReleaseTaskNode, errorMsg string) {
 func (r *Runner) handleFailure() {
 	log.Printf("[Runner] Handling failure for release task %d", r.task.ID)
 
-	// 1. 更新release_tasks.status = failed
-	if err := r.db.Model(&model.ReleaseTask{}).
-		Where("id = ?", r.task.ID).
-		Update("status", model.ReleaseTaskStatusFailed).Error; err != nil {
-		log.Printf("[Runner] Failed to update task status: %v", err)
+	// 1. 更新release_tasks.status = failed（已取消的任务保持cancelled）
+	result := r.db.Model(&model.ReleaseTask{}).
+		Where("id = ? AND status IN ?", r.task.ID, []model.ReleaseTaskStatus{
+			model.ReleaseTaskStatusRunning,
+			model.ReleaseTaskStatusPaused,
+		}).
+		Update("status", model.ReleaseTaskStatusFailed)
+	if result.Error != nil {
+		log.Printf("[Runner] Failed to update task status: %v", result.Error)
+	} else if result.RowsAffected > 0 {
+		publishReleaseEvent(r.db, ReleaseEventFinished, r.task.ID, "", "")
 	}
 
 	// 2. 标记后续batch的nodes为skipped
@@ -316,24 +547,45 @@ func (r *Runner) handleFailure() {
 	}
 
 	// 标记后续batch的pending节点为skipped
-	if err := r.db.Model(&model.ReleaseTaskNode{}).
-		Where("release_task_id = ? AND batch > ? AND status = ?",
-			r.task.ID, currentBatch, model.ReleaseTaskNodeStatusPending).
-		Update("status", model.ReleaseTaskNodeStatusSkipped).Error; err != nil {
+	skipped, err := skipPendingNodes(r.db, r.task.ID, currentBatch)
+	if err != nil {
 		log.Printf("[Runner] Failed to mark nodes as skipped: %v", err)
+		return
 	}
+	publishSkippedNodes(skipped)
 
 	log.Printf("[Runner] Marked subsequent batches as skipped")
 }
 
 // handleSuccess 处理发布成功
+// 有失败节点（在失败预算内）时记录到 last_error，finished 事件的 reason 为 partial_success
 func (r *Runner) handleSuccess() {
 	log.Printf("[Runner] Handling success for release task %d", r.task.ID)
 
-	// 更新release_tasks.status = success
-	if err := r.db.Model(&model.ReleaseTask{}).
-		Where("id = ?", r.task.ID).
-		Update("status", model.ReleaseTaskStatusSuccess).Error; err != nil {
-		log.Printf("[Runner] Failed to update task status: %v", err)
+	updates := map[string]interface{}{
+		"status": model.ReleaseTaskStatusSuccess,
+	}
+	var failedNodes int64
+	if err := r.db.Model(&model.ReleaseTaskNode{}).
+		Where("release_task_id = ? AND status = ?", r.task.ID, model.ReleaseTaskNodeStatusFailed).
+		Count(&failedNodes).Error; err != nil {
+		log.Printf("[Runner] Failed to count failed nodes: %v", err)
+	} else if failedNodes > 0 {
+		updates["failed_nodes"] = failedNodes
+		updates["last_error"] = fmt.Sprintf("completed with %d failed nodes within failure budget", failedNodes)
+		log.Printf("[Runner] Release task %d completed with %d failed nodes within failure budget", r.task.ID, failedNodes)
+	}
+
+	// 更新release_tasks.status = success（已取消的任务保持cancelled）
+	result := r.db.Model(&model.ReleaseTask{}).
+		Where("id = ? AND status IN ?", r.task.ID, []model.ReleaseTaskStatus{
+			model.ReleaseTaskStatusRunning,
+			model.ReleaseTaskStatusPaused,
+		}).
+		Updates(updates)
+	if result.Error != nil {
+		log.Printf("[Runner] Failed to update task status: %v", result.Error)
+	} else if result.RowsAffected > 0 {
+		publishReleaseEvent(r.db, ReleaseEventFinished, r.task.ID, "", "")
 	}
 }
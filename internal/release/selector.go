@@ -1,6 +1,8 @@
 package release
 
 import (
+	"sort"
+
 	"go_cmdb/internal/model"
 
 	"gorm.io/gorm"
@@ -16,31 +18,132 @@ func SelectOnlineNodes(db *gorm.DB) ([]model.Node, error) {
 	return nodes, err
 }
 
-// AllocateBatches 分配批次
-// 规则：batch=1取第1个节点，batch=2取剩余节点
-// 若只有1个节点，仅batch=1，不生成batch=2
-func AllocateBatches(nodes []model.Node) []BatchAllocation {
+// AllocateBatches 按发布策略分配批次
+// strategy 须已经过 NormalizeStrategy 处理
+// groupOf: 节点ID -> 分组ID（node_group/line_group 策略使用），不属于任何分组的节点放在最后一批
+// 最后按 MaxBatchSize 拆分过大的批次，批次号从1开始连续编号
+func AllocateBatches(nodes []model.Node, strategy model.RolloutStrategy, groupOf map[int]int64) []BatchAllocation {
 	if len(nodes) == 0 {
 		return nil
 	}
 
-	batches := []BatchAllocation{
-		{
-			Batch:   1,
-			NodeIDs: []int{nodes[0].ID},
-		},
+	var groups [][]int
+	switch strategy.Type {
+	case model.RolloutStrategyPercentage:
+		groups = allocateByPercentage(nodes, strategy.Percentages)
+	case model.RolloutStrategyNodeGroup, model.RolloutStrategyLineGroup:
+		groups = allocateByGroup(nodes, groupOf)
+	default:
+		groups = allocateCanary(nodes, strategy.CanaryCount)
 	}
 
-	if len(nodes) > 1 {
-		remainingIDs := make([]int, len(nodes)-1)
-		for i := 1; i < len(nodes); i++ {
-			remainingIDs[i-1] = nodes[i].ID
+	var batches []BatchAllocation
+	for _, nodeIDs := range groups {
+		for _, chunk := range splitBatch(nodeIDs, strategy.MaxBatchSize) {
+			batches = append(batches, BatchAllocation{
+				Batch:   len(batches) + 1,
+				NodeIDs: chunk,
+			})
 		}
-		batches = append(batches, BatchAllocation{
-			Batch:   2,
-			NodeIDs: remainingIDs,
-		})
 	}
 
 	return batches
 }
+
+// allocateCanary 前 canaryCount 个节点为第1批，其余节点为第2批
+func allocateCanary(nodes []model.Node, canaryCount int) [][]int {
+	if canaryCount <= 0 {
+		canaryCount = 1
+	}
+	if canaryCount > len(nodes) {
+		canaryCount = len(nodes)
+	}
+
+	groups := [][]int{nodeIDs(nodes[:canaryCount])}
+	if canaryCount < len(nodes) {
+		groups = append(groups, nodeIDs(nodes[canaryCount:]))
+	}
+	return groups
+}
+
+// allocateByPercentage 按累计百分比分批
+// 每一步至少推进1个节点；节点数较少时，推进不了新节点的步骤被合并
+func allocateByPercentage(nodes []model.Node, percentages []int) [][]int {
+	var groups [][]int
+	done := 0
+	for _, p := range percentages {
+		target := (len(nodes)*p + 99) / 100
+		if target <= done {
+			target = done + 1
+		}
+		if target > len(nodes) {
+			target = len(nodes)
+		}
+		if target > done {
+			groups = append(groups, nodeIDs(nodes[done:target]))
+			done = target
+		}
+		if done == len(nodes) {
+			break
+		}
+	}
+	if done < len(nodes) {
+		groups = append(groups, nodeIDs(nodes[done:]))
+	}
+	return groups
+}
+
+// allocateByGroup 每个分组一批（按分组ID升序），无分组的节点放在最后一批
+func allocateByGroup(nodes []model.Node, groupOf map[int]int64) [][]int {
+	byGroup := make(map[int64][]int)
+	var groupIDs []int64
+	var ungrouped []int
+	for _, node := range nodes {
+		groupID, ok := groupOf[node.ID]
+		if !ok {
+			ungrouped = append(ungrouped, node.ID)
+			continue
+		}
+		if _, seen := byGroup[groupID]; !seen {
+			groupIDs = append(groupIDs, groupID)
+		}
+		byGroup[groupID] = append(byGroup[groupID], node.ID)
+	}
+
+	sort.Slice(groupIDs, func(i, j int) bool { return groupIDs[i] < groupIDs[j] })
+
+	groups := make([][]int, 0, len(groupIDs)+1)
+	for _, groupID := range groupIDs {
+		groups = append(groups, byGroup[groupID])
+	}
+	if len(ungrouped) > 0 {
+		groups = append(groups, ungrouped)
+	}
+	return groups
+}
+
+// splitBatch 按最大批次大小拆分，maxSize<=0 表示不拆分
+func splitBatch(ids []int, maxSize int) [][]int {
+	if maxSize <= 0 || len(ids) <= maxSize {
+		return [][]int{ids}
+	}
+
+	var chunks [][]int
+	for start := 0; start < len(ids); start += maxSize {
+		end := start + maxSize
+		if end > len(ids) {
+			end = len(ids)
+		}
+		chunks = append(chunks, ids[start:end])
+	}
+	return chunks
+}
+
+// nodeIDs 提取节点ID列表
+func nodeIDs(nodes []model.Node) []int {
+	ids := make([]int, len(nodes))
+	for i, node := range nodes {
+		ids[i] = node.ID
+	}
+	return ids
+}
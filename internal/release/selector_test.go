@@ -0,0 +1,110 @@
+package release
+
+import (
+	"reflect"
+	"testing"
+
+	"go_cmdb/internal/model"
+)
+
+func makeNodes(n int) []model.Node {
+	nodes := make([]model.Node, n)
+	for i := range nodes {
+		nodes[i].ID = i + 1
+	}
+	return nodes
+}
+
+func batchNodeIDs(batches []BatchAllocation) [][]int {
+	result := make([][]int, len(batches))
+	for i, b := range batches {
+		if b.Batch != i+1 {
+			panic("batches must be numbered from 1")
+		}
+		result[i] = b.NodeIDs
+	}
+	return result
+}
+
+func TestAllocateBatches(t *testing.T) {
+	tests := []struct {
+		name     string
+		nodes    int
+		strategy model.RolloutStrategy
+		groupOf  map[int]int64
+		want     [][]int
+	}{
+		{
+			name:     "default canary",
+			nodes:    4,
+			strategy: model.RolloutStrategy{Type: model.RolloutStrategyCanary, CanaryCount: 1},
+			want:     [][]int{{1}, {2, 3, 4}},
+		},
+		{
+			name:     "single node",
+			nodes:    1,
+			strategy: model.RolloutStrategy{Type: model.RolloutStrategyCanary, CanaryCount: 1},
+			want:     [][]int{{1}},
+		},
+		{
+			name:     "canary count larger than nodes",
+			nodes:    2,
+			strategy: model.RolloutStrategy{Type: model.RolloutStrategyCanary, CanaryCount: 5},
+			want:     [][]int{{1, 2}},
+		},
+		{
+			name:     "canary with max batch size",
+			nodes:    6,
+			strategy: model.RolloutStrategy{Type: model.RolloutStrategyCanary, CanaryCount: 1, MaxBatchSize: 2},
+			want:     [][]int{{1}, {2, 3}, {4, 5}, {6}},
+		},
+		{
+			name:     "percentage steps",
+			nodes:    10,
+			strategy: model.RolloutStrategy{Type: model.RolloutStrategyPercentage, Percentages: []int{10, 50, 100}},
+			want:     [][]int{{1}, {2, 3, 4, 5}, {6, 7, 8, 9, 10}},
+		},
+		{
+			name:     "percentage steps merged for few nodes",
+			nodes:    3,
+			strategy: model.RolloutStrategy{Type: model.RolloutStrategyPercentage, Percentages: []int{1, 10, 50, 100}},
+			want:     [][]int{{1}, {2}, {3}},
+		},
+		{
+			name:     "node groups with ungrouped nodes last",
+			nodes:    5,
+			strategy: model.RolloutStrategy{Type: model.RolloutStrategyNodeGroup},
+			groupOf:  map[int]int64{1: 20, 2: 10, 3: 20, 5: 10},
+			want:     [][]int{{2, 5}, {1, 3}, {4}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := batchNodeIDs(AllocateBatches(makeNodes(tt.nodes), tt.strategy, tt.groupOf))
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("AllocateBatches() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNormalizeStrategy(t *testing.T) {
+	s, err := NormalizeStrategy(nil)
+	if err != nil || s.Type != model.RolloutStrategyCanary || s.CanaryCount != 1 {
+		t.Errorf("unexpected default strategy: %+v, %v", s, err)
+	}
+
+	invalid := []model.RolloutStrategy{
+		{Type: "unknown"},
+		{Type: model.RolloutStrategyPercentage},
+		{Type: model.RolloutStrategyPercentage, Percentages: []int{50, 10, 100}},
+		{Type: model.RolloutStrategyPercentage, Percentages: []int{10, 50}},
+		{Type: model.RolloutStrategyCanary, MaxBatchSize: -1},
+	}
+	for _, strategy := range invalid {
+		if _, err := NormalizeStrategy(&strategy); err == nil {
+			t.Errorf("expected error for %+v", strategy)
+		}
+	}
+}
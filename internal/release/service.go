@@ -1,6 +1,10 @@
 package release
 
 import (
+	"errors"
+
+	"go_cmdb/internal/approval"
+	"go_cmdb/internal/configgen"
 	"go_cmdb/internal/httpx"
 	"go_cmdb/internal/model"
 
@@ -36,14 +40,24 @@ func (s *Service) CreateRelease(req *CreateReleaseRequest) (*CreateReleaseRespon
 	var resp *CreateReleaseResponse
 
 	err := s.db.Transaction(func(tx *gorm.DB) error {
-		// 1. 生成version
+		// 1. 校验发布策略
+		strategy, err := NormalizeStrategy(req.RolloutStrategy)
+		if err != nil {
+			return err
+		}
+		healthGates, err := NormalizeHealthGates(req.HealthGates)
+		if err != nil {
+			return err
+		}
+
+		// 2. 生成version
 		version, err := s.GenerateVersion(tx)
 		if err != nil {
 			return err
 		}
 
-		// 2. 选择在线节点
-		nodes, err := SelectOnlineNodes(tx)
+		// 3. 选择在线节点并按策略分配批次
+		nodes, batches, err := PlanBatches(tx, strategy)
 		if err != nil {
 			return err
 		}
@@ -51,48 +65,88 @@ func (s *Service) CreateRelease(req *CreateReleaseRequest) (*CreateReleaseRespon
 			return httpx.ErrStateConflict("no online nodes")
 		}
 
-		// 3. 分配批次
-		batches := AllocateBatches(nodes)
+		// 校验回源：只校验本次发布会渲染的网站（节点所服务线路组下的网站），
+		// 同一 upstream 不允许混用 http/https，避免到节点上才失败
+		nodeIDs := make([]int, 0, len(nodes))
+		for _, node := range nodes {
+			nodeIDs = append(nodeIDs, node.ID)
+		}
+		if err := configgen.ValidateNodeWebsiteOrigins(tx, nodeIDs); err != nil {
+			if errors.Is(err, configgen.ErrMixedOriginProtocols) {
+				return httpx.ErrParamInvalid(err.Error())
+			}
+			return err
+		}
 
-		// 4. 创建release_tasks
+		// 4. 按审批策略判断是否需要审批，需要时创建为 awaiting_approval，Executor 不会拉起
+		approvalReason, err := approval.Evaluate(tx, approval.Change{NodeCount: len(nodes)})
+		if err != nil {
+			return err
+		}
+		status := model.ReleaseTaskStatusPending
+		if approvalReason != "" {
+			status = model.ReleaseTaskStatusAwaitingApproval
+		}
+
+		// 5. 网站配置快照（用于版本对比）
+		snapshot, err := configgen.NewAggregator(tx).Snapshot(nil)
+		if err != nil {
+			return err
+		}
+
+		// 6. 创建release_tasks
+		autoRollback := true
+		if req.AutoRollback != nil {
+			autoRollback = *req.AutoRollback
+		}
 		task := &model.ReleaseTask{
-			Type:       model.ReleaseTaskTypeApplyConfig,
-			Target:     model.ReleaseTaskTarget(req.Target),
-			Version:    version,
-			Status:     model.ReleaseTaskStatusPending,
-			TotalNodes: len(nodes),
+			Type:             model.ReleaseTaskTypeApplyConfig,
+			Target:           model.ReleaseTaskTarget(req.Target),
+			Version:          version,
+			Status:           status,
+			TotalNodes:       len(nodes),
+			RolloutStrategy:  &strategy,
+			MaxFailedNodes:   req.MaxFailedNodes,
+			MaxFailedPercent: req.MaxFailedPercent,
+			AutoRollback:     autoRollback,
+			HealthGates:      healthGates,
+			ScheduledAt:      req.ScheduledAt,
+			ConfigSnapshot:   &snapshot,
+		}
+		if req.CreatedBy != "" {
+			task.CreatedBy = &req.CreatedBy
+		}
+		if approvalReason != "" {
+			task.ApprovalReason = &approvalReason
 		}
 		if err := tx.Create(task).Error; err != nil {
 			return err
 		}
 
-		// 5. 批量创建release_task_nodes
-		for _, batch := range batches {
-			for _, nodeID := range batch.NodeIDs {
-				node := &model.ReleaseTaskNode{
-					ReleaseTaskID: task.ID,
-					NodeID:        nodeID,
-					Batch:         batch.Batch,
-					Status:        model.ReleaseTaskNodeStatusPending,
-				}
-				if err := tx.Create(node).Error; err != nil {
-					return err
-				}
-			}
+		// 7. 批量创建release_task_nodes
+		if err := CreateBatchNodes(tx, task.ID, batches); err != nil {
+			return err
 		}
 
-		// 6. 构造响应
+		// 8. 构造响应
 		resp = &CreateReleaseResponse{
-			ReleaseID:  task.ID,
-			Version:    version,
-			TotalNodes: len(nodes),
-			Batches:    batches,
+			ReleaseID:       task.ID,
+			Version:         version,
+			TotalNodes:      len(nodes),
+			Batches:         batches,
+			RolloutStrategy: strategy,
+			Status:          string(status),
+			ApprovalReason:  approvalReason,
 		}
 
 		return nil
 	})
+	if err != nil {
+		return nil, err
+	}
 
-	return resp, err
+	publishReleaseEvent(s.db, ReleaseEventCreated, resp.ReleaseID, req.CreatedBy, "")
+	return resp, nil
 }
 
 // GetReleaseResponse 获取发布任务响应
@@ -0,0 +1,139 @@
+package release
+
+import (
+	"fmt"
+
+	"go_cmdb/internal/httpx"
+	"go_cmdb/internal/model"
+
+	"gorm.io/gorm"
+)
+
+// maxRolloutSteps 百分比策略最多步骤数
+const maxRolloutSteps = 10
+
+// NormalizeStrategy 校验发布策略并填充默认值
+// 为空时返回默认策略（canary，1个金丝雀节点，与原有 batch1=1个节点、batch2=其余节点 的行为一致）
+func NormalizeStrategy(strategy *model.RolloutStrategy) (model.RolloutStrategy, error) {
+	if strategy == nil {
+		return model.RolloutStrategy{Type: model.RolloutStrategyCanary, CanaryCount: 1}, nil
+	}
+
+	s := *strategy
+	if s.Type == "" {
+		s.Type = model.RolloutStrategyCanary
+	}
+	if s.MaxBatchSize < 0 {
+		return s, httpx.ErrParamInvalid("maxBatchSize must be >= 0")
+	}
+
+	switch s.Type {
+	case model.RolloutStrategyCanary:
+		if s.CanaryCount < 0 {
+			return s, httpx.ErrParamInvalid("canaryCount must be >= 0")
+		}
+		if s.CanaryCount == 0 {
+			s.CanaryCount = 1
+		}
+	case model.RolloutStrategyPercentage:
+		if len(s.Percentages) == 0 {
+			return s, httpx.ErrParamInvalid("percentages is required for percentage strategy")
+		}
+		if len(s.Percentages) > maxRolloutSteps {
+			return s, httpx.ErrParamInvalid(fmt.Sprintf("percentages supports at most %d steps", maxRolloutSteps))
+		}
+		prev := 0
+		for _, p := range s.Percentages {
+			if p <= prev || p > 100 {
+				return s, httpx.ErrParamInvalid("percentages must be strictly increasing within 1-100")
+			}
+			prev = p
+		}
+		if prev != 100 {
+			return s, httpx.ErrParamInvalid("the last percentage must be 100")
+		}
+	case model.RolloutStrategyNodeGroup, model.RolloutStrategyLineGroup:
+	default:
+		return s, httpx.ErrParamInvalid(fmt.Sprintf("unknown rollout strategy type: %s", s.Type))
+	}
+
+	return s, nil
+}
+
+// nodeGroupRow 节点与分组的对应关系
+type nodeGroupRow struct {
+	NodeID  int
+	GroupID int64
+}
+
+// LoadNodeGroups 加载节点所属分组（节点ID -> 分组ID）
+// node_group：node_group_ips -> node_ips；line_group：再经 line_groups.node_group_id 关联
+// 节点属于多个分组时取ID最小的分组；其他策略返回 nil
+func LoadNodeGroups(db *gorm.DB, strategyType model.RolloutStrategyType) (map[int]int64, error) {
+	var rows []nodeGroupRow
+	switch strategyType {
+	case model.RolloutStrategyNodeGroup:
+		if err := db.Table("node_group_ips").
+			Select("node_ips.node_id AS node_id, MIN(node_group_ips.node_group_id) AS group_id").
+			Joins("JOIN node_ips ON node_group_ips.ip_id = node_ips.id").
+			Where("node_ips.enabled = ?", true).
+			Group("node_ips.node_id").
+			Scan(&rows).Error; err != nil {
+			return nil, fmt.Errorf("failed to query node groups: %w", err)
+		}
+	case model.RolloutStrategyLineGroup:
+		if err := db.Table("line_groups").
+			Select("node_ips.node_id AS node_id, MIN(line_groups.id) AS group_id").
+			Joins("JOIN node_group_ips ON line_groups.node_group_id = node_group_ips.node_group_id").
+			Joins("JOIN node_ips ON node_group_ips.ip_id = node_ips.id").
+			Where("node_ips.enabled = ?", true).
+			Group("node_ips.node_id").
+			Scan(&rows).Error; err != nil {
+			return nil, fmt.Errorf("failed to query line groups: %w", err)
+		}
+	default:
+		return nil, nil
+	}
+
+	groupOf := make(map[int]int64, len(rows))
+	for _, row := range rows {
+		groupOf[row.NodeID] = row.GroupID
+	}
+	return groupOf, nil
+}
+
+// PlanBatches 选择在线节点并按策略分配批次
+func PlanBatches(db *gorm.DB, strategy model.RolloutStrategy) ([]model.Node, []BatchAllocation, error) {
+	nodes, err := SelectOnlineNodes(db)
+	if err != nil {
+		return nil, nil, err
+	}
+	if len(nodes) == 0 {
+		return nil, nil, nil
+	}
+
+	groupOf, err := LoadNodeGroups(db, strategy.Type)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return nodes, AllocateBatches(nodes, strategy, groupOf), nil
+}
+
+// CreateBatchNodes 按批次分配创建 release_task_nodes
+func CreateBatchNodes(tx *gorm.DB, releaseTaskID int64, batches []BatchAllocation) error {
+	for _, batch := range batches {
+		for _, nodeID := range batch.NodeIDs {
+			node := &model.ReleaseTaskNode{
+				ReleaseTaskID: releaseTaskID,
+				NodeID:        nodeID,
+				Batch:         batch.Batch,
+				Status:        model.ReleaseTaskNodeStatusPending,
+			}
+			if err := tx.Create(node).Error; err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
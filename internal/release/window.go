@@ -0,0 +1,126 @@
+package release
+
+import (
+	"fmt"
+	"log"
+	"time"
+
+	"go_cmdb/internal/maintenance"
+	"go_cmdb/internal/model"
+
+	"gorm.io/gorm"
+)
+
+// releaseReady 判断发布是否可以开始（或继续）：计划时间已到，且下一个待执行批次的节点所在线路分组的维护窗口均已打开
+// 窗口按批次检查，不要求全部线路分组的窗口同时打开；不可开始时返回原因
+func releaseReady(db *gorm.DB, task *model.ReleaseTask, now time.Time) (bool, string, error) {
+	if task.ScheduledAt != nil && now.Before(*task.ScheduledAt) {
+		return false, fmt.Sprintf("scheduled at %s", task.ScheduledAt.Format(time.RFC3339)), nil
+	}
+
+	nodeIDs, err := nextBatchNodeIDs(db, task)
+	if err != nil {
+		return false, "", err
+	}
+	return nodesWindowOpen(db, nodeIDs, now)
+}
+
+// WebsiteReleaseReady 判断网站发布是否可以派发：计划时间已到，且网站所在线路分组的维护窗口已打开
+func WebsiteReleaseReady(db *gorm.DB, task *model.ReleaseTask, lineGroupID int64, now time.Time) (bool, string, error) {
+	if task.ScheduledAt != nil && now.Before(*task.ScheduledAt) {
+		return false, fmt.Sprintf("scheduled at %s", task.ScheduledAt.Format(time.RFC3339)), nil
+	}
+
+	var windows []model.MaintenanceWindow
+	if err := db.Model(&model.MaintenanceWindow{}).
+		Joins("JOIN line_groups ON line_groups.maintenance_window_id = maintenance_windows.id").
+		Where("line_groups.id = ?", lineGroupID).
+		Find(&windows).Error; err != nil {
+		return false, "", fmt.Errorf("failed to query maintenance window of line group %d: %w", lineGroupID, err)
+	}
+	ready, reason := windowsOpen(windows, now)
+	return ready, reason, nil
+}
+
+// batchReady 判断批次中待执行节点所在线路分组的维护窗口是否均已打开
+func batchReady(db *gorm.DB, releaseID int64, batch int, now time.Time) (bool, string, error) {
+	var nodeIDs []int
+	if err := db.Model(&model.ReleaseTaskNode{}).
+		Where("release_task_id = ? AND batch = ? AND status = ?", releaseID, batch, model.ReleaseTaskNodeStatusPending).
+		Order("node_id ASC").
+		Pluck("node_id", &nodeIDs).Error; err != nil {
+		return false, "", fmt.Errorf("failed to query nodes of batch %d: %w", batch, err)
+	}
+	return nodesWindowOpen(db, nodeIDs, now)
+}
+
+// nextBatchNodeIDs 下一个待执行批次（仍有 pending 节点的最小批次）的待执行节点
+// 没有待执行节点时（如尚未分配批次）取发布涉及的全部节点
+func nextBatchNodeIDs(db *gorm.DB, task *model.ReleaseTask) ([]int, error) {
+	var batches []int
+	if err := db.Model(&model.ReleaseTaskNode{}).
+		Where("release_task_id = ? AND status = ?", task.ID, model.ReleaseTaskNodeStatusPending).
+		Order("batch ASC").
+		Limit(1).
+		Pluck("batch", &batches).Error; err != nil {
+		return nil, fmt.Errorf("failed to query release batches: %w", err)
+	}
+	if len(batches) == 0 {
+		return releaseNodeIDs(db, task)
+	}
+
+	var ids []int
+	if err := db.Model(&model.ReleaseTaskNode{}).
+		Where("release_task_id = ? AND batch = ? AND status = ?", task.ID, batches[0], model.ReleaseTaskNodeStatusPending).
+		Order("node_id ASC").
+		Pluck("node_id", &ids).Error; err != nil {
+		return nil, fmt.Errorf("failed to query nodes of batch %d: %w", batches[0], err)
+	}
+	return ids, nil
+}
+
+// nodesWindowOpen 判断节点所在线路分组的维护窗口是否均已打开
+func nodesWindowOpen(db *gorm.DB, nodeIDs []int, now time.Time) (bool, string, error) {
+	if len(nodeIDs) == 0 {
+		return true, "", nil
+	}
+
+	windows, err := releaseWindows(db, nodeIDs)
+	if err != nil {
+		return false, "", err
+	}
+	ready, reason := windowsOpen(windows, now)
+	return ready, reason, nil
+}
+
+// windowsOpen 判断维护窗口是否均已打开，未打开时返回原因
+func windowsOpen(windows []model.MaintenanceWindow, now time.Time) (bool, string) {
+	for i := range windows {
+		open, err := maintenance.IsWindowOpen(&windows[i], now)
+		if err != nil {
+			// 窗口配置无效时按未打开处理，避免绕过变更窗口
+			log.Printf("[Executor] Invalid maintenance window: %v", err)
+		}
+		if !open {
+			return false, fmt.Sprintf("waiting for maintenance window %s", windows[i].Name)
+		}
+	}
+	return true, ""
+}
+
+// releaseWindows 查询节点所在线路分组绑定的维护窗口
+// 线路分组 -> node_group_ips -> node_ips -> 节点
+func releaseWindows(db *gorm.DB, nodeIDs []int) ([]model.MaintenanceWindow, error) {
+	var windows []model.MaintenanceWindow
+	if err := db.Model(&model.MaintenanceWindow{}).
+		Distinct("maintenance_windows.*").
+		Joins("JOIN line_groups ON line_groups.maintenance_window_id = maintenance_windows.id").
+		Joins("JOIN node_group_ips ON node_group_ips.node_group_id = line_groups.node_group_id").
+		Joins("JOIN node_ips ON node_ips.id = node_group_ips.ip_id").
+		Where("node_ips.node_id IN ? AND node_ips.enabled = ?", nodeIDs, true).
+		Order("maintenance_windows.id ASC").
+		Find(&windows).Error; err != nil {
+		return nil, fmt.Errorf("failed to query maintenance windows: %w", err)
+	}
+	return windows, nil
+}
@@ -0,0 +1,81 @@
+package release
+
+import (
+	"testing"
+	"time"
+
+	"go_cmdb/internal/model"
+
+	"github.com/glebarez/sqlite"
+	"gorm.io/gorm"
+)
+
+// newWindowTestDB: node 1 is in line group 1 (window open at 02:00-03:00 UTC, batch 1),
+// node 2 is in line group 2 (window open at 04:00-05:00 UTC, batch 2)
+func newWindowTestDB(t *testing.T) *gorm.DB {
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, stmt := range []string{
+		`CREATE TABLE release_task_nodes (id INTEGER PRIMARY KEY, release_task_id INTEGER, node_id INTEGER, batch INTEGER, status TEXT)`,
+		`CREATE TABLE maintenance_windows (id INTEGER PRIMARY KEY, name TEXT, schedule TEXT, duration_minutes INTEGER,
+			timezone TEXT, enabled BOOLEAN, description TEXT, created_at DATETIME, updated_at DATETIME)`,
+		`CREATE TABLE line_groups (id INTEGER PRIMARY KEY, node_group_id INTEGER, maintenance_window_id INTEGER)`,
+		`CREATE TABLE node_group_ips (id INTEGER PRIMARY KEY, node_group_id INTEGER, ip_id INTEGER)`,
+		`CREATE TABLE node_ips (id INTEGER PRIMARY KEY, node_id INTEGER, enabled BOOLEAN)`,
+
+		`INSERT INTO release_task_nodes VALUES (1, 1, 1, 1, 'pending'), (2, 1, 2, 2, 'pending')`,
+		`INSERT INTO maintenance_windows (id, name, schedule, duration_minutes, timezone, enabled, description) VALUES
+			(1, 'night-a', '0 2 * * *', 60, 'UTC', 1, ''), (2, 'night-b', '0 4 * * *', 60, 'UTC', 1, '')`,
+		`INSERT INTO line_groups VALUES (1, 1, 1), (2, 2, 2)`,
+		`INSERT INTO node_group_ips VALUES (1, 1, 1), (2, 2, 2)`,
+		`INSERT INTO node_ips VALUES (1, 1, 1), (2, 2, 1)`,
+	} {
+		if err := db.Exec(stmt).Error; err != nil {
+			t.Fatal(err)
+		}
+	}
+	return db
+}
+
+func TestReleaseReadyPerBatch(t *testing.T) {
+	db := newWindowTestDB(t)
+	task := &model.ReleaseTask{ID: 1}
+	now := time.Date(2026, 10, 17, 2, 30, 0, 0, time.UTC)
+
+	// Only the window of the first batch has to be open to start
+	if ready, reason, err := releaseReady(db, task, now); err != nil || !ready {
+		t.Fatalf("releaseReady() = %v, %q, %v; want ready", ready, reason, err)
+	}
+	if ready, reason, _ := batchReady(db, 1, 2, now); ready || reason != "waiting for maintenance window night-b" {
+		t.Errorf("batchReady(2) = %v, %q; want waiting for night-b", ready, reason)
+	}
+
+	// Once batch 1 is done the release waits for the window of batch 2
+	db.Exec("UPDATE release_task_nodes SET status = 'success' WHERE batch = 1")
+	if ready, _, _ := releaseReady(db, task, now); ready {
+		t.Error("expected release to wait for the window of batch 2")
+	}
+	if ready, _, _ := releaseReady(db, task, now.Add(2*time.Hour)); !ready {
+		t.Error("expected release to continue in the window of batch 2")
+	}
+}
+
+func TestWebsiteReleaseReady(t *testing.T) {
+	db := newWindowTestDB(t)
+	now := time.Date(2026, 10, 17, 2, 30, 0, 0, time.UTC)
+
+	if ready, _, err := WebsiteReleaseReady(db, &model.ReleaseTask{}, 1, now); err != nil || !ready {
+		t.Errorf("line group 1: ready = %v, err = %v; want ready", ready, err)
+	}
+	if ready, _, _ := WebsiteReleaseReady(db, &model.ReleaseTask{}, 2, now); ready {
+		t.Error("line group 2: expected to wait for its window")
+	}
+
+	later := now.Add(time.Hour)
+	if ready, reason, _ := WebsiteReleaseReady(db, &model.ReleaseTask{ScheduledAt: &later}, 1, now); ready {
+		t.Errorf("expected scheduled release to wait, reason %q", reason)
+	}
+}
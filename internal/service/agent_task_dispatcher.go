@@ -4,8 +4,11 @@ import (
 	"encoding/json"
 	"fmt"
 	"log"
+	"time"
 
+	"go_cmdb/internal/configgen"
 	"go_cmdb/internal/model"
+	"go_cmdb/internal/release"
 	"gorm.io/gorm"
 )
 
@@ -29,6 +32,8 @@ type DispatchResult struct {
 		Skipped             int
 		Failed              int
 		ErrorMsg            string
+		QueuedBehind        int64 // 被同一网站上的该发布阻塞，排队等待派发（0 表示未排队）
+		WaitingWindow       bool  // 计划时间未到或维护窗口未打开，等待派发
 	}
 
 // EnsureDispatched 确保 release_task 已派发到所有目标节点（幂等 + 补发）
@@ -46,12 +51,22 @@ func (d *AgentTaskDispatcher) EnsureDispatched(releaseTaskID int64, websiteID in
 		return nil, fmt.Errorf("unsupported targetType: %s", releaseTask.TargetType)
 	}
 
+	// 2.5. 同一网站上的其他发布未结束时排队，由 WebsiteReleaseScheduler 稍后派发
+	if queued, err := d.queueIfBlocked(&releaseTask, result); err != nil || queued {
+		return result, err
+	}
+
 	// 3. 查询 website
 	var website model.Website
 	if err := d.db.First(&website, websiteID).Error; err != nil {
 		return nil, fmt.Errorf("failed to query website: %w", err)
 	}
 
+	// 3.5. 计划时间未到或维护窗口未打开时等待
+	if waiting, err := d.holdForWindow(&releaseTask, int64(website.LineGroupID), result); err != nil || waiting {
+		return result, err
+	}
+
 	// 4. 获取目标节点
 	targetNodes, err := d.getTargetNodes(website.LineGroupID)
 	if err != nil {
@@ -78,93 +93,26 @@ func (d *AgentTaskDispatcher) EnsureDispatched(releaseTaskID int64, websiteID in
 	// 6. 标记派发已触发
 	result.DispatchTriggered = true
 
-	// 7. 查询网站域名
-	var domains []string
-	var websiteDomains []model.WebsiteDomain
-	if err := d.db.Where("website_id = ?", websiteID).Find(&websiteDomains).Error; err != nil {
-		log.Printf("[Dispatcher] Failed to query website_domains: websiteId=%d, error=%v", websiteID, err)
+	// 7-9.5. 构建 payload（域名、回源、缓存规则），配置不完整时标记失败
+	// 回滚发布下发其配置快照（上一个成功发布时的网站配置），而不是网站当前配置
+	var basePayload map[string]interface{}
+	var errMsg string
+	if releaseTask.Type == model.ReleaseTaskTypeRollbackConfig {
+		basePayload, errMsg = snapshotWebsitePayload(&releaseTask, &website, traceID)
 	} else {
-		for _, wd := range websiteDomains {
-			domains = append(domains, wd.Domain)
-		}
-	}
-
-	// 域名必填校验
-	if len(domains) == 0 {
-		errMsg := fmt.Sprintf("no domains found for websiteId=%d", websiteID)
-		result.ErrorMsg = errMsg
-		result.DispatchTriggered = false
-		d.db.Model(&releaseTask).Updates(map[string]interface{}{
-			"status":     model.ReleaseTaskStatusFailed,
-			"last_error": errMsg,
-		})
-		return result, nil
-	}
-
-	// 8. 查询 origin_set_items 获取 origins（初始化为空数组而不是 nil）
-	origins := make([]map[string]interface{}, 0)
-	if website.OriginSetID.Valid && website.OriginSetID.Int32 > 0 {
-		var originSetItems []model.OriginSetItem
-		if err := d.db.Where("origin_set_id = ?", website.OriginSetID.Int32).Find(&originSetItems).Error; err != nil {
-			log.Printf("[Dispatcher] Failed to query origin_set_items: originSetId=%d, error=%v", website.OriginSetID.Int32, err)
-		} else {
-			for _, item := range originSetItems {
-				var snapshotData map[string]interface{}
-				if err := json.Unmarshal([]byte(item.SnapshotJSON), &snapshotData); err != nil {
-					log.Printf("[Dispatcher] Failed to unmarshal snapshot_json: id=%d, error=%v", item.ID, err)
-					continue
-				}
-				// 提取 addresses 数组
-				if addresses, ok := snapshotData["addresses"].([]interface{}); ok {
-					for _, addr := range addresses {
-						if addrMap, ok := addr.(map[string]interface{}); ok {
-							// 只保留稳定字段
-							origin := map[string]interface{}{
-								"address":  addrMap["address"],
-								"role":     addrMap["role"],
-								"weight":   addrMap["weight"],
-								"enabled":  addrMap["enabled"],
-								"protocol": addrMap["protocol"],
-							}
-							origins = append(origins, origin)
-						}
-					}
-				}
-			}
-		}
+		basePayload, errMsg = d.buildWebsitePayload(&website, releaseTaskID, traceID)
 	}
-
-	// 9. group 模式下 origins 必填校验
-	if website.OriginMode == "group" && len(origins) == 0 {
-		errMsg := fmt.Sprintf("no origins found for group mode: websiteId=%d, originSetId=%d", websiteID, website.OriginSetID.Int32)
+	if errMsg != "" {
 		result.ErrorMsg = errMsg
 		result.DispatchTriggered = false
 		d.db.Model(&releaseTask).Updates(map[string]interface{}{
 			"status":     model.ReleaseTaskStatusFailed,
 			"last_error": errMsg,
 		})
+		release.PublishReleaseFinished(d.db, releaseTaskID)
 		return result, nil
 	}
 
-	// 9.5. 查询缓存规则项（仅当 originMode != redirect 且绑定了 cacheRuleId 时）
-	var cacheItems []map[string]interface{}
-	if website.OriginMode != "redirect" && website.CacheRuleID.Valid && website.CacheRuleID.Int32 > 0 {
-		var cacheRuleItems []model.CacheRuleItem
-		if err := d.db.Where("cache_rule_id = ? AND enabled = ?", website.CacheRuleID.Int32, true).Find(&cacheRuleItems).Error; err != nil {
-			log.Printf("[Dispatcher] Failed to query cache_rule_items: cacheRuleId=%d, error=%v", website.CacheRuleID.Int32, err)
-		} else {
-			for _, item := range cacheRuleItems {
-				cacheItem := map[string]interface{}{
-					"matchType":  item.MatchType,
-					"matchValue": item.MatchValue,
-					"mode":       item.Mode,
-					"ttlSeconds": item.TTLSeconds,
-				}
-				cacheItems = append(cacheItems, cacheItem)
-			}
-		}
-	}
-
 	// 10. 为每个节点创建 agent_task（幂等）
 	for _, nodeID := range targetNodes {
 		idKey := fmt.Sprintf("release-%d-node-%d", releaseTaskID, nodeID)
@@ -191,48 +139,12 @@ func (d *AgentTaskDispatcher) EnsureDispatched(releaseTaskID int64, websiteID in
 				continue
 			}
 
-			// 构建 payload（根据 originMode 构建不同结构）
-			payload := map[string]interface{}{
-				"idKey":         idKey,
-				"releaseTaskId": releaseTaskID,
-				"websiteId":     websiteID,
-				"lineGroupId":   website.LineGroupID,
-				"originMode":    website.OriginMode,
-				"type":          "applyConfig",
-				"traceId":       traceID,
-				"reload":        true,
-				"domains":       domains,
-			}
-
-			// 根据 originMode 添加特定字段
-			switch website.OriginMode {
-			case "group":
-				if website.OriginGroupID.Valid {
-					payload["originGroupId"] = website.OriginGroupID.Int32
-				}
-				if website.OriginSetID.Valid {
-					payload["originSetId"] = website.OriginSetID.Int32
-				}
-				payload["origins"] = map[string]interface{}{
-					"items": origins,
-				}
-			case "redirect":
-				payload["redirectUrl"] = website.RedirectURL
-				payload["redirectStatusCode"] = website.RedirectStatusCode
-				// redirect 模式不添加 cacheItems（强制不缓存）
-			case "manual":
-				if website.OriginSetID.Valid {
-					payload["originSetId"] = website.OriginSetID.Int32
-				}
-				payload["origins"] = map[string]interface{}{
-					"items": origins,
-				}
-			}
-
-			// 添加缓存规则项（仅当 originMode != redirect 且 cacheItems 非空时）
-			if website.OriginMode != "redirect" && len(cacheItems) > 0 {
-				payload["cacheItems"] = cacheItems
+			// 构建 payload（每个节点带上自己的 idKey）
+			payload := make(map[string]interface{}, len(basePayload)+1)
+			for k, v := range basePayload {
+				payload[k] = v
 			}
+			payload["idKey"] = idKey
 			payloadBytes, err := json.Marshal(payload)
 			if err != nil {
 				result.Failed++
@@ -275,6 +187,7 @@ func (d *AgentTaskDispatcher) EnsureDispatched(releaseTaskID int64, websiteID in
 			"status":     model.ReleaseTaskStatusFailed,
 			"last_error": result.ErrorMsg,
 		})
+		release.PublishReleaseFinished(d.db, releaseTaskID)
 		return result, fmt.Errorf("dispatch failed: %s", result.ErrorMsg)
 	}
 
@@ -284,6 +197,278 @@ func (d *AgentTaskDispatcher) EnsureDispatched(releaseTaskID int64, websiteID in
 	return result, nil
 }
 
+// queueIfBlocked 首次派发前检查同一网站上是否有阻塞的发布，有则保持 pending 并记录原因
+// 已派发过（total_nodes > 0）的任务是补发，不排队
+func (d *AgentTaskDispatcher) queueIfBlocked(releaseTask *model.ReleaseTask, result *DispatchResult) (bool, error) {
+	if releaseTask.TotalNodes > 0 {
+		return false, nil
+	}
+
+	blockerID, err := releaseBlocker(d.db, releaseTask)
+	if err != nil {
+		return false, err
+	}
+	if blockerID == 0 {
+		return false, nil
+	}
+
+	result.QueuedBehind = blockerID
+	result.ErrorMsg = fmt.Sprintf("queued behind release_task %d", blockerID)
+	if err := d.db.Model(releaseTask).Update("last_error", result.ErrorMsg).Error; err != nil {
+		return false, fmt.Errorf("failed to update release_task: %w", err)
+	}
+	log.Printf("[Dispatcher] release_task %d of website %d queued behind release_task %d", releaseTask.ID, releaseTask.TargetID, blockerID)
+	return true, nil
+}
+
+// holdForWindow 首次派发前检查计划时间和网站所在线路分组的维护窗口
+// 未就绪时转为 waiting_window，由 WebsiteReleaseScheduler 在就绪后派发；就绪时恢复为 pending
+func (d *AgentTaskDispatcher) holdForWindow(releaseTask *model.ReleaseTask, lineGroupID int64, result *DispatchResult) (bool, error) {
+	if releaseTask.TotalNodes > 0 {
+		return false, nil
+	}
+
+	ready, reason, err := release.WebsiteReleaseReady(d.db, releaseTask, lineGroupID, time.Now())
+	if err != nil {
+		return false, err
+	}
+
+	if ready {
+		if releaseTask.Status == model.ReleaseTaskStatusWaitingWindow {
+			if err := d.db.Model(&model.ReleaseTask{}).
+				Where("id = ? AND status = ?", releaseTask.ID, model.ReleaseTaskStatusWaitingWindow).
+				Updates(map[string]interface{}{
+					"status":     model.ReleaseTaskStatusPending,
+					"last_error": nil,
+				}).Error; err != nil {
+				return false, fmt.Errorf("failed to update release_task: %w", err)
+			}
+			releaseTask.Status = model.ReleaseTaskStatusPending
+		}
+		return false, nil
+	}
+
+	result.WaitingWindow = true
+	result.ErrorMsg = reason
+	if err := d.db.Model(&model.ReleaseTask{}).
+		Where("id = ? AND status IN ?", releaseTask.ID, []model.ReleaseTaskStatus{
+			model.ReleaseTaskStatusPending,
+			model.ReleaseTaskStatusWaitingWindow,
+		}).
+		Updates(map[string]interface{}{
+			"status":     model.ReleaseTaskStatusWaitingWindow,
+			"last_error": reason,
+		}).Error; err != nil {
+		return false, fmt.Errorf("failed to update release_task: %w", err)
+	}
+	log.Printf("[Dispatcher] release_task %d of website %d is waiting: %s", releaseTask.ID, releaseTask.TargetID, reason)
+	if releaseTask.Status != model.ReleaseTaskStatusWaitingWindow {
+		release.PublishReleaseWaitingWindow(d.db, releaseTask.ID, reason)
+	}
+	return true, nil
+}
+
+// buildWebsitePayload 构建网站的 agent_task payload（不含 idKey），EnsureDispatched 与 dry-run 共用
+// errMsg 非空表示网站配置不完整（无域名、group 模式无回源），不能派发
+func (d *AgentTaskDispatcher) buildWebsitePayload(website *model.Website, releaseTaskID int64, traceID string) (map[string]interface{}, string) {
+	websiteID := int64(website.ID)
+
+	// 7. 查询网站域名
+	var domains []string
+	var websiteDomains []model.WebsiteDomain
+	if err := d.db.Where("website_id = ?", websiteID).Find(&websiteDomains).Error; err != nil {
+		log.Printf("[Dispatcher] Failed to query website_domains: websiteId=%d, error=%v", websiteID, err)
+	} else {
+		for _, wd := range websiteDomains {
+			domains = append(domains, wd.Domain)
+		}
+	}
+
+	// 域名必填校验
+	if len(domains) == 0 {
+		return nil, fmt.Sprintf("no domains found for websiteId=%d", websiteID)
+	}
+
+	// 8. 查询 origin_set_items 获取 origins（初始化为空数组而不是 nil）
+	origins := make([]map[string]interface{}, 0)
+	if website.OriginSetID.Valid && website.OriginSetID.Int32 > 0 {
+		var originSetItems []model.OriginSetItem
+		if err := d.db.Where("origin_set_id = ?", website.OriginSetID.Int32).Find(&originSetItems).Error; err != nil {
+			log.Printf("[Dispatcher] Failed to query origin_set_items: originSetId=%d, error=%v", website.OriginSetID.Int32, err)
+		} else {
+			for _, item := range originSetItems {
+				var snapshotData map[string]interface{}
+				if err := json.Unmarshal([]byte(item.SnapshotJSON), &snapshotData); err != nil {
+					log.Printf("[Dispatcher] Failed to unmarshal snapshot_json: id=%d, error=%v", item.ID, err)
+					continue
+				}
+				// 提取 addresses 数组
+				if addresses, ok := snapshotData["addresses"].([]interface{}); ok {
+					for _, addr := range addresses {
+						if addrMap, ok := addr.(map[string]interface{}); ok {
+							// 只保留稳定字段
+							origin := map[string]interface{}{
+								"address":  addrMap["address"],
+								"role":     addrMap["role"],
+								"weight":   addrMap["weight"],
+								"enabled":  addrMap["enabled"],
+								"protocol": addrMap["protocol"],
+							}
+							origins = append(origins, origin)
+						}
+					}
+				}
+			}
+		}
+	}
+
+	// 9. group 模式下 origins 必填校验
+	if website.OriginMode == "group" && len(origins) == 0 {
+		return nil, fmt.Sprintf("no origins found for group mode: websiteId=%d, originSetId=%d", websiteID, website.OriginSetID.Int32)
+	}
+
+	// 9.5. 查询缓存规则项（仅当 originMode != redirect 且绑定了 cacheRuleId 时）
+	var cacheItems []map[string]interface{}
+	if website.OriginMode != "redirect" && website.CacheRuleID.Valid && website.CacheRuleID.Int32 > 0 {
+		var cacheRuleItems []model.CacheRuleItem
+		if err := d.db.Where("cache_rule_id = ? AND enabled = ?", website.CacheRuleID.Int32, true).Find(&cacheRuleItems).Error; err != nil {
+			log.Printf("[Dispatcher] Failed to query cache_rule_items: cacheRuleId=%d, error=%v", website.CacheRuleID.Int32, err)
+		} else {
+			for _, item := range cacheRuleItems {
+				cacheItem := map[string]interface{}{
+					"matchType":  item.MatchType,
+					"matchValue": item.MatchValue,
+					"mode":       item.Mode,
+					"ttlSeconds": item.TTLSeconds,
+				}
+				cacheItems = append(cacheItems, cacheItem)
+			}
+		}
+	}
+
+
+	// 构建 payload（根据 originMode 构建不同结构）
+	payload := map[string]interface{}{
+		"releaseTaskId": releaseTaskID,
+		"websiteId":     websiteID,
+		"lineGroupId":   website.LineGroupID,
+		"originMode":    website.OriginMode,
+		"type":          "applyConfig",
+		"traceId":       traceID,
+		"reload":        true,
+		"domains":       domains,
+	}
+
+	// 根据 originMode 添加特定字段
+	switch website.OriginMode {
+	case "group":
+		if website.OriginGroupID.Valid {
+			payload["originGroupId"] = website.OriginGroupID.Int32
+		}
+		if website.OriginSetID.Valid {
+			payload["originSetId"] = website.OriginSetID.Int32
+		}
+		payload["origins"] = map[string]interface{}{
+			"items": origins,
+		}
+	case "redirect":
+		payload["redirectUrl"] = website.RedirectURL
+		payload["redirectStatusCode"] = website.RedirectStatusCode
+		// redirect 模式不添加 cacheItems（强制不缓存）
+	case "manual":
+		if website.OriginSetID.Valid {
+			payload["originSetId"] = website.OriginSetID.Int32
+		}
+		payload["origins"] = map[string]interface{}{
+			"items": origins,
+		}
+	}
+
+	// 添加缓存规则项（仅当 originMode != redirect 且 cacheItems 非空时）
+	if website.OriginMode != "redirect" && len(cacheItems) > 0 {
+		payload["cacheItems"] = cacheItems
+	}
+
+	return payload, ""
+}
+
+// snapshotWebsitePayload 按回滚发布的配置快照构建网站的 agent_task payload（结构同 buildWebsitePayload）
+// errMsg 非空表示快照中没有可下发的网站配置
+func snapshotWebsitePayload(releaseTask *model.ReleaseTask, website *model.Website, traceID string) (map[string]interface{}, string) {
+	if releaseTask.ConfigSnapshot == nil {
+		return nil, fmt.Sprintf("rollback release_task %d has no config snapshot", releaseTask.ID)
+	}
+	configs, err := configgen.ParseSnapshot(*releaseTask.ConfigSnapshot)
+	if err != nil {
+		return nil, fmt.Sprintf("invalid config snapshot of release_task %d: %v", releaseTask.ID, err)
+	}
+	var config *configgen.WebsiteConfig
+	for i := range configs {
+		if int64(configs[i].WebsiteID) == releaseTask.TargetID {
+			config = &configs[i]
+			break
+		}
+	}
+	if config == nil {
+		return nil, fmt.Sprintf("websiteId=%d not found in config snapshot of release_task %d", releaseTask.TargetID, releaseTask.ID)
+	}
+
+	domains := make([]string, 0, len(config.Domains))
+	for _, domain := range config.Domains {
+		domains = append(domains, domain.Domain)
+	}
+	if len(domains) == 0 {
+		return nil, fmt.Sprintf("no domains found in config snapshot for websiteId=%d", releaseTask.TargetID)
+	}
+
+	payload := map[string]interface{}{
+		"releaseTaskId": releaseTask.ID,
+		"websiteId":     releaseTask.TargetID,
+		"lineGroupId":   website.LineGroupID,
+		"originMode":    config.Origin.Mode,
+		"type":          "applyConfig",
+		"traceId":       traceID,
+		"reload":        true,
+		"domains":       domains,
+	}
+
+	// redirect 模式只有跳转配置（强制不缓存）
+	if config.Origin.Mode == "redirect" {
+		payload["redirectUrl"] = config.Origin.RedirectURL
+		payload["redirectStatusCode"] = config.Origin.RedirectStatusCode
+		return payload, ""
+	}
+
+	origins := make([]map[string]interface{}, 0, len(config.Origin.Addresses))
+	for _, addr := range config.Origin.Addresses {
+		origins = append(origins, map[string]interface{}{
+			"address":  addr.Address,
+			"role":     addr.Role,
+			"weight":   addr.Weight,
+			"enabled":  addr.Enabled,
+			"protocol": addr.Protocol,
+		})
+	}
+	payload["origins"] = map[string]interface{}{
+		"items": origins,
+	}
+
+	if len(config.CacheItems) > 0 {
+		cacheItems := make([]map[string]interface{}, 0, len(config.CacheItems))
+		for _, item := range config.CacheItems {
+			cacheItems = append(cacheItems, map[string]interface{}{
+				"matchType":  item.MatchType,
+				"matchValue": item.MatchValue,
+				"mode":       item.Mode,
+				"ttlSeconds": item.TTLSeconds,
+			})
+		}
+		payload["cacheItems"] = cacheItems
+	}
+
+	return payload, ""
+}
+
 // getTargetNodes 获取目标节点列表（去重）
 func (d *AgentTaskDispatcher) getTargetNodes(lineGroupID int) ([]int, error) {
 	// 查询链路：website.lineGroupId -> line_groups.node_group_id -> node_group_ips.ip_id -> node_ips.node_id -> nodes
@@ -17,6 +17,16 @@ func (d *AgentTaskDispatcher) EnsureDispatchedForDelete(releaseTaskID int64, web
 		return nil, fmt.Errorf("failed to query release_task: %w", err)
 	}
 
+	// 1.5. 同一网站上的其他发布未结束时排队
+	if queued, err := d.queueIfBlocked(&releaseTask, result); err != nil || queued {
+		return result, err
+	}
+
+	// 1.6. 计划时间未到或维护窗口未打开时等待
+	if waiting, err := d.holdForWindow(&releaseTask, lineGroupID, result); err != nil || waiting {
+		return result, err
+	}
+
 	// 2. 获取目标节点
 	targetNodes, err := d.getTargetNodes(int(lineGroupID))
 	if err != nil {
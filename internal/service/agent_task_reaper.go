@@ -0,0 +1,219 @@
+package service
+
+import (
+	"fmt"
+	"log"
+	"time"
+
+	"go_cmdb/internal/model"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// reaperBatchSize is the maximum number of stuck tasks handled per tick
+const reaperBatchSize = 100
+
+// AgentTaskReaperConfig defines agent task reaper configuration
+type AgentTaskReaperConfig struct {
+	Enabled        bool
+	IntervalSec    int
+	DefaultTimeout time.Duration            // Timeout of task types without an entry in Timeouts
+	Timeouts       map[string]time.Duration // Per task type timeout, counted from the last status change
+}
+
+// timeoutFor returns the running timeout of a task type
+func (c AgentTaskReaperConfig) timeoutFor(taskType string) time.Duration {
+	if timeout, ok := c.Timeouts[taskType]; ok && timeout > 0 {
+		return timeout
+	}
+	return c.DefaultTimeout
+}
+
+// minTimeout returns the shortest configured timeout
+func (c AgentTaskReaperConfig) minTimeout() time.Duration {
+	shortest := c.DefaultTimeout
+	for _, timeout := range c.Timeouts {
+		if timeout > 0 && timeout < shortest {
+			shortest = timeout
+		}
+	}
+	return shortest
+}
+
+// AgentTaskReaper times out agent tasks stuck in running, e.g. when the agent
+// crashed after pulling them. Reaped tasks count as a failed attempt, so they are
+// requeued with backoff or dead-lettered once the retry attempts are exhausted.
+// Retries nobody claims within the timeout after they became due are dead-lettered,
+// so the release of a gone agent settles instead of waiting forever.
+type AgentTaskReaper struct {
+	db          *gorm.DB
+	config      AgentTaskReaperConfig
+	stopChan    chan struct{}
+	stoppedChan chan struct{}
+}
+
+// NewAgentTaskReaper creates a new agent task reaper
+func NewAgentTaskReaper(db *gorm.DB, config AgentTaskReaperConfig) *AgentTaskReaper {
+	return &AgentTaskReaper{
+		db:          db,
+		config:      config,
+		stopChan:    make(chan struct{}),
+		stoppedChan: make(chan struct{}),
+	}
+}
+
+// Start starts the reaper
+func (r *AgentTaskReaper) Start() {
+	if !r.config.Enabled {
+		log.Println("[Agent Task Reaper] Disabled, skipping")
+		close(r.stoppedChan)
+		return
+	}
+
+	log.Printf("[Agent Task Reaper] Starting with interval=%ds, default_timeout=%s\n", r.config.IntervalSec, r.config.DefaultTimeout)
+
+	go r.run()
+}
+
+// Stop stops the reaper
+func (r *AgentTaskReaper) Stop() {
+	if !r.config.Enabled {
+		return
+	}
+
+	log.Println("[Agent Task Reaper] Stopping...")
+	close(r.stopChan)
+	<-r.stoppedChan
+	log.Println("[Agent Task Reaper] Stopped")
+}
+
+// run is the main reaper loop
+func (r *AgentTaskReaper) run() {
+	defer close(r.stoppedChan)
+
+	ticker := time.NewTicker(time.Duration(r.config.IntervalSec) * time.Second)
+	defer ticker.Stop()
+
+	// Run immediately on start
+	r.tick()
+
+	for {
+		select {
+		case <-ticker.C:
+			r.tick()
+		case <-r.stopChan:
+			return
+		}
+	}
+}
+
+// tick reaps running tasks that exceeded the timeout of their type
+func (r *AgentTaskReaper) tick() {
+	now := time.Now()
+
+	// Candidates: running longer than the shortest timeout, filtered per type below
+	var tasks []model.AgentTask
+	if err := r.db.
+		Where("status = ? AND updated_at < ?", model.TaskStatusRunning, now.Add(-r.config.minTimeout())).
+		Order("updated_at ASC").
+		Limit(reaperBatchSize).
+		Find(&tasks).Error; err != nil {
+		log.Printf("[Agent Task Reaper] Failed to query running tasks: %v\n", err)
+		return
+	}
+
+	reaped := 0
+	for i := range tasks {
+		timeout := r.config.timeoutFor(tasks[i].Type)
+		if now.Sub(tasks[i].UpdatedAt) < timeout {
+			continue
+		}
+		ok, err := r.reap(tasks[i].ID, now.Add(-timeout), timeout)
+		if err != nil {
+			log.Printf("[Agent Task Reaper] Failed to reap task %d: %v\n", tasks[i].ID, err)
+			continue
+		}
+		if ok {
+			reaped++
+		}
+	}
+
+	if reaped > 0 {
+		log.Printf("[Agent Task Reaper] Reaped %d stuck tasks\n", reaped)
+	}
+
+	r.expireRetries(now)
+}
+
+// expireRetries dead-letters retrying tasks not claimed within the timeout of their type after the retry was due
+func (r *AgentTaskReaper) expireRetries(now time.Time) {
+	var tasks []model.AgentTask
+	if err := r.db.
+		Where("status = ? AND next_retry_at < ?", model.TaskStatusRetrying, now.Add(-r.config.minTimeout())).
+		Order("next_retry_at ASC").
+		Limit(reaperBatchSize).
+		Find(&tasks).Error; err != nil {
+		log.Printf("[Agent Task Reaper] Failed to query retrying tasks: %v\n", err)
+		return
+	}
+
+	expired := 0
+	for i := range tasks {
+		timeout := r.config.timeoutFor(tasks[i].Type)
+		if tasks[i].NextRetryAt == nil || now.Sub(*tasks[i].NextRetryAt) < timeout {
+			continue
+		}
+		ok, err := r.reap(tasks[i].ID, now.Add(-timeout), timeout)
+		if err != nil {
+			log.Printf("[Agent Task Reaper] Failed to dead-letter task %d: %v\n", tasks[i].ID, err)
+			continue
+		}
+		if ok {
+			expired++
+		}
+	}
+
+	if expired > 0 {
+		log.Printf("[Agent Task Reaper] Dead-lettered %d unclaimed retries\n", expired)
+	}
+}
+
+// reap settles a single stuck task, unless it changed since it was selected:
+// a task running since before cutoff fails its attempt, a retry due before cutoff is dead-lettered
+func (r *AgentTaskReaper) reap(taskID int, cutoff time.Time, timeout time.Duration) (bool, error) {
+	reaped := false
+	var settled *releaseSettlement
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		var task model.AgentTask
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&task, taskID).Error; err != nil {
+			return err
+		}
+
+		var status, reason string
+		switch {
+		case task.Status == model.TaskStatusRunning && task.UpdatedAt.Before(cutoff):
+			status = model.TaskStatusFailed
+			reason = fmt.Sprintf("reaped: no status report within %s after the task started running (attempt %d)", timeout, task.Attempts)
+		case task.Status == model.TaskStatusRetrying && task.NextRetryAt != nil && task.NextRetryAt.Before(cutoff):
+			status = model.TaskStatusDead
+			reason = fmt.Sprintf("reaped: retry not claimed within %s after it was due (attempt %d)", timeout, task.Attempts)
+		default:
+			return nil
+		}
+
+		log.Printf("[Agent Task Reaper] Task %d (type=%s, node=%d) %s\n", task.ID, task.Type, task.NodeID, reason)
+		var err error
+		if settled, err = applyTaskResult(tx, &task, status, reason); err != nil {
+			return err
+		}
+		reaped = true
+		return nil
+	})
+	if err != nil {
+		return false, err
+	}
+
+	settled.publish(r.db)
+	return reaped, nil
+}
@@ -0,0 +1,148 @@
+package service
+
+import (
+	"database/sql/driver"
+	"testing"
+	"time"
+
+	"go_cmdb/internal/agent"
+	"go_cmdb/internal/model"
+
+	gosqlite "github.com/glebarez/go-sqlite"
+	"github.com/glebarez/sqlite"
+	"gorm.io/gorm"
+)
+
+func init() {
+	// applyTaskResult stamps updated_at with MySQL NOW()
+	gosqlite.MustRegisterScalarFunction("NOW", 0, func(*gosqlite.FunctionContext, []driver.Value) (driver.Value, error) {
+		return time.Now().UTC().Format("2006-01-02 15:04:05.999"), nil
+	})
+}
+
+func TestReaperConfigTimeouts(t *testing.T) {
+	c := AgentTaskReaperConfig{
+		DefaultTimeout: 5 * time.Minute,
+		Timeouts: map[string]time.Duration{
+			model.TaskTypeReload:      2 * time.Minute,
+			model.TaskTypeApplyConfig: 10 * time.Minute,
+			model.TaskTypePurgeCache:  0, // unset, falls back to the default
+		},
+	}
+
+	tests := map[string]time.Duration{
+		model.TaskTypeReload:         2 * time.Minute,
+		model.TaskTypeApplyConfig:    10 * time.Minute,
+		model.TaskTypePurgeCache:     5 * time.Minute,
+		model.TaskTypeRollbackConfig: 5 * time.Minute,
+	}
+	for taskType, want := range tests {
+		if got := c.timeoutFor(taskType); got != want {
+			t.Errorf("timeoutFor(%s) = %s, want %s", taskType, got, want)
+		}
+	}
+
+	if got := c.minTimeout(); got != 2*time.Minute {
+		t.Errorf("minTimeout() = %s, want 2m", got)
+	}
+	if got := (AgentTaskReaperConfig{DefaultTimeout: time.Minute}).minTimeout(); got != time.Minute {
+		t.Errorf("minTimeout() without per type timeouts = %s, want 1m", got)
+	}
+}
+
+func TestReap(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	agent.SetRetryPolicy(agent.RetryPolicy{MaxAttempts: 3})
+	defer agent.SetRetryPolicy(agent.DefaultRetryPolicy)
+
+	now := time.Now()
+	// MySQL enum/json columns do not migrate on sqlite, create the tables by hand
+	for _, stmt := range []string{
+		`CREATE TABLE agent_tasks (id INTEGER PRIMARY KEY, node_id INTEGER, type TEXT, payload TEXT, status TEXT, last_error TEXT,
+			attempts INTEGER NOT NULL DEFAULT 0, next_retry_at DATETIME, pushed BOOLEAN NOT NULL DEFAULT 0, created_at DATETIME, updated_at DATETIME)`,
+		`CREATE TABLE release_tasks (id INTEGER PRIMARY KEY, version INTEGER, status TEXT, total_nodes INTEGER NOT NULL DEFAULT 0,
+			success_nodes INTEGER NOT NULL DEFAULT 0, failed_nodes INTEGER NOT NULL DEFAULT 0, last_error TEXT, created_at DATETIME, updated_at DATETIME)`,
+		`CREATE TABLE release_task_nodes (id INTEGER PRIMARY KEY, release_task_id INTEGER, node_id INTEGER, batch INTEGER, status TEXT,
+			error_msg TEXT, finished_at DATETIME, updated_at DATETIME)`,
+		`INSERT INTO release_tasks (id, version, status, total_nodes) VALUES (1, 1, 'pending', 2)`,
+		`INSERT INTO release_task_nodes (id, release_task_id, node_id, batch, status) VALUES (1, 1, 1, 1, 'running'), (2, 1, 2, 1, 'running')`,
+	} {
+		if err := db.Exec(stmt).Error; err != nil {
+			t.Fatal(err)
+		}
+	}
+	nextRetry := now.Add(-10 * time.Minute)
+	// 1: running for 10 minutes; 2: running for 1 minute; 3: retry due 10 minutes ago
+	for _, task := range []model.AgentTask{
+		{BaseModel: model.BaseModel{ID: 1}, NodeID: 1, Type: model.TaskTypeApplyConfig,
+			Payload: `{"releaseTaskId":1}`, Status: model.TaskStatusRunning, Attempts: 1},
+		{BaseModel: model.BaseModel{ID: 2}, NodeID: 3, Type: model.TaskTypeReload,
+			Payload: `{}`, Status: model.TaskStatusRunning, Attempts: 1},
+		{BaseModel: model.BaseModel{ID: 3}, NodeID: 2, Type: model.TaskTypeApplyConfig,
+			Payload: `{"releaseTaskId":1}`, Status: model.TaskStatusRetrying, Attempts: 1, NextRetryAt: &nextRetry},
+	} {
+		if err := db.Create(&task).Error; err != nil {
+			t.Fatal(err)
+		}
+	}
+	// updated_at is the time of the last status change
+	db.Exec("UPDATE agent_tasks SET updated_at = ? WHERE id = 1", now.Add(-10*time.Minute))
+	db.Exec("UPDATE agent_tasks SET updated_at = ? WHERE id = 2", now.Add(-time.Minute))
+
+	timeout := 5 * time.Minute
+	r := NewAgentTaskReaper(db, AgentTaskReaperConfig{DefaultTimeout: timeout})
+	cutoff := now.Add(-timeout)
+
+	status := func(id int) string {
+		t.Helper()
+		var task model.AgentTask
+		if err := db.First(&task, id).Error; err != nil {
+			t.Fatal(err)
+		}
+		return task.Status
+	}
+
+	// Stuck running task fails its attempt and is retried, the release keeps waiting
+	if ok, err := r.reap(1, cutoff, timeout); err != nil || !ok {
+		t.Fatalf("reap(1) = %v, %v", ok, err)
+	}
+	if got := status(1); got != model.TaskStatusRetrying {
+		t.Errorf("task 1 status = %s, want retrying", got)
+	}
+
+	// A task still within its timeout is left alone
+	if ok, err := r.reap(2, cutoff, timeout); err != nil || ok {
+		t.Fatalf("reap(2) = %v, %v; want not reaped", ok, err)
+	}
+	if got := status(2); got != model.TaskStatusRunning {
+		t.Errorf("task 2 status = %s, want running", got)
+	}
+
+	// A retry nobody claimed is dead-lettered and settles its node
+	if ok, err := r.reap(3, cutoff, timeout); err != nil || !ok {
+		t.Fatalf("reap(3) = %v, %v", ok, err)
+	}
+	if got := status(3); got != model.TaskStatusDead {
+		t.Errorf("task 3 status = %s, want dead", got)
+	}
+	var node model.ReleaseTaskNode
+	db.First(&node, 2)
+	if node.Status != model.ReleaseTaskNodeStatusFailed {
+		t.Errorf("node 2 status = %s, want failed", node.Status)
+	}
+
+	// Dead-lettering the retry of task 1 as well completes the release as failed
+	db.Exec("UPDATE agent_tasks SET next_retry_at = ? WHERE id = 1", nextRetry)
+	r.expireRetries(now)
+	if got := status(1); got != model.TaskStatusDead {
+		t.Errorf("task 1 status = %s, want dead", got)
+	}
+	var release model.ReleaseTask
+	db.First(&release, 1)
+	if release.Status != model.ReleaseTaskStatusFailed || release.FailedNodes != 2 {
+		t.Errorf("release status = %s, failed nodes = %d; want failed, 2", release.Status, release.FailedNodes)
+	}
+}
@@ -4,11 +4,15 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"go_cmdb/internal/agent"
 	"go_cmdb/internal/db"
+	"go_cmdb/internal/httpx"
 	"go_cmdb/internal/model"
+	"go_cmdb/internal/release"
 	"gorm.io/gorm"
 	"gorm.io/gorm/clause"
 	"log"
+	"time"
 )
 
 // AgentTaskPayload defines the structure of the JSON payload in an agent task.
@@ -18,19 +22,25 @@ type AgentTaskPayload struct {
 
 // UpdateTaskStatus handles the entire logic of updating an agent task status
 // and propagating the result to the parent release task.
-func UpdateTaskStatus(nodeID, taskID uint, apiStatus, errorMessage string) error {
-	return db.DB.Transaction(func(tx *gorm.DB) error {
+// attempts is the attempt the agent executed; a report for an attempt that was reaped
+// or reclaimed since is rejected so it cannot settle the newer claim. 0 skips the check.
+func UpdateTaskStatus(nodeID, taskID uint, attempts int, apiStatus, errorMessage string) error {
+	var settled *releaseSettlement
+	err := db.DB.Transaction(func(tx *gorm.DB) error {
 		// 1. Find the agent task and validate its ownership and current state.
 		var agentTask model.AgentTask
 		if err := tx.Where("id = ? AND node_id = ?", taskID, nodeID).First(&agentTask).Error; err != nil {
 			if errors.Is(err, gorm.ErrRecordNotFound) {
-				return fmt.Errorf("task not found or not assigned to this node")
+				return httpx.ErrNotFound("task not found or not assigned to this node")
 			}
 			return err
 		}
 
 		if agentTask.Status != "running" {
-			return fmt.Errorf("task is not in a running state, current status: %s", agentTask.Status)
+			return httpx.ErrStateConflict(fmt.Sprintf("task is not in a running state, current status: %s", agentTask.Status))
+		}
+		if attempts > 0 && attempts != agentTask.Attempts {
+			return httpx.ErrStateConflict(fmt.Sprintf("report is for attempt %d, task is on attempt %d", attempts, agentTask.Attempts))
 		}
 
 		// 2. Update the agent_task status.
@@ -39,67 +49,172 @@ func UpdateTaskStatus(nodeID, taskID uint, apiStatus, errorMessage string) error
 		    dbStatus = "success"
 		}
 
-		updateData := map[string]interface{}{
-			"status":     dbStatus,
-			"updated_at": gorm.Expr("NOW()"),
-		}
-		if dbStatus == "failed" {
-			updateData["last_error"] = errorMessage
-		}
+		var err error
+		settled, err = applyTaskResult(tx, &agentTask, dbStatus, errorMessage)
+		return err
+	})
+	if err != nil {
+		return err
+	}
 
-		if err := tx.Model(&agentTask).Updates(updateData).Error; err != nil {
-			return err
-		}
+	settled.publish(db.DB)
+	return nil
+}
 
-		// 3. Propagate the result to the release_task.
-		var payload AgentTaskPayload
-		if err := json.Unmarshal([]byte(agentTask.Payload), &payload); err != nil {
-			log.Printf("[Error] Failed to unmarshal agent task payload for task %d: %v", agentTask.ID, err)
-			return fmt.Errorf("invalid task payload")
-		}
+// releaseSettlement is what a final agent task result changed on its release,
+// published as release events once the transaction has committed.
+type releaseSettlement struct {
+	releaseID  int64
+	nodeID     int
+	nodeStatus model.ReleaseTaskNodeStatus
+	errorMsg   string
+	finished   bool
+	rollback   *model.ReleaseTask // created when the release failed beyond its failure budget
+}
 
-		if payload.ReleaseTaskID == 0 {
-			log.Printf("[Info] No releaseTaskId in payload for agent task %d. Skipping release task update.", agentTask.ID)
-			return nil // Not every agent task belongs to a release task.
+// publish pushes the node event and, when the release is complete, the finished event.
+// A rollback release created for the release is announced and dispatched here as well.
+func (s *releaseSettlement) publish(db *gorm.DB) {
+	if s == nil {
+		return
+	}
+	release.PublishReleaseNode(s.releaseID, s.nodeID, s.nodeStatus, s.errorMsg)
+	if s.finished {
+		release.PublishReleaseFinished(db, s.releaseID)
+	}
+	if s.rollback != nil {
+		release.PublishReleaseCreated(db, s.rollback.ID, "")
+		// A rollback that cannot be dispatched now stays pending for the WebsiteReleaseScheduler
+		if _, err := NewWebsiteReleaseService(db).dispatchRelease(s.rollback, fmt.Sprintf("release_rollback_%d", s.rollback.ID)); err != nil {
+			log.Printf("[Error] Failed to dispatch rollback release %d of release %d: %v", s.rollback.ID, s.releaseID, err)
 		}
+	}
+}
 
-		// Lock the release_task row for atomic update.
-		var releaseTask model.ReleaseTask
-		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&releaseTask, payload.ReleaseTaskID).Error; err != nil {
-			return fmt.Errorf("failed to find and lock release task %d: %w", payload.ReleaseTaskID, err)
+// applyTaskResult records the result of a running agent task and propagates
+// final results (success or dead) to the linked release_task and release_task_node.
+// The returned settlement is nil when no release was touched.
+func applyTaskResult(tx *gorm.DB, agentTask *model.AgentTask, dbStatus, errorMessage string) (*releaseSettlement, error) {
+	// Failures are retried with exponential backoff until dead-lettered.
+	var nextRetryAt *time.Time
+	if dbStatus == "failed" {
+		attempts := agentTask.Attempts
+		if attempts < 1 {
+			attempts = 1
 		}
+		dbStatus, nextRetryAt = agent.GetRetryPolicy().OnFailure(attempts, time.Now())
+	}
 
-			// Update success/failed node counts.
-			updates := make(map[string]interface{})
-			if dbStatus == "success" {
-				updates["success_nodes"] = gorm.Expr("success_nodes + 1")
-			} else {
-				updates["failed_nodes"] = gorm.Expr("failed_nodes + 1")
-				errorMsg := fmt.Sprintf("Node %d failed: %s", nodeID, errorMessage)
-				updates["last_error"] = errorMsg
-			}
+	updateData := map[string]interface{}{
+		"status":     dbStatus,
+		"updated_at": gorm.Expr("NOW()"),
+	}
+	if dbStatus != model.TaskStatusSuccess {
+		updateData["last_error"] = truncateError(errorMessage)
+		updateData["next_retry_at"] = nextRetryAt
+	}
 
-			// Re-fetch the updated counts to determine final status.
-			if err := tx.Model(&model.ReleaseTask{}).Where("id = ?", payload.ReleaseTaskID).Updates(updates).Error; err != nil {
-				return fmt.Errorf("failed to update release task counts: %w", err)
-			}
+	if err := tx.Model(agentTask).Updates(updateData).Error; err != nil {
+		return nil, err
+	}
 
-			// Re-fetch to get the updated counts.
-			if err := tx.First(&releaseTask, payload.ReleaseTaskID).Error; err != nil {
-				return fmt.Errorf("failed to re-fetch release task: %w", err)
-			}
+	// A task waiting for retry has no final result yet, leave the release_task alone.
+	if dbStatus == model.TaskStatusRetrying {
+		log.Printf("[Info] Agent task %d failed (attempt %d), retry at %s: %s", agentTask.ID, agentTask.Attempts, nextRetryAt.Format(time.RFC3339), errorMessage)
+		return nil, nil
+	}
+
+	// 3. Propagate the result to the release_task.
+	var payload AgentTaskPayload
+	if err := json.Unmarshal([]byte(agentTask.Payload), &payload); err != nil {
+		log.Printf("[Error] Failed to unmarshal agent task payload for task %d: %v", agentTask.ID, err)
+		return nil, fmt.Errorf("invalid task payload")
+	}
 
-			// 4. Check if the release task is complete.
-			if (releaseTask.SuccessNodes + releaseTask.FailedNodes) >= releaseTask.TotalNodes {
-				var finalStatus model.ReleaseTaskStatus
-				if releaseTask.FailedNodes > 0 {
-					finalStatus = model.ReleaseTaskStatusFailed
-				} else {
-					finalStatus = model.ReleaseTaskStatusSuccess
-				}
-				return tx.Model(&model.ReleaseTask{}).Where("id = ?", payload.ReleaseTaskID).Update("status", finalStatus).Error
+	if payload.ReleaseTaskID == 0 {
+		log.Printf("[Info] No releaseTaskId in payload for agent task %d. Skipping release task update.", agentTask.ID)
+		return nil, nil // Not every agent task belongs to a release task.
+	}
+
+	// Lock the release_task row for atomic update.
+	var releaseTask model.ReleaseTask
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&releaseTask, payload.ReleaseTaskID).Error; err != nil {
+		return nil, fmt.Errorf("failed to find and lock release task %d: %w", payload.ReleaseTaskID, err)
+	}
+
+	// Settle the node of the release, if the release tracks nodes.
+	settled := &releaseSettlement{
+		releaseID:  int64(payload.ReleaseTaskID),
+		nodeID:     int(agentTask.NodeID),
+		nodeStatus: model.ReleaseTaskNodeStatusSuccess,
+	}
+	nodeUpdates := map[string]interface{}{
+		"status":      model.ReleaseTaskNodeStatusSuccess,
+		"finished_at": time.Now(),
+	}
+	if dbStatus != model.TaskStatusSuccess {
+		settled.nodeStatus = model.ReleaseTaskNodeStatusFailed
+		settled.errorMsg = truncateError(errorMessage)
+		nodeUpdates["status"] = model.ReleaseTaskNodeStatusFailed
+		nodeUpdates["error_msg"] = truncateError(errorMessage)
+	}
+	if err := tx.Model(&model.ReleaseTaskNode{}).
+		Where("release_task_id = ? AND node_id = ?", payload.ReleaseTaskID, agentTask.NodeID).
+		Where("status IN ?", []model.ReleaseTaskNodeStatus{model.ReleaseTaskNodeStatusPending, model.ReleaseTaskNodeStatusRunning}).
+		Updates(nodeUpdates).Error; err != nil {
+		return nil, fmt.Errorf("failed to update release task node: %w", err)
+	}
+
+	// Update success/failed node counts.
+	updates := make(map[string]interface{})
+	if dbStatus == "success" {
+		updates["success_nodes"] = gorm.Expr("success_nodes + 1")
+	} else {
+		updates["failed_nodes"] = gorm.Expr("failed_nodes + 1")
+		errorMsg := fmt.Sprintf("Node %d failed: %s", agentTask.NodeID, errorMessage)
+		updates["last_error"] = errorMsg
+	}
+
+	// Re-fetch the updated counts to determine final status.
+	if err := tx.Model(&model.ReleaseTask{}).Where("id = ?", payload.ReleaseTaskID).Updates(updates).Error; err != nil {
+		return nil, fmt.Errorf("failed to update release task counts: %w", err)
+	}
+
+	// Re-fetch to get the updated counts.
+	if err := tx.First(&releaseTask, payload.ReleaseTaskID).Error; err != nil {
+		return nil, fmt.Errorf("failed to re-fetch release task: %w", err)
+	}
+
+	// 4. Check if the release task is complete.
+	// Failed nodes within the failure budget still complete the release as success.
+	if (releaseTask.SuccessNodes + releaseTask.FailedNodes) >= releaseTask.TotalNodes {
+		finalStatus := model.ReleaseTaskStatusSuccess
+		if releaseTask.FailedNodes > 0 && release.WebsiteFailureBudgetExceeded(&releaseTask) {
+			finalStatus = model.ReleaseTaskStatusFailed
+		}
+		if err := tx.Model(&model.ReleaseTask{}).Where("id = ?", payload.ReleaseTaskID).Update("status", finalStatus).Error; err != nil {
+			return nil, err
+		}
+		settled.finished = true
+
+		// Over budget: roll the website back to its previous successful release (rollbacks and deletes are not rolled back)
+		if finalStatus == model.ReleaseTaskStatusFailed && releaseTask.AutoRollback && releaseTask.Type == model.ReleaseTaskTypeApplyConfig &&
+			(releaseTask.Payload == nil || releaseTask.Payload.Action != "delete") {
+			rollback, err := release.CreateWebsiteRollbackRelease(tx, &releaseTask)
+			if err != nil {
+				log.Printf("[Error] Failed to create rollback release for release task %d: %v", releaseTask.ID, err)
 			}
+			settled.rollback = rollback
+		}
+	}
 
-			return nil
-	})
+	return settled, nil
+}
+
+// truncateError truncates an error message to fit a varchar(255) column.
+func truncateError(msg string) string {
+	if len(msg) > 255 {
+		return msg[:252] + "..."
+	}
+	return msg
 }
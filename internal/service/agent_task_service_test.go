@@ -0,0 +1,128 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"go_cmdb/internal/db"
+	"go_cmdb/internal/httpx"
+	"go_cmdb/internal/model"
+
+	"github.com/glebarez/sqlite"
+	"gorm.io/gorm"
+)
+
+func TestUpdateTaskStatusRejectsStaleAttempt(t *testing.T) {
+	gdb, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func(prev *gorm.DB) { db.DB = prev }(db.DB)
+	db.DB = gdb
+
+	if err := gdb.Exec(`CREATE TABLE agent_tasks (id INTEGER PRIMARY KEY, node_id INTEGER, type TEXT, payload TEXT, status TEXT, last_error TEXT,
+		attempts INTEGER NOT NULL DEFAULT 0, next_retry_at DATETIME, pushed BOOLEAN NOT NULL DEFAULT 0, created_at DATETIME, updated_at DATETIME)`).Error; err != nil {
+		t.Fatal(err)
+	}
+	// Attempt 1 was reaped and the task is running again as attempt 2
+	task := model.AgentTask{NodeID: 1, Type: model.TaskTypeReload, Payload: `{}`, Status: model.TaskStatusRunning, Attempts: 2}
+	if err := gdb.Create(&task).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	err = UpdateTaskStatus(1, uint(task.ID), 1, "succeeded", "")
+	var appErr *httpx.AppError
+	if !errors.As(err, &appErr) || appErr.Code != httpx.CodeStateConflict {
+		t.Fatalf("UpdateTaskStatus() for attempt 1 = %v, want a state conflict", err)
+	}
+	var saved model.AgentTask
+	gdb.First(&saved, task.ID)
+	if saved.Status != model.TaskStatusRunning {
+		t.Errorf("task status = %s, want running", saved.Status)
+	}
+
+	if err := UpdateTaskStatus(1, uint(task.ID), 2, "succeeded", ""); err != nil {
+		t.Fatalf("UpdateTaskStatus() for attempt 2 failed: %v", err)
+	}
+	gdb.First(&saved, task.ID)
+	if saved.Status != model.TaskStatusSuccess {
+		t.Errorf("task status = %s, want success", saved.Status)
+	}
+}
+
+func TestApplyTaskResultFailureBudget(t *testing.T) {
+	gdb, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, stmt := range []string{
+		`CREATE TABLE agent_tasks (id INTEGER PRIMARY KEY, node_id INTEGER, type TEXT, payload TEXT, status TEXT, last_error TEXT,
+			attempts INTEGER NOT NULL DEFAULT 0, next_retry_at DATETIME, pushed BOOLEAN NOT NULL DEFAULT 0, created_at DATETIME, updated_at DATETIME)`,
+		`CREATE TABLE release_tasks (id INTEGER PRIMARY KEY, type TEXT, target TEXT, version INTEGER, status TEXT,
+			total_nodes INTEGER NOT NULL DEFAULT 0, success_nodes INTEGER NOT NULL DEFAULT 0, failed_nodes INTEGER NOT NULL DEFAULT 0,
+			target_type TEXT, target_id INTEGER, content_hash TEXT, payload BLOB, last_error TEXT, retry_count INTEGER, next_retry_at DATETIME,
+			rollout_strategy TEXT, max_failed_nodes INTEGER NOT NULL DEFAULT 0, max_failed_percent INTEGER NOT NULL DEFAULT 0,
+			auto_rollback BOOLEAN NOT NULL DEFAULT 0, rollback_of_id INTEGER, scheduled_at DATETIME, health_gates TEXT,
+			cancelled_by TEXT, cancel_reason TEXT, cancelled_at DATETIME, created_by TEXT, approval_reason TEXT, config_snapshot TEXT,
+			created_at DATETIME, updated_at DATETIME)`,
+		`CREATE TABLE release_task_nodes (id INTEGER PRIMARY KEY, release_task_id INTEGER, node_id INTEGER, batch INTEGER, status TEXT,
+			error_msg TEXT, finished_at DATETIME, updated_at DATETIME)`,
+		// 1: earlier successful release of website 5; 2: no failure budget; 3: one failed node allowed
+		`INSERT INTO release_tasks (id, type, status, target_type, target_id, content_hash, config_snapshot) VALUES
+			(1, 'apply_config', 'success', 'website', 5, 'hash-1', '[{"websiteId":5}]')`,
+		`INSERT INTO release_tasks (id, type, status, total_nodes, target_type, target_id, content_hash, auto_rollback, max_failed_nodes) VALUES
+			(2, 'apply_config', 'running', 2, 'website', 5, 'hash-2', 1, 0),
+			(3, 'apply_config', 'running', 2, 'website', 6, 'hash-3', 1, 1)`,
+	} {
+		if err := gdb.Exec(stmt).Error; err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// settle runs one node of the release to success and the other to dead
+	settle := func(releaseID int64) *releaseSettlement {
+		t.Helper()
+		var settled *releaseSettlement
+		for i, status := range []string{model.TaskStatusSuccess, model.TaskStatusDead} {
+			task := model.AgentTask{NodeID: uint(i + 1), Type: model.TaskTypeApplyConfig, Status: model.TaskStatusRunning, Attempts: 1,
+				Payload: fmt.Sprintf(`{"releaseTaskId":%d}`, releaseID)}
+			if err := gdb.Create(&task).Error; err != nil {
+				t.Fatal(err)
+			}
+			if settled, err = applyTaskResult(gdb, &task, status, "nginx reload failed"); err != nil {
+				t.Fatal(err)
+			}
+		}
+		return settled
+	}
+	releaseStatus := func(id int64) model.ReleaseTaskStatus {
+		t.Helper()
+		var task model.ReleaseTask
+		gdb.Select("id", "status").First(&task, id)
+		return task.Status
+	}
+
+	// Over budget: failed and rolled back to the previous successful release of the website
+	settled := settle(2)
+	if got := releaseStatus(2); got != model.ReleaseTaskStatusFailed {
+		t.Errorf("release 2 status = %s, want failed", got)
+	}
+	rollback := settled.rollback
+	if rollback == nil {
+		t.Fatal("expected a rollback release for release 2")
+	}
+	if rollback.Type != model.ReleaseTaskTypeRollbackConfig || rollback.RollbackOfID == nil || *rollback.RollbackOfID != 2 ||
+		rollback.ContentHash != "hash-1" || rollback.TargetID != 5 {
+		t.Errorf("unexpected rollback release: %+v", rollback)
+	}
+
+	// Within budget: success, nothing rolled back
+	settled = settle(3)
+	if got := releaseStatus(3); got != model.ReleaseTaskStatusSuccess {
+		t.Errorf("release 3 status = %s, want success", got)
+	}
+	if settled.rollback != nil {
+		t.Errorf("release 3 must not be rolled back, got rollback release %d", settled.rollback.ID)
+	}
+}
@@ -8,7 +8,10 @@ import (
 	"log"
 	"sort"
 
+	"go_cmdb/internal/configgen"
+	"go_cmdb/internal/httpx"
 	"go_cmdb/internal/model"
+	"go_cmdb/internal/release"
 	"gorm.io/gorm"
 )
 
@@ -84,6 +87,19 @@ func (s *OriginSetReleaseService) CreateOriginSetReleaseTask(originSetID int64,
 		}
 	}
 
+	// 2.5. 校验：同一 upstream 不允许混用 http/https
+	addresses := make([]configgen.AddressConfig, 0, len(origins))
+	for _, origin := range origins {
+		addresses = append(addresses, configgen.AddressConfig{
+			Address:  origin.Address,
+			Protocol: origin.Protocol,
+			Enabled:  origin.Enabled,
+		})
+	}
+	if _, err := configgen.ResolveOriginProtocol(addresses); err != nil {
+		return nil, httpx.ErrParamInvalid(fmt.Sprintf("origin set %d: %v", originSetID, err))
+	}
+
 	// 3. 排序 origins（确保 content_hash 稳定）
 	sort.Slice(origins, func(i, j int) bool {
 		if origins[i].Role != origins[j].Role {
@@ -138,12 +154,21 @@ func (s *OriginSetReleaseService) CreateOriginSetReleaseTask(originSetID int64,
 		RetryCount:  0,
 	}
 
+	// 快照范围：绑定该 origin set 的网站
+	var websiteIDs []int64
+	if err := s.db.Model(&model.Website{}).Where("origin_set_id = ?", originSetID).Pluck("id", &websiteIDs).Error; err != nil {
+		log.Printf("[OriginSetReleaseService] Failed to query websites of origin set %d: %v", originSetID, err)
+	} else {
+		releaseTask.ConfigSnapshot = websiteConfigSnapshot(s.db, websiteIDs)
+	}
+
 	if err := s.db.Create(&releaseTask).Error; err != nil {
 		return nil, fmt.Errorf("failed to create release_task: %w", err)
 	}
 
 	log.Printf("[OriginSetReleaseService] Created upstream release_task: id=%d, originSetId=%d, originGroupId=%d, contentHash=%s, traceId=%s",
 		releaseTask.ID, originSetID, originGroupID, contentHash, traceID)
+	release.PublishReleaseCreated(s.db, releaseTask.ID, "")
 
 	result.ReleaseTaskID = releaseTask.ID
 	result.TaskCreated = true
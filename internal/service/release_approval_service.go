@@ -0,0 +1,130 @@
+package service
+
+import (
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"log"
+
+	"go_cmdb/internal/approval"
+	"go_cmdb/internal/model"
+	"go_cmdb/internal/release"
+
+	"gorm.io/gorm"
+)
+
+// WebsiteReleaseApprovalReason 按审批策略判断网站发布是否需要审批，返回命中的策略（为空表示无需审批）
+// 节点数按线路分组当前的目标节点计算
+func WebsiteReleaseApprovalReason(db *gorm.DB, lineGroupID int, change approval.Change) (string, error) {
+	targetNodes, err := NewAgentTaskDispatcher(db).getTargetNodes(lineGroupID)
+	if err != nil {
+		return "", fmt.Errorf("failed to get target nodes: %w", err)
+	}
+	change.NodeCount = len(targetNodes)
+
+	return approval.Evaluate(db, change)
+}
+
+// CreatePendingWebsiteUpdate 创建等待审批的网站更新发布任务
+// 更新请求保存在 payload 中，网站表保持不变；审批通过后由调用方写入网站表再派发，驳回时无需回滚
+func CreatePendingWebsiteUpdate(db *gorm.DB, websiteID int64, request json.RawMessage, snapshot *string, approvalReason, createdBy string) (*CreateReleaseTaskResult, error) {
+	hashData, _ := json.Marshal(map[string]interface{}{
+		"action":    "update",
+		"websiteId": websiteID,
+		"request":   request,
+	})
+	contentHash := fmt.Sprintf("%x", sha256.Sum256(hashData))
+
+	// 相同的更新已在等待审批时不重复创建
+	var existingTask model.ReleaseTask
+	err := db.Where("target_type = ? AND target_id = ? AND content_hash = ? AND status = ?",
+		"website", websiteID, contentHash, model.ReleaseTaskStatusAwaitingApproval).
+		First(&existingTask).Error
+	if err == nil {
+		log.Printf("[WebsiteReleaseService] Skip creating pending update: websiteId=%d, existingTaskId=%d", websiteID, existingTask.ID)
+		return &CreateReleaseTaskResult{
+			ReleaseTaskID:    existingTask.ID,
+			SkipReason:       "same_content_hash",
+			AwaitingApproval: true,
+			ApprovalReason:   approvalReason,
+			PayloadValid:     true,
+		}, nil
+	} else if err != gorm.ErrRecordNotFound {
+		return nil, fmt.Errorf("failed to query existing release_task: %w", err)
+	}
+
+	releaseTask := model.ReleaseTask{
+		Type:       model.ReleaseTaskTypeApplyConfig,
+		TargetType: "website",
+		TargetID:   websiteID,
+		Status:     model.ReleaseTaskStatusAwaitingApproval,
+		Payload: &model.ReleaseTaskPayload{
+			WebsiteID:     int(websiteID),
+			TargetType:    "website",
+			TargetID:      websiteID,
+			Action:        "update",
+			PendingUpdate: request,
+		},
+		ContentHash:    contentHash,
+		ConfigSnapshot: snapshot,
+		ApprovalReason: &approvalReason,
+		AutoRollback:   true,
+	}
+	if createdBy != "" {
+		releaseTask.CreatedBy = &createdBy
+	}
+	if err := db.Create(&releaseTask).Error; err != nil {
+		return nil, fmt.Errorf("failed to create release_task: %w", err)
+	}
+	log.Printf("[WebsiteReleaseService] release_task %d awaiting approval (pending update of website %d): %s", releaseTask.ID, websiteID, approvalReason)
+	release.PublishReleaseCreated(db, releaseTask.ID, createdBy)
+
+	return &CreateReleaseTaskResult{
+		ReleaseTaskID:    releaseTask.ID,
+		TaskCreated:      true,
+		AwaitingApproval: true,
+		ApprovalReason:   approvalReason,
+		PayloadValid:     true,
+	}, nil
+}
+
+// DispatchApproved 审批通过后派发网站发布任务
+// 删除任务按 payload 中保存的线路分组和域名派发，派发后删除网站记录（审批前保留，驳回时网站不受影响）；
+// 更新任务由调用方先写入 payload 中的更新，再补建 upstream 发布任务并派发 server 任务
+func (s *WebsiteReleaseService) DispatchApproved(task *model.ReleaseTask) (*DispatchResult, error) {
+	result, err := s.dispatchRelease(task, fmt.Sprintf("release_approved_%d", task.ID))
+	if err != nil {
+		return nil, err
+	}
+
+	if task.Payload != nil && task.Payload.Action == "delete" {
+		if err := DeleteWebsiteRecords(s.db, task.TargetID); err != nil {
+			return result, fmt.Errorf("failed to delete website %d: %w", task.TargetID, err)
+		}
+	}
+	return result, nil
+}
+
+// dispatchRelease 派发已创建的网站发布任务（审批通过、排队结束时使用）
+func (s *WebsiteReleaseService) dispatchRelease(task *model.ReleaseTask, traceID string) (*DispatchResult, error) {
+	if task.TargetType != "website" {
+		return nil, fmt.Errorf("unsupported targetType: %s", task.TargetType)
+	}
+
+	if task.Payload != nil && task.Payload.Action == "delete" {
+		return s.dispatcher.EnsureDispatchedForDelete(task.ID, task.TargetID, task.Payload.LineGroupID, task.Payload.Domains, traceID)
+	}
+
+	var website model.Website
+	if err := s.db.First(&website, task.TargetID).Error; err != nil {
+		return nil, fmt.Errorf("failed to query website: %w", err)
+	}
+	// 回滚发布下发配置快照中的回源地址，不重新发布网站当前的回源分组
+	if task.Type != model.ReleaseTaskTypeRollbackConfig && website.OriginMode == "group" && website.OriginSetID.Valid && website.OriginSetID.Int32 > 0 {
+		if _, err := s.originSetReleaseService.CreateOriginSetReleaseTask(int64(website.OriginSetID.Int32), traceID+"_upstream"); err != nil {
+			log.Printf("[WebsiteReleaseService] Failed to create upstream release_task: originSetId=%d, error=%v", website.OriginSetID.Int32, err)
+		}
+	}
+
+	return s.dispatcher.EnsureDispatched(task.ID, task.TargetID, traceID)
+}
@@ -0,0 +1,327 @@
+package service
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"sort"
+	"sync"
+	"time"
+
+	agentconfig "go_cmdb/agent/config"
+	"go_cmdb/agent/executor"
+	"go_cmdb/internal/agentclient"
+	"go_cmdb/internal/configgen"
+	"go_cmdb/internal/httpx"
+	"go_cmdb/internal/model"
+
+	"gorm.io/gorm"
+)
+
+// dry-run 的 validate_config 轮询参数
+const (
+	dryRunValidatePollInterval = 2 * time.Second
+	dryRunValidateTimeout      = 60 * time.Second
+)
+
+// validate_config 结果状态
+const (
+	DryRunValidateSuccess = "success"
+	DryRunValidateFailed  = "failed"
+	DryRunValidateTimeout = "timeout"
+)
+
+// ReleaseDryRunRequest dry-run 请求
+type ReleaseDryRunRequest struct {
+	TargetType string `json:"targetType" binding:"required,oneof=website origin_set"`
+	TargetID   int64  `json:"targetId" binding:"required,min=1"`
+	Validate   bool   `json:"validate"` // 是否在节点上执行 validate_config（nginx -t，不切换 live）
+}
+
+// DryRunWebsite 单个网站的发布预览
+type DryRunWebsite struct {
+	WebsiteID   int64                  `json:"websiteId"`
+	LineGroupID int                    `json:"lineGroupId"`
+	ContentHash string                 `json:"contentHash"`
+	Payload     map[string]interface{} `json:"payload,omitempty"` // 与 EnsureDispatched 写入 agent_tasks 的 payload 一致（不含 idKey）
+	NodeIDs     []int                  `json:"nodeIds"`
+	Error       string                 `json:"error,omitempty"`
+}
+
+// DryRunValidation 节点 validate_config 结果
+type DryRunValidation struct {
+	Status  string `json:"status"`
+	Message string `json:"message,omitempty"`
+}
+
+// DryRunNode 单个节点的配置预览
+type DryRunNode struct {
+	NodeID     int                     `json:"nodeId"`
+	NodeName   string                  `json:"nodeName"`
+	MainIP     string                  `json:"mainIp"`
+	WebsiteIDs []int64                 `json:"websiteIds"` // 本次发布影响该节点的网站
+	Files      []executor.RenderedFile `json:"files"`      // Agent 将渲染的全部 upstream/server 文件
+	Error      string                  `json:"error,omitempty"`
+	Validation *DryRunValidation       `json:"validation,omitempty"`
+
+	agentPort int
+}
+
+// ReleaseDryRunResult dry-run 结果
+type ReleaseDryRunResult struct {
+	TargetType string          `json:"targetType"`
+	TargetID   int64           `json:"targetId"`
+	Version    int64           `json:"version"` // 预览使用的版本号（当前 MAX(version)+1，不占用）
+	Websites   []DryRunWebsite `json:"websites"`
+	Nodes      []DryRunNode    `json:"nodes"`
+}
+
+// ReleaseDryRunService 网站/回源组发布 dry-run：只渲染不落库、不派发
+type ReleaseDryRunService struct {
+	db          *gorm.DB
+	dispatcher  *AgentTaskDispatcher
+	taskService *WebsiteReleaseTaskService
+	aggregator  *configgen.Aggregator
+	agentClient *agentclient.Client
+}
+
+// NewReleaseDryRunService 创建 dry-run 服务
+// agentClient 仅在 Validate=true 时使用，可为 nil
+func NewReleaseDryRunService(db *gorm.DB, agentClient *agentclient.Client) *ReleaseDryRunService {
+	return &ReleaseDryRunService{
+		db:          db,
+		dispatcher:  NewAgentTaskDispatcher(db),
+		taskService: NewWebsiteReleaseTaskService(db),
+		aggregator:  configgen.NewAggregator(db),
+		agentClient: agentClient,
+	}
+}
+
+// DryRun 执行发布预览
+func (s *ReleaseDryRunService) DryRun(req *ReleaseDryRunRequest) (*ReleaseDryRunResult, error) {
+	if req.Validate && s.agentClient == nil {
+		return nil, httpx.ErrParamInvalid("validate requires mTLS agent client")
+	}
+
+	// 1. 解析目标网站
+	websites, err := s.targetWebsites(req)
+	if err != nil {
+		return nil, err
+	}
+
+	// 2. 版本号：与 release.Service.GenerateVersion 一致，仅用于渲染证书路径，不占用
+	var maxVersion int64
+	if err := s.db.Model(&model.ReleaseTask{}).Select("COALESCE(MAX(version), 0)").Scan(&maxVersion).Error; err != nil {
+		return nil, httpx.ErrDatabaseError("failed to query release version", err)
+	}
+
+	result := &ReleaseDryRunResult{
+		TargetType: req.TargetType,
+		TargetID:   req.TargetID,
+		Version:    maxVersion + 1,
+		Websites:   make([]DryRunWebsite, 0, len(websites)),
+		Nodes:      make([]DryRunNode, 0),
+	}
+
+	// 3. 每个网站：与正式发布相同的 payload 渲染 + 目标节点
+	nodeWebsites := make(map[int][]int64)
+	for i := range websites {
+		item := s.previewWebsite(&websites[i])
+		for _, nodeID := range item.NodeIDs {
+			nodeWebsites[nodeID] = append(nodeWebsites[nodeID], item.WebsiteID)
+		}
+		result.Websites = append(result.Websites, item)
+	}
+
+	// 4. 每个目标节点：按 configgen 生成 apply_config payload 并渲染 nginx 配置
+	nodeIDs := make([]int, 0, len(nodeWebsites))
+	for nodeID := range nodeWebsites {
+		nodeIDs = append(nodeIDs, nodeID)
+	}
+	sort.Ints(nodeIDs)
+
+	renderer, err := executor.NewApplyConfigExecutor(agentconfig.NewDirConfig())
+	if err != nil {
+		return nil, httpx.ErrInternalError("failed to create config renderer", err)
+	}
+
+	payloads := make(map[int]json.RawMessage)
+	for _, nodeID := range nodeIDs {
+		node, payloadJSON := s.previewNode(renderer, nodeID, result.Version)
+		node.WebsiteIDs = nodeWebsites[nodeID]
+		if payloadJSON != nil {
+			payloads[nodeID] = payloadJSON
+		}
+		result.Nodes = append(result.Nodes, node)
+	}
+
+	// 5. 可选：节点上执行 validate_config（并发，渲染失败的节点不校验）
+	if req.Validate {
+		s.validateNodes(result, payloads)
+	}
+
+	return result, nil
+}
+
+// targetWebsites 查询 dry-run 涉及的网站
+func (s *ReleaseDryRunService) targetWebsites(req *ReleaseDryRunRequest) ([]model.Website, error) {
+	var websites []model.Website
+
+	switch req.TargetType {
+	case "website":
+		var website model.Website
+		if err := s.db.First(&website, req.TargetID).Error; err != nil {
+			if err == gorm.ErrRecordNotFound {
+				return nil, httpx.ErrNotFound("website not found")
+			}
+			return nil, httpx.ErrDatabaseError("failed to query website", err)
+		}
+		websites = append(websites, website)
+	case "origin_set":
+		var originSet model.OriginSet
+		if err := s.db.First(&originSet, req.TargetID).Error; err != nil {
+			if err == gorm.ErrRecordNotFound {
+				return nil, httpx.ErrNotFound("origin set not found")
+			}
+			return nil, httpx.ErrDatabaseError("failed to query origin set", err)
+		}
+		if err := s.db.Where("origin_set_id = ?", req.TargetID).Order("id ASC").Find(&websites).Error; err != nil {
+			return nil, httpx.ErrDatabaseError("failed to query websites", err)
+		}
+	default:
+		return nil, httpx.ErrParamInvalid("targetType must be website or origin_set")
+	}
+
+	return websites, nil
+}
+
+// previewWebsite 渲染单个网站的发布 payload 并计算目标节点
+func (s *ReleaseDryRunService) previewWebsite(website *model.Website) DryRunWebsite {
+	item := DryRunWebsite{
+		WebsiteID:   int64(website.ID),
+		LineGroupID: website.LineGroupID,
+		NodeIDs:     make([]int, 0),
+	}
+
+	// 与 WebsiteReleaseTaskService 相同的 payload/content_hash
+	renderedPayload, err := s.taskService.RenderPayload(item.WebsiteID)
+	if err != nil {
+		item.Error = err.Error()
+		return item
+	}
+	item.ContentHash = s.taskService.CalculateContentHash(renderedPayload)
+
+	// 回源协议校验（与 CreateRelease 一致）
+	if err := configgen.ValidateWebsiteOrigin(s.db, website); err != nil {
+		item.Error = err.Error()
+		return item
+	}
+
+	// 与 EnsureDispatched 相同的 agent_task payload
+	payload, errMsg := s.dispatcher.buildWebsitePayload(website, 0, "dry_run")
+	if errMsg != "" {
+		item.Error = errMsg
+		return item
+	}
+	item.Payload = payload
+
+	nodeIDs, err := s.dispatcher.getTargetNodes(website.LineGroupID)
+	if err != nil {
+		item.Error = fmt.Sprintf("failed to get target nodes: %v", err)
+		return item
+	}
+	item.NodeIDs = append(item.NodeIDs, nodeIDs...)
+
+	return item
+}
+
+// previewNode 渲染节点的 nginx 配置，返回预览和 apply_config payload（失败时为 nil）
+func (s *ReleaseDryRunService) previewNode(renderer *executor.ApplyConfigExecutor, nodeID int, version int64) (DryRunNode, json.RawMessage) {
+	node := DryRunNode{
+		NodeID: nodeID,
+		Files:  make([]executor.RenderedFile, 0),
+	}
+
+	var n model.Node
+	if err := s.db.First(&n, nodeID).Error; err != nil {
+		node.Error = fmt.Sprintf("failed to query node: %v", err)
+		return node, nil
+	}
+	node.NodeName = n.Name
+	node.MainIP = n.MainIP
+	node.agentPort = n.AgentPort
+
+	payload, err := s.aggregator.GeneratePayload(nodeID, version)
+	if err != nil {
+		node.Error = fmt.Sprintf("failed to generate payload: %v", err)
+		return node, nil
+	}
+
+	// configgen 与 Agent 的 payload 结构按 JSON 对齐
+	payloadJSON, err := json.Marshal(payload)
+	if err != nil {
+		node.Error = fmt.Sprintf("failed to marshal payload: %v", err)
+		return node, nil
+	}
+	var agentPayload executor.ApplyConfigPayload
+	if err := json.Unmarshal(payloadJSON, &agentPayload); err != nil {
+		node.Error = fmt.Sprintf("failed to convert payload: %v", err)
+		return node, nil
+	}
+
+	files, err := renderer.Preview(&agentPayload)
+	if err != nil {
+		node.Error = fmt.Sprintf("failed to render config: %v", err)
+		return node, nil
+	}
+	node.Files = files
+
+	return node, payloadJSON
+}
+
+// validateNodes 在节点上并发执行 validate_config 并等待结果
+func (s *ReleaseDryRunService) validateNodes(result *ReleaseDryRunResult, payloads map[int]json.RawMessage) {
+	var wg sync.WaitGroup
+	for i := range result.Nodes {
+		node := &result.Nodes[i]
+		payloadJSON, ok := payloads[node.NodeID]
+		if !ok {
+			continue
+		}
+
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			node.Validation = s.validateNode(node.MainIP, node.agentPort, result.Version, payloadJSON)
+		}()
+	}
+	wg.Wait()
+}
+
+// validateNode 派发 validate_config 并轮询结果
+func (s *ReleaseDryRunService) validateNode(nodeIP string, agentPort int, version int64, payloadJSON json.RawMessage) *DryRunValidation {
+	// requestId 带时间戳：同一版本可重复校验，不命中 Agent 幂等结果
+	taskID := fmt.Sprintf("%s_%d", agentclient.TaskID("validate_config", nodeIP, version), time.Now().UnixNano())
+	if _, err := s.agentClient.Dispatch(nodeIP, agentPort, "validate_config", taskID, payloadJSON); err != nil {
+		return &DryRunValidation{Status: DryRunValidateFailed, Message: err.Error()}
+	}
+
+	deadline := time.Now().Add(dryRunValidateTimeout)
+	for time.Now().Before(deadline) {
+		time.Sleep(dryRunValidatePollInterval)
+
+		status, lastError, err := s.agentClient.Query(nodeIP, agentPort, taskID)
+		if err != nil {
+			log.Printf("[DryRun] Failed to query validate_config on %s: %v", nodeIP, err)
+			continue
+		}
+		switch status {
+		case "success":
+			return &DryRunValidation{Status: DryRunValidateSuccess}
+		case "failed":
+			return &DryRunValidation{Status: DryRunValidateFailed, Message: lastError}
+		}
+	}
+
+	return &DryRunValidation{Status: DryRunValidateTimeout, Message: fmt.Sprintf("no result within %s", dryRunValidateTimeout)}
+}
@@ -0,0 +1,20 @@
+package service
+
+import (
+	"log"
+
+	"go_cmdb/internal/configgen"
+
+	"gorm.io/gorm"
+)
+
+// websiteConfigSnapshot 生成 release_task 的网站配置快照（用于版本对比）
+// 快照失败不阻断发布，仅记录日志，该发布在对比时视为无快照
+func websiteConfigSnapshot(db *gorm.DB, websiteIDs []int64) *string {
+	snapshot, err := configgen.NewAggregator(db).Snapshot(websiteIDs)
+	if err != nil {
+		log.Printf("[ReleaseSnapshot] Failed to snapshot websites %v: %v", websiteIDs, err)
+		return nil
+	}
+	return &snapshot
+}
@@ -9,6 +9,8 @@ import (
 	"os"
 	"sort"
 
+	"go_cmdb/internal/configgen"
+	"go_cmdb/internal/httpx"
 	"go_cmdb/internal/model"
 	"go_cmdb/internal/util"
 
@@ -86,6 +88,11 @@ func (s *ReleaseTaskService) CreateWebsiteReleaseTask(websiteID int64, oldOrigin
 		}
 	}
 
+	// 校验：同一 upstream 不允许混用 http/https
+	if err := validateOriginItems(originItems); err != nil {
+		return nil, err
+	}
+
 	// 对 originItems 进行排序，确保相同地址生成相同 hash
 	sort.Slice(originItems, func(i, j int) bool {
 		if originItems[i].Role != originItems[j].Role {
@@ -191,13 +198,15 @@ func (s *ReleaseTaskService) CreateWebsiteReleaseTask(websiteID int64, oldOrigin
 
 	// 创建新任务
 	task := model.ReleaseTask{
-		Type:        model.ReleaseTaskTypeApplyConfig,
-		Status:      model.ReleaseTaskStatusPending,
-		TargetType:  "website",
-		TargetID:    websiteID,
-		ContentHash: contentHash,
-		Payload:     &payload,
-		RetryCount:  0,
+		Type:           model.ReleaseTaskTypeApplyConfig,
+		Status:         model.ReleaseTaskStatusPending,
+		TargetType:     "website",
+		TargetID:       websiteID,
+		ContentHash:    contentHash,
+		Payload:        &payload,
+		RetryCount:     0,
+		AutoRollback:   true,
+		ConfigSnapshot: websiteConfigSnapshot(s.db, []int64{websiteID}),
 	}
 
 	if err := s.db.Create(&task).Error; err != nil {
@@ -277,6 +286,11 @@ func (s *ReleaseTaskService) CreateWebsiteReleaseTaskWithDispatch(websiteID int6
 		}
 	}
 
+	// 校验：同一 upstream 不允许混用 http/https
+	if err := validateOriginItems(originItems); err != nil {
+		return nil, nil, err
+	}
+
 	// 排序
 	sort.Slice(originItems, func(i, j int) bool {
 		if originItems[i].Address != originItems[j].Address {
@@ -361,13 +375,15 @@ func (s *ReleaseTaskService) CreateWebsiteReleaseTaskWithDispatch(websiteID int6
 
 	// 创建新任务
 	task := model.ReleaseTask{
-		Type:        model.ReleaseTaskTypeApplyConfig,
-		Status:      model.ReleaseTaskStatusPending,
-		TargetType:  "website",
-		TargetID:    websiteID,
-		ContentHash: contentHash,
-		Payload:     &payload,
-		RetryCount:  0,
+		Type:           model.ReleaseTaskTypeApplyConfig,
+		Status:         model.ReleaseTaskStatusPending,
+		TargetType:     "website",
+		TargetID:       websiteID,
+		ContentHash:    contentHash,
+		Payload:        &payload,
+		RetryCount:     0,
+		AutoRollback:   true,
+		ConfigSnapshot: websiteConfigSnapshot(s.db, []int64{websiteID}),
 	}
 
 if err := s.db.Create(&task).Error; err != nil {
@@ -406,6 +422,22 @@ if err := s.db.Create(&task).Error; err != nil {
 	return &task, dispatchResult, nil
 }
 
+// validateOriginItems 校验 origins.items 的协议一致
+func validateOriginItems(items []model.OriginItem) error {
+	addresses := make([]configgen.AddressConfig, 0, len(items))
+	for _, item := range items {
+		addresses = append(addresses, configgen.AddressConfig{
+			Address:  item.Address,
+			Protocol: item.Protocol,
+			Enabled:  item.Enabled,
+		})
+	}
+	if _, err := configgen.ResolveOriginProtocol(addresses); err != nil {
+		return httpx.ErrParamInvalid(err.Error())
+	}
+	return nil
+}
+
 // stableJSONMarshal 生成稳定的 JSON（字段排序）
 func stableJSONMarshal(v interface{}) (string, error) {
 	data, err := json.Marshal(v)
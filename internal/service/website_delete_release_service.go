@@ -5,6 +5,7 @@ import (
 	"encoding/json"
 	"fmt"
 	"go_cmdb/internal/model"
+	"go_cmdb/internal/release"
 	"log"
 	"sort"
 
@@ -16,6 +17,9 @@ type WebsiteDeleteInfo struct {
 	WebsiteID   int64
 	LineGroupID int64
 	Domains     []string
+
+	CreatedBy      string
+	ApprovalReason string // 命中的审批策略，非空时任务创建为 awaiting_approval
 }
 
 // CreateWebsiteDeleteReleaseTask 创建网站删除发布任务
@@ -52,10 +56,10 @@ func CreateWebsiteDeleteReleaseTask(db *gorm.DB, info *WebsiteDeleteInfo, traceI
 	hashBytes, _ := json.Marshal(hashData)
 	contentHash := fmt.Sprintf("%x", sha256.Sum256(hashBytes))
 
-	// 3. 幂等性检查：查询是否已存在相同 content_hash 的任务
+	// 3. 幂等性检查：查询是否已存在相同 content_hash 的任务（被驳回的任务不算）
 	var existingTask model.ReleaseTask
-	err := db.Where("target_type = ? AND target_id = ? AND content_hash = ?",
-		"website", info.WebsiteID, contentHash).
+	err := db.Where("target_type = ? AND target_id = ? AND content_hash = ? AND status != ?",
+		"website", info.WebsiteID, contentHash, model.ReleaseTaskStatusRejected).
 		First(&existingTask).Error
 
 	if err == nil {
@@ -73,14 +77,24 @@ func CreateWebsiteDeleteReleaseTask(db *gorm.DB, info *WebsiteDeleteInfo, traceI
 	payloadObj := &model.ReleaseTaskPayload{}
 	payloadObj.Scan(payloadBytes)
 
+	// 网站删除后配置为空
+	emptySnapshot := "[]"
 	releaseTask := &model.ReleaseTask{
-		TargetType:  "website",
-		TargetID:    info.WebsiteID,
-		Type:        model.ReleaseTaskTypeApplyConfig,
-		Status:      model.ReleaseTaskStatusPending,
-		Payload:     payloadObj,
-		ContentHash: contentHash,
-		TotalNodes:  0, // 初始为 0，派发时更新
+		TargetType:     "website",
+		TargetID:       info.WebsiteID,
+		Type:           model.ReleaseTaskTypeApplyConfig,
+		Status:         model.ReleaseTaskStatusPending,
+		Payload:        payloadObj,
+		ContentHash:    contentHash,
+		TotalNodes:     0, // 初始为 0，派发时更新
+		ConfigSnapshot: &emptySnapshot,
+	}
+	if info.CreatedBy != "" {
+		releaseTask.CreatedBy = &info.CreatedBy
+	}
+	if info.ApprovalReason != "" {
+		releaseTask.Status = model.ReleaseTaskStatusAwaitingApproval
+		releaseTask.ApprovalReason = &info.ApprovalReason
 	}
 
 	if err := db.Create(releaseTask).Error; err != nil {
@@ -89,6 +103,26 @@ func CreateWebsiteDeleteReleaseTask(db *gorm.DB, info *WebsiteDeleteInfo, traceI
 
 	log.Printf("[WebsiteDeleteRelease] Created release_task: id=%d, websiteId=%d, contentHash=%s",
 		releaseTask.ID, info.WebsiteID, contentHash)
+	release.PublishReleaseCreated(db, releaseTask.ID, info.CreatedBy)
 
 	return int64(releaseTask.ID), nil
 }
+
+// DeleteWebsiteRecords 删除网站及其域名、HTTPS 配置
+// 需要审批的删除在审批通过后才调用，驳回时网站记录保持不变
+func DeleteWebsiteRecords(db *gorm.DB, websiteID int64) error {
+	return db.Transaction(func(tx *gorm.DB) error {
+		// 删除 website_https
+		if err := tx.Where("website_id = ?", websiteID).Delete(&model.WebsiteHTTPS{}).Error; err != nil {
+			return err
+		}
+
+		// 删除 website_domains
+		if err := tx.Where("website_id = ?", websiteID).Delete(&model.WebsiteDomain{}).Error; err != nil {
+			return err
+		}
+
+		// 删除 website
+		return tx.Delete(&model.Website{}, websiteID).Error
+	})
+}
@@ -0,0 +1,108 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"go_cmdb/internal/model"
+
+	"gorm.io/gorm"
+)
+
+// unfinishedReleaseStatuses 未结束的网站发布状态
+var unfinishedReleaseStatuses = []model.ReleaseTaskStatus{
+	model.ReleaseTaskStatusPending,
+	model.ReleaseTaskStatusWaitingWindow,
+	model.ReleaseTaskStatusRunning,
+}
+
+// releaseBlocker 返回阻塞该发布派发的同一目标上的发布ID（0 表示可以派发）
+// 同一目标（网站）的发布按ID顺序逐个派发：较早的未结束发布、以及已派发未结束的发布都会阻塞；
+// 不同目标的发布互不阻塞
+func releaseBlocker(db *gorm.DB, task *model.ReleaseTask) (int64, error) {
+	var ids []int64
+	if err := db.Model(&model.ReleaseTask{}).
+		Where("target_type = ? AND target_id = ? AND id <> ?", task.TargetType, task.TargetID, task.ID).
+		Where("status IN ?", unfinishedReleaseStatuses).
+		Where("(id < ? OR total_nodes > 0)", task.ID).
+		Order("id ASC").
+		Limit(1).
+		Pluck("id", &ids).Error; err != nil {
+		return 0, fmt.Errorf("failed to query releases of %s %d: %w", task.TargetType, task.TargetID, err)
+	}
+	if len(ids) == 0 {
+		return 0, nil
+	}
+	return ids[0], nil
+}
+
+// WebsiteReleaseScheduler 派发排队中的网站发布任务
+// 被同一网站上其他发布阻塞的任务保持 pending（total_nodes=0），阻塞的发布结束后由此派发
+type WebsiteReleaseScheduler struct {
+	db       *gorm.DB
+	service  *WebsiteReleaseService
+	interval time.Duration
+}
+
+// NewWebsiteReleaseScheduler 创建网站发布调度器
+func NewWebsiteReleaseScheduler(db *gorm.DB, interval time.Duration) *WebsiteReleaseScheduler {
+	return &WebsiteReleaseScheduler{
+		db:       db,
+		service:  NewWebsiteReleaseService(db),
+		interval: interval,
+	}
+}
+
+// RunOnce 按ID升序尝试派发尚未派发的网站发布任务
+func (s *WebsiteReleaseScheduler) RunOnce() error {
+	var tasks []model.ReleaseTask
+	if err := s.db.Where("target_type = ? AND status IN ? AND total_nodes = 0", "website", []model.ReleaseTaskStatus{
+		model.ReleaseTaskStatusPending,
+		model.ReleaseTaskStatusWaitingWindow,
+	}).Order("id ASC").Find(&tasks).Error; err != nil {
+		return fmt.Errorf("failed to query queued website releases: %w", err)
+	}
+
+	// 本轮已尝试过的网站，同一网站只派发最早的一个
+	tried := make(map[int64]bool)
+	for i := range tasks {
+		task := &tasks[i]
+		if tried[task.TargetID] {
+			continue
+		}
+		tried[task.TargetID] = true
+
+		result, err := s.service.dispatchRelease(task, fmt.Sprintf("release_queued_%d", task.ID))
+		if err != nil {
+			log.Printf("[WebsiteReleaseScheduler] Failed to dispatch release_task %d: %v", task.ID, err)
+			continue
+		}
+		if result.DispatchTriggered {
+			log.Printf("[WebsiteReleaseScheduler] Dispatched queued release_task %d (websiteId=%d)", task.ID, task.TargetID)
+		}
+	}
+
+	return nil
+}
+
+// RunLoop 循环调度，直到 ctx 取消
+func (s *WebsiteReleaseScheduler) RunLoop(ctx context.Context) {
+	log.Printf("[WebsiteReleaseScheduler] Starting scheduler loop (interval=%v)", s.interval)
+
+	ticker := time.NewTicker(s.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			log.Println("[WebsiteReleaseScheduler] Scheduler loop stopped")
+			return
+		case <-ticker.C:
+			if err := s.RunOnce(); err != nil {
+				log.Printf("[WebsiteReleaseScheduler] Run failed: %v", err)
+			}
+		}
+	}
+}
@@ -0,0 +1,78 @@
+package service
+
+import (
+	"testing"
+
+	"go_cmdb/internal/model"
+
+	"github.com/glebarez/sqlite"
+	"gorm.io/gorm"
+)
+
+func TestReleaseBlockerPerWebsite(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// MySQL enum/json columns do not migrate on sqlite, create the table by hand
+	for _, stmt := range []string{
+		`CREATE TABLE release_tasks (id INTEGER PRIMARY KEY, target_type TEXT, target_id INTEGER, status TEXT,
+			total_nodes INTEGER NOT NULL DEFAULT 0, last_error TEXT, created_at DATETIME, updated_at DATETIME)`,
+		// 1: website 1 awaiting approval; 2: website 1 dispatched; 3: website 2 new; 4, 5: website 1 new
+		`INSERT INTO release_tasks (id, target_type, target_id, status, total_nodes) VALUES
+			(1, 'website', 1, 'awaiting_approval', 0),
+			(2, 'website', 1, 'pending', 2),
+			(3, 'website', 2, 'pending', 0),
+			(4, 'website', 1, 'pending', 0),
+			(5, 'website', 1, 'pending', 0)`,
+	} {
+		if err := db.Exec(stmt).Error; err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	blocker := func(id int64) int64 {
+		t.Helper()
+		var task model.ReleaseTask
+		if err := db.First(&task, id).Error; err != nil {
+			t.Fatal(err)
+		}
+		blockerID, err := releaseBlocker(db, &task)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return blockerID
+	}
+
+	// Releases of different websites do not block each other
+	if got := blocker(3); got != 0 {
+		t.Errorf("website 2 release blocked by %d while website 1 is in flight", got)
+	}
+	// Releases of the same website run one at a time, in order
+	if got := blocker(4); got != 2 {
+		t.Errorf("release 4 blocker = %d, want 2", got)
+	}
+	if got := blocker(5); got != 2 {
+		t.Errorf("release 5 blocker = %d, want 2", got)
+	}
+
+	var task model.ReleaseTask
+	db.First(&task, 4)
+	result := &DispatchResult{}
+	queued, err := NewAgentTaskDispatcher(db).queueIfBlocked(&task, result)
+	if err != nil || !queued || result.QueuedBehind != 2 {
+		t.Fatalf("queueIfBlocked() = %v, %v, queuedBehind=%d", queued, err, result.QueuedBehind)
+	}
+	db.First(&task, 4)
+	if task.LastError == nil || *task.LastError != "queued behind release_task 2" {
+		t.Errorf("last_error = %v", task.LastError)
+	}
+
+	db.Exec("UPDATE release_tasks SET status = ? WHERE id = 2", model.ReleaseTaskStatusSuccess)
+	if got := blocker(4); got != 0 {
+		t.Errorf("release 4 still blocked by %d after release 2 finished", got)
+	}
+	if got := blocker(5); got != 4 {
+		t.Errorf("release 5 blocker = %d, want 4", got)
+	}
+}
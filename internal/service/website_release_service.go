@@ -4,10 +4,15 @@ import (
 	"crypto/sha256"
 	"encoding/hex"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
 
+	"go_cmdb/internal/approval"
+	"go_cmdb/internal/configgen"
+	"go_cmdb/internal/httpx"
 	"go_cmdb/internal/model"
+	"go_cmdb/internal/release"
 	"gorm.io/gorm"
 )
 
@@ -39,10 +44,15 @@ type CreateReleaseTaskResult struct {
 	AgentTaskCountAfter    int
 	PayloadValid           bool
 	PayloadInvalidReason   string
+	AwaitingApproval       bool
+	ApprovalReason         string
+	QueuedBehind           int64 // 排队等待同一网站上的该发布结束后派发
+	WaitingWindow          bool  // 等待计划时间或维护窗口后派发
 }
 
 // CreateWebsiteReleaseTaskWithDispatch 创建网站发布任务并派发到 Agent
-func (s *WebsiteReleaseService) CreateWebsiteReleaseTaskWithDispatch(websiteID int64, traceID string) (*CreateReleaseTaskResult, error) {
+// 命中审批策略时任务创建为 awaiting_approval，不派发，审批通过后由 DispatchApproved 派发
+func (s *WebsiteReleaseService) CreateWebsiteReleaseTaskWithDispatch(websiteID int64, traceID string, change approval.Change, createdBy string) (*CreateReleaseTaskResult, error) {
 	result := &CreateReleaseTaskResult{}
 
 	// 1. 查询 website
@@ -51,6 +61,11 @@ func (s *WebsiteReleaseService) CreateWebsiteReleaseTaskWithDispatch(websiteID i
 		return nil, fmt.Errorf("failed to query website: %w", err)
 	}
 
+	// 1.5. 校验回源地址：同一 upstream 不允许混用 http/https
+	if err := validateWebsiteOrigin(s.db, &website); err != nil {
+		return nil, err
+	}
+
 	// 2. 构建 content_hash
 	// 收集 domains
 	domains := make([]string, 0, len(website.Domains))
@@ -69,15 +84,20 @@ func (s *WebsiteReleaseService) CreateWebsiteReleaseTaskWithDispatch(websiteID i
 		"redirectStatusCode": website.RedirectStatusCode,
 		"domains":            domains,
 		"status":             website.Status,
+		"originHostHeader":   website.OriginHostHeader,
+		"originSni":          website.OriginSNI,
+		"originSniName":      website.OriginSNIName,
+		"originSslVerify":    website.OriginSSLVerify,
+		"originCaCert":       website.OriginCACert,
 	}
 	contentJSON, _ := json.Marshal(contentData)
 	hashBytes := sha256.Sum256(contentJSON)
 	contentHash := hex.EncodeToString(hashBytes[:])
 
-	// 3. 查询是否存在相同 content_hash 的任务
+	// 3. 查询是否存在相同 content_hash 的任务（被驳回的任务不算，允许重新提交）
 	var existingTask model.ReleaseTask
-	err := s.db.Where("target_type = ? AND target_id = ? AND content_hash = ?",
-		"website", websiteID, contentHash).
+	err := s.db.Where("target_type = ? AND target_id = ? AND content_hash = ? AND status != ?",
+		"website", websiteID, contentHash, model.ReleaseTaskStatusRejected).
 		Order("id DESC").
 		First(&existingTask).Error
 
@@ -92,14 +112,30 @@ func (s *WebsiteReleaseService) CreateWebsiteReleaseTaskWithDispatch(websiteID i
 		return nil, fmt.Errorf("failed to query existing release_task: %w", err)
 	}
 
+	// 3.5. 按审批策略判断是否需要审批
+	approvalReason, err := WebsiteReleaseApprovalReason(s.db, website.LineGroupID, change)
+	if err != nil {
+		return nil, err
+	}
+
 	// 4. 创建新的 release_task
 	releaseTask := model.ReleaseTask{
-		Type:        "apply_config",
-		TargetType:  "website",
-		TargetID:    websiteID,
-		Status:      model.ReleaseTaskStatusPending,
-		ContentHash: contentHash,
-		RetryCount:  0,
+		Type:           "apply_config",
+		TargetType:     "website",
+		TargetID:       websiteID,
+		Status:         model.ReleaseTaskStatusPending,
+		ContentHash:    contentHash,
+		RetryCount:     0,
+		// 超出失败预算（默认任一节点失败）时回滚到上一个成功发布，同 CDN 发布的默认值
+		AutoRollback:   true,
+		ConfigSnapshot: websiteConfigSnapshot(s.db, []int64{websiteID}),
+	}
+	if createdBy != "" {
+		releaseTask.CreatedBy = &createdBy
+	}
+	if approvalReason != "" {
+		releaseTask.Status = model.ReleaseTaskStatusAwaitingApproval
+		releaseTask.ApprovalReason = &approvalReason
 	}
 
 	if err := s.db.Create(&releaseTask).Error; err != nil {
@@ -107,10 +143,19 @@ func (s *WebsiteReleaseService) CreateWebsiteReleaseTaskWithDispatch(websiteID i
 	}
 
 	log.Printf("[WebsiteReleaseService] Created release_task: id=%d, websiteId=%d, contentHash=%s", releaseTask.ID, websiteID, contentHash)
+	release.PublishReleaseCreated(s.db, releaseTask.ID, createdBy)
 
 	result.ReleaseTaskID = releaseTask.ID
 	result.TaskCreated = true
 
+	if approvalReason != "" {
+		log.Printf("[WebsiteReleaseService] release_task %d awaiting approval: %s", releaseTask.ID, approvalReason)
+		result.AwaitingApproval = true
+		result.ApprovalReason = approvalReason
+		result.PayloadValid = true
+		return result, nil
+	}
+
 	// 4.5. 如果是 group 模式，先创建 upstream 发布任务
 	if website.OriginMode == "group" && website.OriginSetID.Valid && website.OriginSetID.Int32 > 0 {
 		upstreamResult, err := s.originSetReleaseService.CreateOriginSetReleaseTask(int64(website.OriginSetID.Int32), traceID+"_upstream")
@@ -135,9 +180,11 @@ func (s *WebsiteReleaseService) CreateWebsiteReleaseTaskWithDispatch(websiteID i
 	result.CreatedAgentTaskCount = dispatchResult.Created
 	result.SkippedAgentTaskCount = dispatchResult.Skipped
 	result.AgentTaskCountAfter = dispatchResult.AgentTaskCountAfter
+	result.QueuedBehind = dispatchResult.QueuedBehind
+	result.WaitingWindow = dispatchResult.WaitingWindow
 
-	// 设置 payload 有效性
-	if dispatchResult.ErrorMsg != "" {
+	// 设置 payload 有效性（排队、等待窗口不是 payload 问题）
+	if dispatchResult.ErrorMsg != "" && dispatchResult.QueuedBehind == 0 && !dispatchResult.WaitingWindow {
 		result.PayloadValid = false
 		result.PayloadInvalidReason = dispatchResult.ErrorMsg
 	} else {
@@ -149,3 +196,14 @@ func (s *WebsiteReleaseService) CreateWebsiteReleaseTaskWithDispatch(websiteID i
 
 	return result, nil
 }
+
+// validateWebsiteOrigin 校验网站回源地址，协议混用时返回参数错误
+func validateWebsiteOrigin(db *gorm.DB, website *model.Website) error {
+	if err := configgen.ValidateWebsiteOrigin(db, website); err != nil {
+		if errors.Is(err, configgen.ErrMixedOriginProtocols) {
+			return httpx.ErrParamInvalid(err.Error())
+		}
+		return fmt.Errorf("failed to validate website origin: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,49 @@
+package textdiff
+
+import "testing"
+
+func TestUnified(t *testing.T) {
+	a := "server {\n    listen 80;\n    server_name a.example.com;\n    location / {\n        proxy_pass http://up;\n    }\n}\n"
+	b := "server {\n    listen 80;\n    listen 443 ssl;\n    server_name a.example.com b.example.com;\n    location / {\n        proxy_pass http://up;\n    }\n}\n"
+
+	want := `--- a/servers/s.conf
++++ b/servers/s.conf
+@@ -1,6 +1,7 @@
+ server {
+     listen 80;
+-    server_name a.example.com;
++    listen 443 ssl;
++    server_name a.example.com b.example.com;
+     location / {
+         proxy_pass http://up;
+     }
+`
+	if got := Unified("a/servers/s.conf", "b/servers/s.conf", a, b, DefaultContext); got != want {
+		t.Errorf("unexpected diff:\n%s\nwant:\n%s", got, want)
+	}
+
+	if got := Unified("a", "b", a, a, DefaultContext); got != "" {
+		t.Errorf("expected empty diff for equal input, got:\n%s", got)
+	}
+}
+
+func TestUnifiedAddedAndRemovedFiles(t *testing.T) {
+	want := "--- /dev/null\n+++ b/x.conf\n@@ -0,0 +1,2 @@\n+line1\n+line2\n"
+	if got := Unified("/dev/null", "b/x.conf", "", "line1\nline2\n", DefaultContext); got != want {
+		t.Errorf("unexpected diff for added file:\n%q", got)
+	}
+
+	want = "--- a/x.conf\n+++ /dev/null\n@@ -1 +0,0 @@\n-line1\n"
+	if got := Unified("a/x.conf", "/dev/null", "line1\n", "", DefaultContext); got != want {
+		t.Errorf("unexpected diff for removed file:\n%q", got)
+	}
+}
+
+func TestUnifiedSeparateHunks(t *testing.T) {
+	a := "1\n2\n3\n4\n5\n6\n7\n8\n9\n10\n11\n12\n"
+	b := "1x\n2\n3\n4\n5\n6\n7\n8\n9\n10\n11\n12x\n"
+	want := "--- a\n+++ b\n@@ -1,4 +1,4 @@\n-1\n+1x\n 2\n 3\n 4\n@@ -9,4 +9,4 @@\n 9\n 10\n 11\n-12\n+12x\n"
+	if got := Unified("a", "b", a, b, DefaultContext); got != want {
+		t.Errorf("unexpected diff:\n%s", got)
+	}
+}
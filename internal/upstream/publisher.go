@@ -4,8 +4,10 @@ import (
 	"crypto/sha256"
 	"encoding/json"
 	"fmt"
+	"go_cmdb/internal/configgen"
 	"go_cmdb/internal/httpx"
 	"go_cmdb/internal/model"
+	"go_cmdb/internal/release"
 	"time"
 
 	"gorm.io/gorm"
@@ -209,13 +211,20 @@ func (p *Publisher) Publish(req *PublishRequest) (*PublishResponse, error) {
 		}
 		version := maxVersion + 1
 
+		// 网站配置快照（用于版本对比）
+		snapshot, err := configgen.NewAggregator(tx).Snapshot(nil)
+		if err != nil {
+			return httpx.ErrDatabaseError("failed to snapshot website config", err)
+		}
+
 		// 创建 release_task
 		releaseTask := &model.ReleaseTask{
-			Type:       model.ReleaseTaskTypeApplyConfig,
-			Target:     model.ReleaseTaskTargetCDN,
-			Version:    version,
-			Status:     model.ReleaseTaskStatusPending,
-			TotalNodes: len(nodeIDs),
+			Type:           model.ReleaseTaskTypeApplyConfig,
+			Target:         model.ReleaseTaskTargetCDN,
+			Version:        version,
+			Status:         model.ReleaseTaskStatusPending,
+			TotalNodes:     len(nodeIDs),
+			ConfigSnapshot: &snapshot,
 		}
 		if err := tx.Create(releaseTask).Error; err != nil {
 			return httpx.ErrDatabaseError("failed to create release task", err)
@@ -243,6 +252,8 @@ func (p *Publisher) Publish(req *PublishRequest) (*PublishResponse, error) {
 		return nil, err
 	}
 
+	release.PublishReleaseCreated(p.db, releaseID, "")
+
 	return &PublishResponse{
 		ReleaseID: releaseID,
 		TaskID:    firstTaskID,
@@ -67,7 +67,7 @@ func handleRequestWebsites(s socketio.Conn, data interface{}) {
 func sendIncrementalUpdates(s socketio.Conn, lastEventId int64) bool {
 	// Query incremental events (limit to 500)
 	maxCount := 500
-	events, err := GetIncrementalEvents(lastEventId, maxCount)
+	events, err := GetIncrementalEvents(TopicWebsites, lastEventId, maxCount)
 	if err != nil {
 		log.Printf("[WebSocket] Failed to query incremental events: %v", err)
 		return false
@@ -83,7 +83,7 @@ func sendIncrementalUpdates(s socketio.Conn, lastEventId int64) bool {
 	if len(events) == 0 {
 		log.Printf("[WebSocket] No incremental events found")
 		// Get latest event ID
-		latestEventId, _ := GetLatestEventId()
+		latestEventId, _ := GetLatestEventId(TopicWebsites)
 		s.Emit("websites:initial", map[string]interface{}{
 			"items":       []interface{}{},
 			"total":       0,
@@ -172,7 +172,7 @@ func sendFullWebsitesList(s socketio.Conn) {
 	}
 
 	// Get latest event ID
-	latestEventId, _ := GetLatestEventId()
+	latestEventId, _ := GetLatestEventId(TopicWebsites)
 
 	// Send websites:initial event
 	s.Emit("websites:initial", map[string]interface{}{
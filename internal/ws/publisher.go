@@ -9,54 +9,90 @@ import (
 	"go_cmdb/models"
 )
 
+// Event topics stored in ws_events
+const (
+	TopicWebsites = "websites"
+	TopicReleases = "releases"
+)
+
 // PublishWebsiteEvent publishes a website event to the database and broadcasts it
 // eventType: "add", "update", "delete"
 // payload: the website data to be sent to clients
 func PublishWebsiteEvent(eventType string, payload interface{}) error {
+	eventID, err := writeEvent(TopicWebsites, eventType, payload)
+	if err != nil {
+		return err
+	}
+
+	// Broadcast event to all connected clients
+	// Note: Broadcast failure should not affect the main flow
+	BroadcastToAll("websites:update", map[string]interface{}{
+		"eventId": eventID,
+		"type":    eventType,
+		"data":    payload,
+	})
+
+	log.Printf("[WebSocket] Event broadcasted: eventId=%d, type=%s", eventID, eventType)
+
+	return nil
+}
+
+// PublishReleaseEvent publishes a release lifecycle event to the database and broadcasts it
+// to the clients that joined the releases room (see request:releases).
+// eventType: "created", "started", "paused", "finished", "node", ...
+func PublishReleaseEvent(eventType string, payload interface{}) error {
+	eventID, err := writeEvent(TopicReleases, eventType, payload)
+	if err != nil {
+		return err
+	}
+
+	BroadcastToRoom(TopicReleases, "releases:update", map[string]interface{}{
+		"eventId": eventID,
+		"type":    eventType,
+		"data":    payload,
+	})
+
+	return nil
+}
+
+// writeEvent persists an event so clients can resume from lastEventId, returns the event ID
+func writeEvent(topic, eventType string, payload interface{}) (int64, error) {
 	// 1. Serialize payload to JSON
 	payloadJSON, err := json.Marshal(payload)
 	if err != nil {
 		log.Printf("[WebSocket] Failed to marshal payload: %v", err)
-		return fmt.Errorf("failed to marshal payload: %w", err)
+		return 0, fmt.Errorf("failed to marshal payload: %w", err)
+	}
+
+	database := db.GetDB()
+	if database == nil {
+		return 0, fmt.Errorf("database not initialized")
 	}
 
 	// 2. Write event to database
 	event := models.WSEvent{
-		Topic:     "websites",
+		Topic:     topic,
 		EventType: eventType,
 		Payload:   string(payloadJSON),
 	}
 
-	if err := db.GetDB().Create(&event).Error; err != nil {
+	if err := database.Create(&event).Error; err != nil {
 		log.Printf("[WebSocket] Failed to write event to database: %v", err)
-		return fmt.Errorf("failed to write event to database: %w", err)
+		return 0, fmt.Errorf("failed to write event to database: %w", err)
 	}
 
 	log.Printf("[WebSocket] Event written to database: id=%d, type=%s, topic=%s", event.ID, eventType, event.Topic)
 
-	// 3. Broadcast event to all connected clients
-	// Note: Broadcast failure should not affect the main flow
-	broadcastData := map[string]interface{}{
-		"eventId": event.ID,
-		"type":    eventType,
-		"data":    payload,
-	}
-
-	// Broadcast to all clients (no room filtering for now)
-	BroadcastToAll("websites:update", broadcastData)
-
-	log.Printf("[WebSocket] Event broadcasted: eventId=%d, type=%s", event.ID, eventType)
-
-	return nil
+	return event.ID, nil
 }
 
-// GetIncrementalEvents retrieves incremental events from the database
+// GetIncrementalEvents retrieves incremental events of a topic from the database
 // Returns events with id > lastEventId, limited to maxCount
-func GetIncrementalEvents(lastEventId int64, maxCount int) ([]models.WSEvent, error) {
+func GetIncrementalEvents(topic string, lastEventId int64, maxCount int) ([]models.WSEvent, error) {
 	var events []models.WSEvent
 
 	err := db.GetDB().
-		Where("topic = ? AND id > ?", "websites", lastEventId).
+		Where("topic = ? AND id > ?", topic, lastEventId).
 		Order("id ASC").
 		Limit(maxCount).
 		Find(&events).Error
@@ -68,12 +104,12 @@ func GetIncrementalEvents(lastEventId int64, maxCount int) ([]models.WSEvent, er
 	return events, nil
 }
 
-// GetLatestEventId retrieves the latest event ID from the database
-func GetLatestEventId() (int64, error) {
+// GetLatestEventId retrieves the latest event ID of a topic from the database
+func GetLatestEventId(topic string) (int64, error) {
 	var event models.WSEvent
 
 	err := db.GetDB().
-		Where("topic = ?", "websites").
+		Where("topic = ?", topic).
 		Order("id DESC").
 		Limit(1).
 		First(&event).Error
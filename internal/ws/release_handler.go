@@ -0,0 +1,138 @@
+package ws
+
+import (
+	"encoding/json"
+	"log"
+
+	socketio "github.com/googollee/go-socket.io"
+	"go_cmdb/internal/db"
+	"go_cmdb/internal/model"
+)
+
+// maxReleaseReplayEvents is the replay limit; beyond it the client gets releases:initial again
+const maxReleaseReplayEvents = 500
+
+// ReleaseListItem represents an unfinished release in releases:initial
+type ReleaseListItem struct {
+	ID           int64  `json:"id"`
+	Type         string `json:"type"`
+	Target       string `json:"target"`
+	TargetType   string `json:"targetType"`
+	TargetID     int64  `json:"targetId"`
+	Version      int64  `json:"version"`
+	Status       string `json:"status"`
+	TotalNodes   int    `json:"totalNodes"`
+	SuccessNodes int    `json:"successNodes"`
+	FailedNodes  int    `json:"failedNodes"`
+	CreatedAt    string `json:"createdAt"`
+	UpdatedAt    string `json:"updatedAt"`
+}
+
+// handleRequestReleases handles the request:releases event
+// The client joins the releases room and receives releases:update events from then on.
+// With lastEventId the missed events are replayed, otherwise releases:initial lists the unfinished releases.
+func handleRequestReleases(s socketio.Conn, data interface{}) {
+	log.Printf("[WebSocket] request:releases from client %s, data: %v", s.ID(), data)
+
+	s.Join(TopicReleases)
+
+	// Parse lastEventId from data
+	var lastEventId int64 = 0
+	if dataMap, ok := data.(map[string]interface{}); ok {
+		if lastEventIdFloat, ok := dataMap["lastEventId"].(float64); ok {
+			lastEventId = int64(lastEventIdFloat)
+		}
+	}
+
+	if lastEventId > 0 && replayReleaseEvents(s, lastEventId) {
+		return
+	}
+
+	sendReleasesInitial(s)
+}
+
+// replayReleaseEvents sends the release events after lastEventId followed by releases:synced
+// Returns false if the client should reload the initial list instead
+func replayReleaseEvents(s socketio.Conn, lastEventId int64) bool {
+	events, err := GetIncrementalEvents(TopicReleases, lastEventId, maxReleaseReplayEvents)
+	if err != nil {
+		log.Printf("[WebSocket] Failed to query release events: %v", err)
+		return false
+	}
+	if len(events) >= maxReleaseReplayEvents {
+		log.Printf("[WebSocket] Too many release events (%d), sending initial list", len(events))
+		return false
+	}
+
+	latestEventId := lastEventId
+	for _, event := range events {
+		var payload interface{}
+		if err := json.Unmarshal([]byte(event.Payload), &payload); err != nil {
+			log.Printf("[WebSocket] Failed to unmarshal event payload: %v", err)
+			continue
+		}
+
+		s.Emit("releases:update", map[string]interface{}{
+			"eventId": event.ID,
+			"type":    event.EventType,
+			"data":    payload,
+		})
+		latestEventId = event.ID
+	}
+
+	s.Emit("releases:synced", map[string]interface{}{
+		"lastEventId": latestEventId,
+	})
+	return true
+}
+
+// sendReleasesInitial sends the unfinished releases with the latest event ID
+func sendReleasesInitial(s socketio.Conn) {
+	// Read the event ID first: events published while listing are replayed rather than lost
+	latestEventId, _ := GetLatestEventId(TopicReleases)
+
+	var tasks []model.ReleaseTask
+	if err := db.GetDB().
+		Where("status IN ?", []model.ReleaseTaskStatus{
+			model.ReleaseTaskStatusAwaitingApproval,
+			model.ReleaseTaskStatusPending,
+			model.ReleaseTaskStatusWaitingWindow,
+			model.ReleaseTaskStatusRunning,
+			model.ReleaseTaskStatusPaused,
+		}).
+		Order("id DESC").
+		Limit(1000).
+		Find(&tasks).Error; err != nil {
+		log.Printf("[WebSocket] Failed to query releases: %v", err)
+		s.Emit("error", map[string]interface{}{
+			"message": "Failed to query releases",
+		})
+		return
+	}
+
+	items := make([]ReleaseListItem, 0, len(tasks))
+	for _, task := range tasks {
+		items = append(items, ReleaseListItem{
+			ID:           task.ID,
+			Type:         string(task.Type),
+			Target:       string(task.Target),
+			TargetType:   task.TargetType,
+			TargetID:     task.TargetID,
+			Version:      task.Version,
+			Status:       string(task.Status),
+			TotalNodes:   task.TotalNodes,
+			SuccessNodes: task.SuccessNodes,
+			FailedNodes:  task.FailedNodes,
+			CreatedAt:    task.CreatedAt.Format("2006-01-02 15:04:05"),
+			UpdatedAt:    task.UpdatedAt.Format("2006-01-02 15:04:05"),
+		})
+	}
+
+	s.Emit("releases:initial", map[string]interface{}{
+		"items":       items,
+		"total":       len(items),
+		"lastEventId": latestEventId,
+	})
+
+	log.Printf("[WebSocket] Sent releases list: total=%d, lastEventId=%d", len(items), latestEventId)
+}
@@ -81,6 +81,9 @@ func registerEventHandlers(server *socketio.Server) {
 	// Register request:websites handler
 	server.OnEvent("/", "request:websites", handleRequestWebsites)
 
+	// Register request:releases handler (release dashboard, joins the releases room)
+	server.OnEvent("/", "request:releases", handleRequestReleases)
+
 	log.Println("[WebSocket] Event handlers registered")
 }
 
@@ -0,0 +1,6 @@
+-- Migration: 027_alter_agent_tasks_add_rollback_config
+-- Purpose: agent_tasks.type 增加 rollback_config（节点配置回滚）
+-- Date: 2026-10-17
+
+ALTER TABLE `agent_tasks`
+  MODIFY COLUMN `type` ENUM('purge_cache','apply_config','reload','rollback_config') NOT NULL;
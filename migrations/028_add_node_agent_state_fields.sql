@@ -0,0 +1,10 @@
+-- Add agent state fields to nodes table (reported by agent /agent/v1/ping)
+
+ALTER TABLE nodes
+ADD COLUMN agent_applied_version BIGINT NULL COMMENT 'Config version applied on the agent',
+ADD COLUMN agent_last_success_version BIGINT NULL COMMENT 'Last config version applied successfully on the agent',
+ADD COLUMN agent_last_error VARCHAR(1024) NULL COMMENT 'Last config error reported by the agent',
+ADD COLUMN nginx_version VARCHAR(64) NULL COMMENT 'Nginx version on the node',
+ADD COLUMN nginx_config_ok TINYINT NULL COMMENT 'Whether nginx -t currently passes',
+ADD COLUMN agent_uptime_sec BIGINT NULL COMMENT 'Agent process uptime in seconds',
+ADD COLUMN agent_state_at DATETIME NULL COMMENT 'Time the agent state was last reported';
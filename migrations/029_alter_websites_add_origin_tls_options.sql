@@ -0,0 +1,8 @@
+-- Add origin request options to websites (Host header, SNI, upstream certificate verification)
+
+ALTER TABLE websites
+ADD COLUMN origin_host_header VARCHAR(255) NOT NULL DEFAULT '' COMMENT 'Host header sent to the origin, empty means $host',
+ADD COLUMN origin_sni TINYINT(1) NOT NULL DEFAULT 0 COMMENT 'Send SNI to https origins (proxy_ssl_server_name)',
+ADD COLUMN origin_sni_name VARCHAR(255) NOT NULL DEFAULT '' COMMENT 'SNI / verified name, empty means the origin Host header',
+ADD COLUMN origin_ssl_verify TINYINT(1) NOT NULL DEFAULT 0 COMMENT 'Verify the https origin certificate',
+ADD COLUMN origin_ca_cert TEXT NULL COMMENT 'Custom CA (PEM) to verify the origin, empty means the system CA bundle';
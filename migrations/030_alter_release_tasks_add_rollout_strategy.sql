@@ -0,0 +1,4 @@
+-- Add rollout strategy to release_tasks (how release_task_nodes are split into batches)
+
+ALTER TABLE release_tasks
+ADD COLUMN rollout_strategy TEXT NULL COMMENT 'Rollout strategy JSON (canary/percentage/node_group/line_group, maxBatchSize), NULL means canary with 1 node';
@@ -0,0 +1,6 @@
+-- Record who cancelled a release and why
+
+ALTER TABLE release_tasks
+ADD COLUMN cancelled_by VARCHAR(64) NULL COMMENT 'Username of the operator who cancelled the release',
+ADD COLUMN cancel_reason VARCHAR(255) NULL COMMENT 'Cancel reason',
+ADD COLUMN cancelled_at DATETIME(3) NULL COMMENT 'Cancel time';
@@ -0,0 +1,8 @@
+-- Add failure budget and rollback link to release_tasks
+
+ALTER TABLE release_tasks
+ADD COLUMN max_failed_nodes INT NOT NULL DEFAULT 0 COMMENT 'Failed nodes allowed in the whole release (0 = use max_failed_percent only, both 0 = no failures allowed)',
+ADD COLUMN max_failed_percent INT NOT NULL DEFAULT 0 COMMENT 'Failed node percentage allowed in one batch',
+ADD COLUMN auto_rollback TINYINT(1) NOT NULL DEFAULT 0 COMMENT 'Create a rollback release when the failure budget is exceeded',
+ADD COLUMN rollback_of_id BIGINT NULL COMMENT 'Release rolled back by this rollback release',
+ADD INDEX idx_release_tasks_rollback_of_id (rollback_of_id);
@@ -0,0 +1,4 @@
+-- Add health gates checked between release batches
+
+ALTER TABLE release_tasks
+ADD COLUMN health_gates TEXT NULL COMMENT 'Health gates JSON (waitSeconds, checkAgentVersion, httpProbe), NULL means no gates';
@@ -0,0 +1,22 @@
+-- Maintenance windows for releases, attached to line groups, and scheduled releases
+
+CREATE TABLE IF NOT EXISTS maintenance_windows (
+  id BIGINT NOT NULL AUTO_INCREMENT,
+  name VARCHAR(128) NOT NULL COMMENT 'Window name',
+  schedule VARCHAR(128) NOT NULL COMMENT 'Cron expression of the window start (minute hour day month weekday)',
+  duration_minutes INT NOT NULL COMMENT 'How long the window stays open after each start',
+  timezone VARCHAR(64) NOT NULL DEFAULT '' COMMENT 'IANA timezone, empty means server local time',
+  enabled TINYINT(1) NOT NULL DEFAULT 1,
+  description VARCHAR(255) NOT NULL DEFAULT '',
+  created_at DATETIME(3) NOT NULL,
+  updated_at DATETIME(3) NOT NULL,
+  PRIMARY KEY (id),
+  UNIQUE KEY uk_maintenance_windows_name (name)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci COMMENT='Maintenance windows';
+
+ALTER TABLE line_groups
+ADD COLUMN maintenance_window_id BIGINT NULL COMMENT 'Maintenance window releases must wait for',
+ADD INDEX idx_maintenance_window_id (maintenance_window_id);
+
+ALTER TABLE release_tasks
+ADD COLUMN scheduled_at DATETIME(3) NULL COMMENT 'Do not start the release before this time';
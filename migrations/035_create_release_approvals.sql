@@ -0,0 +1,29 @@
+-- Release approval policies, approval decisions and release creator
+
+CREATE TABLE IF NOT EXISTS release_approval_policies (
+  id BIGINT NOT NULL AUTO_INCREMENT,
+  name VARCHAR(128) NOT NULL COMMENT 'Policy name',
+  kind VARCHAR(32) NOT NULL COMMENT 'website_delete, https_change or node_count',
+  threshold INT NOT NULL DEFAULT 0 COMMENT 'node_count: approval is required above this many nodes',
+  enabled TINYINT(1) NOT NULL DEFAULT 1,
+  description VARCHAR(255) NOT NULL DEFAULT '',
+  created_at DATETIME(3) NOT NULL,
+  updated_at DATETIME(3) NOT NULL,
+  PRIMARY KEY (id),
+  UNIQUE KEY uk_release_approval_policies_name (name)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci COMMENT='Release approval policies';
+
+CREATE TABLE IF NOT EXISTS release_approvals (
+  id BIGINT NOT NULL AUTO_INCREMENT,
+  release_task_id BIGINT NOT NULL,
+  decision VARCHAR(16) NOT NULL COMMENT 'approved or rejected',
+  approver VARCHAR(64) NOT NULL COMMENT 'Username of the approver',
+  comment VARCHAR(500) NOT NULL DEFAULT '',
+  created_at DATETIME(3) NOT NULL,
+  PRIMARY KEY (id),
+  KEY idx_release_task_id (release_task_id)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci COMMENT='Release approval decisions';
+
+ALTER TABLE release_tasks
+ADD COLUMN created_by VARCHAR(64) NULL COMMENT 'Username of the operator who created the release',
+ADD COLUMN approval_reason VARCHAR(500) NULL COMMENT 'Matched approval policies, NULL if no approval is needed';
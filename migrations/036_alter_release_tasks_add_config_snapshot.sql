@@ -0,0 +1,4 @@
+-- Add the website config snapshot taken when a release is created (used by the release diff)
+
+ALTER TABLE release_tasks
+ADD COLUMN config_snapshot LONGTEXT NULL COMMENT 'Website config snapshot JSON (configgen.WebsiteConfig list without certificate PEMs), NULL for releases created before snapshots';
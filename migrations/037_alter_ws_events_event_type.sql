@@ -0,0 +1,4 @@
+-- 扩展 ws_events.event_type：releases 主题的事件类型（created/started/paused/finished/node 等）不在原枚举中
+
+ALTER TABLE ws_events
+MODIFY COLUMN event_type VARCHAR(32) NOT NULL COMMENT '事件类型（websites: add/update/delete；releases: created/started/waiting_window/paused/resumed/approved/rejected/cancelled/finished/node）';
@@ -0,0 +1,9 @@
+-- Migration: 038_alter_agent_tasks_add_retry
+-- Purpose: agent_tasks 持久化重试次数和下次重试时间，status 增加 retrying（退避等待）和 dead（死信）
+-- Date: 2026-10-17
+
+ALTER TABLE `agent_tasks`
+  MODIFY COLUMN `status` ENUM('pending','running','success','failed','retrying','dead') NOT NULL DEFAULT 'pending',
+  ADD COLUMN `attempts` INT NOT NULL DEFAULT 0 AFTER `last_error`,
+  ADD COLUMN `next_retry_at` DATETIME(3) NULL AFTER `attempts`,
+  ADD INDEX `idx_agent_tasks_status_next_retry_at` (`status`, `next_retry_at`);
@@ -0,0 +1,6 @@
+-- Migration: 039_add_node_agent_live_version
+-- Purpose: nodes 记录 Agent 上报的 live 版本，用于判断配置漂移（live 版本与最近成功发布的版本不一致）
+-- Date: 2026-10-17
+
+ALTER TABLE `nodes`
+  ADD COLUMN `agent_live_version` BIGINT NULL COMMENT 'Config version the live symlink points to on the agent' AFTER `agent_last_success_version`;
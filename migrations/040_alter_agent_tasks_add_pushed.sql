@@ -0,0 +1,6 @@
+-- Migration: 040_alter_agent_tasks_add_pushed
+-- Purpose: agent_tasks 标记由控制面 mTLS 推送的任务，推送任务进入 retrying 后由 RetryScheduler 到期重新推送
+-- Date: 2026-10-17
+
+ALTER TABLE `agent_tasks`
+  ADD COLUMN `pushed` TINYINT(1) NOT NULL DEFAULT 0 AFTER `next_retry_at`;